@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/spf13/cobra"
+)
+
+// A git bundle carries rekal data where no remote reaches: 'rekal bundle
+// create' writes the user's rekal ref into one, and 'rekal sync
+// --from-bundle' fetches it as if from a remote named "bundle". The bundle
+// copies are kept like remote-tracking refs (bundle/rekal/<email>,
+// refs/rekal-remotes/bundle/<email>), so later team syncs keep importing
+// them, and they go through the same checks as fetched data: git verifies
+// the pack, import checks each branch's hash chain and signatures.
+
+// bundleRemote is the remote name bundle data is fetched under.
+const bundleRemote = "bundle"
+
+func newBundleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "Move rekal data through git bundle files",
+		Long: `Move rekal data through git bundle files, for machines that cannot reach
+the shared remote.
+
+  create <file>    write your rekal data into a bundle
+
+Import a bundle with 'rekal sync --from-bundle <file>'.`,
+	}
+	cmd.AddCommand(newBundleCreateCmd())
+	return cmd
+}
+
+func newBundleCreateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "create <file>",
+		Short: "Write your rekal data into a git bundle",
+		Long: `Write your rekal data into a git bundle file.
+
+Checkpoints not yet exported are encoded onto your rekal ref first, as
+'rekal push' does. The bundle holds that ref — in both layouts if you have
+both — and refs/notes/rekal if it exists. It is a plain git bundle: carry it
+to a machine that has the repository and run 'rekal sync --from-bundle
+<file>' there.
+
+The whole history of the ref is included, so the bundle needs nothing on
+the receiving side but the repository.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			gitRoot, err := EnsureGitRoot()
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			if err := EnsureInitDone(gitRoot); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+
+			return runLocked(cmd, gitRoot, func() error {
+				if err := doBundleCreate(gitRoot, cmd.ErrOrStderr(), args[0]); err != nil {
+					fmt.Fprintln(cmd.ErrOrStderr(), err)
+					return NewSilentError(err)
+				}
+				return nil
+			})
+		},
+	}
+}
+
+// doBundleCreate exports new checkpoints and writes the user's rekal refs
+// and the notes ref into a bundle at file.
+func doBundleCreate(gitRoot string, w io.Writer, file string) error {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return fmt.Errorf("rekal: %w", err)
+	}
+
+	update, err := exportNewFrames(gitRoot)
+	if err != nil {
+		return fmt.Errorf("rekal: export: %w", err)
+	}
+	if update != nil {
+		if _, err := commitWireFormat(gitRoot, update); err != nil {
+			return fmt.Errorf("rekal: commit to rekal branch: %w", err)
+		}
+	}
+
+	var refs []string
+	for _, layout := range []string{layoutBranches, layoutRefs} {
+		if ref := layoutRef(layout); refExists(gitRoot, ref) {
+			refs = append(refs, ref)
+		}
+	}
+	if len(refs) == 0 {
+		return errors.New("rekal: no data to bundle (run 'rekal checkpoint' first)")
+	}
+	if refExists(gitRoot, notesRef) {
+		refs = append(refs, notesRef)
+	}
+
+	args := append([]string{"-C", gitRoot, "bundle", "create", "-q", file}, refs...)
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("rekal: git bundle create: %s", strings.TrimSpace(string(out)))
+	}
+	fmt.Fprintf(w, "rekal: wrote %s (%s)\n", file, strings.Join(refs, ", "))
+	return nil
+}
+
+// fetchBundle verifies the bundle at file and fetches the rekal data in it
+// under bundleRemote, merging its notes into the local notes ref. Returns
+// the fetched copies of the current user's own data. A ref the bundle would
+// move backwards or rewrite is not updated; git's message is printed on w.
+func fetchBundle(gitRoot string, w io.Writer, file string) ([]string, error) {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	if out, err := exec.Command("git", "-C", gitRoot, "bundle", "verify", "-q", file).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, strings.TrimSpace(string(out)))
+	}
+	out, err := exec.Command("git", "-C", gitRoot, "bundle", "list-heads", file).Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: list heads: %w", file, err)
+	}
+	var hasData, hasNotes bool
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		_, ref, _ := strings.Cut(line, " ")
+		switch {
+		case strings.HasPrefix(ref, branchRefPrefix), strings.HasPrefix(ref, customRefPrefix):
+			hasData = true
+		case ref == notesRef:
+			hasNotes = true
+		}
+	}
+	if !hasData {
+		return nil, fmt.Errorf("%s: no rekal data in the bundle", file)
+	}
+
+	args := append([]string{"-C", gitRoot, "fetch", "-q", file}, rekalFetchRefspecs(bundleRemote)...)
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		fmt.Fprintf(w, "rekal: warning: fetch from bundle: %s\n", strings.TrimSpace(string(out)))
+	}
+	if hasNotes {
+		cmd := exec.Command("git", "-C", gitRoot, "fetch", "-q", file, "+"+notesRef+":"+remoteNotesPrefix+bundleRemote)
+		out, err := cmd.CombinedOutput()
+		if err == nil {
+			err = mergeRekalNotes(gitRoot, bundleRemote)
+		} else {
+			err = errors.New(strings.TrimSpace(string(out)))
+		}
+		if err != nil {
+			fmt.Fprintf(w, "rekal: warning: notes from bundle: %v\n", err)
+		}
+	}
+
+	var own []string
+	for _, layout := range []string{layoutBranches, layoutRefs} {
+		if ref := layoutRemoteRef(layout, bundleRemote); refExists(gitRoot, ref) {
+			own = append(own, ref)
+		}
+	}
+	return own, nil
+}
+
+// runSyncBundle imports the rekal data in the bundle at file without
+// touching the network: the user's own sessions into data.db, as
+// 'rekal sync --self' does, and the team's into the index, as team sync
+// does.
+func runSyncBundle(cmd *cobra.Command, gitRoot, file string, remotes []string, full bool, scope *syncScope) error {
+	w := cmd.ErrOrStderr()
+
+	// Step 1: Checkpoint (non-fatal). There is nowhere to push.
+	if err := doCheckpoint(gitRoot, w); err != nil {
+		fmt.Fprintf(w, "rekal: warning: checkpoint failed: %v\n", err)
+	}
+
+	// Step 2: Verify and fetch the bundle.
+	fmt.Fprintf(w, "reading bundle %s...\n", file)
+	own, err := fetchBundle(gitRoot, w, file)
+	if err != nil {
+		return err
+	}
+
+	// Step 3: Import your own data into data.db. If that adds sessions, the
+	// index update below rebuilds, since they are not indexed yet.
+	if len(own) > 0 {
+		dataDB, err := db.OpenData(gitRoot)
+		if err != nil {
+			return fmt.Errorf("open data db: %w", err)
+		}
+		if err := db.MigrateDataSchema(dataDB, dataMigrateContext(gitRoot)); err != nil {
+			dataDB.Close()
+			return fmt.Errorf("migrate data db: %w", err)
+		}
+		for _, ref := range own {
+			n, err := importBranch(gitRoot, dataDB, ref, w)
+			if err != nil {
+				dataDB.Close()
+				return fmt.Errorf("import from %s: %w", ref, err)
+			}
+			fmt.Fprintf(w, "rekal: imported %d session(s) from %s\n", n, ref)
+		}
+		dataDB.Close()
+	}
+
+	// Step 4: Import the team's data into the index.
+	return updateTeamIndex(gitRoot, w, remotes, full, scope)
+}
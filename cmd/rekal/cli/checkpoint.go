@@ -20,7 +20,9 @@ import (
 )
 
 func newCheckpointCmd() *cobra.Command {
-	return &cobra.Command{
+	var hook, deferred bool
+
+	cmd := &cobra.Command{
 		Use:   "checkpoint",
 		Short: "Capture the current session after a commit",
 		Long: `Snapshot the active AI session into the local data DB.
@@ -30,8 +32,14 @@ from the agent's session directory, deduplicates by content hash, and inserts
 into .rekal/data.db. Each checkpoint is linked to the current HEAD commit and
 records which files were changed.
 
+With 'git config rekal.notes true', each checkpoint also adds a git note under
+refs/notes/rekal to HEAD naming the checkpoint and its sessions, so
+'git log --notes=rekal' shows them without rekal installed.
+
 Normally runs automatically via the post-commit hook installed by 'rekal init'.
-Run manually to capture a session without committing.`,
+Run manually to capture a session without committing. If another rekal process
+holds .rekal when the hook fires, the checkpoint is deferred to the background
+so the commit is never held up.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			cmd.SilenceUsage = true
 
@@ -45,9 +53,17 @@ Run manually to capture a session without committing.`,
 				return NewSilentError(err)
 			}
 
-			return runCheckpoint(cmd, gitRoot)
+			return runHookLocked(cmd, gitRoot, hook, deferred, func() error {
+				return runCheckpoint(cmd, gitRoot)
+			})
 		},
 	}
+
+	cmd.Flags().BoolVar(&hook, "hook", false, "Invoked from a git hook: defer to the background if .rekal is locked")
+	cmd.Flags().BoolVar(&deferred, "deferred", false, "Background re-run of a deferred hook")
+	_ = cmd.Flags().MarkHidden("hook")
+	_ = cmd.Flags().MarkHidden("deferred")
+	return cmd
 }
 
 func runCheckpoint(cmd *cobra.Command, gitRoot string) error {
@@ -65,7 +81,7 @@ func doCheckpoint(gitRoot string, w io.Writer) error {
 	defer dataDB.Close()
 
 	// Run forward-only migrations for existing DBs.
-	if err := db.MigrateDataSchema(dataDB); err != nil {
+	if err := db.MigrateDataSchema(dataDB, dataMigrateContext(gitRoot)); err != nil {
 		return fmt.Errorf("migrate data schema: %w", err)
 	}
 
@@ -80,8 +96,15 @@ func doCheckpoint(gitRoot string, w io.Writer) error {
 		return ulid.MustNew(ulid.Timestamp(time.Now()), entropy).String()
 	}
 
-	var sessionIDs []string
-	var inserted int
+	// Literal values from the repo's sensitive files, redacted wherever they
+	// appear. Held in memory for this run only.
+	knownSecrets := learnRepoSecrets(gitRoot)
+
+	// Phase 1: discover, dedup, parse and scrub — read-only against data.db.
+	var captured []capturedSession
+	var stateUpdates []checkpointStateUpdate
+	seenHashes := make(map[string]bool)
+	seenIDs := make(map[string]bool)
 	// Collect unique relative file paths from file-modifying tool_calls across all sessions.
 	toolCallPaths := make(map[string]struct{})
 
@@ -100,8 +123,8 @@ func doCheckpoint(gitRoot string, w io.Writer) error {
 			}
 
 			// For file-based refs, check size+hash cache.
-			var data []byte
 			var hash string
+			var size int64
 			if ref.Path != "" {
 				info, statErr := os.Stat(ref.Path)
 				if statErr != nil {
@@ -112,14 +135,14 @@ func doCheckpoint(gitRoot string, w io.Writer) error {
 				if err != nil || len(fileData) == 0 {
 					continue
 				}
-				data = fileData
-				hash = sha256Hex(data)
+				hash = sha256Hex(fileData)
+				size = info.Size()
 
 				cachedSize, cachedHash, found, csErr := db.GetCheckpointState(dataDB, cacheKey)
 				if csErr != nil {
 					return fmt.Errorf("check checkpoint state: %w", csErr)
 				}
-				if found && cachedSize == info.Size() && cachedHash == hash {
+				if found && cachedSize == size && cachedHash == hash {
 					continue
 				}
 			} else {
@@ -134,20 +157,14 @@ func doCheckpoint(gitRoot string, w io.Writer) error {
 					continue
 				}
 			}
+			state := checkpointStateUpdate{key: cacheKey, size: size, hash: hash}
 
 			exists, err := db.SessionExistsByHash(dataDB, hash)
 			if err != nil {
 				return fmt.Errorf("dedup check: %w", err)
 			}
-			if exists {
-				if ref.Path != "" {
-					info, _ := os.Stat(ref.Path)
-					if info != nil {
-						_ = db.UpsertCheckpointState(dataDB, cacheKey, info.Size(), hash)
-					}
-				} else {
-					_ = db.UpsertCheckpointState(dataDB, cacheKey, 0, hash)
-				}
+			if exists || seenHashes[hash] {
+				stateUpdates = append(stateUpdates, state)
 				continue
 			}
 
@@ -156,41 +173,25 @@ func doCheckpoint(gitRoot string, w io.Writer) error {
 				continue
 			}
 
-			// Redact secrets and anonymize paths before any DB insertion.
-			scrub.Scrub(payload)
-
 			if len(payload.Turns) == 0 && len(payload.ToolCalls) == 0 {
 				continue
 			}
 
-			sessionID := newID()
-			capturedAt := time.Now().UTC()
-
-			// Insert session into DuckDB.
-			if err := db.InsertSession(
-				dataDB, sessionID, "", hash,
-				payload.ActorType, payload.AgentID, email, payload.Branch, capturedAt.Format(time.RFC3339),
-				payload.Source,
-			); err != nil {
-				return fmt.Errorf("insert session: %w", err)
-			}
+			// The same transcript captured on another machine and imported
+			// with 'rekal sync --self' has the same content ID. It is taken
+			// before learned secrets, which differ per machine, are redacted.
+			id := scrub.ContentID(payload, gitRoot)
 
-			// Insert turns into DuckDB.
-			for i, t := range payload.Turns {
-				ts := ""
-				if !t.Timestamp.IsZero() {
-					ts = t.Timestamp.UTC().Format(time.RFC3339)
-				}
-				if err := db.InsertTurn(dataDB, newID(), sessionID, i, t.Role, t.Content, ts); err != nil {
-					return fmt.Errorf("insert turn: %w", err)
-				}
+			// Make paths repo-relative, redact secrets and anonymize paths
+			// before any DB insertion.
+			scrub.Scrub(payload, gitRoot, knownSecrets)
+			existing, err := db.ResolveSessionID(dataDB, id)
+			if err != nil {
+				return fmt.Errorf("dedup check: %w", err)
 			}
-
-			// Insert tool calls into DuckDB.
-			for i, tc := range payload.ToolCalls {
-				if err := db.InsertToolCall(dataDB, newID(), sessionID, i, tc.Tool, tc.Path, tc.CmdPrefix); err != nil {
-					return fmt.Errorf("insert tool_call: %w", err)
-				}
+			if existing != "" || seenIDs[id] {
+				stateUpdates = append(stateUpdates, state)
+				continue
 			}
 
 			// Collect file-modifying tool_call paths for files_touched supplementation.
@@ -203,43 +204,131 @@ func doCheckpoint(gitRoot string, w io.Writer) error {
 				default:
 					continue
 				}
-				rel := strings.TrimPrefix(tc.Path, gitRoot+"/")
-				if rel == tc.Path {
+				// Paths are repo-relative after scrubbing; skip out-of-repo files.
+				if strings.HasPrefix(tc.Path, scrub.OutOfRepoPrefix) || tc.Path == "." {
 					continue
 				}
-				toolCallPaths[rel] = struct{}{}
+				toolCallPaths[tc.Path] = struct{}{}
 			}
 
-			// Update checkpoint state cache.
-			if ref.Path != "" {
-				info, _ := os.Stat(ref.Path)
-				if info != nil {
-					_ = db.UpsertCheckpointState(dataDB, cacheKey, info.Size(), hash)
-				}
-			} else {
-				_ = db.UpsertCheckpointState(dataDB, cacheKey, 0, hash)
-			}
+			seenHashes[hash] = true
+			seenIDs[id] = true
+			stateUpdates = append(stateUpdates, state)
+			captured = append(captured, capturedSession{id: id, hash: hash, payload: payload})
+		}
+	}
+
+	if len(captured) == 0 && len(stateUpdates) == 0 {
+		return nil
+	}
+
+	// Phase 2: write everything — sessions, turns, tool calls, checkpoint,
+	// junction rows and the checkpoint_state cache — in one transaction.
+	// An interrupted run leaves data.db exactly as it was.
+	batch, err := db.BeginBatch(dataDB)
+	if err != nil {
+		return fmt.Errorf("begin checkpoint: %w", err)
+	}
+	defer batch.Rollback() //nolint:errcheck
+
+	var sessionIDs []string
+	for _, cs := range captured {
+		if err := writeCapturedSession(batch, cs, email, newID); err != nil {
+			return err
+		}
+		sessionIDs = append(sessionIDs, cs.id)
+	}
+
+	for _, st := range stateUpdates {
+		if err := db.UpsertCheckpointState(batch, st.key, st.size, st.hash); err != nil {
+			return fmt.Errorf("update checkpoint state: %w", err)
+		}
+	}
 
-			sessionIDs = append(sessionIDs, sessionID)
-			inserted++
+	var checkpointID string
+	if len(captured) > 0 {
+		checkpointID = newID()
+		if err := writeCheckpointRows(batch, gitRoot, checkpointID, email, sessionIDs, toolCallPaths, newID); err != nil {
+			return err
 		}
 	}
 
-	if inserted == 0 {
+	if err := batch.Commit(); err != nil {
+		return fmt.Errorf("commit checkpoint: %w", err)
+	}
+
+	if len(captured) == 0 {
 		return nil
 	}
 
+	if notesEnabled() && refExists(gitRoot, "HEAD") {
+		note := checkpointNote(checkpointID, sessionIDs, noteSummary(gitRoot, captured))
+		if err := addCheckpointNote(gitRoot, "HEAD", note); err != nil {
+			fmt.Fprintf(w, "rekal: warning: %v\n", err)
+		}
+	}
+
+	// Incrementally update the index for newly captured sessions.
+	if err := updateIndexIncremental(gitRoot, sessionIDs, checkpointID, w); err != nil {
+		// Non-fatal — index can be rebuilt later with 'rekal index'.
+		fmt.Fprintf(w, "rekal: warning: incremental index update failed: %v\n", err)
+	}
+
+	fmt.Fprintf(w, "rekal: %d session(s) captured\n", len(captured))
+	return nil
+}
+
+// capturedSession is a parsed, scrubbed session waiting to be written.
+type capturedSession struct {
+	id      string
+	hash    string
+	payload *session.SessionPayload
+}
+
+// checkpointStateUpdate is a checkpoint_state cache entry to record once the
+// batch commits.
+type checkpointStateUpdate struct {
+	key  string
+	size int64
+	hash string
+}
+
+// writeCapturedSession adds a session row plus its turns and tool calls to
+// the batch.
+func writeCapturedSession(batch *db.Batch, cs capturedSession, email string, newID func() string) error {
+	p := cs.payload
+	capturedAt := time.Now().UTC()
+	if err := db.InsertSession(
+		batch, cs.id, "", cs.hash,
+		p.ActorType, p.AgentID, email, p.Branch, capturedAt.Format(time.RFC3339),
+		p.Source,
+	); err != nil {
+		return fmt.Errorf("insert session: %w", err)
+	}
+	for i, t := range p.Turns {
+		if err := batch.AppendTurn(newID(), cs.id, i, t.Role, t.Content, t.Timestamp); err != nil {
+			return err
+		}
+	}
+	for i, tc := range p.ToolCalls {
+		if err := batch.AppendToolCall(newID(), cs.id, i, tc.Tool, tc.Path, tc.CmdPrefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCheckpointRows adds the checkpoint, its files_touched and the
+// checkpoint_sessions junction rows to the batch.
+func writeCheckpointRows(batch *db.Batch, gitRoot, checkpointID, email string, sessionIDs []string, toolCallPaths map[string]struct{}, newID func() string) error {
 	// Get git state for checkpoint.
 	gitSHA := gitHeadSHA(gitRoot)
 	gitBranch := gitCurrentBranch(gitRoot)
 	filesTouched := gitFilesChanged(gitRoot)
 
-	// Generate checkpoint ULID.
-	checkpointID := newID()
-
-	// Insert checkpoint into DuckDB (exported = FALSE by default).
+	// Insert checkpoint (exported = FALSE by default).
 	now := time.Now().UTC()
-	if err := db.InsertCheckpoint(dataDB, checkpointID, gitSHA, gitBranch, email, now.Format(time.RFC3339), "human", ""); err != nil {
+	if err := db.InsertCheckpoint(batch, checkpointID, gitSHA, gitBranch, email, now.Format(time.RFC3339), "human", ""); err != nil {
 		return fmt.Errorf("insert checkpoint: %w", err)
 	}
 
@@ -251,7 +340,7 @@ func doCheckpoint(gitRoot string, w io.Writer) error {
 			continue
 		}
 		gitTouchedSet[parts[1]] = struct{}{}
-		if err := db.InsertFileTouched(dataDB, newID(), checkpointID, parts[1], parts[0]); err != nil {
+		if err := db.InsertFileTouched(batch, newID(), checkpointID, parts[1], parts[0]); err != nil {
 			return fmt.Errorf("insert file_touched: %w", err)
 		}
 	}
@@ -261,25 +350,17 @@ func doCheckpoint(gitRoot string, w io.Writer) error {
 		if _, exists := gitTouchedSet[p]; exists {
 			continue
 		}
-		if err := db.InsertFileTouched(dataDB, newID(), checkpointID, p, "T"); err != nil {
+		if err := db.InsertFileTouched(batch, newID(), checkpointID, p, "T"); err != nil {
 			return fmt.Errorf("insert file_touched (tool_call): %w", err)
 		}
 	}
 
 	// Insert checkpoint_sessions junction rows.
 	for _, sid := range sessionIDs {
-		if err := db.InsertCheckpointSession(dataDB, checkpointID, sid); err != nil {
+		if err := db.InsertCheckpointSession(batch, checkpointID, sid); err != nil {
 			return fmt.Errorf("insert checkpoint_session: %w", err)
 		}
 	}
-
-	// Incrementally update the index for newly captured sessions.
-	if err := updateIndexIncremental(gitRoot, sessionIDs, checkpointID, w); err != nil {
-		// Non-fatal — index can be rebuilt later with 'rekal index'.
-		fmt.Fprintf(w, "rekal: warning: incremental index update failed: %v\n", err)
-	}
-
-	fmt.Fprintf(w, "rekal: %d session(s) captured\n", inserted)
 	return nil
 }
 
@@ -350,6 +431,9 @@ func updateIndexIncremental(gitRoot string, sessionIDs []string, checkpointID st
 	if err := db.PopulateIndexIncremental(indexDB, gitRoot, sessionIDs, checkpointID); err != nil {
 		return fmt.Errorf("populate index: %w", err)
 	}
+	if err := applyEmailAliases(gitRoot, indexDB); err != nil {
+		return fmt.Errorf("apply mailmap: %w", err)
+	}
 
 	// Nomic embeddings for new sessions (non-fatal).
 	sessionContent, err := db.QuerySessionContentByIDs(indexDB, sessionIDs)
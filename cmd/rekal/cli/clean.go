@@ -3,6 +3,7 @@ package cli
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 
@@ -20,6 +21,11 @@ Removes:
   .rekal/           Data DB, index DB, and all local state
   post-commit hook   Only if it contains the rekal marker
   pre-push hook      Only if it contains the rekal marker
+  refs/rekal-remotes/  Fetched copies of teammates' refs/rekal/* data
+  refs/notes/rekal-remotes/  Fetched copies of the remote notes refs
+
+Your own data (rekal/<email> or refs/rekal/<email>) and refs/notes/rekal are
+kept, so 'rekal init' imports the data again.
 
 Run 'rekal init' to reinitialize after cleaning.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
@@ -42,7 +48,8 @@ Run 'rekal init' to reinitialize after cleaning.`,
 	}
 }
 
-// runClean removes .rekal/ and Rekal hooks. Idempotent.
+// runClean removes .rekal/, Rekal hooks and the fetched copies of custom
+// refs. Idempotent.
 func runClean(gitRoot string) error {
 	rekalDir := RekalDir(gitRoot)
 	if err := os.RemoveAll(rekalDir); err != nil {
@@ -50,6 +57,14 @@ func runClean(gitRoot string) error {
 	}
 	removeHook(filepath.Join(gitRoot, ".git", "hooks", "post-commit"))
 	removeHook(filepath.Join(gitRoot, ".git", "hooks", "pre-push"))
+
+	// Remote-tracking branches belong to git, which prunes them with the
+	// remote; refs/rekal-remotes/* only rekal knows about.
+	for _, ref := range listRekalRefs(gitRoot, customRemoteRefPrefix, remoteNotesPrefix) {
+		if err := exec.Command("git", "-C", gitRoot, "update-ref", "-d", ref).Run(); err != nil {
+			return fmt.Errorf("delete %s: %w", ref, err)
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,258 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+	"github.com/spf13/cobra"
+)
+
+// minTrainFrames is the fewest frames 'rekal codec train' trains on. Fewer
+// samples give a dictionary that only fits them.
+const minTrainFrames = 8
+
+// trainHoldout is how often 'rekal codec train' holds a frame out of
+// training: the first frame of each type and every trainHoldout-th after it
+// are only used to compare the dictionaries, so the comparison is on frames
+// the trained one has not seen.
+const trainHoldout = 4
+
+func newCodecCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "codec",
+		Short: "Tune the compression of your rekal branch",
+		Long: `Tune the compression of your rekal branch.
+
+Frames are zstd-compressed with a dictionary. The built-in preset is trained
+on generic programming conversations; a dictionary trained on this
+repository's own sessions, paths and commands compresses its frames better.
+
+  train    train a dictionary on your branch and use it for new frames
+
+See docs/git-transportation.md for how dictionaries are stored.`,
+	}
+	cmd.AddCommand(newCodecTrainCmd())
+	return cmd
+}
+
+func newCodecTrainCmd() *cobra.Command {
+	var size int
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "train",
+		Short: "Train a zstd dictionary on your rekal branch",
+		Long: `Train a zstd dictionary on the frames of your rekal branch.
+
+The dictionary is built from the decompressed payloads of the frames on
+rekal/<email>, holding out every 4th frame of each type. train then
+compresses the held-out payloads with the current dictionary and the
+trained one and prints the sizes per frame type, so the comparison is on
+frames the trained dictionary has not seen.
+
+If the trained dictionary is smaller, it is committed to the branch under
+dicts/ together with a meta frame that names it. Frames exported from then
+on are compressed with it; frames already on the branch are never rewritten
+and keep their dictionary. The next 'rekal push' publishes it, and readers
+pick the dictionary of each frame by the ID in its zstd header.
+
+Use --dry-run to only print the comparison.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+
+			gitRoot, err := EnsureGitRoot()
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			if err := EnsureInitDone(gitRoot); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+
+			return runLocked(cmd, gitRoot, func() error {
+				if err := doCodecTrain(gitRoot, cmd.OutOrStdout(), size, dryRun); err != nil {
+					fmt.Fprintln(cmd.ErrOrStderr(), err)
+					return NewSilentError(err)
+				}
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().IntVar(&size, "size", 16<<10, "Dictionary content size in bytes")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the comparison without storing the dictionary")
+	return cmd
+}
+
+// trainSizes accumulates payload sizes under the current and the trained
+// dictionary.
+type trainSizes struct {
+	Frames  int
+	Raw     int
+	Current int
+	Trained int
+}
+
+func (s *trainSizes) change() string {
+	if s.Current == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f%%", 100*float64(s.Trained-s.Current)/float64(s.Current))
+}
+
+// doCodecTrain trains a dictionary on the user's rekal branch, prints the
+// before/after comparison and, unless dryRun, stores it if it is smaller.
+func doCodecTrain(gitRoot string, w io.Writer, size int, dryRun bool) error {
+	// A trained dictionary is built from frame content and stored in the
+	// clear under dicts/, so it would leak what encryption hides.
+	if rcpts, err := encryptionRecipients(gitRoot); err != nil {
+		return fmt.Errorf("rekal: %w", err)
+	} else if rcpts != nil {
+		return fmt.Errorf("rekal: frames are encrypted for %s; a trained dictionary would publish their content", recipientsFile)
+	}
+
+	branch := rekalBranchName()
+	body, err := readBranchBody(gitRoot, branch)
+	if err != nil {
+		return fmt.Errorf("rekal: %w", err)
+	}
+	if len(body) <= codec.BodyHeaderSize {
+		fmt.Fprintf(w, "rekal: no frames on %s (run 'rekal push' first)\n", branch)
+		return nil
+	}
+
+	dicts, err := readBranchDicts(gitRoot, branch)
+	if err != nil {
+		return fmt.Errorf("rekal: %w", err)
+	}
+	dec, err := newDictDecoder(dicts)
+	if err != nil {
+		return err
+	}
+	defer dec.Close()
+	scan, err := codec.ScanBody(body)
+	if err != nil {
+		return fmt.Errorf("rekal: %s: %w", branch, err)
+	}
+
+	// Train on most frames; compare on the held-out rest.
+	var samples, held [][]byte
+	var heldTypes []codec.FrameType
+	seen := make(map[codec.FrameType]int)
+	for _, fs := range scan.Frames {
+		if fs.Type == codec.FrameTombstone {
+			continue
+		}
+		payload, err := dec.Decompress(codec.ExtractFramePayload(body, fs))
+		if err != nil {
+			continue // reported by 'rekal wire check'
+		}
+		seen[fs.Type]++
+		if seen[fs.Type]%trainHoldout == 1 {
+			held = append(held, payload)
+			heldTypes = append(heldTypes, fs.Type)
+		} else {
+			samples = append(samples, payload)
+		}
+	}
+	if n := len(samples) + len(held); n < minTrainFrames {
+		fmt.Fprintf(w, "rekal: %d frame(s) on %s, need at least %d to train a dictionary\n", n, branch, minTrainFrames)
+		return nil
+	}
+
+	zdict, err := codec.TrainDict(samples, size)
+	if err != nil {
+		return fmt.Errorf("rekal: %w", err)
+	}
+	trained, err := codec.NewEncoderWithDict(zdict)
+	if err != nil {
+		return fmt.Errorf("rekal: %w", err)
+	}
+	defer trained.Close()
+	current, err := newBranchEncoder(gitRoot, branch, body)
+	if err != nil {
+		return fmt.Errorf("rekal: %w", err)
+	}
+	defer current.Close()
+
+	byType := make(map[codec.FrameType]*trainSizes)
+	var total trainSizes
+	for i, p := range held {
+		s := byType[heldTypes[i]]
+		if s == nil {
+			s = &trainSizes{}
+			byType[heldTypes[i]] = s
+		}
+		cur, tr := len(current.Compress(p)), len(trained.Compress(p))
+		for _, s := range []*trainSizes{s, &total} {
+			s.Frames++
+			s.Raw += len(p)
+			s.Current += cur
+			s.Trained += tr
+		}
+	}
+
+	fmt.Fprintf(w, "%s: trained on %d frames, compared on %d held-out frames (%d payload bytes)\n",
+		branch, len(samples), total.Frames, total.Raw)
+	fmt.Fprintf(w, "  current dictionary  %s\n", codecDictName(current.DictID()))
+	fmt.Fprintf(w, "  trained dictionary  %08x, %d bytes\n", trained.DictID(), len(zdict))
+	fmt.Fprintf(w, "\n  %-12s %7s %13s %9s %9s %8s\n", "TYPE", "FRAMES", "UNCOMPRESSED", "CURRENT", "TRAINED", "CHANGE")
+	printRow := func(label string, s *trainSizes) {
+		fmt.Fprintf(w, "  %-12s %7d %13d %9d %9d %8s\n", label, s.Frames, s.Raw, s.Current, s.Trained, s.change())
+	}
+	for _, t := range []codec.FrameType{codec.FrameSession, codec.FrameCheckpoint, codec.FrameMeta} {
+		if s := byType[t]; s != nil {
+			printRow(frameTypeName(t), s)
+		}
+	}
+	printRow("total", &total)
+	fmt.Fprintln(w)
+
+	switch {
+	case trained.DictID() == current.DictID():
+		fmt.Fprintln(w, "rekal: the trained dictionary is already in use")
+		return nil
+	case total.Trained >= total.Current:
+		fmt.Fprintln(w, "rekal: the trained dictionary is not smaller — keeping the current one")
+		return nil
+	case dryRun:
+		fmt.Fprintln(w, "rekal: dry run — dictionary not stored")
+		return nil
+	}
+
+	dict := codec.NewDict()
+	dictData, err := readBranchDictData(gitRoot, branch)
+	if err != nil {
+		return fmt.Errorf("rekal: %w", err)
+	}
+	if len(dictData) > 0 {
+		if dict, err = codec.LoadDict(dictData); err != nil {
+			return fmt.Errorf("rekal: load dict: %w", err)
+		}
+	}
+	u := &wireUpdate{
+		Body:      appendMetaFrame(trained, body, dict, 0),
+		Committed: len(body),
+		Dict:      dict.Encode(),
+		Message:   "rekal: train codec dictionary",
+	}
+	if _, ok := dicts[trained.DictID()]; !ok {
+		u.NewDicts = map[uint32][]byte{trained.DictID(): zdict}
+	}
+	if _, err := commitWireFormat(gitRoot, u); err != nil {
+		return fmt.Errorf("rekal: commit to rekal branch: %w", err)
+	}
+	fmt.Fprintf(w, "rekal: stored dictionary %08x on %s — new frames use it; 'rekal push' publishes it\n", trained.DictID(), branch)
+	return nil
+}
+
+// codecDictName describes a dictionary ID for output.
+func codecDictName(id uint32) string {
+	if id == codec.PresetDictID() {
+		return fmt.Sprintf("%08x (built-in preset)", id)
+	}
+	return fmt.Sprintf("%08x", id)
+}
@@ -1,18 +1,39 @@
 package codec
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/crc32"
 )
 
 const (
-	bodyMagic    = "RKLBODY"
-	bodyVersion  = 0x01
-	bodyHdrSize  = 9 // 7 magic + 1 version + 1 flags
-	frameEnvSize = 6 // 1 type + 3 compressed_len + 2 uncompressed_len
+	bodyMagic   = "RKLBODY"
+	bodyVersion = 0x02 // v2: every envelope carries a CRC-32C
+	// bodyVersionV1 bodies have no per-frame checksum. They are still read,
+	// and frames appended to them stay in v1 form so existing bytes never
+	// change.
+	bodyVersionV1 = 0x01
+	frameEnvSize  = 6 // 1 type + 3 compressed_len + 2 uncompressed_len
+	frameCRCSize  = 4 // v2: CRC-32C (Castagnoli) of envelope + payload, u32 LE
 )
 
+// BodyHeaderSize is the size of the body header: 7 magic + 1 version +
+// 1 flags. A body no longer than this holds no frames.
+const BodyHeaderSize = 9
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// zstdMagic starts every zstd frame.
+var zstdMagic = []byte{0x28, 0xB5, 0x2F, 0xFD}
+
+// payloadMagics start the payload of every frame rekal writes: compressed,
+// or compressed and then sealed. They recognise v1 frames, which have no
+// checksum, and after corruption ScanBody only tries to parse a frame where
+// one of them follows the envelope.
+var payloadMagics = [][]byte{zstdMagic, sealMagic}
+
 // FrameType identifies the kind of frame.
 type FrameType byte
 
@@ -29,21 +50,48 @@ type FrameSlice struct {
 	Offset          int // byte offset from start of body (includes envelope)
 	CompressedLen   int
 	UncompressedLen int
-	PayloadOffset   int // byte offset of compressed payload (Offset + frameEnvSize)
+	PayloadOffset   int // byte offset of compressed payload (after envelope and checksum)
+}
+
+// SkippedRange is a run of bytes that could not be parsed as frames —
+// a torn append, bit rot, or garbage — and was skipped by ScanBody.
+type SkippedRange struct {
+	Offset int
+	Length int
 }
 
-// NewBody returns a 9-byte rekal.body file header.
+// ScanResult is the outcome of ScanBody.
+type ScanResult struct {
+	Version byte
+	Frames  []FrameSlice
+	Skipped []SkippedRange
+}
+
+// NewBody returns a 9-byte rekal.body file header for the current version.
 func NewBody() []byte {
-	buf := make([]byte, bodyHdrSize)
+	buf := make([]byte, BodyHeaderSize)
 	copy(buf[0:7], bodyMagic)
 	buf[7] = bodyVersion
 	buf[8] = 0x01 // flags: bit 0 = preset dict available
 	return buf
 }
 
-// AppendFrame appends an encoded frame (envelope + compressed payload) to the body.
+// AppendFrame appends an encoded frame (envelope + compressed payload, as
+// produced by Encoder) to the body. For v2 bodies the frame checksum is
+// inserted after the envelope; v1 bodies get the frame unchanged.
 func AppendFrame(body, frame []byte) []byte {
-	return append(body, frame...)
+	if len(body) <= 7 || body[7] < bodyVersion || len(frame) < frameEnvSize {
+		return append(body, frame...)
+	}
+	body = append(body, frame[:frameEnvSize]...)
+	body = binary.LittleEndian.AppendUint32(body, frameCRC(frame[:frameEnvSize], frame[frameEnvSize:]))
+	return append(body, frame[frameEnvSize:]...)
+}
+
+// frameCRC returns the CRC-32C of a frame's envelope and payload.
+func frameCRC(env, payload []byte) uint32 {
+	crc := crc32.Update(0, crcTable, env)
+	return crc32.Update(crc, crcTable, payload)
 }
 
 // WriteEnvelope writes a 6-byte frame envelope.
@@ -59,9 +107,27 @@ func WriteEnvelope(frameType FrameType, compressedLen, uncompressedLen int) []by
 	return env
 }
 
-// ScanFrames scans the body and returns metadata for each frame without decompressing.
+// ScanFrames scans the body and returns metadata for each frame without
+// decompressing. Corrupt regions are skipped; use ScanBody to learn where.
+// Fails only if the header is unreadable.
 func ScanFrames(body []byte) ([]FrameSlice, error) {
-	if len(body) < bodyHdrSize {
+	r, err := ScanBody(body)
+	if err != nil {
+		return nil, err
+	}
+	return r.Frames, nil
+}
+
+// ScanBody scans the body, skipping over anything that is not a valid frame
+// and resynchronising on the next one. A v2 frame is valid when its type is
+// known, it fits in the body and its checksum matches; a v1 frame, which has
+// no checksum, when its type is known, it fits and its payload starts with
+// the zstd or seal magic: encryption can be turned on for a branch whose
+// body is still v1. To resynchronise, ScanBody searches for the next payload
+// magic rather than parsing at every offset, so a large corrupt region is
+// skipped in linear time. Fails only if the header is unreadable.
+func ScanBody(body []byte) (*ScanResult, error) {
+	if len(body) < BodyHeaderSize {
 		return nil, errors.New("body: data too short for header")
 	}
 
@@ -69,33 +135,134 @@ func ScanFrames(body []byte) ([]FrameSlice, error) {
 	if magic != bodyMagic {
 		return nil, fmt.Errorf("body: bad magic %q, want %q", magic, bodyMagic)
 	}
+	version := body[7]
+	if version != bodyVersionV1 && version != bodyVersion {
+		return nil, fmt.Errorf("body: unsupported version %d", version)
+	}
+
+	r := &ScanResult{Version: version}
+	pos := BodyHeaderSize
+	badStart := -1
+	var rs *resyncer
 
-	var frames []FrameSlice
-	pos := bodyHdrSize
+	for pos < len(body) {
+		fs, ok := parseFrameAt(body, pos, version)
+		if !ok {
+			if badStart < 0 {
+				badStart = pos
+			}
+			if rs == nil {
+				rs = newResyncer(body, version)
+			}
+			pos = rs.next(pos)
+			continue
+		}
+		if badStart >= 0 {
+			r.Skipped = append(r.Skipped, SkippedRange{Offset: badStart, Length: pos - badStart})
+			badStart = -1
+		}
+		r.Frames = append(r.Frames, fs)
+		pos = fs.PayloadOffset + fs.CompressedLen
+	}
+	if badStart >= 0 {
+		r.Skipped = append(r.Skipped, SkippedRange{Offset: badStart, Length: len(body) - badStart})
+	}
 
-	for pos+frameEnvSize <= len(body) {
-		ft := FrameType(body[pos])
-		compLen := int(body[pos+1]) | int(body[pos+2])<<8 | int(body[pos+3])<<16
-		uncompLen := int(binary.LittleEndian.Uint16(body[pos+4 : pos+6]))
+	return r, nil
+}
 
-		payloadStart := pos + frameEnvSize
-		if payloadStart+compLen > len(body) {
-			return nil, fmt.Errorf("body: frame at offset %d truncated (need %d bytes, have %d)",
-				pos, compLen, len(body)-payloadStart)
+// hasPayloadMagic reports whether payload starts with one of payloadMagics.
+func hasPayloadMagic(payload []byte) bool {
+	for _, magic := range payloadMagics {
+		if bytes.HasPrefix(payload, magic) {
+			return true
 		}
+	}
+	return false
+}
+
+// resyncer finds where a frame may start after a corrupt one: envSize bytes
+// before an occurrence of a payload magic.
+type resyncer struct {
+	body    []byte
+	envSize int
+	found   []int // per payload magic, offset of the last occurrence found, or -1
+}
+
+func newResyncer(body []byte, version byte) *resyncer {
+	envSize := frameEnvSize
+	if version >= bodyVersion {
+		envSize += frameCRCSize
+	}
+	rs := &resyncer{body: body, envSize: envSize, found: make([]int, len(payloadMagics))}
+	for i := range rs.found {
+		rs.found[i] = envSize - 1 // search starts after it
+	}
+	return rs
+}
+
+// next returns the first candidate frame offset after pos, or len(body) if
+// there is none. Each magic is searched forward only, so all calls on one
+// body together take linear time.
+func (rs *resyncer) next(pos int) int {
+	best := len(rs.body)
+	for i, magic := range payloadMagics {
+		for rs.found[i] >= 0 && rs.found[i]-rs.envSize <= pos {
+			j := bytes.Index(rs.body[rs.found[i]+1:], magic)
+			if j < 0 {
+				rs.found[i] = -1
+				break
+			}
+			rs.found[i] += 1 + j
+		}
+		if rs.found[i] >= 0 {
+			best = min(best, rs.found[i]-rs.envSize)
+		}
+	}
+	return best
+}
+
+// parseFrameAt parses the frame starting at pos, reporting whether it is valid.
+func parseFrameAt(body []byte, pos int, version byte) (FrameSlice, bool) {
+	envSize := frameEnvSize
+	if version >= bodyVersion {
+		envSize += frameCRCSize
+	}
+	if pos+envSize > len(body) {
+		return FrameSlice{}, false
+	}
 
-		frames = append(frames, FrameSlice{
-			Type:            ft,
-			Offset:          pos,
-			CompressedLen:   compLen,
-			UncompressedLen: uncompLen,
-			PayloadOffset:   payloadStart,
-		})
+	ft := FrameType(body[pos])
+	switch ft {
+	case FrameSession, FrameCheckpoint, FrameMeta, FrameTombstone:
+	default:
+		return FrameSlice{}, false
+	}
+	compLen := int(body[pos+1]) | int(body[pos+2])<<8 | int(body[pos+3])<<16
+	uncompLen := int(binary.LittleEndian.Uint16(body[pos+4 : pos+6]))
 
-		pos = payloadStart + compLen
+	payloadStart := pos + envSize
+	if payloadStart+compLen > len(body) {
+		return FrameSlice{}, false
+	}
+	payload := body[payloadStart : payloadStart+compLen]
+
+	if version >= bodyVersion {
+		want := binary.LittleEndian.Uint32(body[pos+frameEnvSize : pos+envSize])
+		if frameCRC(body[pos:pos+frameEnvSize], payload) != want {
+			return FrameSlice{}, false
+		}
+	} else if !hasPayloadMagic(payload) {
+		return FrameSlice{}, false
 	}
 
-	return frames, nil
+	return FrameSlice{
+		Type:            ft,
+		Offset:          pos,
+		CompressedLen:   compLen,
+		UncompressedLen: uncompLen,
+		PayloadOffset:   payloadStart,
+	}, true
 }
 
 // ExtractFramePayload returns the compressed payload bytes for a frame slice.
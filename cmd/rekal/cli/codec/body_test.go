@@ -1,14 +1,15 @@
 package codec
 
 import (
+	"bytes"
 	"testing"
 	"time"
 )
 
 func TestNewBody_Header(t *testing.T) {
 	body := NewBody()
-	if len(body) != bodyHdrSize {
-		t.Errorf("body header: got %d bytes, want %d", len(body), bodyHdrSize)
+	if len(body) != BodyHeaderSize {
+		t.Errorf("body header: got %d bytes, want %d", len(body), BodyHeaderSize)
 	}
 	if string(body[0:7]) != "RKLBODY" {
 		t.Errorf("magic: got %q", body[0:7])
@@ -268,3 +269,185 @@ func BenchmarkBodyAppendAndScan(b *testing.B) {
 		_, _ = ScanFrames(body)
 	}
 }
+
+// v1Body builds a version 1 body (no frame checksums) from encoded frames.
+func v1Body(frames ...[]byte) []byte {
+	body := NewBody()
+	body[7] = bodyVersionV1
+	for _, f := range frames {
+		body = AppendFrame(body, f)
+	}
+	return body
+}
+
+func TestAppendFrame_Checksum(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+
+	frame := enc.EncodeSessionFrame(&SessionFrame{Turns: []TurnRecord{{Text: "hello"}}})
+
+	v2 := AppendFrame(NewBody(), frame)
+	if len(v2) != BodyHeaderSize+len(frame)+frameCRCSize {
+		t.Errorf("v2 frame size: got %d, want %d", len(v2)-BodyHeaderSize, len(frame)+frameCRCSize)
+	}
+	// Frames appended to a v1 body stay in v1 form.
+	v1 := v1Body(frame)
+	if len(v1) != BodyHeaderSize+len(frame) {
+		t.Errorf("v1 frame size: got %d, want %d", len(v1)-BodyHeaderSize, len(frame))
+	}
+
+	for name, body := range map[string][]byte{"v1": v1, "v2": v2} {
+		frames, err := ScanFrames(body)
+		if err != nil || len(frames) != 1 {
+			t.Fatalf("%s: ScanFrames = %d frames, %v", name, len(frames), err)
+		}
+		if got := ExtractFramePayload(body, frames[0]); string(got) != string(frame[frameEnvSize:]) {
+			t.Errorf("%s: payload mismatch", name)
+		}
+	}
+}
+
+func TestScanBody_ResyncAfterCorruption(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+
+	var encoded [][]byte
+	for _, text := range []string{"first", "second", "third"} {
+		encoded = append(encoded, enc.EncodeSessionFrame(&SessionFrame{Turns: []TurnRecord{{Text: text}}}))
+	}
+	body := NewBody()
+	for _, f := range encoded {
+		body = AppendFrame(body, f)
+	}
+	clean, err := ScanFrames(body)
+	if err != nil || len(clean) != 3 {
+		t.Fatalf("clean scan: %d frames, %v", len(clean), err)
+	}
+
+	// Flip a byte in the middle frame's payload and tear the last append.
+	corrupt := append([]byte(nil), body[:clean[2].Offset+5]...)
+	corrupt[clean[1].PayloadOffset+2] ^= 0xFF
+
+	r, err := ScanBody(corrupt)
+	if err != nil {
+		t.Fatalf("ScanBody: %v", err)
+	}
+	if len(r.Frames) != 1 || r.Frames[0].Offset != clean[0].Offset {
+		t.Fatalf("frames: got %+v, want only the first", r.Frames)
+	}
+	want := []SkippedRange{{Offset: clean[1].Offset, Length: len(corrupt) - clean[1].Offset}}
+	if len(r.Skipped) != 1 || r.Skipped[0] != want[0] {
+		t.Errorf("skipped: got %+v, want %+v", r.Skipped, want)
+	}
+
+	// Frames after a corrupt region are recovered.
+	corrupt = append([]byte(nil), body...)
+	corrupt[clean[1].PayloadOffset+2] ^= 0xFF
+	r, err = ScanBody(corrupt)
+	if err != nil {
+		t.Fatalf("ScanBody: %v", err)
+	}
+	if len(r.Frames) != 2 || r.Frames[1].Offset != clean[2].Offset {
+		t.Fatalf("frames: got %+v, want first and third", r.Frames)
+	}
+	if len(r.Skipped) != 1 || r.Skipped[0] != (SkippedRange{Offset: clean[1].Offset, Length: clean[2].Offset - clean[1].Offset}) {
+		t.Errorf("skipped: got %+v", r.Skipped)
+	}
+}
+
+// A large corrupt region that looks like frame envelopes at every offset is
+// skipped without a full parse at each of them.
+func TestScanBody_ResyncLargeCorruptRegion(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+
+	f1 := enc.EncodeSessionFrame(&SessionFrame{Turns: []TurnRecord{{Text: "before"}}})
+	f2 := enc.EncodeSessionFrame(&SessionFrame{Turns: []TurnRecord{{Text: "after"}}})
+	body := AppendFrame(NewBody(), f1)
+	garbageAt := len(body)
+	// 0x01 is a session frame type and gives a 64 KiB length that fits.
+	body = append(body, bytes.Repeat([]byte{0x01}, 4<<20)...)
+	resumeAt := len(body)
+	body = AppendFrame(body, f2)
+
+	r, err := ScanBody(body)
+	if err != nil {
+		t.Fatalf("ScanBody: %v", err)
+	}
+	if len(r.Frames) != 2 || r.Frames[1].Offset != resumeAt {
+		t.Fatalf("frames: got %+v, want the frames before and after the garbage", r.Frames)
+	}
+	if len(r.Skipped) != 1 || r.Skipped[0] != (SkippedRange{Offset: garbageAt, Length: resumeAt - garbageAt}) {
+		t.Errorf("skipped: got %+v", r.Skipped)
+	}
+}
+
+func TestScanBody_V1TruncatedTail(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+
+	f1 := enc.EncodeSessionFrame(&SessionFrame{Turns: []TurnRecord{{Text: "kept"}}})
+	f2 := enc.EncodeSessionFrame(&SessionFrame{Turns: []TurnRecord{{Text: "torn"}}})
+	body := v1Body(f1, f2)
+	body = body[:len(body)-3]
+
+	r, err := ScanBody(body)
+	if err != nil {
+		t.Fatalf("ScanBody: %v", err)
+	}
+	if r.Version != bodyVersionV1 || len(r.Frames) != 1 {
+		t.Fatalf("version %d, %d frames; want v1 with 1 frame", r.Version, len(r.Frames))
+	}
+	tornAt := BodyHeaderSize + len(f1)
+	if len(r.Skipped) != 1 || r.Skipped[0] != (SkippedRange{Offset: tornAt, Length: len(body) - tornAt}) {
+		t.Errorf("skipped: got %+v", r.Skipped)
+	}
+}
+
+// Encryption can be turned on for a branch whose body is still v1: sealed
+// frames appended to it must scan like the compressed ones before them.
+func TestScanBody_V1SealedFrames(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+
+	plain := enc.EncodeSessionFrame(&SessionFrame{Turns: []TurnRecord{{Text: "before"}}})
+	if err := enc.SetSealKey(bytes.Repeat([]byte{0x42}, KeySize)); err != nil {
+		t.Fatal(err)
+	}
+	sealed := enc.EncodeSessionFrame(&SessionFrame{Turns: []TurnRecord{{Text: "after"}}})
+	body := v1Body(plain, sealed)
+
+	r, err := ScanBody(body)
+	if err != nil {
+		t.Fatalf("ScanBody: %v", err)
+	}
+	if len(r.Frames) != 2 || len(r.Skipped) != 0 {
+		t.Fatalf("got %d frames, skipped %+v; want 2 frames", len(r.Frames), r.Skipped)
+	}
+	if !IsSealed(ExtractFramePayload(body, r.Frames[1])) {
+		t.Error("second frame payload is not sealed")
+	}
+}
+
+func TestScanBody_UnsupportedVersion(t *testing.T) {
+	body := NewBody()
+	body[7] = 0x7F
+	if _, err := ScanBody(body); err == nil {
+		t.Error("expected error for unknown body version")
+	}
+}
@@ -0,0 +1,208 @@
+package codec
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildChainedBody builds a body of n batches, each a session frame followed
+// by a chained meta frame, the way export writes it.
+func buildChainedBody(t *testing.T, enc *Encoder, n int) []byte {
+	t.Helper()
+	body := NewBody()
+	for i := 0; i < n; i++ {
+		body = AppendFrame(body, enc.EncodeSessionFrame(&SessionFrame{
+			SessionRef: uint64(i),
+			CapturedAt: time.Date(2026, 2, 25, 10, i, 0, 0, time.UTC),
+			Turns:      []TurnRecord{{Role: RoleHuman, Text: strings.Repeat("x", i+1)}},
+		}))
+		frames, err := ScanFrames(body)
+		if err != nil {
+			t.Fatalf("ScanFrames: %v", err)
+		}
+		body = AppendFrame(body, enc.EncodeMetaFrame(&MetaFrame{
+			FormatVersion: 1,
+			CheckpointSHA: strings.Repeat("0", 40),
+			Timestamp:     time.Date(2026, 2, 25, 10, i, 0, 0, time.UTC),
+			NFrames:       uint32(len(frames) + 1),
+			Chained:       true,
+			Chain:         Chain(body, frames),
+		}))
+	}
+	return body
+}
+
+func TestMetaFrame_ChainRoundtrip(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+	dec, err := NewDecoder()
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	defer dec.Close()
+
+	h := NextChain(ChainHash{}, []byte("frame"))
+	encoded := enc.EncodeMetaFrame(&MetaFrame{FormatVersion: 1, Chained: true, Chain: h, NFrames: 7})
+	mf, err := dec.DecodeMetaFrame(encoded[frameEnvSize:])
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !mf.Chained || mf.Chain != h {
+		t.Errorf("chain: got %v/%s, want true/%s", mf.Chained, mf.Chain, h)
+	}
+	if mf.NFrames != 7 {
+		t.Errorf("n_frames: got %d, want 7", mf.NFrames)
+	}
+
+	parsed, ok := ParseChainHash(h.String())
+	if !ok || parsed != h {
+		t.Errorf("ParseChainHash(%s) = %s, %v", h, parsed, ok)
+	}
+}
+
+func TestVerifyChain_Intact(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+	dec, err := NewDecoder()
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	defer dec.Close()
+
+	body := buildChainedBody(t, enc, 3)
+	r, err := dec.VerifyChain(body)
+	if err != nil {
+		t.Fatalf("VerifyChain: %v", err)
+	}
+	if !r.OK() {
+		t.Fatalf("unexpected breaks: %+v", r.Breaks)
+	}
+	if r.Frames != 6 || r.Sealed != 6 || r.Unsealed != 0 {
+		t.Errorf("frames/sealed/unsealed: got %d/%d/%d, want 6/6/0", r.Frames, r.Sealed, r.Unsealed)
+	}
+
+	// An unsealed tail is reported but is not a break.
+	body = AppendFrame(body, enc.EncodeSessionFrame(&SessionFrame{SessionRef: 9}))
+	r, err = dec.VerifyChain(body)
+	if err != nil {
+		t.Fatalf("VerifyChain: %v", err)
+	}
+	if !r.OK() || r.Unsealed != 1 {
+		t.Errorf("tail: ok=%v unsealed=%d, want true/1", r.OK(), r.Unsealed)
+	}
+}
+
+func TestVerifyChain_DetectsTampering(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+	dec, err := NewDecoder()
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	defer dec.Close()
+
+	body := buildChainedBody(t, enc, 3)
+	frames, err := ScanFrames(body)
+	if err != nil {
+		t.Fatalf("ScanFrames: %v", err)
+	}
+
+	// Replace the second batch's session frame with a different, well-formed
+	// one — checksums pass, only the chain can tell.
+	tampered := append([]byte(nil), body[:frames[2].Offset]...)
+	tampered = AppendFrame(tampered, enc.EncodeSessionFrame(&SessionFrame{
+		SessionRef: 1,
+		Turns:      []TurnRecord{{Role: RoleHuman, Text: "rewritten"}},
+	}))
+	tampered = append(tampered, body[frames[3].Offset:]...)
+	tamperedFrames, err := ScanFrames(tampered)
+	if err != nil {
+		t.Fatalf("ScanFrames: %v", err)
+	}
+
+	r, err := dec.VerifyChain(tampered)
+	if err != nil {
+		t.Fatalf("VerifyChain: %v", err)
+	}
+	// Resync localises the break to the meta frame sealing the altered batch.
+	if len(r.Breaks) != 1 {
+		t.Fatalf("breaks: got %d, want 1: %+v", len(r.Breaks), r.Breaks)
+	}
+	if r.Breaks[0].Frame != 3 || r.Breaks[0].Offset != tamperedFrames[3].Offset {
+		t.Errorf("break at frame %d offset %d, want frame 3 offset %d", r.Breaks[0].Frame, r.Breaks[0].Offset, tamperedFrames[3].Offset)
+	}
+
+	// Dropping a whole batch breaks the chain too.
+	dropped := append([]byte(nil), body[:frames[2].Offset]...)
+	dropped = append(dropped, body[frames[4].Offset:]...)
+	r, err = dec.VerifyChain(dropped)
+	if err != nil {
+		t.Fatalf("VerifyChain: %v", err)
+	}
+	if r.OK() {
+		t.Error("expected a break after dropping frames")
+	}
+}
+
+func TestVerifyChain_LegacyBody(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+	dec, err := NewDecoder()
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	defer dec.Close()
+
+	body := NewBody()
+	body = AppendFrame(body, enc.EncodeSessionFrame(&SessionFrame{SessionRef: 0}))
+	body = AppendFrame(body, enc.EncodeMetaFrame(&MetaFrame{FormatVersion: 1, CheckpointSHA: strings.Repeat("0", 40)}))
+
+	r, err := dec.VerifyChain(body)
+	if err != nil {
+		t.Fatalf("VerifyChain: %v", err)
+	}
+	if !r.OK() || r.Sealed != 0 || r.Unsealed != 2 {
+		t.Errorf("legacy: ok=%v sealed=%d unsealed=%d, want true/0/2", r.OK(), r.Sealed, r.Unsealed)
+	}
+
+	// Chaining continues over legacy frames: a new sealed batch covers them.
+	frames, _ := ScanFrames(body)
+	body = AppendFrame(body, enc.EncodeMetaFrame(&MetaFrame{FormatVersion: 1, Chained: true, Chain: Chain(body, frames)}))
+	r, err = dec.VerifyChain(body)
+	if err != nil {
+		t.Fatalf("VerifyChain: %v", err)
+	}
+	if !r.OK() || r.Sealed != 3 {
+		t.Errorf("upgraded: ok=%v sealed=%d, want true/3", r.OK(), r.Sealed)
+	}
+}
+
+func TestChainFrom(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+	body := buildChainedBody(t, enc, 3)
+	frames, err := ScanFrames(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Continuing a prefix's chain gives the chain of the whole body.
+	if got, want := ChainFrom(Chain(body, frames[:2]), body, frames[2:]), Chain(body, frames); got != want {
+		t.Errorf("ChainFrom = %s, want %s", got, want)
+	}
+}
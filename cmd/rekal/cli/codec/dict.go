@@ -14,27 +14,37 @@ const (
 	NSBranches
 	NSEmails
 	NSPaths
+	NSTools
 )
 
 const (
 	dictMagic   = "RKDICT"
-	dictVersion = 0x01
+	dictVersion = 0x02
 	dictHdrSize = 12 // 6 magic + 1 version + 1 reserved + 2 n_sessions + 2 n_branches
+
+	// dictVersionV1 has no tools namespace and no path count: paths run to
+	// the end of the file. It is still written while the tools namespace is
+	// empty, so older readers keep loading dict.bin.
+	dictVersionV1 = 0x01
+	// dictV2ExtSize follows the header in v2: 4 n_paths + 2 n_tools.
+	dictV2ExtSize = 6
 )
 
 // Dict is the in-memory representation of dict.bin.
-// It maps strings to compact integer indices within four namespaces.
+// It maps strings to compact integer indices within five namespaces.
 type Dict struct {
 	Sessions []string
 	Branches []string
 	Emails   []string
 	Paths    []string
+	Tools    []string // tool names without a fixed code
 
 	// Reverse lookup maps for O(1) lookup.
 	sessIdx   map[string]uint64
 	branchIdx map[string]uint64
 	emailIdx  map[string]uint64
 	pathIdx   map[string]uint64
+	toolIdx   map[string]uint64
 }
 
 // NewDict creates an empty dictionary.
@@ -44,6 +54,7 @@ func NewDict() *Dict {
 		branchIdx: make(map[string]uint64),
 		emailIdx:  make(map[string]uint64),
 		pathIdx:   make(map[string]uint64),
+		toolIdx:   make(map[string]uint64),
 	}
 }
 
@@ -85,7 +96,32 @@ func (d *Dict) Len(ns Namespace) int {
 
 // TotalEntries returns the total number of entries across all namespaces.
 func (d *Dict) TotalEntries() int {
-	return len(d.Sessions) + len(d.Branches) + len(d.Emails) + len(d.Paths)
+	return len(d.Sessions) + len(d.Branches) + len(d.Emails) + len(d.Paths) + len(d.Tools)
+}
+
+// EncodeTool returns the wire code for a tool name. Tools with a fixed code
+// use it; any other name is added to the tools namespace and returned as
+// ToolDictRef with its ref.
+func (d *Dict) EncodeTool(name string) (code byte, ref uint64) {
+	if c := ToolCode(name); c != ToolUnknown {
+		return c, 0
+	}
+	if name == "" || len(name) > 255 {
+		return ToolUnknown, 0
+	}
+	return ToolDictRef, d.LookupOrAdd(NSTools, name)
+}
+
+// ToolName returns the tool name of a decoded tool call, resolving
+// ToolDictRef through the tools namespace.
+func (d *Dict) ToolName(tc ToolCallRecord) string {
+	if tc.Tool == ToolDictRef {
+		if name, err := d.Get(NSTools, tc.ToolRef); err == nil {
+			return name
+		}
+		return ToolName(ToolUnknown)
+	}
+	return ToolName(tc.Tool)
 }
 
 func (d *Dict) nsRef(ns Namespace) (*[]string, *map[string]uint64) {
@@ -98,6 +134,8 @@ func (d *Dict) nsRef(ns Namespace) (*[]string, *map[string]uint64) {
 		return &d.Emails, &d.emailIdx
 	case NSPaths:
 		return &d.Paths, &d.pathIdx
+	case NSTools:
+		return &d.Tools, &d.toolIdx
 	default:
 		panic(fmt.Sprintf("dict: unknown namespace %d", ns))
 	}
@@ -117,9 +155,19 @@ func (d *Dict) Encode() []byte {
 	for _, s := range d.Paths {
 		size += 2 + len(s) // 2-byte length prefix
 	}
+	if len(d.Tools) > 0 {
+		size += dictV2ExtSize
+		for _, s := range d.Tools {
+			size += 1 + len(s)
+		}
+	}
 
 	buf := make([]byte, dictHdrSize, size)
 	d.encodeHeader(buf)
+	if len(d.Tools) > 0 {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(d.Paths)))
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(d.Tools)))
+	}
 
 	// Session entries: fixed 26-byte ULID strings.
 	for _, s := range d.Sessions {
@@ -150,6 +198,12 @@ func (d *Dict) Encode() []byte {
 		buf = append(buf, []byte(s)...)
 	}
 
+	// Tool entries (v2): 1-byte length prefix + UTF-8.
+	for _, s := range d.Tools {
+		buf = append(buf, byte(len(s)))
+		buf = append(buf, []byte(s)...)
+	}
+
 	return buf
 }
 
@@ -164,7 +218,7 @@ func LoadDict(data []byte) (*Dict, error) {
 		return nil, fmt.Errorf("dict: bad magic %q, want %q", magic, dictMagic)
 	}
 	version := data[6]
-	if version != dictVersion {
+	if version != dictVersion && version != dictVersionV1 {
 		return nil, fmt.Errorf("dict: unsupported version %d", version)
 	}
 	// data[7] = reserved
@@ -175,6 +229,17 @@ func LoadDict(data []byte) (*Dict, error) {
 	d := NewDict()
 	pos := dictHdrSize
 
+	// v2 counts paths and tools; v1 paths run to the end of the data.
+	nPaths, nTools := -1, 0
+	if version >= dictVersion {
+		if pos+dictV2ExtSize > len(data) {
+			return nil, errors.New("dict: data too short for v2 header")
+		}
+		nPaths = int(binary.LittleEndian.Uint32(data[pos : pos+4]))
+		nTools = int(binary.LittleEndian.Uint16(data[pos+4 : pos+6]))
+		pos += dictV2ExtSize
+	}
+
 	// Session entries: fixed 26 bytes each.
 	for i := 0; i < nSessions; i++ {
 		if pos+26 > len(data) {
@@ -231,8 +296,14 @@ func LoadDict(data []byte) (*Dict, error) {
 		pos += n
 	}
 
-	// Path entries: 2-byte length prefix (u16 LE). Read remaining data.
-	for i := 0; pos+2 <= len(data); i++ {
+	// Path entries: 2-byte length prefix (u16 LE).
+	for i := 0; i != nPaths; i++ {
+		if pos+2 > len(data) {
+			if nPaths < 0 {
+				break
+			}
+			return nil, fmt.Errorf("dict: truncated at path entry %d", i)
+		}
 		n := int(binary.LittleEndian.Uint16(data[pos : pos+2]))
 		pos += 2
 		if pos+n > len(data) {
@@ -244,13 +315,33 @@ func LoadDict(data []byte) (*Dict, error) {
 		pos += n
 	}
 
+	// Tool entries: 1-byte length prefix.
+	for i := 0; i < nTools; i++ {
+		if pos >= len(data) {
+			return nil, fmt.Errorf("dict: truncated at tool entry %d", i)
+		}
+		n := int(data[pos])
+		pos++
+		if pos+n > len(data) {
+			return nil, fmt.Errorf("dict: truncated at tool entry %d data", i)
+		}
+		s := string(data[pos : pos+n])
+		d.Tools = append(d.Tools, s)
+		d.toolIdx[s] = uint64(i)
+		pos += n
+	}
+
 	return d, nil
 }
 
-// encodeHeader writes the 12-byte header.
+// encodeHeader writes the 12-byte header. The version is v1 until the tools
+// namespace is used.
 func (d *Dict) encodeHeader(buf []byte) {
 	copy(buf[0:6], dictMagic)
-	buf[6] = dictVersion
+	buf[6] = dictVersionV1
+	if len(d.Tools) > 0 {
+		buf[6] = dictVersion
+	}
 	buf[7] = byte(len(d.Emails)) // reserved byte = n_emails
 	binary.LittleEndian.PutUint16(buf[8:10], uint16(len(d.Sessions)))
 	binary.LittleEndian.PutUint16(buf[10:12], uint16(len(d.Branches)))
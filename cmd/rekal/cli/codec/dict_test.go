@@ -218,3 +218,45 @@ func BenchmarkDictLoad(b *testing.B) {
 		_, _ = LoadDict(encoded)
 	}
 }
+
+func TestDict_ToolsNamespace(t *testing.T) {
+	d := NewDict()
+	d.LookupOrAdd(NSPaths, "src/main.go")
+
+	// Fixed codes stay on the fast path and keep dict.bin at v1.
+	if code, _ := d.EncodeTool("Bash"); code != ToolBash {
+		t.Errorf("EncodeTool(Bash) = %d, want %d", code, ToolBash)
+	}
+	if v := d.Encode()[6]; v != dictVersionV1 {
+		t.Errorf("version without tools: got %d, want %d", v, dictVersionV1)
+	}
+
+	code, ref := d.EncodeTool("mcp__github__create_issue")
+	if code != ToolDictRef || ref != 0 {
+		t.Errorf("EncodeTool(mcp) = (%d, %d), want (%d, 0)", code, ref, ToolDictRef)
+	}
+	if _, ref := d.EncodeTool("apply_patch"); ref != 1 {
+		t.Errorf("second tool ref: got %d, want 1", ref)
+	}
+
+	data := d.Encode()
+	if data[6] != dictVersion {
+		t.Errorf("version with tools: got %d, want %d", data[6], dictVersion)
+	}
+	loaded, err := LoadDict(data)
+	if err != nil {
+		t.Fatalf("LoadDict: %v", err)
+	}
+	if loaded.Len(NSPaths) != 1 || loaded.Len(NSTools) != 2 {
+		t.Fatalf("loaded paths/tools: %d/%d, want 1/2", loaded.Len(NSPaths), loaded.Len(NSTools))
+	}
+	if got := loaded.ToolName(ToolCallRecord{Tool: ToolDictRef, ToolRef: 1}); got != "apply_patch" {
+		t.Errorf("ToolName(ref 1) = %q, want apply_patch", got)
+	}
+	if got := loaded.ToolName(ToolCallRecord{Tool: ToolRead}); got != "Read" {
+		t.Errorf("ToolName(Read) = %q", got)
+	}
+	if got := loaded.ToolName(ToolCallRecord{Tool: ToolDictRef, ToolRef: 9}); got != "Unknown" {
+		t.Errorf("ToolName(bad ref) = %q, want Unknown", got)
+	}
+}
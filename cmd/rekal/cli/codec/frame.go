@@ -17,6 +17,7 @@ const (
 	ToolGlob    byte = 0x04
 	ToolGrep    byte = 0x05
 	ToolTask    byte = 0x06
+	ToolDictRef byte = 0xFE // name in the dict tools namespace; a varint ref follows
 	ToolUnknown byte = 0xFF
 )
 
@@ -55,28 +56,54 @@ var (
 
 const payloadVersion = 0x01
 
+// sessionPayloadVersion is the session payload written by this version.
+// v2 adds source, parent session, session-level branch and agent id for any
+// actor, and replaces per-turn second deltas with lossless timestamps.
+const sessionPayloadVersion = 0x02
+
+// metaPayloadVersionChained marks a meta payload that carries a chain hash.
+// Older readers ignore the trailing hash and still decode the rest.
+const metaPayloadVersionChained = 0x02
+
+// metaPayloadVersionDict marks a meta payload that also names the zstd
+// dictionary frames after it are compressed with.
+const metaPayloadVersionDict = 0x03
+
 // SessionFrame is the decoded content of a session frame (0x01).
 type SessionFrame struct {
 	SessionRef uint64
 	CapturedAt time.Time
 	EmailRef   uint64
 	ActorType  byte
-	AgentIDRef uint64 // only valid if ActorType == ActorAgent
+	AgentIDRef uint64 // valid if HasAgentID
+	HasAgentID bool   // v1: set for ActorAgent
 	Turns      []TurnRecord
 	ToolCalls  []ToolCallRecord
+
+	// Session-level fields added in payload v2. v1 payloads decode with an
+	// empty Source and ParentSessionID, and the branch of the first turn.
+	Source          string
+	ParentSessionID string
+	BranchRef       uint64 // valid if HasBranch
+	HasBranch       bool
 }
 
 // TurnRecord is a single conversation turn.
 type TurnRecord struct {
 	Role      byte
-	TsDelta   uint64 // seconds since previous turn
-	BranchRef uint64
+	TsDelta   uint64 // v1: seconds since previous turn
+	BranchRef uint64 // v1: per turn; v2: the session branch
 	Text      string
+	// Ts is the absolute turn timestamp, zero if unknown. Written and
+	// reconstructed by v2 payloads as microsecond deltas from the previous
+	// timestamp, starting at CapturedAt; v1 payloads leave it zero.
+	Ts time.Time
 }
 
 // ToolCallRecord is a single tool invocation.
 type ToolCallRecord struct {
 	Tool       byte
+	ToolRef    uint64 // valid if Tool == ToolDictRef
 	PathFlag   byte
 	PathRef    uint64 // valid if PathFlag == PathDictRef
 	PathInline string // valid if PathFlag == PathInline
@@ -112,6 +139,14 @@ type MetaFrame struct {
 	NCheckpoints  uint32
 	NFrames       uint32
 	NDictEntries  uint32
+	// Chained is set when Chain holds the chain hash of every frame before
+	// this one. Meta frames written before the hash chain have it unset.
+	Chained bool
+	Chain   ChainHash
+	// DictID is the ID of the zstd dictionary the branch's writer uses from
+	// this frame on, stored under dicts/ on the branch. Zero means the
+	// embedded preset.
+	DictID uint32
 }
 
 // toolNameToCode maps tool name strings to binary codes.
@@ -137,7 +172,8 @@ var toolCodeToName = map[byte]string{
 	ToolUnknown: "Unknown",
 }
 
-// ToolCode returns the binary code for a tool name.
+// ToolCode returns the fixed binary code for a tool name, or ToolUnknown.
+// Use Dict.EncodeTool to keep the names of tools without a fixed code.
 func ToolCode(name string) byte {
 	if c, ok := toolNameToCode[name]; ok {
 		return c
@@ -155,22 +191,79 @@ func ToolName(code byte) string {
 
 // Encoder handles frame encoding with zstd compression.
 type Encoder struct {
-	zw *zstd.Encoder
+	zw     *zstd.Encoder
+	dictID uint32
+	sealer *sealer // nil unless SetSealKey was called
 }
 
 // NewEncoder creates a new frame encoder with zstd preset dictionary support.
 func NewEncoder() (*Encoder, error) {
+	return NewEncoderWithDict(nil)
+}
+
+// NewEncoderWithDict creates a frame encoder that compresses with dict, a
+// zstd dictionary such as one from TrainDict. A nil dict means the preset.
+func NewEncoderWithDict(dict []byte) (*Encoder, error) {
+	if dict == nil {
+		dict = presetDict
+	}
 	opts := []zstd.EOption{
 		zstd.WithEncoderLevel(zstd.SpeedDefault), // level 3
 	}
-	if len(presetDict) > 0 {
-		opts = append(opts, zstd.WithEncoderDict(presetDict))
+	var dictID uint32
+	if len(dict) > 0 {
+		id, err := DictID(dict)
+		if err != nil {
+			return nil, err
+		}
+		dictID = id
+		opts = append(opts, zstd.WithEncoderDict(dict))
 	}
 	zw, err := zstd.NewWriter(nil, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("codec: create zstd encoder: %w", err)
 	}
-	return &Encoder{zw: zw}, nil
+	return &Encoder{zw: zw, dictID: dictID}, nil
+}
+
+// DictID returns the ID of the dictionary the encoder compresses with, or 0
+// if it uses none.
+func (e *Encoder) DictID() uint32 {
+	return e.dictID
+}
+
+// Compress compresses payload as the encoder would a frame payload.
+func (e *Encoder) Compress(payload []byte) []byte {
+	return e.zw.EncodeAll(payload, nil)
+}
+
+// SetSealKey makes the encoder encrypt session and checkpoint frame
+// payloads under key after compressing them. Meta frames stay in the clear.
+func (e *Encoder) SetSealKey(key []byte) error {
+	s, err := newSealer(key)
+	if err != nil {
+		return err
+	}
+	e.sealer = s
+	return nil
+}
+
+// KeyID returns the ID of the key the encoder seals with, and false if it
+// does not seal.
+func (e *Encoder) KeyID() (uint32, bool) {
+	if e.sealer == nil {
+		return 0, false
+	}
+	return e.sealer.id, true
+}
+
+// Seal encrypts data, such as dict.bin, under the encoder's key. Without a
+// key data is returned unchanged.
+func (e *Encoder) Seal(data []byte) []byte {
+	if e.sealer == nil {
+		return data
+	}
+	return e.sealer.seal(data)
 }
 
 // Close releases encoder resources.
@@ -198,60 +291,85 @@ func (e *Encoder) EncodeMetaFrame(mf *MetaFrame) []byte {
 
 func (e *Encoder) wrapFrame(ft FrameType, payload []byte) []byte {
 	compressed := e.zw.EncodeAll(payload, nil)
+	if e.sealer != nil && ft != FrameMeta {
+		compressed = e.sealer.seal(compressed)
+	}
 	env := WriteEnvelope(ft, len(compressed), len(payload))
 	return append(env, compressed...)
 }
 
 func encodeSessionPayload(sf *SessionFrame) []byte {
+	return encodeSessionPayloadVersion(sf, sessionPayloadVersion)
+}
+
+func encodeSessionPayloadVersion(sf *SessionFrame, version byte) []byte {
 	buf := make([]byte, 0, 256)
 
 	// Header: magic + payload_version + dict_flags + n_turns + n_tools
 	buf = append(buf, sessionMagic...)
-	buf = append(buf, payloadVersion)
+	buf = append(buf, version)
 	dictFlags := byte(0x00)
 	if len(presetDict) > 0 {
 		dictFlags = 0x01
 	}
 	buf = append(buf, dictFlags)
-	buf = append(buf, byte(len(sf.Turns)))
-	buf = append(buf, byte(len(sf.ToolCalls)))
+	buf = append(buf, byte(min(len(sf.Turns), 255)))
+	buf = append(buf, byte(min(len(sf.ToolCalls), 255)))
 
 	// Session meta.
 	buf = appendUvarint(buf, sf.SessionRef)
-	buf = binary.LittleEndian.AppendUint32(buf, uint32(sf.CapturedAt.Unix()))
+	capturedAt := uint32(sf.CapturedAt.Unix())
+	buf = binary.LittleEndian.AppendUint32(buf, capturedAt)
 	buf = appendUvarint(buf, sf.EmailRef)
 	buf = append(buf, sf.ActorType)
-	if sf.ActorType == ActorAgent {
+	if version >= sessionPayloadVersion {
+		buf = appendOptRef(buf, sf.AgentIDRef, sf.HasAgentID)
+		buf = appendString(buf, sf.Source)
+		buf = appendString(buf, sf.ParentSessionID)
+		buf = appendOptRef(buf, sf.BranchRef, sf.HasBranch)
+		// The header counts are one byte; v2 carries the exact counts.
+		buf = appendUvarint(buf, uint64(len(sf.Turns)))
+		buf = appendUvarint(buf, uint64(len(sf.ToolCalls)))
+	} else if sf.ActorType == ActorAgent {
 		buf = appendUvarint(buf, sf.AgentIDRef)
 	}
 
 	// Turns.
+	prev := time.Unix(int64(capturedAt), 0)
 	for _, t := range sf.Turns {
 		buf = append(buf, t.Role)
-		buf = appendUvarint(buf, t.TsDelta)
-		buf = appendUvarint(buf, t.BranchRef)
-		buf = appendUvarint(buf, uint64(len(t.Text)))
-		buf = append(buf, []byte(t.Text)...)
+		if version >= sessionPayloadVersion {
+			// 0 = no timestamp, else 1 + zigzag(µs since the previous one).
+			if t.Ts.IsZero() {
+				buf = appendUvarint(buf, 0)
+			} else {
+				delta := t.Ts.Sub(prev).Microseconds()
+				buf = appendUvarint(buf, zigzag(delta)+1)
+				prev = prev.Add(time.Duration(delta) * time.Microsecond)
+			}
+		} else {
+			buf = appendUvarint(buf, t.TsDelta)
+			buf = appendUvarint(buf, t.BranchRef)
+		}
+		buf = appendString(buf, t.Text)
 	}
 
 	// Tool calls.
 	for _, tc := range sf.ToolCalls {
 		buf = append(buf, tc.Tool)
+		if tc.Tool == ToolDictRef {
+			buf = appendUvarint(buf, tc.ToolRef)
+		}
 		buf = append(buf, tc.PathFlag)
 		switch tc.PathFlag {
 		case PathDictRef:
 			buf = appendUvarint(buf, tc.PathRef)
 		case PathInline:
-			buf = appendUvarint(buf, uint64(len(tc.PathInline)))
-			buf = append(buf, []byte(tc.PathInline)...)
+			buf = appendString(buf, tc.PathInline)
 		case PathNull:
 			// no additional bytes
 		}
-		cmdBytes := []byte(tc.CmdPrefix)
-		buf = appendUvarint(buf, uint64(len(cmdBytes)))
-		if len(cmdBytes) > 0 {
-			buf = append(buf, cmdBytes...)
-		}
+		buf = appendString(buf, tc.CmdPrefix)
 	}
 
 	return buf
@@ -302,7 +420,14 @@ func encodeMetaPayload(mf *MetaFrame) []byte {
 
 	// Header: magic + payload_version
 	buf = append(buf, metaMagic...)
-	buf = append(buf, payloadVersion)
+	switch {
+	case mf.DictID != 0:
+		buf = append(buf, metaPayloadVersionDict)
+	case mf.Chained:
+		buf = append(buf, metaPayloadVersionChained)
+	default:
+		buf = append(buf, payloadVersion)
+	}
 
 	// Meta fields.
 	buf = append(buf, mf.FormatVersion)
@@ -319,21 +444,32 @@ func encodeMetaPayload(mf *MetaFrame) []byte {
 	buf = binary.LittleEndian.AppendUint32(buf, mf.NCheckpoints)
 	buf = binary.LittleEndian.AppendUint32(buf, mf.NFrames)
 	buf = binary.LittleEndian.AppendUint32(buf, mf.NDictEntries)
+	if mf.Chained || mf.DictID != 0 {
+		buf = append(buf, mf.Chain[:]...)
+	}
+	if mf.DictID != 0 {
+		buf = binary.LittleEndian.AppendUint32(buf, mf.DictID)
+	}
 
 	return buf
 }
 
 // Decoder handles frame decoding with zstd decompression.
 type Decoder struct {
-	zr *zstd.Decoder
+	zr   *zstd.Decoder
+	keys map[uint32][]byte // data keys for sealed payloads, by key ID
 }
 
-// NewDecoder creates a new frame decoder.
-func NewDecoder() (*Decoder, error) {
+// NewDecoder creates a new frame decoder. Frames compressed with the preset
+// dictionary or any of dicts decode; each frame names its dictionary by ID.
+func NewDecoder(dicts ...[]byte) (*Decoder, error) {
 	opts := []zstd.DOption{}
 	if len(presetDict) > 0 {
 		opts = append(opts, zstd.WithDecoderDicts(presetDict))
 	}
+	if len(dicts) > 0 {
+		opts = append(opts, zstd.WithDecoderDicts(dicts...))
+	}
 	zr, err := zstd.NewReader(nil, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("codec: create zstd decoder: %w", err)
@@ -346,29 +482,56 @@ func (d *Decoder) Close() {
 	d.zr.Close()
 }
 
+// AddKeys adds data keys, by key ID, for decoding sealed frames.
+func (d *Decoder) AddKeys(keys map[uint32][]byte) {
+	for id, key := range keys {
+		if d.keys == nil {
+			d.keys = make(map[uint32][]byte)
+		}
+		d.keys[id] = key
+	}
+}
+
+// Decompress returns the raw payload of a compressed frame payload,
+// decrypting it first if it is sealed.
+func (d *Decoder) Decompress(compressed []byte) ([]byte, error) {
+	if IsSealed(compressed) {
+		opened, err := Open(d.keys, compressed)
+		if err != nil {
+			return nil, err
+		}
+		compressed = opened
+	}
+	payload, err := d.zr.DecodeAll(compressed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("zstd: %w", err)
+	}
+	return payload, nil
+}
+
 // DecodeSessionFrame decodes a compressed session frame payload.
 func (d *Decoder) DecodeSessionFrame(compressed []byte) (*SessionFrame, error) {
-	payload, err := d.zr.DecodeAll(compressed, nil)
+	payload, err := d.Decompress(compressed)
 	if err != nil {
-		return nil, fmt.Errorf("decode session: zstd: %w", err)
+		return nil, fmt.Errorf("decode session: %w", err)
 	}
 	return parseSessionPayload(payload)
 }
 
 // DecodeCheckpointFrame decodes a compressed checkpoint frame payload.
 func (d *Decoder) DecodeCheckpointFrame(compressed []byte) (*CheckpointFrame, error) {
-	payload, err := d.zr.DecodeAll(compressed, nil)
+	payload, err := d.Decompress(compressed)
 	if err != nil {
-		return nil, fmt.Errorf("decode checkpoint: zstd: %w", err)
+		return nil, fmt.Errorf("decode checkpoint: %w", err)
 	}
 	return parseCheckpointPayload(payload)
 }
 
 // DecodeMetaFrame decodes a compressed meta frame payload.
 func (d *Decoder) DecodeMetaFrame(compressed []byte) (*MetaFrame, error) {
-	payload, err := d.zr.DecodeAll(compressed, nil)
+	payload, err := d.Decompress(compressed)
 	if err != nil {
-		return nil, fmt.Errorf("decode meta: zstd: %w", err)
+		return nil, fmt.Errorf("decode meta: %w", err)
 	}
 	return parseMetaPayload(payload)
 }
@@ -380,7 +543,10 @@ func parseSessionPayload(data []byte) (*SessionFrame, error) {
 	if string(data[0:4]) != string(sessionMagic) {
 		return nil, fmt.Errorf("session payload bad magic: %x", data[0:4])
 	}
-	// data[4] = payload_version
+	version := data[4]
+	if version > sessionPayloadVersion {
+		return nil, fmt.Errorf("session payload: unsupported version %d", version)
+	}
 	// data[5] = dict_flags
 	nTurns := int(data[6])
 	nTools := int(data[7])
@@ -388,28 +554,58 @@ func parseSessionPayload(data []byte) (*SessionFrame, error) {
 	pos := 8
 	sf := &SessionFrame{}
 
-	var n int
-	sf.SessionRef, n = readUvarint(data[pos:])
-	pos += n
+	var ok bool
+	if sf.SessionRef, pos, ok = readUvarint(data, pos); !ok {
+		return nil, fmt.Errorf("session payload truncated at session_ref")
+	}
 	if pos+4 > len(data) {
 		return nil, fmt.Errorf("session payload truncated at captured_at")
 	}
 	sf.CapturedAt = time.Unix(int64(binary.LittleEndian.Uint32(data[pos:pos+4])), 0).UTC()
 	pos += 4
-	sf.EmailRef, n = readUvarint(data[pos:])
-	pos += n
+	if sf.EmailRef, pos, ok = readUvarint(data, pos); !ok {
+		return nil, fmt.Errorf("session payload truncated at email_ref")
+	}
 	if pos >= len(data) {
 		return nil, fmt.Errorf("session payload truncated at actor_type")
 	}
 	sf.ActorType = data[pos]
 	pos++
-	if sf.ActorType == ActorAgent {
-		sf.AgentIDRef, n = readUvarint(data[pos:])
-		pos += n
+	if version >= sessionPayloadVersion {
+		sf.AgentIDRef, sf.HasAgentID, pos, ok = readOptRef(data, pos)
+		if ok {
+			sf.Source, pos, ok = readString(data, pos)
+		}
+		if ok {
+			sf.ParentSessionID, pos, ok = readString(data, pos)
+		}
+		if ok {
+			sf.BranchRef, sf.HasBranch, pos, ok = readOptRef(data, pos)
+		}
+		var turns, tools uint64
+		if ok {
+			turns, pos, ok = readUvarint(data, pos)
+		}
+		if ok {
+			tools, pos, ok = readUvarint(data, pos)
+		}
+		// Every turn and tool call takes at least two bytes; larger counts
+		// are corrupt and must not size the allocations below.
+		limit := uint64(len(data)-pos) / 2
+		if !ok || turns > limit || tools > limit-turns {
+			return nil, fmt.Errorf("session payload truncated at session fields")
+		}
+		nTurns, nTools = int(turns), int(tools)
+	} else if sf.ActorType == ActorAgent {
+		if sf.AgentIDRef, pos, ok = readUvarint(data, pos); !ok {
+			return nil, fmt.Errorf("session payload truncated at agent_id_ref")
+		}
+		sf.HasAgentID = true
 	}
 
 	// Turns.
 	sf.Turns = make([]TurnRecord, 0, nTurns)
+	prev := sf.CapturedAt
 	for i := 0; i < nTurns; i++ {
 		if pos >= len(data) {
 			return nil, fmt.Errorf("session payload truncated at turn %d", i)
@@ -417,19 +613,31 @@ func parseSessionPayload(data []byte) (*SessionFrame, error) {
 		var t TurnRecord
 		t.Role = data[pos]
 		pos++
-		t.TsDelta, n = readUvarint(data[pos:])
-		pos += n
-		t.BranchRef, n = readUvarint(data[pos:])
-		pos += n
-		textLen, n2 := readUvarint(data[pos:])
-		pos += n2
-		if pos+int(textLen) > len(data) {
-			return nil, fmt.Errorf("session payload truncated at turn %d text", i)
+		if version >= sessionPayloadVersion {
+			var ts uint64
+			if ts, pos, ok = readUvarint(data, pos); ok && ts > 0 {
+				prev = prev.Add(time.Duration(unzigzag(ts-1)) * time.Microsecond)
+				t.Ts = prev
+			}
+			t.BranchRef = sf.BranchRef
+		} else {
+			t.TsDelta, pos, ok = readUvarint(data, pos)
+			if ok {
+				t.BranchRef, pos, ok = readUvarint(data, pos)
+			}
+		}
+		if ok {
+			t.Text, pos, ok = readString(data, pos)
+		}
+		if !ok {
+			return nil, fmt.Errorf("session payload truncated at turn %d", i)
 		}
-		t.Text = string(data[pos : pos+int(textLen)])
-		pos += int(textLen)
 		sf.Turns = append(sf.Turns, t)
 	}
+	if version < sessionPayloadVersion && len(sf.Turns) > 0 {
+		// v1 repeats the session branch on every turn.
+		sf.BranchRef, sf.HasBranch = sf.Turns[0].BranchRef, true
+	}
 
 	// Tool calls.
 	sf.ToolCalls = make([]ToolCallRecord, 0, nTools)
@@ -440,31 +648,27 @@ func parseSessionPayload(data []byte) (*SessionFrame, error) {
 		var tc ToolCallRecord
 		tc.Tool = data[pos]
 		pos++
+		if tc.Tool == ToolDictRef {
+			if tc.ToolRef, pos, ok = readUvarint(data, pos); !ok || pos >= len(data) {
+				return nil, fmt.Errorf("session payload truncated at tool %d tool_ref", i)
+			}
+		}
 		tc.PathFlag = data[pos]
 		pos++
 		switch tc.PathFlag {
 		case PathDictRef:
-			tc.PathRef, n = readUvarint(data[pos:])
-			pos += n
+			if tc.PathRef, pos, ok = readUvarint(data, pos); !ok {
+				return nil, fmt.Errorf("session payload truncated at tool %d path_ref", i)
+			}
 		case PathInline:
-			pathLen, n2 := readUvarint(data[pos:])
-			pos += n2
-			if pos+int(pathLen) > len(data) {
+			if tc.PathInline, pos, ok = readString(data, pos); !ok {
 				return nil, fmt.Errorf("session payload truncated at tool %d inline path", i)
 			}
-			tc.PathInline = string(data[pos : pos+int(pathLen)])
-			pos += int(pathLen)
 		case PathNull:
 			// no additional bytes
 		}
-		cmdLen, n2 := readUvarint(data[pos:])
-		pos += n2
-		if cmdLen > 0 {
-			if pos+int(cmdLen) > len(data) {
-				return nil, fmt.Errorf("session payload truncated at tool %d cmd", i)
-			}
-			tc.CmdPrefix = string(data[pos : pos+int(cmdLen)])
-			pos += int(cmdLen)
+		if tc.CmdPrefix, pos, ok = readString(data, pos); !ok {
+			return nil, fmt.Errorf("session payload truncated at tool %d cmd", i)
 		}
 		sf.ToolCalls = append(sf.ToolCalls, tc)
 	}
@@ -486,19 +690,22 @@ func parseCheckpointPayload(data []byte) (*CheckpointFrame, error) {
 	cf := &CheckpointFrame{}
 
 	// Checkpoint ULID dict ref.
-	var n int
-	cf.CheckpointRef, n = readUvarint(data[pos:])
-	pos += n
+	var ok bool
+	if cf.CheckpointRef, pos, ok = readUvarint(data, pos); !ok {
+		return nil, fmt.Errorf("checkpoint payload truncated at checkpoint_ref")
+	}
 
 	if pos+40 > len(data) {
 		return nil, fmt.Errorf("checkpoint payload truncated at git_sha")
 	}
 	cf.GitSHA = string(data[pos : pos+40])
 	pos += 40
-	cf.BranchRef, n = readUvarint(data[pos:])
-	pos += n
-	cf.EmailRef, n = readUvarint(data[pos:])
-	pos += n
+	if cf.BranchRef, pos, ok = readUvarint(data, pos); !ok {
+		return nil, fmt.Errorf("checkpoint payload truncated at branch_ref")
+	}
+	if cf.EmailRef, pos, ok = readUvarint(data, pos); !ok {
+		return nil, fmt.Errorf("checkpoint payload truncated at email_ref")
+	}
 	if pos+4 > len(data) {
 		return nil, fmt.Errorf("checkpoint payload truncated at ts")
 	}
@@ -510,16 +717,26 @@ func parseCheckpointPayload(data []byte) (*CheckpointFrame, error) {
 	cf.ActorType = data[pos]
 	pos++
 	if cf.ActorType == ActorAgent {
-		cf.AgentIDRef, n = readUvarint(data[pos:])
-		pos += n
+		if cf.AgentIDRef, pos, ok = readUvarint(data, pos); !ok {
+			return nil, fmt.Errorf("checkpoint payload truncated at agent_id_ref")
+		}
 	}
 
-	nSess, n2 := readUvarint(data[pos:])
-	pos += n2
+	var nSess uint64
+	if nSess, pos, ok = readUvarint(data, pos); !ok {
+		return nil, fmt.Errorf("checkpoint payload truncated at n_sessions")
+	}
+	// Every ref takes at least one byte; a larger count is corrupt and must
+	// not size the allocation.
+	if nSess > uint64(len(data)-pos) {
+		return nil, fmt.Errorf("checkpoint payload truncated at session refs")
+	}
 	cf.SessionRefs = make([]uint64, 0, nSess)
 	for i := uint64(0); i < nSess; i++ {
-		ref, n3 := readUvarint(data[pos:])
-		pos += n3
+		var ref uint64
+		if ref, pos, ok = readUvarint(data, pos); !ok {
+			return nil, fmt.Errorf("checkpoint payload truncated at session ref %d", i)
+		}
 		cf.SessionRefs = append(cf.SessionRefs, ref)
 	}
 
@@ -527,9 +744,7 @@ func parseCheckpointPayload(data []byte) (*CheckpointFrame, error) {
 	cf.Files = make([]FileTouchedRecord, 0, nFiles)
 	for i := 0; i < nFiles; i++ {
 		var f FileTouchedRecord
-		f.PathRef, n = readUvarint(data[pos:])
-		pos += n
-		if pos >= len(data) {
+		if f.PathRef, pos, ok = readUvarint(data, pos); !ok || pos >= len(data) {
 			return nil, fmt.Errorf("checkpoint payload truncated at file %d change_type", i)
 		}
 		f.ChangeType = data[pos]
@@ -547,7 +762,7 @@ func parseMetaPayload(data []byte) (*MetaFrame, error) {
 	if string(data[0:4]) != string(metaMagic) {
 		return nil, fmt.Errorf("meta payload bad magic: %x", data[0:4])
 	}
-	// data[4] = payload_version
+	version := data[4]
 
 	pos := 5
 	mf := &MetaFrame{}
@@ -558,9 +773,10 @@ func parseMetaPayload(data []byte) (*MetaFrame, error) {
 	mf.FormatVersion = data[pos]
 	pos++
 
-	var n int
-	mf.EmailRef, n = readUvarint(data[pos:])
-	pos += n
+	var ok bool
+	if mf.EmailRef, pos, ok = readUvarint(data, pos); !ok {
+		return nil, fmt.Errorf("meta payload truncated at email_ref")
+	}
 
 	if pos+40 > len(data) {
 		return nil, fmt.Errorf("meta payload truncated at checkpoint_sha")
@@ -580,6 +796,22 @@ func parseMetaPayload(data []byte) (*MetaFrame, error) {
 	mf.NFrames = binary.LittleEndian.Uint32(data[pos : pos+4])
 	pos += 4
 	mf.NDictEntries = binary.LittleEndian.Uint32(data[pos : pos+4])
+	pos += 4
+
+	if version >= metaPayloadVersionChained {
+		if pos+len(mf.Chain) > len(data) {
+			return nil, fmt.Errorf("meta payload truncated at chain hash")
+		}
+		copy(mf.Chain[:], data[pos:])
+		mf.Chained = true
+		pos += len(mf.Chain)
+	}
+	if version >= metaPayloadVersionDict {
+		if pos+4 > len(data) {
+			return nil, fmt.Errorf("meta payload truncated at dict_id")
+		}
+		mf.DictID = binary.LittleEndian.Uint32(data[pos : pos+4])
+	}
 
 	return mf, nil
 }
@@ -591,12 +823,57 @@ func appendUvarint(buf []byte, x uint64) []byte {
 	return append(buf, tmp[:n]...)
 }
 
-// readUvarint reads an unsigned LEB128 varint from data.
-// Returns the value and the number of bytes consumed.
-func readUvarint(data []byte) (uint64, int) {
-	v, n := binary.Uvarint(data)
+// readUvarint reads an unsigned LEB128 varint from data at pos.
+// Returns the value and the position after it; ok is false if the varint
+// is truncated or overflows 64 bits.
+func readUvarint(data []byte, pos int) (v uint64, next int, ok bool) {
+	if pos > len(data) {
+		return 0, pos, false
+	}
+	v, n := binary.Uvarint(data[pos:])
 	if n <= 0 {
-		return 0, 1 // consume at least 1 byte on error
+		return 0, pos, false
+	}
+	return v, pos + n, true
+}
+
+// appendString appends a varint length followed by the bytes of s.
+func appendString(buf []byte, s string) []byte {
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// appendOptRef appends an optional dict ref: 0 if absent, else ref+1.
+func appendOptRef(buf []byte, ref uint64, present bool) []byte {
+	if !present {
+		return appendUvarint(buf, 0)
+	}
+	return appendUvarint(buf, ref+1)
+}
+
+// readOptRef reads a ref written by appendOptRef.
+func readOptRef(data []byte, pos int) (ref uint64, present bool, next int, ok bool) {
+	v, next, ok := readUvarint(data, pos)
+	if !ok || v == 0 {
+		return 0, false, next, ok
+	}
+	return v - 1, true, next, true
+}
+
+// zigzag maps signed integers to unsigned so small magnitudes stay small.
+func zigzag(x int64) uint64 {
+	return uint64(x<<1) ^ uint64(x>>63)
+}
+
+func unzigzag(u uint64) int64 {
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+// readString reads a varint length followed by that many bytes.
+func readString(data []byte, pos int) (s string, next int, ok bool) {
+	n, pos, ok := readUvarint(data, pos)
+	if !ok || n > uint64(len(data)-pos) {
+		return "", pos, false
 	}
-	return v, n
+	return string(data[pos : pos+int(n)]), pos + int(n), true
 }
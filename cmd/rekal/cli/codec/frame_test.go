@@ -24,9 +24,9 @@ func TestSessionFrame_Roundtrip(t *testing.T) {
 		EmailRef:   0,
 		ActorType:  ActorHuman,
 		Turns: []TurnRecord{
-			{Role: RoleHuman, TsDelta: 0, BranchRef: 0, Text: "fix the bug in auth middleware"},
-			{Role: RoleAssistant, TsDelta: 45, BranchRef: 0, Text: "Let me read the file first."},
-			{Role: RoleHuman, TsDelta: 120, BranchRef: 0, Text: "looks good, thanks"},
+			{Role: RoleHuman, Ts: time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC), Text: "fix the bug in auth middleware"},
+			{Role: RoleAssistant, Ts: time.Date(2026, 2, 25, 10, 0, 45, 250000000, time.UTC), Text: "Let me read the file first."},
+			{Role: RoleHuman, Ts: time.Date(2026, 2, 25, 10, 2, 45, 0, time.UTC), Text: "looks good, thanks"},
 		},
 		ToolCalls: []ToolCallRecord{
 			{Tool: ToolRead, PathFlag: PathDictRef, PathRef: 0},
@@ -65,8 +65,8 @@ func TestSessionFrame_Roundtrip(t *testing.T) {
 		if turn.Role != sf.Turns[i].Role {
 			t.Errorf("turn %d role: got %d, want %d", i, turn.Role, sf.Turns[i].Role)
 		}
-		if turn.TsDelta != sf.Turns[i].TsDelta {
-			t.Errorf("turn %d ts_delta: got %d, want %d", i, turn.TsDelta, sf.Turns[i].TsDelta)
+		if !turn.Ts.Equal(sf.Turns[i].Ts) {
+			t.Errorf("turn %d ts: got %v, want %v", i, turn.Ts, sf.Turns[i].Ts)
 		}
 		if turn.Text != sf.Turns[i].Text {
 			t.Errorf("turn %d text: got %q, want %q", i, turn.Text, sf.Turns[i].Text)
@@ -107,8 +107,9 @@ func TestSessionFrame_WithAgent(t *testing.T) {
 		EmailRef:   2,
 		ActorType:  ActorAgent,
 		AgentIDRef: 3,
+		HasAgentID: true,
 		Turns: []TurnRecord{
-			{Role: RoleAssistant, TsDelta: 0, BranchRef: 1, Text: "Running automated tests"},
+			{Role: RoleAssistant, Text: "Running automated tests"},
 		},
 		ToolCalls: []ToolCallRecord{
 			{Tool: ToolBash, PathFlag: PathNull, CmdPrefix: "npm test"},
@@ -125,8 +126,98 @@ func TestSessionFrame_WithAgent(t *testing.T) {
 	if decoded.ActorType != ActorAgent {
 		t.Errorf("actor_type: got %d, want %d", decoded.ActorType, ActorAgent)
 	}
-	if decoded.AgentIDRef != 3 {
-		t.Errorf("agent_id_ref: got %d, want 3", decoded.AgentIDRef)
+	if !decoded.HasAgentID || decoded.AgentIDRef != 3 {
+		t.Errorf("agent_id_ref: got %d/%v, want 3/true", decoded.AgentIDRef, decoded.HasAgentID)
+	}
+}
+
+func TestSessionFrame_V2Fields(t *testing.T) {
+	captured := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	sf := &SessionFrame{
+		CapturedAt:      captured,
+		ActorType:       ActorHuman,
+		AgentIDRef:      4,
+		HasAgentID:      true,
+		Source:          "codex",
+		ParentSessionID: "01KJ9KSM0000000000000000AB",
+		BranchRef:       2,
+		HasBranch:       true,
+		Turns: []TurnRecord{
+			{Role: RoleHuman, Ts: captured.Add(-90 * time.Minute), Text: "before capture"},
+			{Role: RoleAssistant, Text: "no timestamp"},
+			{Role: RoleAssistant, Ts: captured.Add(-95*time.Minute + 123456*time.Microsecond), Text: "out of order"},
+		},
+	}
+	got, err := parseSessionPayload(encodeSessionPayload(sf))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got.Source != "codex" || got.ParentSessionID != sf.ParentSessionID {
+		t.Errorf("source/parent: got %q/%q", got.Source, got.ParentSessionID)
+	}
+	if !got.HasAgentID || got.AgentIDRef != 4 || got.ActorType != ActorHuman {
+		t.Errorf("agent: got %d/%v actor %d", got.AgentIDRef, got.HasAgentID, got.ActorType)
+	}
+	if !got.HasBranch || got.BranchRef != 2 {
+		t.Errorf("branch: got %d/%v, want 2/true", got.BranchRef, got.HasBranch)
+	}
+	for i, turn := range got.Turns {
+		if !turn.Ts.Equal(sf.Turns[i].Ts) {
+			t.Errorf("turn %d ts: got %v, want %v", i, turn.Ts, sf.Turns[i].Ts)
+		}
+		if turn.BranchRef != 2 {
+			t.Errorf("turn %d branch_ref: got %d, want 2", i, turn.BranchRef)
+		}
+	}
+
+	// More than 255 turns and tool calls survive the one-byte header counts.
+	long := &SessionFrame{Turns: make([]TurnRecord, 300), ToolCalls: make([]ToolCallRecord, 260)}
+	for i := range long.ToolCalls {
+		long.ToolCalls[i].PathFlag = PathNull
+	}
+	got, err = parseSessionPayload(encodeSessionPayload(long))
+	if err != nil {
+		t.Fatalf("parse long: %v", err)
+	}
+	if len(got.Turns) != 300 || len(got.ToolCalls) != 260 {
+		t.Errorf("long session: got %d turns, %d tools, want 300/260", len(got.Turns), len(got.ToolCalls))
+	}
+
+	// Absent optional fields stay absent.
+	got, err = parseSessionPayload(encodeSessionPayload(&SessionFrame{CapturedAt: captured}))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got.HasAgentID || got.HasBranch || got.Source != "" || got.ParentSessionID != "" {
+		t.Errorf("empty session: got %+v", got)
+	}
+}
+
+func TestSessionFrame_V1Compat(t *testing.T) {
+	sf := &SessionFrame{
+		CapturedAt: time.Date(2026, 1, 15, 8, 0, 0, 0, time.UTC),
+		ActorType:  ActorAgent,
+		AgentIDRef: 3,
+		Turns: []TurnRecord{
+			{Role: RoleHuman, BranchRef: 1, Text: "first"},
+			{Role: RoleAssistant, TsDelta: 45, BranchRef: 1, Text: "second"},
+		},
+	}
+	got, err := parseSessionPayload(encodeSessionPayloadVersion(sf, payloadVersion))
+	if err != nil {
+		t.Fatalf("parse v1: %v", err)
+	}
+	if !got.HasAgentID || got.AgentIDRef != 3 {
+		t.Errorf("agent: got %d/%v, want 3/true", got.AgentIDRef, got.HasAgentID)
+	}
+	if !got.HasBranch || got.BranchRef != 1 {
+		t.Errorf("branch from first turn: got %d/%v, want 1/true", got.BranchRef, got.HasBranch)
+	}
+	if got.Turns[1].TsDelta != 45 || !got.Turns[1].Ts.IsZero() {
+		t.Errorf("v1 turn ts: delta %d ts %v, want 45 and zero", got.Turns[1].TsDelta, got.Turns[1].Ts)
+	}
+	if got.Source != "" || got.ParentSessionID != "" {
+		t.Errorf("v1 source/parent: got %q/%q, want empty", got.Source, got.ParentSessionID)
 	}
 }
 
@@ -172,6 +263,40 @@ func TestSessionFrame_InlinePath(t *testing.T) {
 	}
 }
 
+func TestSessionFrame_ToolDictRef(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+	dec, err := NewDecoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+
+	sf := &SessionFrame{
+		ToolCalls: []ToolCallRecord{
+			{Tool: ToolDictRef, ToolRef: 300, PathFlag: PathNull, CmdPrefix: "gh issue"},
+			{Tool: ToolRead, PathFlag: PathDictRef, PathRef: 2},
+		},
+	}
+	frame := enc.EncodeSessionFrame(sf)
+	got, err := dec.DecodeSessionFrame(frame[frameEnvSize:])
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got.ToolCalls) != 2 {
+		t.Fatalf("tool calls: got %d, want 2", len(got.ToolCalls))
+	}
+	if tc := got.ToolCalls[0]; tc.Tool != ToolDictRef || tc.ToolRef != 300 || tc.CmdPrefix != "gh issue" {
+		t.Errorf("dict tool: got %+v", tc)
+	}
+	if tc := got.ToolCalls[1]; tc.Tool != ToolRead || tc.PathRef != 2 {
+		t.Errorf("fixed tool: got %+v", tc)
+	}
+}
+
 func TestCheckpointFrame_Roundtrip(t *testing.T) {
 	enc, err := NewEncoder()
 	if err != nil {
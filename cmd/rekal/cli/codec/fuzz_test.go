@@ -0,0 +1,142 @@
+package codec
+
+import (
+	"testing"
+	"time"
+)
+
+// Decoders run on bytes fetched from other people's branches. None of them
+// may panic, whatever the input.
+
+func fuzzSeedFrames(f *testing.F) (session, checkpoint, meta []byte) {
+	f.Helper()
+	enc, err := NewEncoder()
+	if err != nil {
+		f.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+	sf := &SessionFrame{
+		CapturedAt: time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC),
+		ActorType:  ActorAgent,
+		AgentIDRef: 2,
+		Turns:      []TurnRecord{{Role: RoleHuman, Text: "fix the bug"}, {Role: RoleAssistant, TsDelta: 5, Text: "done"}},
+		ToolCalls:  []ToolCallRecord{{Tool: ToolEdit, PathFlag: PathInline, PathInline: "a.go"}, {Tool: ToolBash, PathFlag: PathNull, CmdPrefix: "go test"}},
+	}
+	cf := &CheckpointFrame{
+		GitSHA:      "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		Timestamp:   time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC),
+		SessionRefs: []uint64{0, 1},
+		Files:       []FileTouchedRecord{{PathRef: 0, ChangeType: ChangeModified}},
+	}
+	mf := &MetaFrame{FormatVersion: 1, CheckpointSHA: cf.GitSHA, NFrames: 3, Chained: true}
+	return encodeSessionPayload(sf), encodeCheckpointPayload(cf), encodeMetaPayload(mf)
+}
+
+func FuzzScanFrames(f *testing.F) {
+	enc, err := NewEncoder()
+	if err != nil {
+		f.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+	frame := enc.EncodeSessionFrame(&SessionFrame{Turns: []TurnRecord{{Text: "hello"}}})
+	f.Add(AppendFrame(AppendFrame(NewBody(), frame), frame))
+	f.Add(v1Body(frame, frame))
+	f.Add(NewBody())
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		r, err := ScanBody(body)
+		if err != nil {
+			return
+		}
+		// Frames and skipped ranges tile the body after the header.
+		covered := BodyHeaderSize
+		for _, fs := range r.Frames {
+			end := fs.PayloadOffset + fs.CompressedLen
+			if fs.Offset < covered || end > len(body) {
+				t.Fatalf("frame %+v out of order or out of bounds (len %d)", fs, len(body))
+			}
+			covered += end - fs.Offset
+			_ = ExtractFramePayload(body, fs)
+		}
+		for _, s := range r.Skipped {
+			covered += s.Length
+		}
+		if covered != len(body) {
+			t.Fatalf("frames and skipped ranges cover %d of %d bytes", covered, len(body))
+		}
+	})
+}
+
+func FuzzLoadDict(f *testing.F) {
+	d := NewDict()
+	d.LookupOrAdd(NSSessions, "01KJ9KSM0000000000000000AB")
+	d.LookupOrAdd(NSBranches, "main")
+	d.LookupOrAdd(NSEmails, "dev@example.com")
+	d.LookupOrAdd(NSPaths, "src/main.go")
+	f.Add(d.Encode())
+	d.EncodeTool("WebFetch")
+	f.Add(d.Encode())
+	f.Add(NewDict().Encode())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		d, err := LoadDict(data)
+		if err != nil {
+			return
+		}
+		// Whatever loads must re-encode and load again identically.
+		again, err := LoadDict(d.Encode())
+		if err != nil {
+			t.Fatalf("reload: %v", err)
+		}
+		if again.TotalEntries() != d.TotalEntries() {
+			t.Fatalf("reload: %d entries, want %d", again.TotalEntries(), d.TotalEntries())
+		}
+	})
+}
+
+func FuzzLoadManifest(f *testing.F) {
+	f.Add((&Manifest{}).Encode())
+	f.Add((&Manifest{Segments: []SegmentInfo{{Len: 300, Frames: 3}, {Len: 49, Frames: 1}}}).Encode())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := LoadManifest(data)
+		if err != nil {
+			return
+		}
+		again, err := LoadManifest(m.Encode())
+		if err != nil {
+			t.Fatalf("reload: %v", err)
+		}
+		if len(again.Segments) != len(m.Segments) {
+			t.Fatalf("reload: %d segments, want %d", len(again.Segments), len(m.Segments))
+		}
+	})
+}
+
+func FuzzParseSessionPayload(f *testing.F) {
+	session, _, _ := fuzzSeedFrames(f)
+	f.Add(session)
+	f.Add(encodeSessionPayloadVersion(&SessionFrame{
+		ActorType: ActorAgent,
+		Turns:     []TurnRecord{{Role: RoleHuman, TsDelta: 3, Text: "v1"}},
+	}, payloadVersion))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = parseSessionPayload(data)
+	})
+}
+
+func FuzzParseCheckpointPayload(f *testing.F) {
+	_, checkpoint, _ := fuzzSeedFrames(f)
+	f.Add(checkpoint)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = parseCheckpointPayload(data)
+	})
+}
+
+func FuzzParseMetaPayload(f *testing.F) {
+	_, _, meta := fuzzSeedFrames(f)
+	f.Add(meta)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = parseMetaPayload(data)
+	})
+}
@@ -0,0 +1,123 @@
+package codec
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Sealed payloads. With encryption on, the compressed payload of every
+// session and checkpoint frame, and dict.bin as a whole, is encrypted with
+// AES-256-GCM under a 32-byte data key:
+//
+//	"RKLE" | version 0x01 | key ID uint32 LE | nonce [12] | ciphertext | tag [16]
+//
+// The header is authenticated as additional data. The key ID is the first
+// four bytes of the key's SHA-256, so a reader holding several keys knows
+// which one to use. Meta frames are never sealed: the hash chain covers the
+// sealed bytes, so verify works without a key. How data keys reach readers
+// is up to the caller.
+
+var sealMagic = []byte("RKLE")
+
+const (
+	sealVersion   = 0x01
+	sealNonceLen  = 12
+	sealHeaderLen = 4 + 1 + 4 + sealNonceLen
+
+	// KeySize is the size of a data key.
+	KeySize = 32
+)
+
+// ErrNoKey is returned for a sealed payload whose key the reader does not
+// have.
+var ErrNoKey = errors.New("encrypted with a key this reader does not have")
+
+// KeyID returns the ID sealed payloads name key by.
+func KeyID(key []byte) uint32 {
+	sum := sha256.Sum256(key)
+	return binary.LittleEndian.Uint32(sum[:4])
+}
+
+// IsSealed reports whether data is a sealed payload.
+func IsSealed(data []byte) bool {
+	return len(data) >= sealHeaderLen && string(data[:4]) == string(sealMagic)
+}
+
+// SealedKeyID returns the ID of the key data is sealed with.
+func SealedKeyID(data []byte) (uint32, bool) {
+	if !IsSealed(data) {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(data[5:9]), true
+}
+
+// sealer encrypts payloads under one data key.
+type sealer struct {
+	id   uint32
+	aead cipher.AEAD
+}
+
+func newSealer(key []byte) (*sealer, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("codec: data key is %d bytes, want %d", len(key), KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("codec: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("codec: %w", err)
+	}
+	return &sealer{id: KeyID(key), aead: aead}, nil
+}
+
+func (s *sealer) seal(plaintext []byte) []byte {
+	out := make([]byte, sealHeaderLen, sealHeaderLen+len(plaintext)+s.aead.Overhead())
+	copy(out, sealMagic)
+	out[4] = sealVersion
+	binary.LittleEndian.PutUint32(out[5:9], s.id)
+	if _, err := rand.Read(out[9:sealHeaderLen]); err != nil {
+		panic("codec: read random nonce: " + err.Error())
+	}
+	return s.aead.Seal(out, out[9:sealHeaderLen], plaintext, out[:sealHeaderLen])
+}
+
+// Seal encrypts plaintext under key.
+func Seal(key, plaintext []byte) ([]byte, error) {
+	s, err := newSealer(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.seal(plaintext), nil
+}
+
+// Open decrypts a sealed payload with the key it names from keys, which
+// maps key IDs to data keys.
+func Open(keys map[uint32][]byte, sealed []byte) ([]byte, error) {
+	id, ok := SealedKeyID(sealed)
+	if !ok {
+		return nil, errors.New("codec: not a sealed payload")
+	}
+	if sealed[4] != sealVersion {
+		return nil, fmt.Errorf("codec: unsupported seal version %d", sealed[4])
+	}
+	key, ok := keys[id]
+	if !ok {
+		return nil, fmt.Errorf("key %08x: %w", id, ErrNoKey)
+	}
+	s, err := newSealer(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := s.aead.Open(nil, sealed[9:sealHeaderLen], sealed[sealHeaderLen:], sealed[:sealHeaderLen])
+	if err != nil {
+		return nil, fmt.Errorf("codec: key %08x: decrypt: %w", id, err)
+	}
+	return plaintext, nil
+}
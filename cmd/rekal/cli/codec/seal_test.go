@@ -0,0 +1,93 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSeal_Roundtrip(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, KeySize)
+	sealed, err := Seal(key, []byte("dict.bin contents"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if !IsSealed(sealed) {
+		t.Fatal("IsSealed = false for sealed payload")
+	}
+	if id, _ := SealedKeyID(sealed); id != KeyID(key) {
+		t.Errorf("SealedKeyID = %08x, want %08x", id, KeyID(key))
+	}
+	if bytes.Contains(sealed, []byte("contents")) {
+		t.Error("plaintext visible in sealed payload")
+	}
+
+	got, err := Open(map[uint32][]byte{KeyID(key): key}, sealed)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if string(got) != "dict.bin contents" {
+		t.Errorf("Open = %q", got)
+	}
+
+	if _, err := Open(nil, sealed); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Open without key: err = %v, want ErrNoKey", err)
+	}
+
+	// The header is authenticated.
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := Open(map[uint32][]byte{KeyID(key): key}, tampered); err == nil {
+		t.Error("Open accepted a tampered payload")
+	}
+}
+
+func TestEncoder_SealsSessionFramesNotMeta(t *testing.T) {
+	key := bytes.Repeat([]byte{9}, KeySize)
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+	if err := enc.SetSealKey(key); err != nil {
+		t.Fatalf("SetSealKey: %v", err)
+	}
+
+	sf := &SessionFrame{
+		CapturedAt: time.Date(2026, 2, 25, 10, 30, 0, 0, time.UTC),
+		Turns:      []TurnRecord{{Role: RoleHuman, Text: "a secret prompt"}},
+	}
+	body := AppendFrame(NewBody(), enc.EncodeSessionFrame(sf))
+	body = AppendFrame(body, enc.EncodeMetaFrame(&MetaFrame{FormatVersion: 1, Timestamp: time.Unix(0, 0).UTC()}))
+	frames, err := ScanFrames(body)
+	if err != nil || len(frames) != 2 {
+		t.Fatalf("ScanFrames = %d frames, %v", len(frames), err)
+	}
+	if !IsSealed(ExtractFramePayload(body, frames[0])) {
+		t.Error("session frame not sealed")
+	}
+	if IsSealed(ExtractFramePayload(body, frames[1])) {
+		t.Error("meta frame sealed")
+	}
+
+	dec, err := NewDecoder()
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	defer dec.Close()
+	if _, err := dec.DecodeMetaFrame(ExtractFramePayload(body, frames[1])); err != nil {
+		t.Errorf("meta frame without key: %v", err)
+	}
+	if _, err := dec.DecodeSessionFrame(ExtractFramePayload(body, frames[0])); !errors.Is(err, ErrNoKey) {
+		t.Errorf("session frame without key: err = %v, want ErrNoKey", err)
+	}
+	dec.AddKeys(map[uint32][]byte{KeyID(key): key})
+	got, err := dec.DecodeSessionFrame(ExtractFramePayload(body, frames[0]))
+	if err != nil {
+		t.Fatalf("session frame with key: %v", err)
+	}
+	if got.Turns[0].Text != "a secret prompt" {
+		t.Errorf("turn = %q", got.Turns[0].Text)
+	}
+}
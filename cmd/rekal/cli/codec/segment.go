@@ -0,0 +1,167 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// A segmented body stores rekal.body as immutable segment files under
+// body/ instead of one file that is rewritten on every push:
+//
+//	body/manifest     segment list
+//	body/000000.rkb   body header + frames
+//	body/000001.rkb   same header + the frames that follow
+//	...
+//
+// Every segment starts with the same 9-byte body header. The logical body
+// is the first segment followed by the frames of the others, byte for byte
+// what a single rekal.body would hold, so offsets, checksums and the hash
+// chain are unchanged. A push only adds segments; segments already
+// committed are never rewritten.
+
+const (
+	// SegmentDir is the tree entry that holds segments and the manifest.
+	SegmentDir = "body"
+	// ManifestName is the manifest file within SegmentDir.
+	ManifestName = "manifest"
+	// SegmentSize is the rollover size: new frames are cut into segments
+	// of at most this many bytes. A single larger frame gets its own.
+	SegmentSize = 1 << 20
+
+	manifestMagic   = "RKMANIF"
+	manifestVersion = 0x01
+	manifestHdrSize = 12 // 7 magic + 1 version + 4 n_segments
+	manifestEntSize = 8  // 4 byte_len + 4 n_frames
+)
+
+// SegmentName returns the file name of segment i within SegmentDir.
+func SegmentName(i int) string {
+	return fmt.Sprintf("%06d.rkb", i)
+}
+
+// SegmentInfo describes one segment in the manifest.
+type SegmentInfo struct {
+	Len    int // bytes, including the body header
+	Frames int
+}
+
+// Manifest lists a body's segments in order.
+type Manifest struct {
+	Segments []SegmentInfo
+}
+
+// Frames returns the number of frames across all segments.
+func (m *Manifest) Frames() int {
+	n := 0
+	for _, s := range m.Segments {
+		n += s.Frames
+	}
+	return n
+}
+
+// Encode serializes the manifest.
+func (m *Manifest) Encode() []byte {
+	buf := make([]byte, 0, manifestHdrSize+len(m.Segments)*manifestEntSize)
+	buf = append(buf, manifestMagic...)
+	buf = append(buf, manifestVersion)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(m.Segments)))
+	for _, s := range m.Segments {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(s.Len))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(s.Frames))
+	}
+	return buf
+}
+
+// LoadManifest parses a manifest.
+func LoadManifest(data []byte) (*Manifest, error) {
+	if len(data) < manifestHdrSize {
+		return nil, errors.New("manifest: data too short for header")
+	}
+	if magic := string(data[0:7]); magic != manifestMagic {
+		return nil, fmt.Errorf("manifest: bad magic %q, want %q", magic, manifestMagic)
+	}
+	if data[7] != manifestVersion {
+		return nil, fmt.Errorf("manifest: unsupported version %d", data[7])
+	}
+	n := int(binary.LittleEndian.Uint32(data[8:12]))
+	if n > (len(data)-manifestHdrSize)/manifestEntSize {
+		return nil, fmt.Errorf("manifest: %d segments do not fit in %d bytes", n, len(data))
+	}
+	m := &Manifest{Segments: make([]SegmentInfo, n)}
+	pos := manifestHdrSize
+	for i := range m.Segments {
+		m.Segments[i] = SegmentInfo{
+			Len:    int(binary.LittleEndian.Uint32(data[pos:])),
+			Frames: int(binary.LittleEndian.Uint32(data[pos+4:])),
+		}
+		pos += manifestEntSize
+	}
+	return m, nil
+}
+
+// JoinSegments returns the logical body of segs, which must be in manifest
+// order and share one body header. Returns nil for no segments.
+func JoinSegments(segs [][]byte) ([]byte, error) {
+	if len(segs) == 0 {
+		return nil, nil
+	}
+	size := 0
+	for i, s := range segs {
+		if len(s) < BodyHeaderSize {
+			return nil, fmt.Errorf("segment %d: data too short for header", i)
+		}
+		if !bytes.Equal(s[:BodyHeaderSize], segs[0][:BodyHeaderSize]) {
+			return nil, fmt.Errorf("segment %d: header differs from segment 0", i)
+		}
+		size += len(s) - BodyHeaderSize
+	}
+	body := make([]byte, 0, BodyHeaderSize+size)
+	body = append(body, segs[0]...)
+	for _, s := range segs[1:] {
+		body = append(body, s[BodyHeaderSize:]...)
+	}
+	return body, nil
+}
+
+// Segment is a segment file and the number of frames in it.
+type Segment struct {
+	Data   []byte
+	Frames int
+}
+
+// SplitBody cuts the frames of body at or after offset from into segments
+// of at most maxLen bytes, each prefixed with body's header. from must be a
+// frame boundary, normally the length of the body already committed.
+func SplitBody(body []byte, from, maxLen int) ([]Segment, error) {
+	frames, err := ScanFrames(body)
+	if err != nil {
+		return nil, err
+	}
+	hdr := body[:BodyHeaderSize]
+
+	var segs []Segment
+	var cur *Segment
+	next := from
+	for _, fs := range frames {
+		if fs.Offset < from {
+			continue
+		}
+		if fs.Offset != next {
+			return nil, fmt.Errorf("split body: unreadable bytes at offset %d", next)
+		}
+		end := fs.PayloadOffset + fs.CompressedLen
+		if cur == nil || len(cur.Data)+end-fs.Offset > maxLen {
+			segs = append(segs, Segment{Data: append([]byte{}, hdr...)})
+			cur = &segs[len(segs)-1]
+		}
+		cur.Data = append(cur.Data, body[fs.Offset:end]...)
+		cur.Frames++
+		next = end
+	}
+	if next != len(body) {
+		return nil, fmt.Errorf("split body: unreadable bytes at offset %d", next)
+	}
+	return segs, nil
+}
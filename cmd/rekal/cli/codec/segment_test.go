@@ -0,0 +1,99 @@
+package codec
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestManifest_Roundtrip(t *testing.T) {
+	m := &Manifest{Segments: []SegmentInfo{{Len: 9 + 300, Frames: 3}, {Len: 9 + 40, Frames: 1}}}
+	got, err := LoadManifest(m.Encode())
+	if err != nil {
+		t.Fatalf("LoadManifest: %v", err)
+	}
+	if len(got.Segments) != 2 || got.Segments[0] != m.Segments[0] || got.Segments[1] != m.Segments[1] {
+		t.Errorf("segments: got %+v, want %+v", got.Segments, m.Segments)
+	}
+	if got.Frames() != 4 {
+		t.Errorf("Frames: got %d, want 4", got.Frames())
+	}
+
+	empty, err := LoadManifest((&Manifest{}).Encode())
+	if err != nil || len(empty.Segments) != 0 {
+		t.Errorf("empty manifest: %+v, %v", empty, err)
+	}
+
+	// A count that does not fit in the data is rejected, not allocated.
+	bad := m.Encode()
+	bad[8] = 0xFF
+	if _, err := LoadManifest(bad); err == nil {
+		t.Error("LoadManifest should reject a segment count beyond the data")
+	}
+	if _, err := LoadManifest([]byte("RKDICT\x02\x00\x00\x00\x00\x00")); err == nil {
+		t.Error("LoadManifest should reject a bad magic")
+	}
+}
+
+func TestSplitAndJoinSegments(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+
+	body := NewBody()
+	for i := 0; i < 5; i++ {
+		sf := &SessionFrame{SessionRef: uint64(i), Turns: []TurnRecord{{Text: strings.Repeat("x", 100*i)}}}
+		body = AppendFrame(body, enc.EncodeSessionFrame(sf))
+	}
+	frames, err := ScanFrames(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Frames 0-1 are committed; cut the rest into segments of at most one
+	// frame plus a little, so each frame gets its own segment.
+	committed := frames[2].Offset
+	maxLen := BodyHeaderSize + frames[4].PayloadOffset + frames[4].CompressedLen - frames[4].Offset
+	segs, err := SplitBody(body, committed, maxLen)
+	if err != nil {
+		t.Fatalf("SplitBody: %v", err)
+	}
+	if len(segs) != 3 {
+		t.Fatalf("SplitBody: got %d segments, want 3", len(segs))
+	}
+	for i, s := range segs {
+		if s.Frames != 1 || len(s.Data) > maxLen || !bytes.Equal(s.Data[:BodyHeaderSize], body[:BodyHeaderSize]) {
+			t.Errorf("segment %d: %d frames, %d bytes", i, s.Frames, len(s.Data))
+		}
+	}
+
+	// The committed prefix plus the new segments join back into body.
+	parts := [][]byte{body[:committed]}
+	for _, s := range segs {
+		parts = append(parts, s.Data)
+	}
+	joined, err := JoinSegments(parts)
+	if err != nil {
+		t.Fatalf("JoinSegments: %v", err)
+	}
+	if !bytes.Equal(joined, body) {
+		t.Error("joined segments differ from the body")
+	}
+
+	// Nothing new: no segments.
+	if segs, err := SplitBody(body, len(body), SegmentSize); err != nil || len(segs) != 0 {
+		t.Errorf("SplitBody at end: %d segments, %v", len(segs), err)
+	}
+	// A cut inside a frame is rejected.
+	if _, err := SplitBody(body, committed+1, SegmentSize); err == nil {
+		t.Error("SplitBody should reject an offset that is not a frame boundary")
+	}
+	// Segments must share a header.
+	v1 := append([]byte{}, segs[0].Data...)
+	v1[7] = bodyVersionV1
+	if _, err := JoinSegments([][]byte{body[:committed], v1}); err == nil {
+		t.Error("JoinSegments should reject segments with different headers")
+	}
+}
@@ -0,0 +1,178 @@
+package codec
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Frames name the zstd dictionary they were compressed with by ID in the
+// zstd frame header, so a Decoder that knows every dictionary of a branch
+// decodes any frame. The embedded preset is always known; a branch can add
+// dictionaries trained on its own payloads with TrainDict.
+
+const (
+	// trainDmer is the substring length whose frequency across samples
+	// scores a segment.
+	trainDmer = 8
+	// trainSegment is the length of the segments the dictionary content
+	// is assembled from.
+	trainSegment = 64
+	// trainStep is the distance between candidate segment starts.
+	trainStep = 16
+
+	// dictIDMin is the first dictionary ID outside the range zstd reserves
+	// for registered dictionaries.
+	dictIDMin = 32768
+)
+
+// trainOffsets are the repeat offsets a trained dictionary starts with: the
+// defaults of the zstd format.
+var trainOffsets = [3]int{1, 4, 8}
+
+// DictID returns the ID of a zstd dictionary.
+func DictID(dict []byte) (uint32, error) {
+	d, err := zstd.InspectDictionary(dict)
+	if err != nil {
+		return 0, fmt.Errorf("codec: %w", err)
+	}
+	return d.ID(), nil
+}
+
+// PresetDictID returns the ID of the embedded preset dictionary, or 0 if
+// there is none.
+func PresetDictID() uint32 {
+	if len(presetDict) == 0 {
+		return 0
+	}
+	id, _ := DictID(presetDict)
+	return id
+}
+
+// TrainDict builds a zstd dictionary with about size bytes of content for
+// payloads like samples. The content is chosen with a simplified COVER:
+// every trainSegment-byte window of the samples is scored by how many
+// samples share its trainDmer-byte substrings, and the best windows are
+// taken greedily, skipping substrings already covered. The entropy tables
+// are then built by zstd from the samples. The ID is derived from the
+// content, so the same samples give the same dictionary.
+func TrainDict(samples [][]byte, size int) ([]byte, error) {
+	if size < trainSegment {
+		return nil, fmt.Errorf("codec: dictionary size %d is below %d bytes", size, trainSegment)
+	}
+
+	// Number of samples each d-mer occurs in.
+	freq := make(map[uint64]int)
+	for _, s := range samples {
+		seen := make(map[uint64]bool)
+		for i := 0; i+trainDmer <= len(s); i++ {
+			h := binary.LittleEndian.Uint64(s[i:])
+			if !seen[h] {
+				seen[h] = true
+				freq[h]++
+			}
+		}
+	}
+
+	type candidate struct {
+		sample, start int
+		score         int
+	}
+	score := func(seg []byte) int {
+		n := 0
+		seen := make(map[uint64]bool)
+		for i := 0; i+trainDmer <= len(seg); i++ {
+			h := binary.LittleEndian.Uint64(seg[i:])
+			if f := freq[h]; f > 1 && !seen[h] {
+				seen[h] = true
+				n += f
+			}
+		}
+		return n
+	}
+	var cands []candidate
+	for si, s := range samples {
+		for start := 0; start+trainSegment <= len(s); start += trainStep {
+			if sc := score(s[start : start+trainSegment]); sc > 0 {
+				cands = append(cands, candidate{si, start, sc})
+			}
+		}
+	}
+	sort.SliceStable(cands, func(i, j int) bool { return cands[i].score > cands[j].score })
+
+	// Take the best segments while they still add enough new d-mers.
+	var picked [][]byte
+	total := 0
+	for _, c := range cands {
+		if total+trainSegment > size {
+			break
+		}
+		seg := samples[c.sample][c.start : c.start+trainSegment]
+		if score(seg)*2 < c.score {
+			continue
+		}
+		picked = append(picked, seg)
+		total += len(seg)
+		for i := 0; i+trainDmer <= len(seg); i++ {
+			delete(freq, binary.LittleEndian.Uint64(seg[i:]))
+		}
+	}
+	if len(picked) == 0 {
+		return nil, errors.New("codec: samples have no repeated content to train on")
+	}
+
+	// zstd prefers matches close to the data, so the best segments go last.
+	history := make([]byte, 0, total)
+	for i := len(picked) - 1; i >= 0; i-- {
+		history = append(history, picked[i]...)
+	}
+
+	sum := sha256.Sum256(history)
+	id := dictIDMin + binary.LittleEndian.Uint32(sum[:])%(1<<31-dictIDMin)
+	dict, err := zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       id,
+		Contents: samples,
+		History:  history,
+		Offsets:  trainOffsets,
+		Level:    zstd.SpeedDefault,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("codec: build dictionary: %w", err)
+	}
+	return withOffsets(dict, trainOffsets)
+}
+
+// withOffsets returns dict with offsets as its repeat offsets. BuildDict
+// replaces the offsets it is given with the ones the samples use most, and
+// breaks ties between them in map order, so the same samples could give
+// different bytes. The dictionary is reassembled from its parts as the zstd
+// format lays them out — entropy tables, three offsets, content — and
+// parsed again, so a layout this does not expect is an error.
+func withOffsets(dict []byte, offsets [3]int) ([]byte, error) {
+	d, err := zstd.InspectDictionary(dict)
+	if err != nil {
+		return nil, fmt.Errorf("codec: build dictionary: %w", err)
+	}
+	tables := len(dict) - d.ContentSize() - 4*len(offsets)
+	if tables < 8 {
+		return nil, errors.New("codec: build dictionary: unexpected layout")
+	}
+
+	out := make([]byte, 0, len(dict))
+	out = append(out, dict[:tables]...)
+	for _, o := range offsets {
+		out = binary.LittleEndian.AppendUint32(out, uint32(o))
+	}
+	out = append(out, d.Content()...)
+
+	check, err := zstd.InspectDictionary(out)
+	if err != nil || check.ID() != d.ID() || check.Offsets() != offsets || !bytes.Equal(check.Content(), d.Content()) {
+		return nil, errors.New("codec: build dictionary: unexpected layout")
+	}
+	return out, nil
+}
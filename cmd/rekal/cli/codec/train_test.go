@@ -0,0 +1,167 @@
+package codec
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// trainSamples returns session payloads that share a project's vocabulary
+// but differ in their details, like the frames of one repository.
+func trainSamples(n int) [][]byte {
+	var samples [][]byte
+	for i := 0; i < n; i++ {
+		sf := &SessionFrame{
+			SessionRef: uint64(i),
+			CapturedAt: time.Date(2026, 3, 1, 9, i%60, 0, 0, time.UTC),
+			ActorType:  ActorHuman,
+			Turns: []TurnRecord{
+				{Role: RoleHuman, Text: fmt.Sprintf("the rekal sync of branch feature-%d fails on the duckdb index rebuild", i)},
+				{Role: RoleAssistant, Text: fmt.Sprintf("Let me read cmd/rekal/cli/sync_remote.go and check importBranchToIndex around line %d.", 100+i)},
+				{Role: RoleAssistant, Text: "The index rebuild drops turns_ft before the facets are written, so the remote sessions are missing."},
+			},
+			ToolCalls: []ToolCallRecord{
+				{Tool: ToolRead, PathFlag: PathInline, PathInline: "cmd/rekal/cli/sync_remote.go"},
+				{Tool: ToolBash, PathFlag: PathNull, CmdPrefix: "go test ./cmd/rekal/cli/..."},
+			},
+		}
+		samples = append(samples, encodeSessionPayload(sf))
+	}
+	return samples
+}
+
+func TestTrainDict_SmallerThanPreset(t *testing.T) {
+	samples := trainSamples(64)
+	dict, err := TrainDict(samples, 4096)
+	if err != nil {
+		t.Fatalf("TrainDict: %v", err)
+	}
+	id, err := DictID(dict)
+	if err != nil {
+		t.Fatalf("DictID: %v", err)
+	}
+	if id < dictIDMin || id == PresetDictID() {
+		t.Errorf("dict id %d: want >= %d and not the preset's", id, dictIDMin)
+	}
+
+	again, err := TrainDict(samples, 4096)
+	if err != nil {
+		t.Fatalf("TrainDict again: %v", err)
+	}
+	if string(again) != string(dict) {
+		t.Error("TrainDict is not deterministic")
+	}
+	info, err := zstd.InspectDictionary(dict)
+	if err != nil {
+		t.Fatalf("InspectDictionary: %v", err)
+	}
+	if got := info.Offsets(); got != trainOffsets {
+		t.Errorf("repeat offsets = %v, want %v", got, trainOffsets)
+	}
+
+	preset, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer preset.Close()
+	trained, err := NewEncoderWithDict(dict)
+	if err != nil {
+		t.Fatalf("NewEncoderWithDict: %v", err)
+	}
+	defer trained.Close()
+	if trained.DictID() != id {
+		t.Errorf("encoder dict id: got %d, want %d", trained.DictID(), id)
+	}
+
+	var before, after int
+	for _, s := range samples {
+		before += len(preset.Compress(s))
+		after += len(trained.Compress(s))
+	}
+	if after >= before {
+		t.Errorf("trained dict: %d bytes, preset %d bytes; want smaller", after, before)
+	}
+}
+
+func TestDecoder_SelectsDictPerFrame(t *testing.T) {
+	dict, err := TrainDict(trainSamples(32), 2048)
+	if err != nil {
+		t.Fatalf("TrainDict: %v", err)
+	}
+	preset, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer preset.Close()
+	trained, err := NewEncoderWithDict(dict)
+	if err != nil {
+		t.Fatalf("NewEncoderWithDict: %v", err)
+	}
+	defer trained.Close()
+
+	sf := &SessionFrame{
+		CapturedAt: time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC),
+		ActorType:  ActorHuman,
+		Turns:      []TurnRecord{{Role: RoleHuman, Text: "rebuild the duckdb index"}},
+	}
+	old := preset.EncodeSessionFrame(sf)[frameEnvSize:]
+	cur := trained.EncodeSessionFrame(sf)[frameEnvSize:]
+
+	dec, err := NewDecoder(dict)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	defer dec.Close()
+	for name, compressed := range map[string][]byte{"preset": old, "trained": cur} {
+		got, err := dec.DecodeSessionFrame(compressed)
+		if err != nil {
+			t.Fatalf("%s: decode: %v", name, err)
+		}
+		if got.Turns[0].Text != sf.Turns[0].Text {
+			t.Errorf("%s: text %q", name, got.Turns[0].Text)
+		}
+	}
+
+	// Without the trained dictionary the new frame cannot be read.
+	plain, err := NewDecoder()
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	defer plain.Close()
+	if _, err := plain.DecodeSessionFrame(cur); err == nil {
+		t.Error("decoded a trained-dict frame without its dictionary")
+	}
+}
+
+func TestMetaFrame_DictID(t *testing.T) {
+	enc, err := NewEncoder()
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	defer enc.Close()
+	dec, err := NewDecoder()
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	defer dec.Close()
+
+	h := NextChain(ChainHash{}, []byte("frame"))
+	encoded := enc.EncodeMetaFrame(&MetaFrame{FormatVersion: 1, Chained: true, Chain: h, NFrames: 3, DictID: 0x12345678})
+	mf, err := dec.DecodeMetaFrame(encoded[frameEnvSize:])
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if mf.DictID != 0x12345678 {
+		t.Errorf("dict id: got %#x, want 0x12345678", mf.DictID)
+	}
+	if !mf.Chained || mf.Chain != h {
+		t.Errorf("chain: got %v/%s, want true/%s", mf.Chained, mf.Chain, h)
+	}
+
+	encoded = enc.EncodeMetaFrame(&MetaFrame{FormatVersion: 1, Chained: true, Chain: h})
+	if mf, err = dec.DecodeMetaFrame(encoded[frameEnvSize:]); err != nil || mf.DictID != 0 {
+		t.Errorf("v2 meta: dict id %d, err %v", mf.DictID, err)
+	}
+}
@@ -0,0 +1,104 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBech32_Vectors(t *testing.T) {
+	for _, s := range []string{
+		"A12UEL5L",
+		"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+	} {
+		hrp, data, err := bech32Decode(s)
+		if err != nil {
+			t.Errorf("decode %q: %v", s, err)
+			continue
+		}
+		got, err := bech32Encode(hrp, data)
+		if err != nil || !strings.EqualFold(got, s) {
+			t.Errorf("encode(decode(%q)) = %q, %v", s, got, err)
+		}
+	}
+	if _, _, err := bech32Decode("abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxx"); err == nil {
+		t.Error("bad checksum accepted")
+	}
+}
+
+func TestKeys_Roundtrip(t *testing.T) {
+	id, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(id.String(), "AGE-SECRET-KEY-1") {
+		t.Errorf("identity = %q", id)
+	}
+	parsed, err := ParseIdentity(id.String())
+	if err != nil {
+		t.Fatalf("ParseIdentity: %v", err)
+	}
+	if !parsed.Recipient().Equal(id.Recipient()) {
+		t.Error("parsed identity has a different recipient")
+	}
+
+	r := id.Recipient().String()
+	if !strings.HasPrefix(r, "age1") {
+		t.Errorf("recipient = %q", r)
+	}
+	file := "# team\n" + r + " # alice\n\n" + r + "\n"
+	rcpts, err := ParseRecipients(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("ParseRecipients: %v", err)
+	}
+	if len(rcpts) != 1 || !rcpts[0].Equal(id.Recipient()) {
+		t.Errorf("ParseRecipients = %v, want one recipient", rcpts)
+	}
+	if _, err := ParseRecipients(strings.NewReader("# nobody\n")); err == nil {
+		t.Error("empty recipients file accepted")
+	}
+}
+
+func TestKeys_AgeCompatible(t *testing.T) {
+	// age's test key: the scalar 0x42 repeated.
+	id, err := ParseIdentity("AGE-SECRET-KEY-1GFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPQ4EGAEX")
+	if err != nil {
+		t.Fatalf("ParseIdentity: %v", err)
+	}
+	if got, want := id.Recipient().String(), "age1zvkyg2lqzraa2lnjvqej32nkuu0ues2s82hzrye869xeexvn73equnujwj"; got != want {
+		t.Errorf("recipient = %s, want %s", got, want)
+	}
+}
+
+func TestWrap_Unwrap(t *testing.T) {
+	alice, _ := GenerateIdentity()
+	bob, _ := GenerateIdentity()
+	eve, _ := GenerateIdentity()
+	key := bytes.Repeat([]byte{42}, 32)
+
+	wrapped, err := Wrap(key, []*Recipient{alice.Recipient(), bob.Recipient()})
+	if err != nil {
+		t.Fatalf("Wrap: %v", err)
+	}
+	for _, id := range []*Identity{alice, bob} {
+		got, err := Unwrap(wrapped, []*Identity{eve, id})
+		if err != nil || !bytes.Equal(got, key) {
+			t.Errorf("Unwrap = %x, %v", got, err)
+		}
+	}
+	if _, err := Unwrap(wrapped, []*Identity{eve}); !errors.Is(err, ErrNotRecipient) {
+		t.Errorf("Unwrap as non-recipient: err = %v, want ErrNotRecipient", err)
+	}
+
+	rcpts, err := WrappedRecipients(wrapped)
+	if err != nil {
+		t.Fatalf("WrappedRecipients: %v", err)
+	}
+	if !SameRecipients(rcpts, []*Recipient{bob.Recipient(), alice.Recipient()}) {
+		t.Error("WrappedRecipients differs from the list wrapped for")
+	}
+	if SameRecipients(rcpts, []*Recipient{alice.Recipient()}) {
+		t.Error("SameRecipients ignores a missing recipient")
+	}
+}
@@ -0,0 +1,157 @@
+// Package crypt handles the keys rekal encrypts wire frames for: X25519
+// identities and recipients, written as age writes them, and data keys
+// wrapped for a list of recipients. Frames themselves are sealed by the
+// codec package.
+package crypt
+
+import (
+	"bufio"
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Key string prefixes, the same as age's: a key made with age-keygen works
+// with rekal and the other way round.
+const (
+	identityHRP  = "AGE-SECRET-KEY-"
+	recipientHRP = "age"
+)
+
+// Identity is an X25519 private key that unwraps data keys.
+type Identity struct {
+	key *ecdh.PrivateKey
+}
+
+// Recipient is an X25519 public key data keys are wrapped for.
+type Recipient struct {
+	key *ecdh.PublicKey
+}
+
+// GenerateIdentity returns a new random identity.
+func GenerateIdentity() (*Identity, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: generate identity: %w", err)
+	}
+	return &Identity{key: key}, nil
+}
+
+// ParseIdentity parses an AGE-SECRET-KEY-1… string.
+func ParseIdentity(s string) (*Identity, error) {
+	hrp, data, err := bech32Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: malformed identity: %w", err)
+	}
+	if hrp != strings.ToLower(identityHRP) {
+		return nil, fmt.Errorf("crypt: malformed identity: unexpected prefix %q", hrp)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: malformed identity: %w", err)
+	}
+	return &Identity{key: key}, nil
+}
+
+// String returns the identity as an AGE-SECRET-KEY-1… string.
+func (i *Identity) String() string {
+	s, _ := bech32Encode(identityHRP, i.key.Bytes())
+	return s
+}
+
+// Recipient returns the public key of the identity.
+func (i *Identity) Recipient() *Recipient {
+	return &Recipient{key: i.key.PublicKey()}
+}
+
+// ParseRecipient parses an age1… string.
+func ParseRecipient(s string) (*Recipient, error) {
+	hrp, data, err := bech32Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: malformed recipient %q: %w", s, err)
+	}
+	if hrp != recipientHRP {
+		return nil, fmt.Errorf("crypt: malformed recipient %q: unexpected prefix %q", s, hrp)
+	}
+	key, err := ecdh.X25519().NewPublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: malformed recipient %q: %w", s, err)
+	}
+	return &Recipient{key: key}, nil
+}
+
+// String returns the recipient as an age1… string.
+func (r *Recipient) String() string {
+	s, _ := bech32Encode(recipientHRP, r.key.Bytes())
+	return s
+}
+
+// Equal reports whether r and o are the same key.
+func (r *Recipient) Equal(o *Recipient) bool {
+	return r.key.Equal(o.key)
+}
+
+// ParseIdentities parses an identity file: one identity per line; blank
+// lines and lines starting with # are ignored.
+func ParseIdentities(r io.Reader) ([]*Identity, error) {
+	var ids []*Identity
+	err := parseKeyLines(r, func(line string) error {
+		id, err := ParseIdentity(line)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+		return nil
+	})
+	return ids, err
+}
+
+// ParseRecipients parses a recipients file: one recipient per line,
+// optionally followed by a # comment; blank lines and lines starting with #
+// are ignored. Duplicates are dropped.
+func ParseRecipients(r io.Reader) ([]*Recipient, error) {
+	var rcpts []*Recipient
+	err := parseKeyLines(r, func(line string) error {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		rcpt, err := ParseRecipient(line)
+		if err != nil {
+			return err
+		}
+		for _, seen := range rcpts {
+			if seen.Equal(rcpt) {
+				return nil
+			}
+		}
+		rcpts = append(rcpts, rcpt)
+		return nil
+	})
+	return rcpts, err
+}
+
+func parseKeyLines(r io.Reader, parse func(line string) error) error {
+	sc := bufio.NewScanner(r)
+	n, keys := 0, 0
+	for sc.Scan() {
+		n++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := parse(line); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		keys++
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if keys == 0 {
+		return errors.New("crypt: no keys")
+	}
+	return nil
+}
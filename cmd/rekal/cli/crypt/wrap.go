@@ -0,0 +1,182 @@
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// A wrapped key is a data key encrypted for each of a list of recipients:
+//
+//	"RKLK" | version 0x01 | count uvarint | count × stanza
+//	stanza: recipient [32] | ephemeral share [32] | encrypted key [48]
+//
+// Each stanza is an X25519 exchange between a fresh ephemeral key and the
+// recipient. HKDF-SHA256 over the shared secret, salted with the ephemeral
+// share and the recipient, gives a single-use AES-256-GCM key that encrypts
+// the data key under a zero nonce. Recipients are stored in the clear —
+// they are public keys, committed to the repository anyway — so writers can
+// tell which list a key was wrapped for and readers which stanza is theirs.
+
+var wrapMagic = []byte("RKLK")
+
+const (
+	wrapVersion   = 0x01
+	wrapKeySize   = 32
+	wrapStanzaLen = 32 + 32 + wrapKeySize + 16
+	wrapInfo      = "rekal key wrap v1"
+)
+
+// ErrNotRecipient is returned by Unwrap when none of the identities is a
+// recipient of the key.
+var ErrNotRecipient = errors.New("not a recipient")
+
+type stanza struct {
+	recipient, share, encrypted []byte
+}
+
+// Wrap encrypts the data key for each recipient.
+func Wrap(key []byte, recipients []*Recipient) ([]byte, error) {
+	if len(key) != wrapKeySize {
+		return nil, fmt.Errorf("crypt: data key is %d bytes, want %d", len(key), wrapKeySize)
+	}
+	if len(recipients) == 0 {
+		return nil, errors.New("crypt: no recipients")
+	}
+	out := append([]byte{}, wrapMagic...)
+	out = append(out, wrapVersion)
+	out = binary.AppendUvarint(out, uint64(len(recipients)))
+	for _, r := range recipients {
+		eph, err := GenerateIdentity()
+		if err != nil {
+			return nil, err
+		}
+		shared, err := eph.key.ECDH(r.key)
+		if err != nil {
+			return nil, fmt.Errorf("crypt: %s: %w", r, err)
+		}
+		share := eph.key.PublicKey().Bytes()
+		aead, err := stanzaAEAD(shared, share, r.key.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r.key.Bytes()...)
+		out = append(out, share...)
+		out = aead.Seal(out, make([]byte, aead.NonceSize()), key, nil)
+	}
+	return out, nil
+}
+
+// Unwrap returns the data key, decrypted with the first identity that is a
+// recipient.
+func Unwrap(wrapped []byte, identities []*Identity) ([]byte, error) {
+	stanzas, err := parseWrapped(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range identities {
+		pub := id.key.PublicKey().Bytes()
+		for _, s := range stanzas {
+			if string(s.recipient) != string(pub) {
+				continue
+			}
+			share, err := ecdh.X25519().NewPublicKey(s.share)
+			if err != nil {
+				return nil, fmt.Errorf("crypt: malformed wrapped key: %w", err)
+			}
+			shared, err := id.key.ECDH(share)
+			if err != nil {
+				return nil, fmt.Errorf("crypt: %w", err)
+			}
+			aead, err := stanzaAEAD(shared, s.share, s.recipient)
+			if err != nil {
+				return nil, err
+			}
+			key, err := aead.Open(nil, make([]byte, aead.NonceSize()), s.encrypted, nil)
+			if err != nil {
+				return nil, fmt.Errorf("crypt: unwrap key: %w", err)
+			}
+			return key, nil
+		}
+	}
+	return nil, ErrNotRecipient
+}
+
+// WrappedRecipients returns the recipients a key was wrapped for.
+func WrappedRecipients(wrapped []byte) ([]*Recipient, error) {
+	stanzas, err := parseWrapped(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	rcpts := make([]*Recipient, 0, len(stanzas))
+	for _, s := range stanzas {
+		key, err := ecdh.X25519().NewPublicKey(s.recipient)
+		if err != nil {
+			return nil, fmt.Errorf("crypt: malformed wrapped key: %w", err)
+		}
+		rcpts = append(rcpts, &Recipient{key: key})
+	}
+	return rcpts, nil
+}
+
+// SameRecipients reports whether a and b hold the same keys, in any order.
+func SameRecipients(a, b []*Recipient) bool {
+	contains := func(list []*Recipient, r *Recipient) bool {
+		for _, x := range list {
+			if x.Equal(r) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, r := range a {
+		if !contains(b, r) {
+			return false
+		}
+	}
+	for _, r := range b {
+		if !contains(a, r) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseWrapped(wrapped []byte) ([]stanza, error) {
+	if len(wrapped) < len(wrapMagic)+1 || string(wrapped[:len(wrapMagic)]) != string(wrapMagic) {
+		return nil, errors.New("crypt: not a wrapped key")
+	}
+	if v := wrapped[len(wrapMagic)]; v != wrapVersion {
+		return nil, fmt.Errorf("crypt: unsupported wrapped key version %d", v)
+	}
+	rest := wrapped[len(wrapMagic)+1:]
+	n, k := binary.Uvarint(rest)
+	if k <= 0 || n == 0 || uint64(len(rest)-k) != n*wrapStanzaLen {
+		return nil, errors.New("crypt: malformed wrapped key")
+	}
+	rest = rest[k:]
+	stanzas := make([]stanza, n)
+	for i := range stanzas {
+		s := rest[i*wrapStanzaLen : (i+1)*wrapStanzaLen]
+		stanzas[i] = stanza{recipient: s[:32], share: s[32:64], encrypted: s[64:]}
+	}
+	return stanzas, nil
+}
+
+func stanzaAEAD(shared, share, recipient []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, share...), recipient...)
+	key, err := hkdf.Key(sha256.New, shared, salt, wrapInfo, 32)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
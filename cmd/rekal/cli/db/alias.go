@@ -0,0 +1,142 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/session"
+)
+
+// ResolveSessionID returns the ID of the session row that holds session id:
+// the session id is an alias of, else id itself if there is such a row. A
+// merged duplicate resolves to the session it was merged into. Returns "" if
+// neither exists.
+func ResolveSessionID(d *sql.DB, id string) (string, error) {
+	var resolved sql.NullString
+	err := d.QueryRow(
+		`SELECT COALESCE(
+			(SELECT session_id FROM session_aliases WHERE alias = $1),
+			(SELECT id FROM sessions WHERE id = $1))`, id,
+	).Scan(&resolved)
+	if err != nil {
+		return "", fmt.Errorf("resolve session id: %w", err)
+	}
+	return resolved.String, nil
+}
+
+// InsertSessionAlias records alias as another ID of session sessionID. An
+// existing alias is left unchanged.
+func InsertSessionAlias(d Execer, alias, sessionID string) error {
+	if _, err := d.Exec(
+		`INSERT INTO session_aliases (alias, session_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		alias, sessionID,
+	); err != nil {
+		return fmt.Errorf("insert session alias: %w", err)
+	}
+	return nil
+}
+
+// mergeDuplicateSessions aliases sessions captured before content IDs.
+// Sessions with the same content are merged into the earliest captured one,
+// and the content ID of every session stored under another ID is aliased
+// to it, so later captures and imports of the same transcript find it.
+func mergeDuplicateSessions(tx *sql.Tx, _ MigrateContext) error {
+	type stored struct {
+		id      string
+		payload session.SessionPayload
+	}
+	var sessions []*stored
+	byID := make(map[string]*stored)
+
+	rows, err := tx.Query(`SELECT id, COALESCE(source, '') FROM sessions ORDER BY captured_at, id`)
+	if err != nil {
+		return fmt.Errorf("read sessions: %w", err)
+	}
+	for rows.Next() {
+		s := &stored{}
+		if err := rows.Scan(&s.id, &s.payload.Source); err != nil {
+			rows.Close() //nolint:errcheck
+			return fmt.Errorf("scan session: %w", err)
+		}
+		sessions = append(sessions, s)
+		byID[s.id] = s
+	}
+	rows.Close() //nolint:errcheck
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = tx.Query(`SELECT session_id, role, content, ts FROM turns ORDER BY session_id, turn_index`)
+	if err != nil {
+		return fmt.Errorf("read turns: %w", err)
+	}
+	for rows.Next() {
+		var sid string
+		var t session.Turn
+		var ts sql.NullTime
+		if err := rows.Scan(&sid, &t.Role, &t.Content, &ts); err != nil {
+			rows.Close() //nolint:errcheck
+			return fmt.Errorf("scan turn: %w", err)
+		}
+		if ts.Valid {
+			t.Timestamp = ts.Time
+		}
+		if s := byID[sid]; s != nil {
+			s.payload.Turns = append(s.payload.Turns, t)
+		}
+	}
+	rows.Close() //nolint:errcheck
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = tx.Query(`SELECT session_id, tool, COALESCE(path, ''), COALESCE(cmd_prefix, '') FROM tool_calls ORDER BY session_id, call_order`)
+	if err != nil {
+		return fmt.Errorf("read tool calls: %w", err)
+	}
+	for rows.Next() {
+		var sid string
+		var tc session.ToolCall
+		if err := rows.Scan(&sid, &tc.Tool, &tc.Path, &tc.CmdPrefix); err != nil {
+			rows.Close() //nolint:errcheck
+			return fmt.Errorf("scan tool call: %w", err)
+		}
+		if s := byID[sid]; s != nil {
+			s.payload.ToolCalls = append(s.payload.ToolCalls, tc)
+		}
+	}
+	rows.Close() //nolint:errcheck
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Group by content in capture order. A session already stored under its
+	// content ID holds the group; otherwise the earliest does.
+	var order []string
+	groups := make(map[string][]*stored)
+	for _, s := range sessions {
+		cid := session.ContentID(&s.payload)
+		if _, ok := groups[cid]; !ok {
+			order = append(order, cid)
+		}
+		if s.id == cid {
+			groups[cid] = append([]*stored{s}, groups[cid]...)
+		} else {
+			groups[cid] = append(groups[cid], s)
+		}
+	}
+	for _, cid := range order {
+		canonical := groups[cid][0].id
+		if cid != canonical {
+			if err := InsertSessionAlias(tx, cid, canonical); err != nil {
+				return err
+			}
+		}
+		for _, dup := range groups[cid][1:] {
+			if err := InsertSessionAlias(tx, dup.id, canonical); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/marcboeker/go-duckdb"
+)
+
+// Batch writes one checkpoint's rows in a single DuckDB transaction.
+// Sessions, checkpoints, junction rows and checkpoint_state go through Exec
+// (Batch satisfies Execer); turns and tool calls are bulk-loaded with
+// appenders on the same connection. Nothing is visible to other readers
+// until Commit, and a crash before Commit leaves the data DB untouched.
+type Batch struct {
+	conn      *sql.Conn
+	tx        *sql.Tx
+	turns     *duckdb.Appender
+	toolCalls *duckdb.Appender
+	done      bool
+}
+
+// BeginBatch starts a transaction on a dedicated connection and opens
+// appenders for turns and tool_calls.
+func BeginBatch(d *sql.DB) (*Batch, error) {
+	ctx := context.Background()
+	conn, err := d.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire connection: %w", err)
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	b := &Batch{conn: conn, tx: tx}
+	err = conn.Raw(func(driverConn any) error {
+		dc, ok := driverConn.(driver.Conn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection %T", driverConn)
+		}
+		if b.turns, err = duckdb.NewAppenderFromConn(dc, "", "turns"); err != nil {
+			return fmt.Errorf("turns appender: %w", err)
+		}
+		if b.toolCalls, err = duckdb.NewAppenderFromConn(dc, "", "tool_calls"); err != nil {
+			return fmt.Errorf("tool_calls appender: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		b.Rollback() //nolint:errcheck
+		return nil, err
+	}
+	return b, nil
+}
+
+// Exec runs a statement inside the batch transaction.
+func (b *Batch) Exec(query string, args ...any) (sql.Result, error) {
+	return b.tx.Exec(query, args...)
+}
+
+// AppendTurn queues a turn row. A zero ts is stored as NULL.
+func (b *Batch) AppendTurn(id, sessionID string, turnIndex int, role, content string, ts time.Time) error {
+	var tsVal driver.Value
+	if !ts.IsZero() {
+		tsVal = ts.UTC()
+	}
+	if err := b.turns.AppendRow(id, sessionID, int32(turnIndex), role, content, tsVal); err != nil {
+		return fmt.Errorf("append turn: %w", err)
+	}
+	return nil
+}
+
+// AppendToolCall queues a tool_call row.
+func (b *Batch) AppendToolCall(id, sessionID string, callOrder int, tool, path, cmdPrefix string) error {
+	if err := b.toolCalls.AppendRow(id, sessionID, int32(callOrder), tool, path, cmdPrefix); err != nil {
+		return fmt.Errorf("append tool_call: %w", err)
+	}
+	return nil
+}
+
+// Commit flushes the appenders and commits the transaction. The batch
+// cannot be used afterwards.
+func (b *Batch) Commit() error {
+	if b.done {
+		return errors.New("batch already finished")
+	}
+	if err := b.closeAppenders(); err != nil {
+		b.Rollback() //nolint:errcheck
+		return fmt.Errorf("flush appenders: %w", err)
+	}
+	b.done = true
+	defer b.conn.Close() //nolint:errcheck
+	if err := b.tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+// Rollback discards every row written through the batch. Safe to call
+// after Commit (no-op), so callers can defer it.
+func (b *Batch) Rollback() error {
+	if b.done {
+		return nil
+	}
+	b.done = true
+	b.closeAppenders() //nolint:errcheck
+	err := b.tx.Rollback()
+	b.conn.Close() //nolint:errcheck
+	return err
+}
+
+func (b *Batch) closeAppenders() error {
+	var errs []error
+	for _, a := range []**duckdb.Appender{&b.turns, &b.toolCalls} {
+		if *a == nil {
+			continue
+		}
+		if err := (*a).Close(); err != nil {
+			errs = append(errs, err)
+		}
+		*a = nil
+	}
+	return errors.Join(errs...)
+}
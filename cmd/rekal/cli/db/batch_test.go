@@ -0,0 +1,192 @@
+package db
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestDataDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d, err := OpenData(dir)
+	if err != nil {
+		t.Fatalf("OpenData: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	if err := InitDataSchema(d, MigrateContext{}); err != nil {
+		t.Fatalf("InitDataSchema: %v", err)
+	}
+	return d
+}
+
+func countRows(t *testing.T, d *sql.DB, table string) int {
+	t.Helper()
+	var n int
+	if err := d.QueryRow("SELECT count(*) FROM " + table).Scan(&n); err != nil {
+		t.Fatalf("count %s: %v", table, err)
+	}
+	return n
+}
+
+func writeTestBatch(t *testing.T, b *Batch) {
+	t.Helper()
+	if err := InsertSession(b, "s1", "", "h1", "human", "", "a@b.c", "main", "2026-02-25T10:00:00Z", "claude"); err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+	if err := b.AppendTurn("t1", "s1", 0, "human", "hello", ts); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AppendTurn("t2", "s1", 1, "assistant", "hi", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AppendToolCall("c1", "s1", 0, "Edit", "main.go", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := InsertCheckpoint(b, "cp1", "abc", "main", "a@b.c", "2026-02-25T10:01:00Z", "human", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := InsertCheckpointSession(b, "cp1", "s1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpsertCheckpointState(b, "/tmp/s1.jsonl", 10, "h1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBatch_Commit(t *testing.T) {
+	t.Parallel()
+	d := openTestDataDB(t)
+
+	b, err := BeginBatch(d)
+	if err != nil {
+		t.Fatalf("BeginBatch: %v", err)
+	}
+	writeTestBatch(t, b)
+	if err := b.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if err := b.Rollback(); err != nil {
+		t.Errorf("Rollback after Commit should be a no-op: %v", err)
+	}
+
+	for table, want := range map[string]int{
+		"sessions": 1, "turns": 2, "tool_calls": 1,
+		"checkpoints": 1, "checkpoint_sessions": 1, "checkpoint_state": 1,
+	} {
+		if got := countRows(t, d, table); got != want {
+			t.Errorf("%s rows = %d, want %d", table, got, want)
+		}
+	}
+
+	var ts sql.NullTime
+	if err := d.QueryRow("SELECT ts FROM turns WHERE id = 't2'").Scan(&ts); err != nil {
+		t.Fatal(err)
+	}
+	if ts.Valid {
+		t.Errorf("zero timestamp should be NULL, got %v", ts.Time)
+	}
+}
+
+func TestBatch_RollbackLeavesNothing(t *testing.T) {
+	t.Parallel()
+	d := openTestDataDB(t)
+
+	b, err := BeginBatch(d)
+	if err != nil {
+		t.Fatalf("BeginBatch: %v", err)
+	}
+	writeTestBatch(t, b)
+	if err := b.Rollback(); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+
+	for _, table := range []string{"sessions", "turns", "tool_calls", "checkpoints", "checkpoint_sessions", "checkpoint_state"} {
+		if got := countRows(t, d, table); got != 0 {
+			t.Errorf("%s rows = %d after rollback, want 0", table, got)
+		}
+	}
+}
+
+func TestRepairOrphans(t *testing.T) {
+	t.Parallel()
+	d := openTestDataDB(t)
+
+	// A healthy checkpoint plus the leftovers of an interrupted one.
+	b, err := BeginBatch(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestBatch(t, b)
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := InsertSession(d, "s2", "", "h2", "human", "", "a@b.c", "main", "2026-02-25T11:00:00Z", "claude"); err != nil {
+		t.Fatal(err)
+	}
+	if err := InsertTurn(d, "t3", "s2", 0, "human", "half a session", ""); err != nil {
+		t.Fatal(err)
+	}
+	// Imported without its checkpoint frame: not an orphan.
+	if err := InsertSession(d, "s3", "", "wire:s3", "human", "", "x@y.z", "main", "2026-02-25T12:00:00Z", "claude"); err != nil {
+		t.Fatal(err)
+	}
+	if err := InsertTurn(d, "t4", "s3", 0, "human", "a teammate's session", ""); err != nil {
+		t.Fatal(err)
+	}
+	// Aliases naming the orphan, either way round, go with it.
+	for alias, sid := range map[string]string{"c2": "s2", "s2": "s1", "c1": "s1"} {
+		if err := InsertSessionAlias(d, alias, sid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := InsertCheckpoint(d, "cp2", "def", "main", "a@b.c", "2026-02-25T11:01:00Z", "human", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := InsertFileTouched(d, "f1", "cp2", "main.go", "M"); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := FindOrphans(d)
+	if err != nil {
+		t.Fatalf("FindOrphans: %v", err)
+	}
+	if r.UnlinkedSessions != 1 || r.EmptyCheckpoints != 1 {
+		t.Fatalf("report = %+v, want 1 unlinked session and 1 empty checkpoint", r)
+	}
+
+	if _, err := RepairOrphans(d); err != nil {
+		t.Fatalf("RepairOrphans: %v", err)
+	}
+	r, err = FindOrphans(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Clean() {
+		t.Errorf("report after repair = %+v, want clean", r)
+	}
+	if got := countRows(t, d, "sessions"); got != 2 {
+		t.Errorf("sessions = %d, want healthy and imported sessions kept", got)
+	}
+	if got := countRows(t, d, "turns"); got != 3 {
+		t.Errorf("turns = %d, want 3", got)
+	}
+	if got := countRows(t, d, "checkpoint_state"); got != 0 {
+		t.Errorf("checkpoint_state = %d, want cleared", got)
+	}
+	for id, want := range map[string]string{"c1": "s1", "c2": "", "s2": ""} {
+		got, err := ResolveSessionID(d, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("ResolveSessionID(%s) = %q, want %q", id, got, want)
+		}
+	}
+}
@@ -4,23 +4,35 @@ import (
 	"database/sql"
 	"fmt"
 	"path/filepath"
+	"regexp"
+	"strings"
 
 	_ "github.com/marcboeker/go-duckdb"
 )
 
 // OpenData opens (or creates) the data DB at <gitRoot>/.rekal/data.db.
+// Fails with *SchemaTooNewError if the DB was written by a newer rekal.
 func OpenData(gitRoot string) (*sql.DB, error) {
 	path := filepath.Join(gitRoot, ".rekal", "data.db")
-	return open(path)
+	return open(path, dataSchema)
 }
 
 // OpenIndex opens (or creates) the index DB at <gitRoot>/.rekal/index.db.
+// Fails with *SchemaTooNewError if the DB was written by a newer rekal.
 func OpenIndex(gitRoot string) (*sql.DB, error) {
 	path := filepath.Join(gitRoot, ".rekal", "index.db")
-	return open(path)
+	return open(path, indexSchema)
 }
 
-func open(path string) (*sql.DB, error) {
+// OpenIndexReadOnly opens the existing index DB at <gitRoot>/.rekal/index.db
+// for reading. Any number of processes may hold it open read-only, but not
+// while one has it open for writing — callers hold the .rekal lock shared.
+func OpenIndexReadOnly(gitRoot string) (*sql.DB, error) {
+	path := filepath.Join(gitRoot, ".rekal", "index.db")
+	return open(path+"?access_mode=read_only", indexSchema)
+}
+
+func open(path string, spec schemaSpec) (*sql.DB, error) {
 	db, err := sql.Open("duckdb", path)
 	if err != nil {
 		return nil, fmt.Errorf("open database %s: %w", path, err)
@@ -29,9 +41,19 @@ func open(path string) (*sql.DB, error) {
 		db.Close()
 		return nil, fmt.Errorf("ping database %s: %w", path, err)
 	}
+	if err := checkNotTooNew(db, spec); err != nil {
+		db.Close()
+		return nil, err
+	}
 	return db, nil
 }
 
+// Execer is the subset of *sql.DB and *sql.Tx used by the insert helpers,
+// so the same helpers serve autocommit callers and batched checkpoints.
+type Execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
 // SessionExistsByHash reports whether a session with the given content hash
 // already exists in the data DB. Used for deduplication.
 func SessionExistsByHash(d *sql.DB, hash string) (bool, error) {
@@ -44,7 +66,7 @@ func SessionExistsByHash(d *sql.DB, hash string) (bool, error) {
 }
 
 // InsertSession inserts a new session row into the data DB.
-func InsertSession(d *sql.DB, id, parentSessionID, hash, actorType, agentID, userEmail, branch, capturedAt, source string) error {
+func InsertSession(d Execer, id, parentSessionID, hash, actorType, agentID, userEmail, branch, capturedAt, source string) error {
 	if source == "" {
 		source = "claude"
 	}
@@ -69,7 +91,7 @@ func nullIfEmpty(s string) interface{} {
 }
 
 // InsertTurn inserts a turn row into the data DB.
-func InsertTurn(d *sql.DB, id, sessionID string, turnIndex int, role, content, ts string) error {
+func InsertTurn(d Execer, id, sessionID string, turnIndex int, role, content, ts string) error {
 	_, err := d.Exec(
 		`INSERT INTO turns (id, session_id, turn_index, role, content, ts)
 		 VALUES ($1, $2, $3, $4, $5, $6)`,
@@ -82,7 +104,7 @@ func InsertTurn(d *sql.DB, id, sessionID string, turnIndex int, role, content, t
 }
 
 // InsertToolCall inserts a tool_call row into the data DB.
-func InsertToolCall(d *sql.DB, id, sessionID string, callOrder int, tool, path, cmdPrefix string) error {
+func InsertToolCall(d Execer, id, sessionID string, callOrder int, tool, path, cmdPrefix string) error {
 	_, err := d.Exec(
 		`INSERT INTO tool_calls (id, session_id, call_order, tool, path, cmd_prefix)
 		 VALUES ($1, $2, $3, $4, $5, $6)`,
@@ -95,7 +117,7 @@ func InsertToolCall(d *sql.DB, id, sessionID string, callOrder int, tool, path,
 }
 
 // InsertCheckpoint inserts a new checkpoint row into the data DB.
-func InsertCheckpoint(d *sql.DB, id, gitSHA, branch, email, ts, actorType, agentID string) error {
+func InsertCheckpoint(d Execer, id, gitSHA, branch, email, ts, actorType, agentID string) error {
 	_, err := d.Exec(
 		`INSERT INTO checkpoints (id, git_sha, git_branch, user_email, ts, actor_type, agent_id)
 		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
@@ -108,7 +130,7 @@ func InsertCheckpoint(d *sql.DB, id, gitSHA, branch, email, ts, actorType, agent
 }
 
 // InsertFileTouched inserts a file_touched row.
-func InsertFileTouched(d *sql.DB, id, checkpointID, filePath, changeType string) error {
+func InsertFileTouched(d Execer, id, checkpointID, filePath, changeType string) error {
 	_, err := d.Exec(
 		`INSERT INTO files_touched (id, checkpoint_id, file_path, change_type)
 		 VALUES ($1, $2, $3, $4)`,
@@ -121,7 +143,7 @@ func InsertFileTouched(d *sql.DB, id, checkpointID, filePath, changeType string)
 }
 
 // InsertCheckpointSession inserts a checkpoint_sessions junction row.
-func InsertCheckpointSession(d *sql.DB, checkpointID, sessionID string) error {
+func InsertCheckpointSession(d Execer, checkpointID, sessionID string) error {
 	_, err := d.Exec(
 		`INSERT INTO checkpoint_sessions (checkpoint_id, session_id)
 		 VALUES ($1, $2)`,
@@ -150,7 +172,7 @@ func GetCheckpointState(d *sql.DB, filePath string) (byteSize int64, fileHash st
 }
 
 // UpsertCheckpointState inserts or updates the cached state for a session file.
-func UpsertCheckpointState(d *sql.DB, filePath string, byteSize int64, fileHash string) error {
+func UpsertCheckpointState(d Execer, filePath string, byteSize int64, fileHash string) error {
 	_, err := d.Exec(
 		`INSERT INTO checkpoint_state (file_path, byte_size, file_hash)
 		 VALUES ($1, $2, $3)
@@ -230,13 +252,15 @@ func QuerySessionsByCheckpoint(d *sql.DB, checkpointID string) ([]string, error)
 
 // SessionRow represents a session with its turns and tool calls.
 type SessionRow struct {
-	ID         string
-	Hash       string
-	CapturedAt string
-	ActorType  string
-	AgentID    string
-	Email      string
-	Branch     string
+	ID              string
+	ParentSessionID string
+	Hash            string
+	CapturedAt      string
+	ActorType       string
+	AgentID         string
+	Email           string
+	Branch          string
+	Source          string
 }
 
 // TurnRow represents a turn from the turns table.
@@ -259,9 +283,10 @@ type ToolCallRow struct {
 func QuerySession(d *sql.DB, id string) (*SessionRow, error) {
 	r := &SessionRow{}
 	err := d.QueryRow(
-		`SELECT id, session_hash, captured_at, actor_type, COALESCE(agent_id, ''), COALESCE(user_email, ''), COALESCE(branch, '')
+		`SELECT id, COALESCE(parent_session_id, ''), session_hash, captured_at, actor_type,
+		        COALESCE(agent_id, ''), COALESCE(user_email, ''), COALESCE(branch, ''), COALESCE(source, '')
 		 FROM sessions WHERE id = $1`, id,
-	).Scan(&r.ID, &r.Hash, &r.CapturedAt, &r.ActorType, &r.AgentID, &r.Email, &r.Branch)
+	).Scan(&r.ID, &r.ParentSessionID, &r.Hash, &r.CapturedAt, &r.ActorType, &r.AgentID, &r.Email, &r.Branch, &r.Source)
 	if err != nil {
 		return nil, fmt.Errorf("query session: %w", err)
 	}
@@ -403,3 +428,113 @@ func SessionExistsByID(d *sql.DB, id string) (bool, error) {
 	}
 	return count > 0, nil
 }
+
+// SetSessionVerified records whether an imported session's rekal branch
+// commit carried a valid signature from the branch owner.
+func SetSessionVerified(d Execer, id string, verified bool) error {
+	if _, err := d.Exec("UPDATE sessions SET verified = $1 WHERE id = $2", verified, id); err != nil {
+		return fmt.Errorf("set session verified: %w", err)
+	}
+	return nil
+}
+
+// ImportedHead is the history of a rekal branch as of its last import.
+type ImportedHead struct {
+	NFrames   int
+	ChainHead string
+}
+
+// QueryImportedHead returns the recorded head for branch. found is false
+// when the branch was never imported.
+func QueryImportedHead(d *sql.DB, branch string) (head ImportedHead, found bool, err error) {
+	err = d.QueryRow(
+		"SELECT n_frames, chain_head FROM imported_heads WHERE branch = $1", branch,
+	).Scan(&head.NFrames, &head.ChainHead)
+	if err == sql.ErrNoRows {
+		return head, false, nil
+	}
+	if err != nil {
+		return head, false, fmt.Errorf("query imported head: %w", err)
+	}
+	return head, true, nil
+}
+
+// UpsertImportedHead records the head of branch after an import.
+func UpsertImportedHead(d Execer, branch string, head ImportedHead) error {
+	_, err := d.Exec(`
+		INSERT INTO imported_heads (branch, n_frames, chain_head, imported_at) VALUES ($1, $2, $3, now())
+		ON CONFLICT (branch) DO UPDATE SET n_frames = $2, chain_head = $3, imported_at = now()
+	`, branch, head.NFrames, head.ChainHead)
+	if err != nil {
+		return fmt.Errorf("upsert imported head: %w", err)
+	}
+	return nil
+}
+
+// CanonicalizeRepoPaths rewrites rows captured before paths were stored
+// repo-relative. Absolute tool_call paths, command prefixes and turn text under
+// any of roots are made repo-relative; tool_call paths that remain absolute are
+// marked with outOfRepoPrefix. In text a root only matches between characters
+// that cannot continue a path, as in scrub.RepoRelativeText. Idempotent —
+// canonical rows are left untouched.
+func CanonicalizeRepoPaths(d Execer, roots []string, outOfRepoPrefix string) error {
+	const notPathChar = `[^A-Za-z0-9._/\-]`
+	for _, root := range roots {
+		root = strings.TrimSuffix(root, "/")
+		if root == "" {
+			continue
+		}
+		prefix := root + "/"
+		quoted := regexp.QuoteMeta(root)
+		under := `(^|` + notPathChar + `)` + quoted + `/`
+		bare := `(^|` + notPathChar + `)` + quoted + `($|` + notPathChar + `)`
+
+		if _, err := d.Exec(
+			`UPDATE tool_calls SET path = substr(path, $2) WHERE starts_with(path, $1)`,
+			prefix, len(prefix)+1,
+		); err != nil {
+			return fmt.Errorf("canonicalize tool_call paths: %w", err)
+		}
+		if _, err := d.Exec(`UPDATE tool_calls SET path = '.' WHERE path = $1`, root); err != nil {
+			return fmt.Errorf("canonicalize tool_call root path: %w", err)
+		}
+		if _, err := d.Exec(
+			`UPDATE tool_calls SET cmd_prefix = regexp_replace(regexp_replace(cmd_prefix, $1, '\1', 'g'), $2, '\1.\2', 'g')
+			 WHERE contains(cmd_prefix, $3)`,
+			under, bare, root,
+		); err != nil {
+			return fmt.Errorf("canonicalize tool_call commands: %w", err)
+		}
+		if _, err := d.Exec(
+			`UPDATE turns SET content = regexp_replace(regexp_replace(content, $1, '\1', 'g'), $2, '\1.\2', 'g')
+			 WHERE contains(content, $3)`,
+			under, bare, root,
+		); err != nil {
+			return fmt.Errorf("canonicalize turn text: %w", err)
+		}
+	}
+
+	if _, err := d.Exec(
+		`UPDATE tool_calls SET path = $1 || path WHERE starts_with(path, '/')`,
+		outOfRepoPrefix,
+	); err != nil {
+		return fmt.Errorf("mark out-of-repo paths: %w", err)
+	}
+	return nil
+}
+
+// relativizeRepoPaths is the Func of the repo-relative paths migration. It
+// canonicalizes the paths of rows captured by older versions against the
+// repository roots in mc, then aliases sessions again by content: the content
+// IDs computed before the paths were rewritten no longer match new captures
+// of the same transcript. Without roots (e.g. an in-memory DB) rows are left
+// as they are.
+func relativizeRepoPaths(tx *sql.Tx, mc MigrateContext) error {
+	if len(mc.RepoRoots) == 0 {
+		return nil
+	}
+	if err := CanonicalizeRepoPaths(tx, mc.RepoRoots, mc.OutOfRepoPrefix); err != nil {
+		return err
+	}
+	return mergeDuplicateSessions(tx, mc)
+}
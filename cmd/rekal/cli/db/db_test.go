@@ -61,7 +61,7 @@ func TestInitDataSchema(t *testing.T) {
 	}
 	defer db.Close()
 
-	if err := InitDataSchema(db); err != nil {
+	if err := InitDataSchema(db, MigrateContext{}); err != nil {
 		t.Fatalf("InitDataSchema: %v", err)
 	}
 
@@ -96,3 +96,232 @@ func TestInitIndexSchema(t *testing.T) {
 		t.Fatalf("InitIndexSchema: %v", err)
 	}
 }
+
+func TestCanonicalizeRepoPaths(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d, err := OpenData(dir)
+	if err != nil {
+		t.Fatalf("OpenData: %v", err)
+	}
+	defer d.Close()
+	if err := InitDataSchema(d, MigrateContext{}); err != nil {
+		t.Fatalf("InitDataSchema: %v", err)
+	}
+
+	root := "/work/acme/rekal"
+	stmts := []string{
+		`INSERT INTO sessions (id, session_hash, captured_at) VALUES ('s1', 'h', now())`,
+		`INSERT INTO turns (id, session_id, turn_index, role, content) VALUES ('t1', 's1', 0, 'human', 'open /work/acme/rekal/main.go in /work/acme/rekal')`,
+		`INSERT INTO turns (id, session_id, turn_index, role, content) VALUES ('t2', 's1', 1, 'human', 'not /mnt/work/acme/rekal/x or /mnt/work/acme/rekal')`,
+		`INSERT INTO tool_calls (id, session_id, call_order, tool, path, cmd_prefix) VALUES ('c1', 's1', 0, 'Edit', '/work/acme/rekal/main.go', 'cd /work/acme/rekal && go')`,
+		`INSERT INTO tool_calls (id, session_id, call_order, tool, path) VALUES ('c2', 's1', 1, 'Read', '/etc/hosts')`,
+		`INSERT INTO tool_calls (id, session_id, call_order, tool, path) VALUES ('c3', 's1', 2, 'Read', 'already/relative.go')`,
+	}
+	for _, s := range stmts {
+		if _, err := d.Exec(s); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+
+	// Running twice must be a no-op the second time.
+	for i := 0; i < 2; i++ {
+		if err := CanonicalizeRepoPaths(d, []string{root}, "ext:"); err != nil {
+			t.Fatalf("CanonicalizeRepoPaths: %v", err)
+		}
+	}
+
+	wantPaths := map[string]string{"c1": "main.go", "c2": "ext:/etc/hosts", "c3": "already/relative.go"}
+	for id, want := range wantPaths {
+		var got string
+		if err := d.QueryRow("SELECT path FROM tool_calls WHERE id = $1", id).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s path = %q, want %q", id, got, want)
+		}
+	}
+
+	var cmd, content string
+	if err := d.QueryRow("SELECT cmd_prefix FROM tool_calls WHERE id = 'c1'").Scan(&cmd); err != nil {
+		t.Fatal(err)
+	}
+	if cmd != "cd . && go" {
+		t.Errorf("cmd_prefix = %q", cmd)
+	}
+	if err := d.QueryRow("SELECT content FROM turns WHERE id = 't1'").Scan(&content); err != nil {
+		t.Fatal(err)
+	}
+	if content != "open main.go in ." {
+		t.Errorf("content = %q", content)
+	}
+	if err := d.QueryRow("SELECT content FROM turns WHERE id = 't2'").Scan(&content); err != nil {
+		t.Fatal(err)
+	}
+	if content != "not /mnt/work/acme/rekal/x or /mnt/work/acme/rekal" {
+		t.Errorf("content under another root = %q", content)
+	}
+}
+
+func TestPopulateIndex_FilesFromToolCalls(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	data, err := OpenData(dir)
+	if err != nil {
+		t.Fatalf("OpenData: %v", err)
+	}
+	if err := InitDataSchema(data, MigrateContext{}); err != nil {
+		t.Fatalf("InitDataSchema: %v", err)
+	}
+	stmts := []string{
+		`INSERT INTO sessions (id, session_hash, captured_at) VALUES ('s1', 'h', now())`,
+		`INSERT INTO checkpoints (id, git_sha, git_branch, user_email, ts) VALUES ('cp1', 'abc', 'main', 'a@b.c', now())`,
+		`INSERT INTO checkpoint_sessions (checkpoint_id, session_id) VALUES ('cp1', 's1')`,
+		`INSERT INTO tool_calls (id, session_id, call_order, tool, path) VALUES ('c1', 's1', 0, 'Edit', 'auth/login.go')`,
+		`INSERT INTO tool_calls (id, session_id, call_order, tool, path) VALUES ('c2', 's1', 1, 'Write', 'ext:/tmp/scratch.go')`,
+		`INSERT INTO tool_calls (id, session_id, call_order, tool, path) VALUES ('c3', 's1', 2, 'Edit', '.')`,
+		`INSERT INTO tool_calls (id, session_id, call_order, tool, path) VALUES ('c4', 's1', 3, 'Read', 'README.md')`,
+	}
+	for _, s := range stmts {
+		if _, err := data.Exec(s); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+	data.Close()
+
+	d, err := OpenIndex(dir)
+	if err != nil {
+		t.Fatalf("OpenIndex: %v", err)
+	}
+	defer d.Close()
+	if err := InitIndexSchema(d); err != nil {
+		t.Fatalf("InitIndexSchema: %v", err)
+	}
+	if err := PopulateIndex(d, dir); err != nil {
+		t.Fatalf("PopulateIndex: %v", err)
+	}
+
+	rows, err := d.Query("SELECT file_path, change_type FROM files_index ORDER BY file_path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var path, change string
+		if err := rows.Scan(&path, &change); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, path+" "+change)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "auth/login.go T" {
+		t.Errorf("files_index = %q, want [auth/login.go T]", got)
+	}
+}
+
+func TestImportedHead_UpsertAndQuery(t *testing.T) {
+	t.Parallel()
+
+	_, d := openFixtureDB(t, "index.db", "")
+	if err := InitIndexSchema(d); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, found, err := QueryImportedHead(d, "origin/rekal/a@example.com"); err != nil || found {
+		t.Fatalf("empty table: found=%v err=%v", found, err)
+	}
+	if err := UpsertImportedHead(d, "origin/rekal/a@example.com", ImportedHead{NFrames: 3, ChainHead: "aa"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpsertImportedHead(d, "origin/rekal/a@example.com", ImportedHead{NFrames: 6, ChainHead: "bb"}); err != nil {
+		t.Fatal(err)
+	}
+	head, found, err := QueryImportedHead(d, "origin/rekal/a@example.com")
+	if err != nil || !found {
+		t.Fatalf("query: found=%v err=%v", found, err)
+	}
+	if head.NFrames != 6 || head.ChainHead != "bb" {
+		t.Errorf("head = %+v, want {6 bb}", head)
+	}
+
+	// Import history is not derived data and survives an index rebuild.
+	if err := DropIndexTables(d); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitIndexSchema(d); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := QueryImportedHead(d, "origin/rekal/a@example.com"); !found {
+		t.Error("imported head lost after DropIndexTables")
+	}
+}
+
+func TestMergeFileCooccurrence(t *testing.T) {
+	t.Parallel()
+
+	_, d := openFixtureDB(t, "index.db", "")
+	if err := InitIndexSchema(d); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := MergeFileCooccurrence(d, map[FilePair]int{{A: "a.go", B: "b.go"}: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := MergeFileCooccurrence(d, map[FilePair]int{{A: "a.go", B: "b.go"}: 3, {A: "a.go", B: "c.go"}: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	var ab, rows int
+	if err := d.QueryRow("SELECT count FROM file_cooccurrence WHERE file_a = 'a.go' AND file_b = 'b.go'").Scan(&ab); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.QueryRow("SELECT count(*) FROM file_cooccurrence").Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if ab != 5 || rows != 2 {
+		t.Errorf("a.go/b.go count = %d, rows = %d; want 5, 2", ab, rows)
+	}
+}
+
+func TestIndexState_ReadAndPrefix(t *testing.T) {
+	t.Parallel()
+
+	_, d := openFixtureDB(t, "index.db", "")
+	if err := InitIndexSchema(d); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, found, err := ReadIndexState(d, "session_count"); err != nil || found {
+		t.Fatalf("empty table: found=%v err=%v", found, err)
+	}
+	for k, v := range map[string]string{
+		"session_count":                "4",
+		"imported:origin/rekal/a@x.io": "aaa 3",
+		"imported:origin/rekal/b@x.io": "bbb 5",
+	} {
+		if err := WriteIndexState(d, k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v, found, err := ReadIndexState(d, "session_count"); err != nil || !found || v != "4" {
+		t.Errorf("session_count = %q, %v, %v", v, found, err)
+	}
+	got, err := IndexStateWithPrefix(d, "imported:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["imported:origin/rekal/b@x.io"] != "bbb 5" {
+		t.Errorf("IndexStateWithPrefix = %v", got)
+	}
+}
@@ -83,6 +83,7 @@ func ftsCacheDir() (string, error) {
 // DropIndexTables drops all index tables for a clean rebuild.
 func DropIndexTables(d *sql.DB) error {
 	tables := []string{
+		"schema_version",
 		"index_state",
 		"session_embeddings",
 		"file_cooccurrence",
@@ -108,11 +109,17 @@ func PopulateIndex(d *sql.DB, gitRoot string) error {
 	}
 	defer d.Exec("DETACH data_db") //nolint:errcheck
 
+	if err := collectMergedSessions(d); err != nil {
+		return err
+	}
+	defer d.Exec("DROP TABLE IF EXISTS merged_sessions") //nolint:errcheck
+
 	// turns_ft
 	if _, err := d.Exec(`
 		INSERT INTO turns_ft (id, session_id, turn_index, role, content, ts)
 		SELECT id, session_id, turn_index, role, content, CAST(ts AS VARCHAR)
 		FROM data_db.turns
+		WHERE session_id NOT IN (SELECT * FROM merged_sessions)
 	`); err != nil {
 		return fmt.Errorf("populate turns_ft: %w", err)
 	}
@@ -122,6 +129,7 @@ func PopulateIndex(d *sql.DB, gitRoot string) error {
 		INSERT INTO tool_calls_index (id, session_id, call_order, tool, path, cmd_prefix)
 		SELECT id, session_id, call_order, tool, path, cmd_prefix
 		FROM data_db.tool_calls
+		WHERE session_id NOT IN (SELECT * FROM merged_sessions)
 	`); err != nil {
 		return fmt.Errorf("populate tool_calls_index: %w", err)
 	}
@@ -132,30 +140,32 @@ func PopulateIndex(d *sql.DB, gitRoot string) error {
 		SELECT ft.checkpoint_id, cs.session_id, ft.file_path, ft.change_type
 		FROM data_db.files_touched ft
 		JOIN data_db.checkpoint_sessions cs ON cs.checkpoint_id = ft.checkpoint_id
+		WHERE cs.session_id NOT IN (SELECT * FROM merged_sessions)
 	`); err != nil {
 		return fmt.Errorf("populate files_index: %w", err)
 	}
 
 	// files_index — supplement from file-modifying tool_calls for existing data
-	// that was checkpointed before the capture-time fix.
-	gitRootPrefix := gitRoot + "/"
+	// that was checkpointed before the capture-time fix. Tool call paths are
+	// stored repo-relative; out-of-repo paths (ext:) and the repo root itself
+	// are not files in the repo.
 	if _, err := d.Exec(`
 		INSERT INTO files_index (checkpoint_id, session_id, file_path, change_type)
-		SELECT DISTINCT cs.checkpoint_id, tc.session_id,
-			replace(tc.path, $1, ''),
-			'T'
+		SELECT DISTINCT cs.checkpoint_id, tc.session_id, tc.path, 'T'
 		FROM data_db.tool_calls tc
 		JOIN data_db.checkpoint_sessions cs ON cs.session_id = tc.session_id
 		WHERE tc.tool IN ('Write', 'Edit', 'NotebookEdit')
 		  AND tc.path IS NOT NULL AND length(tc.path) > 0
-		  AND tc.path LIKE ($1 || '%')
+		  AND NOT starts_with(tc.path, 'ext:')
+		  AND tc.path <> '.'
+		  AND tc.session_id NOT IN (SELECT * FROM merged_sessions)
 		  AND NOT EXISTS (
 			SELECT 1 FROM files_index fi
 			WHERE fi.checkpoint_id = cs.checkpoint_id
 			  AND fi.session_id = tc.session_id
-			  AND fi.file_path = replace(tc.path, $1, '')
+			  AND fi.file_path = tc.path
 		  )
-	`, gitRootPrefix); err != nil {
+	`); err != nil {
 		return fmt.Errorf("populate files_index from tool_calls: %w", err)
 	}
 
@@ -164,7 +174,7 @@ func PopulateIndex(d *sql.DB, gitRoot string) error {
 		INSERT INTO session_facets (
 			session_id, user_email, git_branch, actor_type, agent_id,
 			captured_at, turn_count, tool_call_count, file_count,
-			checkpoint_id, git_sha
+			checkpoint_id, git_sha, verified
 		)
 		SELECT
 			s.id,
@@ -177,7 +187,8 @@ func PopulateIndex(d *sql.DB, gitRoot string) error {
 			(SELECT count(*) FROM data_db.tool_calls tc WHERE tc.session_id = s.id),
 			COALESCE(fc.file_count, 0),
 			c.id,
-			c.git_sha
+			c.git_sha,
+			COALESCE(s.verified, TRUE)
 		FROM data_db.sessions s
 		LEFT JOIN data_db.checkpoint_sessions cs ON cs.session_id = s.id
 		LEFT JOIN data_db.checkpoints c ON c.id = cs.checkpoint_id
@@ -187,6 +198,7 @@ func PopulateIndex(d *sql.DB, gitRoot string) error {
 			JOIN data_db.files_touched ft ON ft.checkpoint_id = cs2.checkpoint_id
 			GROUP BY cs2.session_id
 		) fc ON fc.session_id = s.id
+		WHERE s.id NOT IN (SELECT * FROM merged_sessions)
 	`); err != nil {
 		return fmt.Errorf("populate session_facets: %w", err)
 	}
@@ -199,6 +211,7 @@ func PopulateIndex(d *sql.DB, gitRoot string) error {
 		JOIN data_db.tool_calls b ON a.session_id = b.session_id AND a.path < b.path
 		WHERE a.path IS NOT NULL AND a.path != ''
 		  AND b.path IS NOT NULL AND b.path != ''
+		  AND a.session_id NOT IN (SELECT * FROM merged_sessions)
 		GROUP BY a.path, b.path
 	`); err != nil {
 		return fmt.Errorf("populate file_cooccurrence: %w", err)
@@ -207,6 +220,72 @@ func PopulateIndex(d *sql.DB, gitRoot string) error {
 	return nil
 }
 
+// collectMergedSessions fills the temp table merged_sessions with the
+// sessions of the attached data_db that are not indexed. Duplicates merged
+// into another session stay in data.db but are not indexed. data.db may
+// predate session_aliases.
+func collectMergedSessions(d *sql.DB) error {
+	merged := `SELECT NULL::VARCHAR AS id WHERE false`
+	var n int
+	if err := d.QueryRow(
+		"SELECT count(*) FROM information_schema.tables WHERE table_catalog = 'data_db' AND table_name = 'session_aliases'",
+	).Scan(&n); err != nil {
+		return fmt.Errorf("check session_aliases: %w", err)
+	}
+	if n > 0 {
+		merged = `SELECT a.alias FROM data_db.session_aliases a JOIN data_db.sessions s ON s.id = a.alias`
+	}
+	if _, err := d.Exec("CREATE OR REPLACE TEMP TABLE merged_sessions AS " + merged); err != nil {
+		return fmt.Errorf("collect merged sessions: %w", err)
+	}
+	return nil
+}
+
+// CountLocalSessions returns the number of sessions in the data DB that
+// belong in the index, and how many of them are not in session_facets.
+func CountLocalSessions(d *sql.DB, gitRoot string) (total, unindexed int, err error) {
+	dataPath := filepath.Join(gitRoot, ".rekal", "data.db")
+
+	if _, err := d.Exec(fmt.Sprintf("ATTACH '%s' AS data_db (READ_ONLY)", dataPath)); err != nil {
+		return 0, 0, fmt.Errorf("attach data_db: %w", err)
+	}
+	defer d.Exec("DETACH data_db") //nolint:errcheck
+
+	if err := collectMergedSessions(d); err != nil {
+		return 0, 0, err
+	}
+	defer d.Exec("DROP TABLE IF EXISTS merged_sessions") //nolint:errcheck
+
+	err = d.QueryRow(`
+		SELECT count(*), count(*) FILTER (WHERE s.id NOT IN (SELECT session_id FROM session_facets))
+		FROM data_db.sessions s
+		WHERE s.id NOT IN (SELECT * FROM merged_sessions)
+	`).Scan(&total, &unindexed)
+	if err != nil {
+		return 0, 0, fmt.Errorf("count local sessions: %w", err)
+	}
+	return total, unindexed, nil
+}
+
+// FilePair is an ordered file_cooccurrence key (A < B).
+type FilePair struct {
+	A, B string
+}
+
+// MergeFileCooccurrence adds counts to file_cooccurrence, summing with
+// existing rows.
+func MergeFileCooccurrence(d *sql.DB, counts map[FilePair]int) error {
+	for p, n := range counts {
+		if _, err := d.Exec(`
+			INSERT INTO file_cooccurrence (file_a, file_b, count) VALUES ($1, $2, $3)
+			ON CONFLICT (file_a, file_b) DO UPDATE SET count = file_cooccurrence.count + excluded.count
+		`, p.A, p.B, n); err != nil {
+			return fmt.Errorf("merge file_cooccurrence: %w", err)
+		}
+	}
+	return nil
+}
+
 // CreateFTSIndex creates the DuckDB full-text search index on turns_ft.
 func CreateFTSIndex(d *sql.DB) error {
 	_, err := d.Exec(`PRAGMA create_fts_index('turns_ft', 'id', 'content', stemmer='english', stopwords='english', overwrite=1)`)
@@ -235,6 +314,73 @@ func WriteIndexState(d *sql.DB, key, value string) error {
 	return nil
 }
 
+// ReadIndexState returns the value of key in the index_state table. found is
+// false when key is not set.
+func ReadIndexState(d *sql.DB, key string) (value string, found bool, err error) {
+	err = d.QueryRow("SELECT value FROM index_state WHERE key = $1", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("read index_state: %w", err)
+	}
+	return value, true, nil
+}
+
+// IndexStateWithPrefix returns the index_state entries whose key starts with
+// prefix.
+func IndexStateWithPrefix(d *sql.DB, prefix string) (map[string]string, error) {
+	rows, err := d.Query("SELECT key, value FROM index_state WHERE starts_with(key, $1)", prefix)
+	if err != nil {
+		return nil, fmt.Errorf("read index_state: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	result := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("scan index_state: %w", err)
+		}
+		result[key] = value
+	}
+	return result, rows.Err()
+}
+
+// ApplyEmailAliases rewrites user_email in session_facets to the canonical
+// email of each alias. aliases maps lower-case alias emails to canonical
+// ones; matching ignores case. DuckDB cannot update an indexed column of a
+// row in place, so the rows are deleted and inserted again.
+func ApplyEmailAliases(d *sql.DB, aliases map[string]string) error {
+	if len(aliases) == 0 {
+		return nil
+	}
+	if _, err := d.Exec("CREATE OR REPLACE TEMP TABLE email_aliases (alias VARCHAR, canonical VARCHAR)"); err != nil {
+		return fmt.Errorf("apply email aliases: %w", err)
+	}
+	for alias, canonical := range aliases {
+		if _, err := d.Exec("INSERT INTO email_aliases VALUES ($1, $2)", alias, canonical); err != nil {
+			return fmt.Errorf("apply email aliases: %w", err)
+		}
+	}
+	for _, stmt := range []string{
+		`CREATE OR REPLACE TEMP TABLE aliased_facets AS
+			SELECT sf.* REPLACE (a.canonical AS user_email)
+			FROM session_facets sf
+			JOIN email_aliases a ON a.alias = lower(sf.user_email)
+			WHERE sf.user_email <> a.canonical`,
+		"DELETE FROM session_facets WHERE session_id IN (SELECT session_id FROM aliased_facets)",
+		"INSERT INTO session_facets SELECT * FROM aliased_facets",
+		"DROP TABLE aliased_facets",
+		"DROP TABLE email_aliases",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			return fmt.Errorf("apply email aliases: %w", err)
+		}
+	}
+	return nil
+}
+
 // StoreEmbeddings bulk-inserts session embeddings into the index DB.
 func StoreEmbeddings(d *sql.DB, vectors map[string][]float64, model string) error {
 	for sessionID, vec := range vectors {
@@ -252,6 +398,14 @@ func StoreEmbeddings(d *sql.DB, vectors map[string][]float64, model string) erro
 	return nil
 }
 
+// DeleteEmbeddings removes every stored embedding of model.
+func DeleteEmbeddings(d *sql.DB, model string) error {
+	if _, err := d.Exec("DELETE FROM session_embeddings WHERE model = $1", model); err != nil {
+		return fmt.Errorf("delete %s embeddings: %w", model, err)
+	}
+	return nil
+}
+
 // float64SliceToDuckDB serializes a float64 slice as a DuckDB list literal
 // (e.g. "[0.1, 0.2, 0.3]") because the database/sql driver does not support
 // passing Go slices for FLOAT[] columns.
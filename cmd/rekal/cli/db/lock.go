@@ -0,0 +1,166 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DuckDB allows a single process to open a database file for writing, and
+// rekal always opens read-write. Every command that touches .rekal/data.db
+// or .rekal/index.db therefore holds one advisory lock, .rekal/rekal.lock,
+// for its duration. The lock file also records who holds it so a waiting
+// process can say what it is waiting for. Commands that only read take the
+// lock shared and open the DBs read-only, so they run side by side.
+
+const lockFileName = "rekal.lock"
+
+// lockPollInterval is how often a waiting process retries the lock.
+const lockPollInterval = 100 * time.Millisecond
+
+// LockHolder describes the process holding the .rekal lock.
+type LockHolder struct {
+	PID     int
+	Command string
+	Since   time.Time
+}
+
+// String formats the holder for messages, e.g. `"rekal sync" (pid 4242, since 10:02:03)`.
+func (h LockHolder) String() string {
+	if h.PID == 0 {
+		return "another rekal process"
+	}
+	s := fmt.Sprintf("%q (pid %d", h.Command, h.PID)
+	if !h.Since.IsZero() {
+		s += ", since " + h.Since.Local().Format("15:04:05")
+	}
+	return s + ")"
+}
+
+// LockedError is returned when the lock could not be acquired in time.
+type LockedError struct {
+	Holder LockHolder
+	Waited time.Duration
+}
+
+func (e *LockedError) Error() string {
+	if e.Waited > 0 {
+		return fmt.Sprintf(".rekal is locked by %s — gave up after %s", e.Holder, e.Waited.Round(time.Second))
+	}
+	return fmt.Sprintf(".rekal is locked by %s", e.Holder)
+}
+
+// IsLocked reports whether err (or an error it wraps) is a *LockedError.
+func IsLocked(err error) bool {
+	var le *LockedError
+	return errors.As(err, &le)
+}
+
+// Lock is a held .rekal lock. Release it when done.
+type Lock struct {
+	f      *os.File
+	shared bool
+}
+
+// AcquireLock takes the .rekal lock for command, retrying until timeout.
+// A zero timeout tries exactly once. onWait, if non-nil, is called once with
+// the current holder when the first attempt fails.
+func AcquireLock(gitRoot, command string, timeout time.Duration, onWait func(LockHolder)) (*Lock, error) {
+	return acquireLock(gitRoot, command, timeout, onWait, false)
+}
+
+// AcquireSharedLock takes the .rekal lock shared, for a command that only
+// reads: it waits for an exclusive holder but not for other shared ones.
+// Shared holders are not recorded in the lock file.
+func AcquireSharedLock(gitRoot, command string, timeout time.Duration, onWait func(LockHolder)) (*Lock, error) {
+	return acquireLock(gitRoot, command, timeout, onWait, true)
+}
+
+func acquireLock(gitRoot, command string, timeout time.Duration, onWait func(LockHolder), shared bool) (*Lock, error) {
+	path := lockPath(gitRoot)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+
+	start := time.Now()
+	notified := false
+	for {
+		ok, err := tryLockFile(f, shared)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("lock %s: %w", path, err)
+		}
+		if ok {
+			break
+		}
+		holder, _ := ReadLockHolder(gitRoot)
+		waited := time.Since(start)
+		if waited >= timeout {
+			f.Close()
+			return nil, &LockedError{Holder: holder, Waited: waited}
+		}
+		if !notified && onWait != nil {
+			onWait(holder)
+			notified = true
+		}
+		time.Sleep(min(lockPollInterval, timeout-waited))
+	}
+
+	if shared {
+		return &Lock{f: f, shared: true}, nil
+	}
+	// Record the holder. Best effort — the lock itself is what matters.
+	info := fmt.Sprintf("%d\n%s\n%s\n", os.Getpid(), command, time.Now().UTC().Format(time.RFC3339))
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt([]byte(info), 0)
+	}
+	return &Lock{f: f}, nil
+}
+
+// Release clears the holder record and drops the lock. Safe on nil.
+func (l *Lock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	if !l.shared {
+		_ = l.f.Truncate(0)
+	}
+	err := unlockFile(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	return err
+}
+
+// ReadLockHolder returns the holder recorded in the lock file. ok is false
+// when nothing is recorded (unlocked, or the holder has not written yet).
+func ReadLockHolder(gitRoot string) (holder LockHolder, ok bool) {
+	data, err := os.ReadFile(lockPath(gitRoot))
+	if err != nil {
+		return holder, false
+	}
+	lines := strings.SplitN(strings.TrimSpace(string(data)), "\n", 3)
+	if len(lines) < 2 {
+		return holder, false
+	}
+	pid, err := strconv.Atoi(lines[0])
+	if err != nil {
+		return holder, false
+	}
+	holder.PID = pid
+	holder.Command = lines[1]
+	if len(lines) == 3 {
+		holder.Since, _ = time.Parse(time.RFC3339, lines[2])
+	}
+	return holder, true
+}
+
+func lockPath(gitRoot string) string {
+	return filepath.Join(gitRoot, ".rekal", lockFileName)
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireLock_ExclusiveAndNamesHolder(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := AcquireLock(dir, "rekal sync", 0, nil)
+	if err != nil {
+		t.Fatalf("first AcquireLock: %v", err)
+	}
+
+	holder, ok := ReadLockHolder(dir)
+	if !ok || holder.PID != os.Getpid() || holder.Command != "rekal sync" {
+		t.Errorf("holder = %+v (ok=%v), want this pid running rekal sync", holder, ok)
+	}
+
+	waitedFor := ""
+	_, err = AcquireLock(dir, "rekal checkpoint", 250*time.Millisecond, func(h LockHolder) {
+		waitedFor = h.Command
+	})
+	if !IsLocked(err) {
+		t.Fatalf("second AcquireLock err = %v, want LockedError", err)
+	}
+	if !strings.Contains(err.Error(), `"rekal sync"`) {
+		t.Errorf("error %q should name the holder", err)
+	}
+	if waitedFor != "rekal sync" {
+		t.Errorf("onWait holder = %q, want rekal sync", waitedFor)
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if _, ok := ReadLockHolder(dir); ok {
+		t.Error("holder should be cleared after release")
+	}
+
+	second, err := AcquireLock(dir, "rekal checkpoint", 0, nil)
+	if err != nil {
+		t.Fatalf("AcquireLock after release: %v", err)
+	}
+	if err := second.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcquireLock_WaitsForRelease(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := AcquireLock(dir, "rekal index", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		first.Release() //nolint:errcheck
+	}()
+
+	second, err := AcquireLock(dir, "rekal checkpoint", 5*time.Second, nil)
+	if err != nil {
+		t.Fatalf("AcquireLock should succeed once the holder releases: %v", err)
+	}
+	second.Release() //nolint:errcheck
+}
+
+func TestAcquireSharedLock(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	r1, err := AcquireSharedLock(dir, "rekal", 0, nil)
+	if err != nil {
+		t.Fatalf("first AcquireSharedLock: %v", err)
+	}
+	r2, err := AcquireSharedLock(dir, "rekal", 0, nil)
+	if err != nil {
+		t.Fatalf("second AcquireSharedLock should not wait for the first: %v", err)
+	}
+	if _, err := AcquireLock(dir, "rekal checkpoint", 0, nil); !IsLocked(err) {
+		t.Fatalf("AcquireLock while shared held: err = %v, want LockedError", err)
+	}
+	r1.Release() //nolint:errcheck
+	r2.Release() //nolint:errcheck
+
+	w, err := AcquireLock(dir, "rekal checkpoint", 0, nil)
+	if err != nil {
+		t.Fatalf("AcquireLock after shared release: %v", err)
+	}
+	if _, err := AcquireSharedLock(dir, "rekal", 0, nil); !IsLocked(err) {
+		t.Errorf("AcquireSharedLock while exclusive held: err = %v, want LockedError", err)
+	}
+	if holder, ok := ReadLockHolder(dir); !ok || holder.Command != "rekal checkpoint" {
+		t.Errorf("holder = %+v (ok=%v), want the exclusive holder", holder, ok)
+	}
+	w.Release() //nolint:errcheck
+}
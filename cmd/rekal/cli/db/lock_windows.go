@@ -0,0 +1,15 @@
+//go:build windows
+
+package db
+
+import "os"
+
+// tryLockFile is a no-op on Windows (not a release target); DuckDB's own
+// file lock still prevents concurrent writers.
+func tryLockFile(_ *os.File, _ bool) (bool, error) {
+	return true, nil
+}
+
+func unlockFile(_ *os.File) error {
+	return nil
+}
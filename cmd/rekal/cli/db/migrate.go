@@ -0,0 +1,265 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Migration is one numbered, forward-only schema step. Versions start at 1
+// and are contiguous; a migration is never edited once released — add a new
+// one instead.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+	// Func, if set, runs after SQL in the same transaction, for steps that
+	// need Go code. SQL may then be empty.
+	Func func(tx *sql.Tx, mc MigrateContext) error
+}
+
+// MigrateContext describes the repository a DB belongs to, for migrations
+// that rewrite rows against it. The zero value is valid: such migrations
+// then leave the rows as they are.
+type MigrateContext struct {
+	// RepoRoots are the absolute spellings of the repository root that rows
+	// captured by older versions may hold.
+	RepoRoots []string
+	// OutOfRepoPrefix marks stored paths that remain absolute.
+	OutOfRepoPrefix string
+}
+
+// schemaSpec describes the migrations of one database file.
+type schemaSpec struct {
+	name       string // "data.db" / "index.db", for messages
+	migrations []Migration
+	// legacyVersion infers the version of a DB created before schema_version
+	// existed. Returns 0 for an empty DB.
+	legacyVersion func(d *sql.DB) (int, error)
+}
+
+// latest returns the newest version this binary knows.
+func (s schemaSpec) latest() int {
+	return len(s.migrations)
+}
+
+const schemaVersionDDL = `
+CREATE TABLE IF NOT EXISTS schema_version (
+	version         INTEGER PRIMARY KEY,
+	name            VARCHAR NOT NULL,
+	applied_at      TIMESTAMP NOT NULL
+);
+`
+
+// SchemaTooNewError is returned when a DB was written by a newer rekal. Such
+// a DB is never opened: older code could corrupt data it does not understand.
+type SchemaTooNewError struct {
+	DB        string
+	Version   int
+	Supported int
+}
+
+func (e *SchemaTooNewError) Error() string {
+	return fmt.Sprintf("%s has schema v%d but this rekal supports up to v%d — upgrade rekal", e.DB, e.Version, e.Supported)
+}
+
+// MigrationStatus is one row of SchemaStatus.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// SchemaStatus describes the migration state of one database.
+type SchemaStatus struct {
+	DB         string
+	Current    int
+	Latest     int
+	Migrations []MigrationStatus
+}
+
+// Pending returns the number of migrations not yet applied.
+func (s SchemaStatus) Pending() int {
+	if s.Current >= s.Latest {
+		return 0
+	}
+	return s.Latest - s.Current
+}
+
+// migrate brings d up to the latest version of spec. Each migration runs in
+// its own transaction together with its schema_version row. DBs created
+// before versioning are stamped with their inferred version first.
+func migrate(d *sql.DB, spec schemaSpec, mc MigrateContext) (applied int, err error) {
+	current, err := ensureSchemaVersion(d, spec)
+	if err != nil {
+		return 0, err
+	}
+	if current > spec.latest() {
+		return 0, &SchemaTooNewError{DB: spec.name, Version: current, Supported: spec.latest()}
+	}
+	for _, m := range spec.migrations[current:] {
+		if err := applyMigration(d, m, mc); err != nil {
+			return applied, fmt.Errorf("%s migration %d (%s): %w", spec.name, m.Version, m.Name, err)
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+func applyMigration(d *sql.DB, m Migration, mc MigrateContext) error {
+	tx, err := d.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	if m.SQL != "" {
+		if _, err := tx.Exec(m.SQL); err != nil {
+			return err
+		}
+	}
+	if m.Func != nil {
+		if err := m.Func(tx, mc); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO schema_version (version, name, applied_at) VALUES ($1, $2, $3)",
+		m.Version, m.Name, time.Now().UTC(),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ensureSchemaVersion creates schema_version if needed and returns the
+// current version. A pre-versioning DB is stamped with its legacy version.
+func ensureSchemaVersion(d *sql.DB, spec schemaSpec) (int, error) {
+	exists, err := tableExists(d, "schema_version")
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		return currentVersion(d)
+	}
+
+	legacy, err := spec.legacyVersion(d)
+	if err != nil {
+		return 0, fmt.Errorf("detect %s legacy schema: %w", spec.name, err)
+	}
+	// Table and legacy stamps commit together: a crash in between must not
+	// leave an empty schema_version that reads as version 0.
+	tx, err := d.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	if _, err := tx.Exec(schemaVersionDDL); err != nil {
+		return 0, fmt.Errorf("create schema_version: %w", err)
+	}
+	now := time.Now().UTC()
+	for _, m := range spec.migrations[:min(legacy, spec.latest())] {
+		if _, err := tx.Exec(
+			"INSERT INTO schema_version (version, name, applied_at) VALUES ($1, $2, $3)",
+			m.Version, m.Name, now,
+		); err != nil {
+			return 0, fmt.Errorf("stamp legacy schema: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("stamp legacy schema: %w", err)
+	}
+	return legacy, nil
+}
+
+func currentVersion(d *sql.DB) (int, error) {
+	var v int
+	if err := d.QueryRow("SELECT coalesce(max(version), 0) FROM schema_version").Scan(&v); err != nil {
+		return 0, fmt.Errorf("read schema_version: %w", err)
+	}
+	return v, nil
+}
+
+// checkNotTooNew refuses a DB whose schema_version is ahead of spec.
+// DBs without schema_version are older than versioning and always accepted.
+func checkNotTooNew(d *sql.DB, spec schemaSpec) error {
+	exists, err := tableExists(d, "schema_version")
+	if err != nil || !exists {
+		return err
+	}
+	v, err := currentVersion(d)
+	if err != nil {
+		return err
+	}
+	if v > spec.latest() {
+		return &SchemaTooNewError{DB: spec.name, Version: v, Supported: spec.latest()}
+	}
+	return nil
+}
+
+// schemaStatus reports applied and pending migrations without changing d.
+func schemaStatus(d *sql.DB, spec schemaSpec) (SchemaStatus, error) {
+	st := SchemaStatus{DB: spec.name, Latest: spec.latest()}
+
+	applied := make(map[int]time.Time)
+	exists, err := tableExists(d, "schema_version")
+	if err != nil {
+		return st, err
+	}
+	if exists {
+		rows, err := d.Query("SELECT version, applied_at FROM schema_version ORDER BY version")
+		if err != nil {
+			return st, fmt.Errorf("read schema_version: %w", err)
+		}
+		defer rows.Close() //nolint:errcheck
+		for rows.Next() {
+			var v int
+			var at time.Time
+			if err := rows.Scan(&v, &at); err != nil {
+				return st, err
+			}
+			applied[v] = at
+			st.Current = max(st.Current, v)
+		}
+		if err := rows.Err(); err != nil {
+			return st, err
+		}
+	} else {
+		if st.Current, err = spec.legacyVersion(d); err != nil {
+			return st, err
+		}
+		for _, m := range spec.migrations[:min(st.Current, spec.latest())] {
+			applied[m.Version] = time.Time{}
+		}
+	}
+
+	for _, m := range spec.migrations {
+		at, ok := applied[m.Version]
+		st.Migrations = append(st.Migrations, MigrationStatus{Version: m.Version, Name: m.Name, Applied: ok, AppliedAt: at})
+	}
+	return st, nil
+}
+
+func tableExists(d *sql.DB, table string) (bool, error) {
+	var n int
+	err := d.QueryRow(
+		"SELECT count(*) FROM information_schema.tables WHERE table_catalog = current_database() AND table_schema = 'main' AND table_name = $1",
+		table,
+	).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("check table %s: %w", table, err)
+	}
+	return n > 0, nil
+}
+
+func columnExists(d *sql.DB, table, column string) (bool, error) {
+	var n int
+	err := d.QueryRow(
+		"SELECT count(*) FROM information_schema.columns WHERE table_catalog = current_database() AND table_schema = 'main' AND table_name = $1 AND column_name = $2",
+		table, column,
+	).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("check column %s.%s: %w", table, column, err)
+	}
+	return n > 0, nil
+}
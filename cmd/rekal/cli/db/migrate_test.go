@@ -0,0 +1,287 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/session"
+)
+
+// openFixtureDB creates .rekal/<name> in a temp repo and loads fixture SQL
+// (if any) into it. Returns the git root and the open DB.
+func openFixtureDB(t *testing.T, name, fixture string) (string, *sql.DB) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var d *sql.DB
+	var err error
+	if name == "data.db" {
+		d, err = OpenData(dir)
+	} else {
+		d, err = OpenIndex(dir)
+	}
+	if err != nil {
+		t.Fatalf("open %s: %v", name, err)
+	}
+	t.Cleanup(func() { d.Close() })
+	if fixture != "" {
+		sqlText, err := os.ReadFile(filepath.Join("testdata", fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.Exec(string(sqlText)); err != nil {
+			t.Fatalf("load fixture %s: %v", fixture, err)
+		}
+	}
+	return dir, d
+}
+
+func TestMigrate_FreshDBs(t *testing.T) {
+	t.Parallel()
+
+	_, d := openFixtureDB(t, "data.db", "")
+	n, err := MigrateData(d, MigrateContext{})
+	if err != nil {
+		t.Fatalf("MigrateData: %v", err)
+	}
+	if n != dataSchema.latest() {
+		t.Errorf("applied %d, want %d", n, dataSchema.latest())
+	}
+	var nullable string
+	if err := d.QueryRow(
+		"SELECT is_nullable FROM information_schema.columns WHERE table_name = 'sessions' AND column_name = 'source'",
+	).Scan(&nullable); err != nil {
+		t.Fatalf("sessions.source missing after migration: %v", err)
+	}
+	if nullable != "NO" {
+		t.Errorf("sessions.source is_nullable = %q, want NO on a new DB", nullable)
+	}
+
+	_, idx := openFixtureDB(t, "index.db", "")
+	if _, err := MigrateIndex(idx); err != nil {
+		t.Fatalf("MigrateIndex: %v", err)
+	}
+	st, err := IndexSchemaStatus(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Current != indexSchema.latest() || st.Pending() != 0 {
+		t.Errorf("index status = %+v, want fully migrated", st)
+	}
+}
+
+// Every released data.db version must have a fixture in testdata, so each
+// new migration ships with a DB from the version before it.
+func TestMigrate_DataFixtures(t *testing.T) {
+	t.Parallel()
+
+	wantSource := map[int]string{1: "claude", 2: "codex"}
+	for v := 1; v <= dataSchema.latest(); v++ {
+		fixture := fmt.Sprintf("data_v%d.sql", v)
+		t.Run(fixture, func(t *testing.T) {
+			t.Parallel()
+			_, d := openFixtureDB(t, "data.db", fixture)
+
+			before, err := DataSchemaStatus(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if before.Current != v {
+				t.Fatalf("inferred version = %d, want %d", before.Current, v)
+			}
+
+			n, err := MigrateData(d, MigrateContext{})
+			if err != nil {
+				t.Fatalf("MigrateData: %v", err)
+			}
+			if n != dataSchema.latest()-v {
+				t.Errorf("applied %d migrations, want %d", n, dataSchema.latest()-v)
+			}
+
+			after, err := DataSchemaStatus(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if after.Current != dataSchema.latest() || after.Pending() != 0 {
+				t.Errorf("status after = %+v, want fully migrated", after)
+			}
+			for _, m := range after.Migrations {
+				if !m.Applied {
+					t.Errorf("migration %d not recorded as applied", m.Version)
+				}
+			}
+
+			// Existing rows survive and remain usable.
+			var content, source string
+			if err := d.QueryRow("SELECT content FROM turns WHERE session_id = '01SE0000000000000000000001'").Scan(&content); err != nil {
+				t.Fatalf("read turn: %v", err)
+			}
+			if content != "fix the login bug" {
+				t.Errorf("turn content = %q", content)
+			}
+			if want, ok := wantSource[v]; ok {
+				if err := d.QueryRow("SELECT source FROM sessions").Scan(&source); err != nil {
+					t.Fatal(err)
+				}
+				if source != want {
+					t.Errorf("source = %q, want %q", source, want)
+				}
+			}
+			if err := InsertSession(d, "01SE0000000000000000000002", "", "hash2", "human", "", "dev@example.com", "main", "2026-01-11T10:00:00Z", "gemini"); err != nil {
+				t.Errorf("insert into migrated DB: %v", err)
+			}
+
+			if n, err := MigrateData(d, MigrateContext{}); err != nil || n != 0 {
+				t.Errorf("second MigrateData = %d, %v; want 0, nil", n, err)
+			}
+		})
+	}
+}
+
+func TestMigrate_IndexFixtures(t *testing.T) {
+	t.Parallel()
+
+	for v := 1; v <= indexSchema.latest(); v++ {
+		fixture := fmt.Sprintf("index_v%d.sql", v)
+		t.Run(fixture, func(t *testing.T) {
+			t.Parallel()
+			_, d := openFixtureDB(t, "index.db", fixture)
+			if _, err := MigrateIndex(d); err != nil {
+				t.Fatalf("MigrateIndex: %v", err)
+			}
+			st, err := IndexSchemaStatus(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if st.Current != indexSchema.latest() {
+				t.Errorf("version = %d, want %d", st.Current, indexSchema.latest())
+			}
+			var count string
+			if err := d.QueryRow("SELECT value FROM index_state WHERE key = 'session_count'").Scan(&count); err != nil || count != "1" {
+				t.Errorf("index_state lost: %q, %v", count, err)
+			}
+		})
+	}
+}
+
+func TestMigrate_RefusesNewerSchema(t *testing.T) {
+	t.Parallel()
+
+	dir, d := openFixtureDB(t, "data.db", "")
+	if err := InitDataSchema(d, MigrateContext{}); err != nil {
+		t.Fatal(err)
+	}
+	future := dataSchema.latest() + 1
+	if _, err := d.Exec("INSERT INTO schema_version (version, name, applied_at) VALUES ($1, 'from the future', now())", future); err != nil {
+		t.Fatal(err)
+	}
+
+	var tooNew *SchemaTooNewError
+	if _, err := MigrateData(d, MigrateContext{}); !errors.As(err, &tooNew) {
+		t.Errorf("MigrateData err = %v, want SchemaTooNewError", err)
+	}
+	d.Close()
+
+	_, err := OpenData(dir)
+	if !errors.As(err, &tooNew) {
+		t.Fatalf("OpenData err = %v, want SchemaTooNewError", err)
+	}
+	if tooNew.Version != future || tooNew.Supported != dataSchema.latest() {
+		t.Errorf("error = %+v", tooNew)
+	}
+}
+
+// Sessions captured before content IDs are merged by content, and their
+// content IDs resolve to the session that holds them.
+func TestMigrate_MergesDuplicateSessions(t *testing.T) {
+	t.Parallel()
+	_, d := openFixtureDB(t, "data.db", "data_v4.sql")
+
+	// 01SE...2 repeats 01SE...1 captured on another machine; 01SE...3 differs.
+	for _, stmt := range []string{
+		`INSERT INTO sessions (id, session_hash, captured_at, actor_type, user_email, branch, source)
+		 VALUES ('01SE0000000000000000000002', 'hash2', '2026-01-12 10:00:00', 'human', 'dev@example.com', 'main', 'codex'),
+		        ('01SE0000000000000000000003', 'hash3', '2026-01-11 10:00:00', 'human', 'dev@example.com', 'main', 'codex')`,
+		`INSERT INTO turns (id, session_id, turn_index, role, content, ts)
+		 VALUES ('01TU0000000000000000000002', '01SE0000000000000000000002', 0, 'human', 'fix the login bug', '2026-01-10 10:00:00'),
+		        ('01TU0000000000000000000003', '01SE0000000000000000000003', 0, 'human', 'fix the signup bug', '2026-01-10 10:00:00')`,
+		`INSERT INTO tool_calls (id, session_id, call_order, tool, path, cmd_prefix)
+		 VALUES ('01TC0000000000000000000002', '01SE0000000000000000000002', 0, 'Edit', 'main.go', NULL)`,
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := MigrateData(d, MigrateContext{}); err != nil {
+		t.Fatalf("MigrateData: %v", err)
+	}
+
+	contentID := session.ContentID(&session.SessionPayload{
+		Source:    "codex",
+		Turns:     []session.Turn{{Role: "human", Content: "fix the login bug", Timestamp: time.Date(2026, 1, 10, 10, 0, 0, 0, time.UTC)}},
+		ToolCalls: []session.ToolCall{{Tool: "Edit", Path: "main.go"}},
+	})
+	for id, want := range map[string]string{
+		"01SE0000000000000000000001": "01SE0000000000000000000001",
+		"01SE0000000000000000000002": "01SE0000000000000000000001", // later duplicate
+		contentID:                    "01SE0000000000000000000001",
+		"01SE0000000000000000000003": "01SE0000000000000000000003",
+		"01SE0000000000000000000009": "",
+	} {
+		got, err := ResolveSessionID(d, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("ResolveSessionID(%s) = %q, want %q", id, got, want)
+		}
+	}
+
+	// History is not rewritten: the duplicate row and its turns remain.
+	var n int
+	if err := d.QueryRow("SELECT count(*) FROM turns WHERE session_id = '01SE0000000000000000000002'").Scan(&n); err != nil || n != 1 {
+		t.Errorf("duplicate turns = %d, %v; want 1", n, err)
+	}
+	if err := d.QueryRow("SELECT count(*) FROM session_aliases").Scan(&n); err != nil || n != 3 {
+		t.Errorf("aliases = %d, %v; want 3", n, err)
+	}
+}
+
+func TestMigrate_RelativizesRepoPaths(t *testing.T) {
+	t.Parallel()
+	dir, d := openFixtureDB(t, "data.db", "data_v5.sql")
+
+	for _, stmt := range []string{
+		fmt.Sprintf(`INSERT INTO turns (id, session_id, turn_index, role, content, ts)
+		 VALUES ('01TU0000000000000000000002', '01SE0000000000000000000001', 1, 'assistant', 'edited %s/auth/login.go', '2026-01-10 10:00:01')`, dir),
+		fmt.Sprintf(`INSERT INTO tool_calls (id, session_id, call_order, tool, path, cmd_prefix)
+		 VALUES ('01TC0000000000000000000002', '01SE0000000000000000000001', 1, 'Read', '%s/auth/login.go', 'cd %s && go')`, dir, dir),
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The root comes from the caller, not from where the DB file lives.
+	mc := MigrateContext{RepoRoots: []string{dir}, OutOfRepoPrefix: "ext:"}
+	if n, err := MigrateData(d, mc); err != nil || n != 1 {
+		t.Fatalf("MigrateData = %d, %v; want 1, nil", n, err)
+	}
+
+	var content, path, cmd string
+	if err := d.QueryRow("SELECT content FROM turns WHERE id = '01TU0000000000000000000002'").Scan(&content); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.QueryRow("SELECT path, cmd_prefix FROM tool_calls WHERE id = '01TC0000000000000000000002'").Scan(&path, &cmd); err != nil {
+		t.Fatal(err)
+	}
+	if content != "edited auth/login.go" || path != "auth/login.go" || cmd != "cd . && go" {
+		t.Errorf("after migration: content %q, path %q, cmd %q", content, path, cmd)
+	}
+}
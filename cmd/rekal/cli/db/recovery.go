@@ -0,0 +1,89 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// OrphanReport counts rows left behind by a checkpoint that was interrupted
+// before batched writes existed (sessions inserted, checkpoint never linked).
+type OrphanReport struct {
+	// UnlinkedSessions are locally captured sessions not referenced by any
+	// checkpoint. Their turns and tool calls may be incomplete.
+	UnlinkedSessions int
+	// EmptyCheckpoints are unexported checkpoints with no linked sessions.
+	EmptyCheckpoints int
+}
+
+// Clean reports whether no orphaned rows were found.
+func (r OrphanReport) Clean() bool {
+	return r.UnlinkedSessions == 0 && r.EmptyCheckpoints == 0
+}
+
+// Sessions imported from the wire format (session_hash "wire:<id>") are never
+// orphans: their checkpoint frame may have been skipped, corrupt or deduped,
+// and unlike a local capture they cannot be re-read from a transcript.
+const unlinkedSessionsWhere = `id NOT IN (SELECT session_id FROM checkpoint_sessions) AND NOT starts_with(session_hash, 'wire:')`
+
+const emptyCheckpointsWhere = `NOT exported AND id NOT IN (SELECT checkpoint_id FROM checkpoint_sessions)`
+
+// FindOrphans scans the data DB for rows left by an interrupted checkpoint.
+func FindOrphans(d *sql.DB) (OrphanReport, error) {
+	var r OrphanReport
+	if err := d.QueryRow("SELECT count(*) FROM sessions WHERE " + unlinkedSessionsWhere).Scan(&r.UnlinkedSessions); err != nil {
+		return r, fmt.Errorf("count unlinked sessions: %w", err)
+	}
+	if err := d.QueryRow("SELECT count(*) FROM checkpoints WHERE " + emptyCheckpointsWhere).Scan(&r.EmptyCheckpoints); err != nil {
+		return r, fmt.Errorf("count empty checkpoints: %w", err)
+	}
+	return r, nil
+}
+
+// RepairOrphans deletes the rows counted by FindOrphans and clears the
+// checkpoint_state cache so the next checkpoint re-reads every transcript
+// and captures the affected sessions in full. Returns what was removed.
+//
+// Statements run in autocommit mode, children before parents: DuckDB does
+// not see same-transaction deletes when checking foreign keys. Each step is
+// idempotent, so an interrupted repair can simply be re-run. The sessions
+// are deleted in one transaction with the aliases that name them, so no
+// alias is left resolving to a removed session.
+func RepairOrphans(d *sql.DB) (OrphanReport, error) {
+	r, err := FindOrphans(d)
+	if err != nil || r.Clean() {
+		return r, err
+	}
+	const unlinkedIDs = "(SELECT id FROM sessions WHERE " + unlinkedSessionsWhere + ")"
+	steps := [][]string{
+		{"DELETE FROM turns WHERE session_id IN " + unlinkedIDs},
+		{"DELETE FROM tool_calls WHERE session_id IN " + unlinkedIDs},
+		{
+			"DELETE FROM session_aliases WHERE session_id IN " + unlinkedIDs + " OR alias IN " + unlinkedIDs,
+			"DELETE FROM sessions WHERE " + unlinkedSessionsWhere,
+		},
+		{"DELETE FROM files_touched WHERE checkpoint_id IN (SELECT id FROM checkpoints WHERE " + emptyCheckpointsWhere + ")"},
+		{"DELETE FROM checkpoints WHERE " + emptyCheckpointsWhere},
+		{"DELETE FROM checkpoint_state"},
+	}
+	for _, stmts := range steps {
+		if err := execInTx(d, stmts); err != nil {
+			return r, fmt.Errorf("repair orphans: %w", err)
+		}
+	}
+	return r, nil
+}
+
+// execInTx runs stmts in one transaction.
+func execInTx(d *sql.DB, stmts []string) error {
+	tx, err := d.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
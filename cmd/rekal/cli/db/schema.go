@@ -4,39 +4,126 @@ import "database/sql"
 
 // InitDataSchema creates the data DB tables if they do not exist.
 // Data DB is the source of truth — append-only, never rebuilt.
-func InitDataSchema(d *sql.DB) error {
-	if _, err := d.Exec(dataDDL); err != nil {
-		return err
-	}
-	return MigrateDataSchema(d)
+func InitDataSchema(d *sql.DB, mc MigrateContext) error {
+	return MigrateDataSchema(d, mc)
 }
 
-// MigrateDataSchema applies forward-only migrations to an existing data DB.
-// Safe to call multiple times — each migration checks before applying.
-func MigrateDataSchema(d *sql.DB) error {
-	// Migration: add source column if missing (existing DBs pre-multi-agent).
-	var count int
-	err := d.QueryRow(`SELECT count(*) FROM information_schema.columns
-		WHERE table_name = 'sessions' AND column_name = 'source'`).Scan(&count)
-	if err == nil && count == 0 {
-		if _, err := d.Exec(`ALTER TABLE sessions ADD COLUMN source VARCHAR NOT NULL DEFAULT 'claude'`); err != nil {
-			return err
-		}
-	}
-	return nil
+// MigrateDataSchema applies pending numbered migrations to the data DB.
+// Safe to call on every open — applied migrations are recorded in
+// schema_version and skipped.
+func MigrateDataSchema(d *sql.DB, mc MigrateContext) error {
+	_, err := migrate(d, dataSchema, mc)
+	return err
 }
 
 // InitIndexSchema creates the index DB tables if they do not exist.
 // Index DB is derived — can be dropped and rebuilt from data DB.
 func InitIndexSchema(d *sql.DB) error {
-	if indexDDL == "" {
-		return nil
-	}
-	_, err := d.Exec(indexDDL)
+	return MigrateIndexSchema(d)
+}
+
+// MigrateIndexSchema applies pending numbered migrations to the index DB.
+func MigrateIndexSchema(d *sql.DB) error {
+	_, err := migrate(d, indexSchema, MigrateContext{})
 	return err
 }
 
-const dataDDL = `
+// MigrateData applies pending data DB migrations and returns how many ran.
+func MigrateData(d *sql.DB, mc MigrateContext) (int, error) {
+	return migrate(d, dataSchema, mc)
+}
+
+// MigrateIndex applies pending index DB migrations and returns how many ran.
+func MigrateIndex(d *sql.DB) (int, error) {
+	return migrate(d, indexSchema, MigrateContext{})
+}
+
+// DataSchemaStatus reports applied and pending data DB migrations.
+func DataSchemaStatus(d *sql.DB) (SchemaStatus, error) {
+	return schemaStatus(d, dataSchema)
+}
+
+// IndexSchemaStatus reports applied and pending index DB migrations.
+func IndexSchemaStatus(d *sql.DB) (SchemaStatus, error) {
+	return schemaStatus(d, indexSchema)
+}
+
+// dataSchema lists data DB migrations in order. Append only.
+var dataSchema = schemaSpec{
+	name: "data.db",
+	migrations: []Migration{
+		{Version: 1, Name: "initial schema", SQL: dataDDLv1},
+		// Pre-multi-agent DBs have no source column. DuckDB cannot add a
+		// column with a NOT NULL constraint, so for them the default carries
+		// it; new DBs get the column, NOT NULL, from dataDDLv1.
+		{Version: 2, Name: "sessions.source", SQL: `ALTER TABLE sessions ADD COLUMN IF NOT EXISTS source VARCHAR DEFAULT 'claude'`},
+		{Version: 3, Name: "imported_heads", SQL: importedHeadsDDL},
+		// NULL for sessions captured locally; set on import from the
+		// signature of the rekal branch commit that added the session.
+		{Version: 4, Name: "sessions.verified", SQL: `ALTER TABLE sessions ADD COLUMN IF NOT EXISTS verified BOOLEAN`},
+		{Version: 5, Name: "session_aliases", SQL: sessionAliasesDDL, Func: mergeDuplicateSessions},
+		// Rows captured before paths were stored repo-relative hold
+		// absolute, checkout-specific paths.
+		{Version: 6, Name: "repo-relative paths", Func: relativizeRepoPaths},
+	},
+	legacyVersion: func(d *sql.DB) (int, error) {
+		if ok, err := tableExists(d, "sessions"); err != nil || !ok {
+			return 0, err
+		}
+		if ok, err := columnExists(d, "sessions", "source"); err != nil || !ok {
+			return 1, err
+		}
+		return 2, nil
+	},
+}
+
+// indexSchema lists index DB migrations in order. Append only.
+var indexSchema = schemaSpec{
+	name: "index.db",
+	migrations: []Migration{
+		{Version: 1, Name: "initial schema", SQL: indexDDLv1},
+		{Version: 2, Name: "imported_heads", SQL: importedHeadsDDL},
+		{Version: 3, Name: "session_facets.verified", SQL: `ALTER TABLE session_facets ADD COLUMN IF NOT EXISTS verified BOOLEAN DEFAULT TRUE`},
+	},
+	legacyVersion: func(d *sql.DB) (int, error) {
+		if ok, err := tableExists(d, "turns_ft"); err != nil || !ok {
+			return 0, err
+		}
+		return 1, nil
+	},
+}
+
+// importedHeadsDDL records, per imported rekal branch, how much of its body
+// was imported and the chain hash over it. The next import checks the new
+// body still starts with that history. Shared by both DBs: data.db for
+// 'rekal sync --self' and init, index.db for team sync. The index copy
+// survives DropIndexTables because it is not derived from data.db.
+const importedHeadsDDL = `
+CREATE TABLE IF NOT EXISTS imported_heads (
+	branch          VARCHAR PRIMARY KEY,
+	n_frames        INTEGER NOT NULL,
+	chain_head      VARCHAR NOT NULL,
+	imported_at     TIMESTAMP NOT NULL
+);
+`
+
+// sessionAliasesDDL maps session IDs that are not stored as a session row to
+// the row that holds the session: legacy duplicates of one transcript, and
+// the content ID of sessions stored under an older random ID. Session rows
+// and the frames already pushed keep their IDs; readers resolve through
+// this table instead.
+const sessionAliasesDDL = `
+CREATE TABLE IF NOT EXISTS session_aliases (
+	alias           VARCHAR PRIMARY KEY,
+	session_id      VARCHAR NOT NULL
+);
+`
+
+// dataDDLv1 is the data DB schema of a new install as first released — the
+// source of truth, append-only. Later changes are separate migrations.
+// sessions.source is part of it, as it was of the schema before versioning;
+// migration 2 only adds it to DBs older than that.
+const dataDDLv1 = `
 CREATE TABLE IF NOT EXISTS sessions (
 	id                VARCHAR PRIMARY KEY,
 	parent_session_id VARCHAR,
@@ -98,8 +185,8 @@ CREATE TABLE IF NOT EXISTS checkpoint_state (
 );
 `
 
-// Index DDL defines the derived index tables — rebuilt from data DB.
-const indexDDL = `
+// indexDDLv1 defines the derived index tables — rebuilt from data DB.
+const indexDDLv1 = `
 CREATE TABLE IF NOT EXISTS turns_ft (
 	id              VARCHAR PRIMARY KEY,
 	session_id      VARCHAR NOT NULL,
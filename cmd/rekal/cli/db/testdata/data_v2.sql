@@ -0,0 +1,73 @@
+-- data.db as written by rekal with multi-agent support, before schema_version (schema v2).
+CREATE TABLE IF NOT EXISTS sessions (
+	id                VARCHAR PRIMARY KEY,
+	parent_session_id VARCHAR,
+	session_hash      VARCHAR NOT NULL,
+	captured_at       TIMESTAMP NOT NULL,
+	actor_type        VARCHAR NOT NULL DEFAULT 'human',
+	agent_id          VARCHAR,
+	user_email        VARCHAR,
+	branch            VARCHAR,
+	source            VARCHAR NOT NULL DEFAULT 'claude'
+);
+
+CREATE TABLE IF NOT EXISTS turns (
+	id              VARCHAR PRIMARY KEY,
+	session_id      VARCHAR NOT NULL REFERENCES sessions(id),
+	turn_index      INTEGER NOT NULL,
+	role            VARCHAR NOT NULL,
+	content         VARCHAR NOT NULL,
+	ts              TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS tool_calls (
+	id              VARCHAR PRIMARY KEY,
+	session_id      VARCHAR NOT NULL REFERENCES sessions(id),
+	call_order      INTEGER NOT NULL,
+	tool            VARCHAR NOT NULL,
+	path            VARCHAR,
+	cmd_prefix      VARCHAR
+);
+
+CREATE TABLE IF NOT EXISTS checkpoints (
+	id              VARCHAR PRIMARY KEY,
+	git_sha         VARCHAR NOT NULL,
+	git_branch      VARCHAR NOT NULL,
+	user_email      VARCHAR NOT NULL,
+	ts              TIMESTAMP NOT NULL,
+	actor_type      VARCHAR NOT NULL DEFAULT 'human',
+	agent_id        VARCHAR,
+	exported        BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS files_touched (
+	id              VARCHAR PRIMARY KEY,
+	checkpoint_id   VARCHAR NOT NULL REFERENCES checkpoints(id),
+	file_path       VARCHAR NOT NULL,
+	change_type     VARCHAR NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS checkpoint_sessions (
+	checkpoint_id   VARCHAR NOT NULL REFERENCES checkpoints(id),
+	session_id      VARCHAR NOT NULL REFERENCES sessions(id),
+	PRIMARY KEY (checkpoint_id, session_id)
+);
+
+CREATE TABLE IF NOT EXISTS checkpoint_state (
+	file_path   VARCHAR PRIMARY KEY,
+	byte_size   BIGINT NOT NULL,
+	file_hash   VARCHAR NOT NULL
+);
+
+INSERT INTO sessions (id, session_hash, captured_at, actor_type, user_email, branch, source)
+VALUES ('01SE0000000000000000000001', 'hash1', '2026-01-10 10:00:00', 'human', 'dev@example.com', 'main', 'codex');
+
+INSERT INTO checkpoints (id, git_sha, git_branch, user_email, ts, actor_type, exported)
+VALUES ('01CP0000000000000000000001', 'aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa', 'main', 'dev@example.com', '2026-01-10 10:01:00', 'human', TRUE);
+INSERT INTO checkpoint_sessions (checkpoint_id, session_id) VALUES ('01CP0000000000000000000001', '01SE0000000000000000000001');
+INSERT INTO files_touched (id, checkpoint_id, file_path, change_type) VALUES ('01FT0000000000000000000001', '01CP0000000000000000000001', 'main.go', 'M');
+INSERT INTO turns (id, session_id, turn_index, role, content, ts)
+VALUES ('01TU0000000000000000000001', '01SE0000000000000000000001', 0, 'human', 'fix the login bug', '2026-01-10 10:00:00');
+INSERT INTO tool_calls (id, session_id, call_order, tool, path, cmd_prefix)
+VALUES ('01TC0000000000000000000001', '01SE0000000000000000000001', 0, 'Edit', 'main.go', NULL);
+INSERT INTO checkpoint_state (file_path, byte_size, file_hash) VALUES ('/tmp/session.jsonl', 42, 'abc');
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/spf13/cobra"
+)
+
+func newDoctorCmd() *cobra.Command {
+	var fix bool
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the data DB and git hooks for problems left by older versions",
+		Long: `Check the data DB for rows left by interrupted checkpoints, and the git
+hooks for scripts written by an older rekal.
+
+Checkpoints are written in a single transaction, but versions before that
+could be interrupted (Ctrl-C, crash) mid-write, leaving sessions that belong
+to no checkpoint — possibly with only some of their turns — and empty
+checkpoints.
+
+Hooks installed by versions before the .rekal lock do not pass --hook, so a
+contended lock can hold up or fail a commit or push.
+
+With --fix, orphaned rows are deleted and the transcript cache is cleared,
+so the next 'rekal checkpoint' captures the affected sessions in full, and
+stale rekal hooks are rewritten. Hooks not managed by rekal are never touched.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+
+			gitRoot, err := EnsureGitRoot()
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			if err := EnsureInitDone(gitRoot); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+
+			return runLocked(cmd, gitRoot, func() error {
+				if err := doDoctor(gitRoot, cmd.OutOrStdout(), fix); err != nil {
+					fmt.Fprintln(cmd.ErrOrStderr(), err)
+					return NewSilentError(err)
+				}
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().BoolVar(&fix, "fix", false, "Delete orphaned rows so they are re-captured")
+	return cmd
+}
+
+// doDoctor reports orphaned rows and optionally repairs them. Returns an
+// error when problems remain so scripts can detect them.
+func doDoctor(gitRoot string, w io.Writer, fix bool) error {
+	dataDB, err := db.OpenData(gitRoot)
+	if err != nil {
+		return fmt.Errorf("open data DB: %w", err)
+	}
+	defer dataDB.Close()
+
+	var report db.OrphanReport
+	if fix {
+		report, err = db.RepairOrphans(dataDB)
+	} else {
+		report, err = db.FindOrphans(dataDB)
+	}
+	if err != nil {
+		return err
+	}
+
+	stale := staleHooks(gitRoot)
+	if len(stale) > 0 {
+		fmt.Fprintf(w, "stale rekal hooks:           %s\n", strings.Join(stale, ", "))
+		if fix {
+			if err := refreshHooks(gitRoot); err != nil {
+				return fmt.Errorf("update hooks: %w", err)
+			}
+			fmt.Fprintln(w, "rekal: hooks updated")
+		}
+	}
+
+	if report.Clean() {
+		fmt.Fprintln(w, "rekal: data DB ok")
+		if len(stale) > 0 && !fix {
+			return fmt.Errorf("rekal: stale hooks found — run 'rekal doctor --fix' to update them")
+		}
+		return nil
+	}
+
+	fmt.Fprintf(w, "sessions without checkpoint: %d\n", report.UnlinkedSessions)
+	fmt.Fprintf(w, "empty checkpoints:           %d\n", report.EmptyCheckpoints)
+	if fix {
+		fmt.Fprintln(w, "rekal: orphaned rows removed — run 'rekal checkpoint' to re-capture")
+		return nil
+	}
+	return fmt.Errorf("rekal: orphaned rows found — run 'rekal doctor --fix' to repair")
+}
@@ -0,0 +1,246 @@
+package cli
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/crypt"
+)
+
+// Wire frames can be encrypted, for rekal branches pushed to a public
+// repository. The team's recipients — X25519 public keys in age's format,
+// one per line — are committed to .rekal-recipients at the repository root:
+//
+//	age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p # alice
+//	age1lggyhqrw2nlhcxprm67z43rta597azn8gknawjehu9d9dl0jq3yqqvfafg # bob
+//
+// While the file exists, the session and checkpoint frames rekal writes, and
+// dict.bin, are encrypted under a random data key (see codec/seal.go). The
+// data key is wrapped for every recipient and stored under keys/ on the
+// branch; a new one is made when the recipient list changes. Readers unwrap
+// the keys with the identities in rekal.identity (default
+// ~/.config/rekal/identity) and skip what they cannot decrypt.
+
+const (
+	recipientsFile    = ".rekal-recipients"
+	identityConfigKey = "rekal.identity"
+
+	// keyDir is the tree entry that holds the wrapped data keys, one file
+	// per key ID.
+	keyDir = "keys"
+)
+
+// keyFileName returns the file name of wrapped key id within keyDir.
+func keyFileName(id uint32) string {
+	return fmt.Sprintf("%08x.key", id)
+}
+
+// encryptionRecipients returns the recipients in .rekal-recipients, or nil
+// if the file does not exist and frames are written in the clear.
+func encryptionRecipients(gitRoot string) ([]*crypt.Recipient, error) {
+	f, err := os.Open(filepath.Join(gitRoot, recipientsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rcpts, err := crypt.ParseRecipients(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", recipientsFile, err)
+	}
+	return rcpts, nil
+}
+
+// identityFile returns the path of the identity file: rekal.identity, or
+// rekal/identity in the user's config directory.
+func identityFile() string {
+	if p := gitConfigValue(identityConfigKey); p != "" {
+		if rest, ok := strings.CutPrefix(p, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				return filepath.Join(home, rest)
+			}
+		}
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "rekal", "identity")
+}
+
+// loadIdentities returns the identities in the identity file, or nil if
+// there is none.
+func loadIdentities() ([]*crypt.Identity, error) {
+	path := identityFile()
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ids, err := crypt.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ids, nil
+}
+
+// readBranchKeys returns the wrapped data keys on ref by key ID. Nil if ref
+// has none.
+func readBranchKeys(gitRoot, ref string) (map[uint32][]byte, error) {
+	out, err := exec.Command("git", "-C", gitRoot, "ls-tree", "--name-only", ref+":"+keyDir).Output()
+	if err != nil {
+		return nil, nil // no keys/ on ref
+	}
+	var keys map[uint32][]byte
+	for _, name := range strings.Fields(string(out)) {
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, ".key"), 16, 32)
+		if err != nil || name != keyFileName(uint32(id)) {
+			return nil, fmt.Errorf("%s: unexpected file %s/%s", ref, keyDir, name)
+		}
+		if keys == nil {
+			keys = make(map[uint32][]byte)
+		}
+		keys[uint32(id)] = gitShowFile(gitRoot, ref, keyDir+"/"+name)
+	}
+	return keys, nil
+}
+
+// branchDataKeys returns the data keys on ref that the local identities
+// unwrap, by key ID.
+func branchDataKeys(gitRoot, ref string) (map[uint32][]byte, error) {
+	wrapped, err := readBranchKeys(gitRoot, ref)
+	if err != nil || len(wrapped) == 0 {
+		return nil, err
+	}
+	ids, err := loadIdentities()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	keys := make(map[uint32][]byte)
+	for id, w := range wrapped {
+		key, err := crypt.Unwrap(w, ids)
+		if errors.Is(err, crypt.ErrNotRecipient) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s/%s: %w", ref, keyDir, keyFileName(id), err)
+		}
+		if codec.KeyID(key) != id {
+			return nil, fmt.Errorf("%s: %s/%s holds key %08x", ref, keyDir, keyFileName(id), codec.KeyID(key))
+		}
+		keys[id] = key
+	}
+	return keys, nil
+}
+
+// readBranchDictData returns dict.bin on ref, decrypted if it is sealed.
+// Empty if ref has none. The error wraps codec.ErrNoKey if none of the
+// local identities can decrypt it.
+func readBranchDictData(gitRoot, ref string) ([]byte, error) {
+	data := gitShowFile(gitRoot, ref, "dict.bin")
+	if !codec.IsSealed(data) {
+		return data, nil
+	}
+	keys, err := branchDataKeys(gitRoot, ref)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := codec.Open(keys, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: dict.bin: %w", ref, err)
+	}
+	return plain, nil
+}
+
+// wireSeal is the data key new frames and dict.bin on a branch are
+// encrypted with, and the wrapped key to store under keys/ if it is new.
+type wireSeal struct {
+	Key     []byte
+	NewKeys map[uint32][]byte
+}
+
+// branchSeal returns the seal for writing to ref, or nil if
+// .rekal-recipients does not exist. It reuses a key on ref wrapped for
+// exactly the current recipients that the local identities unwrap, and
+// otherwise makes a new one. Key IDs are hashes of the keys, not creation
+// order; should several keys qualify, the highest ID wins so every writer
+// picks the same one. The writer must be a recipient, or it could not read
+// its own branch back.
+func branchSeal(gitRoot, ref string) (*wireSeal, error) {
+	rcpts, err := encryptionRecipients(gitRoot)
+	if err != nil || rcpts == nil {
+		return nil, err
+	}
+	ids, err := loadIdentities()
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(ids, func(id *crypt.Identity) bool {
+		return slices.ContainsFunc(rcpts, id.Recipient().Equal)
+	}) {
+		return nil, fmt.Errorf("frames are encrypted for %s, but no identity in %s is listed there — run 'rekal keygen --add'", recipientsFile, identityFile())
+	}
+
+	wrapped, err := readBranchKeys(gitRoot, ref)
+	if err != nil {
+		return nil, err
+	}
+	keyIDs := make([]uint32, 0, len(wrapped))
+	for id := range wrapped {
+		keyIDs = append(keyIDs, id)
+	}
+	slices.Sort(keyIDs)
+	for _, id := range slices.Backward(keyIDs) {
+		if wr, err := crypt.WrappedRecipients(wrapped[id]); err != nil || !crypt.SameRecipients(wr, rcpts) {
+			continue
+		}
+		if key, err := crypt.Unwrap(wrapped[id], ids); err == nil && codec.KeyID(key) == id {
+			return &wireSeal{Key: key}, nil
+		}
+	}
+
+	key := make([]byte, codec.KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate data key: %w", err)
+	}
+	w, err := crypt.Wrap(key, rcpts)
+	if err != nil {
+		return nil, err
+	}
+	return &wireSeal{Key: key, NewKeys: map[uint32][]byte{codec.KeyID(key): w}}, nil
+}
+
+// apply makes enc encrypt with the seal's key and records a new key in u.
+// A nil seal leaves both unchanged.
+func (s *wireSeal) apply(enc *codec.Encoder, u *wireUpdate) error {
+	if s == nil {
+		return nil
+	}
+	if err := enc.SetSealKey(s.Key); err != nil {
+		return err
+	}
+	for id, w := range s.NewKeys {
+		if u.NewKeys == nil {
+			u.NewKeys = make(map[uint32][]byte)
+		}
+		u.NewKeys[id] = w
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package cli
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -12,26 +14,38 @@ import (
 
 // exportNewFrames reads existing wire format from the orphan branch, appends
 // frames for any unexported checkpoints from DuckDB, and returns the updated
-// body + dict. Returns (nil, nil, nil) if there are no unexported checkpoints.
-func exportNewFrames(gitRoot string) ([]byte, []byte, error) {
+// body + dict. Returns nil if there are no unexported checkpoints.
+func exportNewFrames(gitRoot string) (*wireUpdate, error) {
 	dataDB, err := db.OpenData(gitRoot)
 	if err != nil {
-		return nil, nil, fmt.Errorf("open data DB: %w", err)
+		return nil, fmt.Errorf("open data DB: %w", err)
 	}
 	defer dataDB.Close()
 
+	// Never put checkout-specific absolute paths on the wire: the
+	// migrations rewrite rows captured before paths were repo-relative.
+	if err := db.MigrateDataSchema(dataDB, dataMigrateContext(gitRoot)); err != nil {
+		return nil, fmt.Errorf("migrate data schema: %w", err)
+	}
+
 	checkpoints, err := db.QueryUnexportedCheckpoints(dataDB)
 	if err != nil {
-		return nil, nil, fmt.Errorf("query unexported checkpoints: %w", err)
+		return nil, fmt.Errorf("query unexported checkpoints: %w", err)
 	}
 	if len(checkpoints) == 0 {
-		return nil, nil, nil
+		return nil, nil
 	}
 
 	// Load existing wire format from orphan branch.
 	branch := rekalBranchName()
-	bodyData := gitShowFile(gitRoot, branch, "rekal.body")
-	dictData := gitShowFile(gitRoot, branch, "dict.bin")
+	bodyData, err := readBranchBody(gitRoot, branch)
+	if err != nil {
+		return nil, fmt.Errorf("read rekal body: %w", err)
+	}
+	dictData, err := readBranchDictData(gitRoot, branch)
+	if err != nil {
+		return nil, fmt.Errorf("read rekal dict: %w", err)
+	}
 
 	dict := codec.NewDict()
 	if len(dictData) > 0 {
@@ -44,108 +58,41 @@ func exportNewFrames(gitRoot string) ([]byte, []byte, error) {
 	if len(body) == 0 {
 		body = codec.NewBody()
 	}
+	committed := len(body)
 
-	enc, err := codec.NewEncoder()
+	enc, err := newBranchEncoder(gitRoot, branch, body)
 	if err != nil {
-		return nil, nil, fmt.Errorf("create encoder: %w", err)
+		return nil, err
 	}
 	defer enc.Close()
 
+	u := &wireUpdate{Committed: committed}
+	seal, err := branchSeal(gitRoot, branch)
+	if err != nil {
+		return nil, err
+	}
+	if err := seal.apply(enc, u); err != nil {
+		return nil, err
+	}
+
 	var exportedIDs []string
 
 	for _, cp := range checkpoints {
 		// Query sessions linked to this checkpoint.
 		sessionIDs, err := db.QuerySessionsByCheckpoint(dataDB, cp.ID)
 		if err != nil {
-			return nil, nil, fmt.Errorf("query sessions for checkpoint %s: %w", cp.ID, err)
+			return nil, fmt.Errorf("query sessions for checkpoint %s: %w", cp.ID, err)
 		}
 
 		var sessionRefs []uint64
 
 		for _, sid := range sessionIDs {
-			sess, err := db.QuerySession(dataDB, sid)
+			sf, err := sessionFrameFromDB(dataDB, dict, sid)
 			if err != nil {
-				return nil, nil, fmt.Errorf("query session %s: %w", sid, err)
-			}
-			turns, err := db.QueryTurns(dataDB, sid)
-			if err != nil {
-				return nil, nil, fmt.Errorf("query turns for %s: %w", sid, err)
-			}
-			toolCalls, err := db.QueryToolCalls(dataDB, sid)
-			if err != nil {
-				return nil, nil, fmt.Errorf("query tool_calls for %s: %w", sid, err)
-			}
-
-			sessRef := dict.LookupOrAdd(codec.NSSessions, sid)
-			emailRef := dict.LookupOrAdd(codec.NSEmails, sess.Email)
-			branchRef := uint64(0)
-			if sess.Branch != "" {
-				branchRef = dict.LookupOrAdd(codec.NSBranches, sess.Branch)
-			}
-
-			actorType := codec.ActorHuman
-			agentIDRef := uint64(0)
-			if sess.ActorType == "agent" {
-				actorType = codec.ActorAgent
-				if sess.AgentID != "" {
-					agentIDRef = dict.LookupOrAdd(codec.NSEmails, sess.AgentID)
-				}
-			}
-
-			capturedAt, _ := time.Parse(time.RFC3339, sess.CapturedAt)
-			sf := &codec.SessionFrame{
-				SessionRef: sessRef,
-				CapturedAt: capturedAt,
-				EmailRef:   emailRef,
-				ActorType:  actorType,
-				AgentIDRef: agentIDRef,
-			}
-
-			// Build turn records with delta timestamps.
-			var prevTs time.Time
-			for _, t := range turns {
-				role := codec.RoleHuman
-				if t.Role == "assistant" {
-					role = codec.RoleAssistant
-				}
-				var tsDelta uint64
-				if t.Ts != "" {
-					ts, _ := time.Parse(time.RFC3339, t.Ts)
-					if !prevTs.IsZero() && !ts.IsZero() {
-						delta := ts.Sub(prevTs)
-						if delta > 0 {
-							tsDelta = uint64(delta.Seconds())
-						}
-					}
-					prevTs = ts
-				}
-				sf.Turns = append(sf.Turns, codec.TurnRecord{
-					Role:      role,
-					TsDelta:   tsDelta,
-					BranchRef: branchRef,
-					Text:      t.Content,
-				})
-			}
-
-			// Build tool call records.
-			for _, tc := range toolCalls {
-				toolCode := codec.ToolCode(tc.Tool)
-				tcr := codec.ToolCallRecord{
-					Tool: toolCode,
-				}
-				if tc.Path == "" {
-					tcr.PathFlag = codec.PathNull
-				} else {
-					pathRef := dict.LookupOrAdd(codec.NSPaths, tc.Path)
-					tcr.PathFlag = codec.PathDictRef
-					tcr.PathRef = pathRef
-				}
-				tcr.CmdPrefix = tc.CmdPrefix
-				sf.ToolCalls = append(sf.ToolCalls, tcr)
+				return nil, err
 			}
-
 			body = codec.AppendFrame(body, enc.EncodeSessionFrame(sf))
-			sessionRefs = append(sessionRefs, sessRef)
+			sessionRefs = append(sessionRefs, sf.SessionRef)
 		}
 
 		// Build checkpoint frame.
@@ -167,7 +114,7 @@ func exportNewFrames(gitRoot string) ([]byte, []byte, error) {
 		// Query files touched.
 		filesTouched, err := db.QueryFilesTouched(dataDB, cp.ID)
 		if err != nil {
-			return nil, nil, fmt.Errorf("query files_touched for %s: %w", cp.ID, err)
+			return nil, fmt.Errorf("query files_touched for %s: %w", cp.ID, err)
 		}
 		var fileRecords []codec.FileTouchedRecord
 		for _, ft := range filesTouched {
@@ -198,7 +145,21 @@ func exportNewFrames(gitRoot string) ([]byte, []byte, error) {
 		exportedIDs = append(exportedIDs, cp.ID)
 	}
 
-	// Append meta frame.
+	body = appendMetaFrame(enc, body, dict, len(exportedIDs))
+
+	// Mark checkpoints as exported.
+	if err := db.MarkCheckpointsExported(dataDB, exportedIDs); err != nil {
+		return nil, fmt.Errorf("mark exported: %w", err)
+	}
+
+	u.Body, u.Dict = body, enc.Seal(dict.Encode())
+	return u, nil
+}
+
+// appendMetaFrame appends a meta frame for nCheckpoints new checkpoints to
+// body. It seals every frame before it into the hash chain and names the
+// dictionary enc compresses with.
+func appendMetaFrame(enc *codec.Encoder, body []byte, dict *codec.Dict, nCheckpoints int) []byte {
 	existingFrames, _ := codec.ScanFrames(body)
 	nFrames := uint32(len(existingFrames))
 
@@ -211,23 +172,54 @@ func exportNewFrames(gitRoot string) ([]byte, []byte, error) {
 		CheckpointSHA: strings.Repeat("0", 40), // placeholder
 		Timestamp:     time.Now().UTC(),
 		NSessions:     uint32(dict.Len(codec.NSSessions)),
-		NCheckpoints:  uint32(len(exportedIDs)),
+		NCheckpoints:  uint32(nCheckpoints),
 		NFrames:       nFrames + 1, // +1 for this meta frame
 		NDictEntries:  uint32(dict.TotalEntries()),
+		Chained:       true,
+		Chain:         codec.Chain(body, existingFrames),
 	}
-	body = codec.AppendFrame(body, enc.EncodeMetaFrame(mf))
-
-	// Mark checkpoints as exported.
-	if err := db.MarkCheckpointsExported(dataDB, exportedIDs); err != nil {
-		return nil, nil, fmt.Errorf("mark exported: %w", err)
+	if id := enc.DictID(); id != codec.PresetDictID() {
+		mf.DictID = id
 	}
+	return codec.AppendFrame(body, enc.EncodeMetaFrame(mf))
+}
 
-	return body, dict.Encode(), nil
+// newBranchEncoder returns an encoder for new frames on branch: it
+// compresses with the dictionary the last meta frame of body names, or the
+// preset if none does.
+func newBranchEncoder(gitRoot, branch string, body []byte) (*codec.Encoder, error) {
+	dicts, err := readBranchDicts(gitRoot, branch)
+	if err != nil {
+		return nil, err
+	}
+	var zdict []byte
+	if len(dicts) > 0 {
+		dec, err := newDictDecoder(dicts)
+		if err != nil {
+			return nil, err
+		}
+		scan, err := codec.ScanBody(body)
+		var id uint32
+		if err == nil {
+			id = activeDictID(dec, body, scan.Frames)
+		}
+		dec.Close()
+		if id != 0 {
+			if zdict = dicts[id]; zdict == nil {
+				return nil, fmt.Errorf("%s: zstd dictionary %08x is not in %s/", branch, id, dictDir)
+			}
+		}
+	}
+	enc, err := codec.NewEncoderWithDict(zdict)
+	if err != nil {
+		return nil, fmt.Errorf("create encoder: %w", err)
+	}
+	return enc, nil
 }
 
-// commitWireFormat commits rekal.body and dict.bin to the orphan branch.
-// Returns the new commit SHA.
-func commitWireFormat(gitRoot string, bodyData, dictData []byte) (string, error) {
+// commitWireFormat commits u to the orphan branch, adding only the new
+// segments. Returns the new commit SHA.
+func commitWireFormat(gitRoot string, u *wireUpdate) (string, error) {
 	branch := rekalBranchName()
 
 	// Get the current tip of the orphan branch.
@@ -235,45 +227,151 @@ func commitWireFormat(gitRoot string, bodyData, dictData []byte) (string, error)
 	if err != nil {
 		return "", fmt.Errorf("resolve branch %s: %w", branch, err)
 	}
-	parent := strings.TrimSpace(string(parentOut))
-
-	bodyHash, err := gitHashObject(gitRoot, bodyData)
-	if err != nil {
-		return "", fmt.Errorf("hash rekal.body: %w", err)
-	}
-	dictHash, err := gitHashObject(gitRoot, dictData)
-	if err != nil {
-		return "", fmt.Errorf("hash dict.bin: %w", err)
+	parents := []string{strings.TrimSpace(string(parentOut))}
+	if u.Base != "" {
+		parents = []string{u.Base, parents[0]}
 	}
 
-	treeEntry := fmt.Sprintf("100644 blob %s\tdict.bin\n100644 blob %s\trekal.body\n", dictHash, bodyHash)
-	mktreeCmd := exec.Command("git", "-C", gitRoot, "mktree")
-	mktreeCmd.Stdin = strings.NewReader(treeEntry)
-	treeOut, err := mktreeCmd.Output()
+	treeHash, err := writeWireTree(gitRoot, parents[0], u)
 	if err != nil {
-		return "", fmt.Errorf("mktree: %w", err)
+		return "", err
 	}
-	treeHash := strings.TrimSpace(string(treeOut))
 
-	// Use the HEAD commit message from the main branch.
-	msg := "rekal: checkpoint"
-	if headMsg, err := exec.Command("git", "-C", gitRoot, "log", "-1", "--format=%s", "HEAD").Output(); err == nil {
-		if m := strings.TrimSpace(string(headMsg)); m != "" {
-			msg = m
+	// Unless u says otherwise, use the HEAD commit message from the main
+	// branch.
+	msg := u.Message
+	if msg == "" {
+		msg = "rekal: checkpoint"
+		if headMsg, err := exec.Command("git", "-C", gitRoot, "log", "-1", "--format=%s", "HEAD").Output(); err == nil {
+			if m := strings.TrimSpace(string(headMsg)); m != "" {
+				msg = m
+			}
 		}
 	}
 
-	commitOut, err := exec.Command("git", "-C", gitRoot,
-		"commit-tree", treeHash, "-p", parent, "-m", msg,
-	).Output()
+	// Sign like a regular commit when commit.gpgsign is set; -S picks up
+	// user.signingkey and gpg.format.
+	args := []string{"-C", gitRoot, "commit-tree", treeHash}
+	for _, p := range parents {
+		args = append(args, "-p", p)
+	}
+	args = append(args, "-m", msg)
+	if signingEnabled() {
+		args = append(args, "-S")
+	}
+	commitOut, err := exec.Command("git", args...).Output()
 	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("commit-tree: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
 		return "", fmt.Errorf("commit-tree: %w", err)
 	}
 	commitSHA := strings.TrimSpace(string(commitOut))
 
-	if err := exec.Command("git", "-C", gitRoot, "update-ref", "refs/heads/"+branch, commitSHA).Run(); err != nil {
+	if err := exec.Command("git", "-C", gitRoot, "update-ref", rekalRef(), commitSHA).Run(); err != nil {
 		return "", fmt.Errorf("update-ref: %w", err)
 	}
 
 	return commitSHA, nil
 }
+
+// sessionFrameFromDB builds the wire session frame for session sid, adding
+// its strings to dict.
+func sessionFrameFromDB(dataDB *sql.DB, dict *codec.Dict, sid string) (*codec.SessionFrame, error) {
+	sess, err := db.QuerySession(dataDB, sid)
+	if err != nil {
+		return nil, fmt.Errorf("query session %s: %w", sid, err)
+	}
+	turns, err := db.QueryTurns(dataDB, sid)
+	if err != nil {
+		return nil, fmt.Errorf("query turns for %s: %w", sid, err)
+	}
+	toolCalls, err := db.QueryToolCalls(dataDB, sid)
+	if err != nil {
+		return nil, fmt.Errorf("query tool_calls for %s: %w", sid, err)
+	}
+
+	capturedAt, _ := time.Parse(time.RFC3339, sess.CapturedAt)
+	ws := &wireSession{
+		ID:              sid,
+		ParentSessionID: sess.ParentSessionID,
+		Email:           sess.Email,
+		ActorType:       sess.ActorType,
+		AgentID:         sess.AgentID,
+		Branch:          sess.Branch,
+		Source:          sess.Source,
+		CapturedAt:      capturedAt,
+		ToolCalls:       toolCalls,
+	}
+	for _, t := range turns {
+		ws.Turns = append(ws.Turns, wireTurn{Role: t.Role, Content: t.Content, Ts: parseDBTimestamp(t.Ts)})
+	}
+	return encodeWireSession(dict, ws), nil
+}
+
+// encodeWireSession builds the wire session frame for ws, adding its strings
+// to dict.
+func encodeWireSession(dict *codec.Dict, ws *wireSession) *codec.SessionFrame {
+	sf := &codec.SessionFrame{
+		SessionRef:      dict.LookupOrAdd(codec.NSSessions, ws.ID),
+		CapturedAt:      ws.CapturedAt,
+		EmailRef:        dict.LookupOrAdd(codec.NSEmails, ws.Email),
+		ActorType:       codec.ActorHuman,
+		Source:          ws.Source,
+		ParentSessionID: ws.ParentSessionID,
+	}
+	if ws.ActorType == "agent" {
+		sf.ActorType = codec.ActorAgent
+	}
+	if ws.AgentID != "" {
+		sf.AgentIDRef, sf.HasAgentID = dict.LookupOrAdd(codec.NSEmails, ws.AgentID), true
+	}
+	if ws.Branch != "" {
+		sf.BranchRef, sf.HasBranch = dict.LookupOrAdd(codec.NSBranches, ws.Branch), true
+	}
+
+	for _, t := range ws.Turns {
+		role := codec.RoleHuman
+		if t.Role == "assistant" {
+			role = codec.RoleAssistant
+		}
+		sf.Turns = append(sf.Turns, codec.TurnRecord{
+			Role: role,
+			Ts:   t.Ts,
+			Text: t.Content,
+		})
+	}
+
+	for _, tc := range ws.ToolCalls {
+		toolCode, toolRef := dict.EncodeTool(tc.Tool)
+		tcr := codec.ToolCallRecord{
+			Tool:    toolCode,
+			ToolRef: toolRef,
+		}
+		if tc.Path == "" {
+			tcr.PathFlag = codec.PathNull
+		} else {
+			pathRef := dict.LookupOrAdd(codec.NSPaths, tc.Path)
+			tcr.PathFlag = codec.PathDictRef
+			tcr.PathRef = pathRef
+		}
+		tcr.CmdPrefix = tc.CmdPrefix
+		sf.ToolCalls = append(sf.ToolCalls, tcr)
+	}
+	return sf
+}
+
+// dbTimestampLayout is how DuckDB casts a TIMESTAMP to VARCHAR.
+const dbTimestampLayout = "2006-01-02 15:04:05.999999"
+
+// parseDBTimestamp parses a TIMESTAMP cast to VARCHAR by DuckDB or an
+// RFC 3339 string. Zero if empty or unparseable.
+func parseDBTimestamp(s string) time.Time {
+	for _, layout := range []string{dbTimestampLayout, time.RFC3339Nano} {
+		if ts, err := time.Parse(layout, s); err == nil {
+			return ts.UTC()
+		}
+	}
+	return time.Time{}
+}
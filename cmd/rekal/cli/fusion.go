@@ -0,0 +1,244 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Hybrid search fuses three signals per session: the best BM25 score of its
+// turns, LSA similarity and Nomic similarity. Two fusions are available:
+//
+//   - weighted: each signal is divided by its maximum over the candidates
+//     and the results are summed with weights. Sensitive to one outlier
+//     flattening everyone else.
+//   - rrf: reciprocal rank fusion. Each signal contributes weight/(k+rank),
+//     so only the order within a signal matters, not its scale.
+//
+// Both are configured per repository:
+//
+//	git config rekal.fusion rrf
+//	git config rekal.bm25Weight 0.5
+//	git config rekal.rrfK 60
+
+const (
+	fusionConfigKey      = "rekal.fusion"
+	bm25WeightConfigKey  = "rekal.bm25Weight"
+	lsaWeightConfigKey   = "rekal.lsaWeight"
+	nomicWeightConfigKey = "rekal.nomicWeight"
+	rrfKConfigKey        = "rekal.rrfK"
+
+	fusionWeighted = "weighted"
+	fusionRRF      = "rrf"
+
+	defaultRRFK = 60
+)
+
+// rankingConfig is how hybrid search fuses its signals.
+type rankingConfig struct {
+	Fusion string
+	BM25   float64
+	LSA    float64
+	Nomic  float64
+	RRFK   float64
+
+	// weightsSet is true when any weight is configured. Without it the
+	// built-in weights apply, with their own split for when Nomic is
+	// unavailable. With it, unset weights keep their defaults and all of
+	// them are scaled to sum to 1.
+	weightsSet bool
+}
+
+// loadRankingConfig reads the ranking settings from git config. fusion, if
+// not empty, overrides rekal.fusion.
+func loadRankingConfig(fusion string) (rankingConfig, error) {
+	r := rankingConfig{
+		Fusion: fusionWeighted,
+		BM25:   bm25Weight3Way,
+		LSA:    lsaWeight3Way,
+		Nomic:  nomicWeight3Way,
+		RRFK:   defaultRRFK,
+	}
+	if fusion == "" {
+		fusion = gitConfigValue(fusionConfigKey)
+	}
+	switch f := strings.ToLower(strings.TrimSpace(fusion)); f {
+	case "":
+	case fusionWeighted, fusionRRF:
+		r.Fusion = f
+	default:
+		return r, fmt.Errorf("unknown fusion %q: want %s or %s", fusion, fusionWeighted, fusionRRF)
+	}
+
+	for _, w := range []struct {
+		key string
+		dst *float64
+	}{
+		{bm25WeightConfigKey, &r.BM25},
+		{lsaWeightConfigKey, &r.LSA},
+		{nomicWeightConfigKey, &r.Nomic},
+	} {
+		v := gitConfigValue(w.key)
+		if v == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return r, fmt.Errorf("%s: want a non-negative number, got %q", w.key, v)
+		}
+		*w.dst = f
+		r.weightsSet = true
+	}
+	if v := gitConfigValue(rrfKConfigKey); v != "" {
+		k, err := strconv.ParseFloat(v, 64)
+		if err != nil || k <= 0 {
+			return r, fmt.Errorf("%s: want a positive number, got %q", rrfKConfigKey, v)
+		}
+		r.RRFK = k
+	}
+	return r, nil
+}
+
+// weights returns the BM25, LSA and Nomic weights to use. Configured
+// weights are scaled to sum to 1 over the signals in use, so setting one
+// weight shifts the balance without pushing scores past 1.
+func (r rankingConfig) weights(useNomic bool) (bm25, lsaW, nomicW float64) {
+	switch {
+	case !r.weightsSet && useNomic:
+		return r.BM25, r.LSA, r.Nomic
+	case !r.weightsSet:
+		return bm25Weight2Way, lsaWeight2Way, 0
+	}
+	if useNomic {
+		nomicW = r.Nomic
+	}
+	sum := r.BM25 + r.LSA + nomicW
+	if sum == 0 {
+		return 0, 0, 0
+	}
+	return r.BM25 / sum, r.LSA / sum, nomicW / sum
+}
+
+// signalExplain is one signal's part in a session's hybrid score.
+type signalExplain struct {
+	Raw        float64 `json:"raw"`
+	Normalized float64 `json:"normalized"` // raw divided by the signal's maximum
+	Rank       int     `json:"rank"`       // 1-based among sessions with a score; 0 without one
+	Weight     float64 `json:"weight"`
+}
+
+// scoreExplain breaks down a hybrid search result's score.
+type scoreExplain struct {
+	Fused float64       `json:"fused"` // the score before rounding
+	BM25  signalExplain `json:"bm25"`
+	LSA   signalExplain `json:"lsa"`
+	Nomic signalExplain `json:"nomic"`
+}
+
+// rankingExplain describes the fusion a hybrid search used.
+type rankingExplain struct {
+	Fusion  string             `json:"fusion"`
+	Weights map[string]float64 `json:"weights"`
+	RRFK    float64            `json:"rrf_k,omitempty"`
+	Nomic   bool               `json:"nomic"` // whether Nomic scores were available
+}
+
+// explain describes r as used for a search with or without Nomic.
+func (r rankingConfig) explain(useNomic bool) *rankingExplain {
+	bm25, lsaW, nomicW := r.weights(useNomic)
+	e := &rankingExplain{
+		Fusion:  r.Fusion,
+		Weights: map[string]float64{"bm25": bm25, "lsa": lsaW, "nomic": nomicW},
+		Nomic:   useNomic,
+	}
+	if r.Fusion == fusionRRF {
+		e.RRFK = r.RRFK
+	}
+	return e
+}
+
+// fuseScores scores every session in sessions under r. Scores are in
+// [0,1]: a weighted sum of normalized signals, or the reciprocal rank sum
+// divided by its best possible value.
+func fuseScores(sessions map[string]*sessionHit, r rankingConfig, useNomic bool) []scored {
+	bm25W, lsaW, nomicW := r.weights(useNomic)
+	signals := []struct {
+		weight float64
+		value  func(*sessionHit) float64
+		part   func(*scoreExplain) *signalExplain
+	}{
+		{bm25W, func(sh *sessionHit) float64 { return sh.bm25Max }, func(e *scoreExplain) *signalExplain { return &e.BM25 }},
+		{lsaW, func(sh *sessionHit) float64 { return sh.lsaScore }, func(e *scoreExplain) *signalExplain { return &e.LSA }},
+		{nomicW, func(sh *sessionHit) float64 { return sh.nomicScore }, func(e *scoreExplain) *signalExplain { return &e.Nomic }},
+	}
+
+	explains := make(map[string]*scoreExplain, len(sessions))
+	for sid := range sessions {
+		explains[sid] = &scoreExplain{}
+	}
+	var best float64 // the fused score of a session first in every signal
+	for _, sig := range signals {
+		var maxRaw float64
+		for _, sh := range sessions {
+			maxRaw = max(maxRaw, sig.value(sh))
+		}
+		ranks := rankSessions(sessions, sig.value)
+		for sid, sh := range sessions {
+			p := sig.part(explains[sid])
+			p.Raw, p.Rank, p.Weight = sig.value(sh), ranks[sid], sig.weight
+			if maxRaw > 0 {
+				p.Normalized = p.Raw / maxRaw
+			}
+		}
+		if r.Fusion == fusionRRF {
+			best += sig.weight / (r.RRFK + 1)
+		}
+	}
+
+	results := make([]scored, 0, len(sessions))
+	for sid, sh := range sessions {
+		e := explains[sid]
+		var score float64
+		for _, sig := range signals {
+			p := sig.part(e)
+			switch {
+			case r.Fusion != fusionRRF:
+				score += sig.weight * p.Normalized
+			case p.Rank > 0:
+				score += sig.weight / (r.RRFK + float64(p.Rank))
+			}
+		}
+		if r.Fusion == fusionRRF && best > 0 {
+			score /= best
+		}
+		e.Fused = score
+		results = append(results, scored{sessionID: sid, score: score, hit: sh, explain: e})
+	}
+	// sortScored is stable: order ties by session ID, not map order.
+	sort.Slice(results, func(i, j int) bool { return results[i].sessionID < results[j].sessionID })
+	return results
+}
+
+// rankSessions ranks the sessions with a positive value, highest first,
+// from 1. Ties are broken by session ID so ranks are stable.
+func rankSessions(sessions map[string]*sessionHit, value func(*sessionHit) float64) map[string]int {
+	var ids []string
+	for sid, sh := range sessions {
+		if value(sh) > 0 {
+			ids = append(ids, sid)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		vi, vj := value(sessions[ids[i]]), value(sessions[ids[j]])
+		if vi != vj {
+			return vi > vj
+		}
+		return ids[i] < ids[j]
+	})
+	ranks := make(map[string]int, len(ids))
+	for i, sid := range ids {
+		ranks[sid] = i + 1
+	}
+	return ranks
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"math"
+	"testing"
+)
+
+func defaultRanking(fusion string) rankingConfig {
+	return rankingConfig{Fusion: fusion, BM25: bm25Weight3Way, LSA: lsaWeight3Way, Nomic: nomicWeight3Way, RRFK: defaultRRFK}
+}
+
+func scoreOf(t *testing.T, results []scored, sid string) *scored {
+	t.Helper()
+	for i := range results {
+		if results[i].sessionID == sid {
+			return &results[i]
+		}
+	}
+	t.Fatalf("no result for %s", sid)
+	return nil
+}
+
+func TestFuseScores_Weighted(t *testing.T) {
+	t.Parallel()
+	sessions := map[string]*sessionHit{
+		"a": {bm25Max: 10, lsaScore: 0.2, nomicScore: 0.5},
+		"b": {bm25Max: 5, lsaScore: 0.4},
+	}
+
+	// With Nomic: the 3-way weights over max-normalized scores.
+	got := scoreOf(t, fuseScores(sessions, defaultRanking(fusionWeighted), true), "b")
+	want := bm25Weight3Way*0.5 + lsaWeight3Way*1
+	if math.Abs(got.score-want) > 1e-9 {
+		t.Errorf("b = %v, want %v", got.score, want)
+	}
+	if e := got.explain; e.BM25.Raw != 5 || e.BM25.Normalized != 0.5 || e.BM25.Rank != 2 || e.LSA.Rank != 1 || e.Nomic.Rank != 0 {
+		t.Errorf("explain = %+v", *e)
+	}
+
+	// Without Nomic: the built-in 2-way weights.
+	got = scoreOf(t, fuseScores(sessions, defaultRanking(fusionWeighted), false), "b")
+	if want := bm25Weight2Way*0.5 + lsaWeight2Way*1; math.Abs(got.score-want) > 1e-9 {
+		t.Errorf("b without nomic = %v, want %v", got.score, want)
+	}
+}
+
+func TestFuseScores_RRF(t *testing.T) {
+	t.Parallel()
+	// One BM25 outlier does not flatten the rest: only ranks count.
+	sessions := map[string]*sessionHit{
+		"a": {bm25Max: 1000, lsaScore: 0.1},
+		"b": {bm25Max: 2, lsaScore: 0.9},
+		"c": {bm25Max: 1, lsaScore: 0.8},
+	}
+	r := defaultRanking(fusionRRF)
+	r.BM25, r.LSA, r.weightsSet = 1, 1, true
+	results := fuseScores(sessions, r, false)
+	sortScored(results)
+	if results[0].sessionID != "b" {
+		t.Errorf("top result = %s, want b", results[0].sessionID)
+	}
+
+	// First in every signal scores 1.
+	top := map[string]*sessionHit{"a": {bm25Max: 3, lsaScore: 0.5}, "b": {bm25Max: 1, lsaScore: 0.1}}
+	if got := scoreOf(t, fuseScores(top, r, false), "a").score; math.Abs(got-1) > 1e-9 {
+		t.Errorf("best possible score = %v, want 1", got)
+	}
+}
+
+func TestRankingConfig_Weights(t *testing.T) {
+	t.Parallel()
+	r := rankingConfig{BM25: 0.3, LSA: 0.1, Nomic: 0.6, weightsSet: true}
+	if b, l, n := r.weights(true); b != 0.3 || l != 0.1 || n != 0.6 {
+		t.Errorf("with nomic = %v %v %v", b, l, n)
+	}
+	// Configured weights are rescaled when Nomic is missing.
+	if b, l, n := r.weights(false); math.Abs(b-0.75) > 1e-9 || math.Abs(l-0.25) > 1e-9 || n != 0 {
+		t.Errorf("without nomic = %v %v %v", b, l, n)
+	}
+
+	// Only BM25 configured: the defaults fill in the rest and the sum is
+	// brought back to 1.
+	partial := defaultRanking(fusionWeighted)
+	partial.BM25, partial.weightsSet = 0.9, true
+	b, l, n := partial.weights(true)
+	sum := 0.9 + lsaWeight3Way + nomicWeight3Way
+	if math.Abs(b-0.9/sum) > 1e-9 || math.Abs(l-lsaWeight3Way/sum) > 1e-9 || math.Abs(n-nomicWeight3Way/sum) > 1e-9 {
+		t.Errorf("partial with nomic = %v %v %v", b, l, n)
+	}
+	if math.Abs(b+l+n-1) > 1e-9 {
+		t.Errorf("partial weights sum to %v, want 1", b+l+n)
+	}
+	if b, l, _ := partial.weights(false); math.Abs(b+l-1) > 1e-9 {
+		t.Errorf("partial without nomic sums to %v, want 1", b+l)
+	}
+
+	// All configured weights zero: nothing contributes.
+	zero := rankingConfig{weightsSet: true}
+	if b, l, n := zero.weights(true); b != 0 || l != 0 || n != 0 {
+		t.Errorf("zero = %v %v %v", b, l, n)
+	}
+}
+
+func TestLoadRankingConfig_Fusion(t *testing.T) {
+	t.Parallel()
+	r, err := loadRankingConfig("RRF")
+	if err != nil || r.Fusion != fusionRRF {
+		t.Errorf("loadRankingConfig(RRF) = %+v, %v", r, err)
+	}
+	if _, err := loadRankingConfig("borda"); err == nil {
+		t.Error("unknown fusion accepted")
+	}
+}
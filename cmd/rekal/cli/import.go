@@ -2,25 +2,40 @@ package cli
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"time"
 
 	"github.com/oklog/ulid/v2"
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/session"
 )
 
 // importBranch decodes wire format from an orphan branch and imports
 // sessions + checkpoints into DuckDB. Returns the number of sessions imported.
-// Deduplicates by session ID and checkpoint ID.
-func importBranch(gitRoot string, dataDB *sql.DB, branch string) (int, error) {
-	bodyData := gitShowFile(gitRoot, branch, "rekal.body")
-	if len(bodyData) <= 9 {
+// Deduplicates by session ID and checkpoint ID. Warns on w if the branch's
+// history was rewritten since it was last imported. Each session records
+// whether the commit that added it was signed by the branch owner.
+func importBranch(gitRoot string, dataDB *sql.DB, branch string, w io.Writer) (int, error) {
+	bodyData, err := readBranchBody(gitRoot, branch)
+	if err != nil {
+		return 0, fmt.Errorf("read body: %w", err)
+	}
+	if len(bodyData) <= codec.BodyHeaderSize {
 		return 0, nil // empty body (header only)
 	}
 
-	dictData := gitShowFile(gitRoot, branch, "dict.bin")
+	dictData, err := readBranchDictData(gitRoot, branch)
+	if errors.Is(err, codec.ErrNoKey) {
+		fmt.Fprintf(w, "rekal: skipping %s: encrypted for %s, and no local identity is a recipient\n", branch, recipientsFile)
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
 	if len(dictData) == 0 {
 		return 0, nil
 	}
@@ -30,14 +45,23 @@ func importBranch(gitRoot string, dataDB *sql.DB, branch string) (int, error) {
 		return 0, fmt.Errorf("load dict: %w", err)
 	}
 
-	frames, err := codec.ScanFrames(bodyData)
+	scan, err := codec.ScanBody(bodyData)
 	if err != nil {
 		return 0, fmt.Errorf("scan frames: %w", err)
 	}
+	frames := scan.Frames
+	reportSkippedRanges(w, branch, scan.Skipped)
+	checkImportedHistory(dataDB, branch, bodyData, frames, w)
 
-	dec, err := codec.NewDecoder()
+	sigs, err := verifyBranchSignatures(gitRoot, branch, branchWatermark{})
 	if err != nil {
-		return 0, fmt.Errorf("create decoder: %w", err)
+		fmt.Fprintf(w, "rekal: warning: %v\n", err)
+	}
+	reportBranchSignatures(w, branch, sigs)
+
+	dec, err := newBranchDecoder(gitRoot, branch)
+	if err != nil {
+		return 0, err
 	}
 	defer dec.Close()
 
@@ -48,7 +72,7 @@ func importBranch(gitRoot string, dataDB *sql.DB, branch string) (int, error) {
 
 	var imported int
 
-	for _, fs := range frames {
+	for fi, fs := range frames {
 		compressed := codec.ExtractFramePayload(bodyData, fs)
 
 		switch fs.Type {
@@ -58,64 +82,39 @@ func importBranch(gitRoot string, dataDB *sql.DB, branch string) (int, error) {
 				continue // skip malformed frames
 			}
 
-			sessionID, err := dict.Get(codec.NSSessions, sf.SessionRef)
+			ws, err := resolveSessionFrame(dict, sf)
 			if err != nil {
 				continue
 			}
 
-			// Dedup by session ID.
-			exists, err := db.SessionExistsByID(dataDB, sessionID)
+			// Dedup by session ID, then by content: a session stored here
+			// under another ID becomes an alias of that one.
+			existing, err := db.ResolveSessionID(dataDB, ws.ID)
 			if err != nil {
 				return imported, fmt.Errorf("check session: %w", err)
 			}
-			if exists {
+			if existing != "" {
 				continue
 			}
-
-			email, _ := dict.Get(codec.NSEmails, sf.EmailRef)
-			actorType := "human"
-			agentID := ""
-			if sf.ActorType == codec.ActorAgent {
-				actorType = "agent"
-				agentID, _ = dict.Get(codec.NSEmails, sf.AgentIDRef)
-			}
-
-			// Determine branch from first turn's BranchRef (all turns share the same branch).
-			branch := ""
-			if len(sf.Turns) > 0 {
-				branch, _ = dict.Get(codec.NSBranches, sf.Turns[0].BranchRef)
-			}
-
-			sessionHash := "wire:" + sessionID
-			capturedAt := sf.CapturedAt.UTC().Format(time.RFC3339)
-
-			if err := db.InsertSession(dataDB, sessionID, "", sessionHash, actorType, agentID, email, branch, capturedAt, ""); err != nil {
-				return imported, fmt.Errorf("insert session: %w", err)
-			}
-
-			// Insert turns.
-			for i, t := range sf.Turns {
-				role := "human"
-				if t.Role == codec.RoleAssistant {
-					role = "assistant"
+			cid := ws.ContentID()
+			if cid != ws.ID {
+				if existing, err = db.ResolveSessionID(dataDB, cid); err != nil {
+					return imported, fmt.Errorf("check session: %w", err)
 				}
-				if err := db.InsertTurn(dataDB, newID(), sessionID, i, role, t.Text, ""); err != nil {
-					return imported, fmt.Errorf("insert turn: %w", err)
+				if existing != "" {
+					if err := db.InsertSessionAlias(dataDB, ws.ID, existing); err != nil {
+						return imported, err
+					}
+					continue
 				}
 			}
 
-			// Insert tool calls.
-			for i, tc := range sf.ToolCalls {
-				toolName := codec.ToolName(tc.Tool)
-				path := ""
-				switch tc.PathFlag {
-				case codec.PathDictRef:
-					path, _ = dict.Get(codec.NSPaths, tc.PathRef)
-				case codec.PathInline:
-					path = tc.PathInline
-				}
-				if err := db.InsertToolCall(dataDB, newID(), sessionID, i, toolName, path, tc.CmdPrefix); err != nil {
-					return imported, fmt.Errorf("insert tool_call: %w", err)
+			if err := insertWireSession(dataDB, ws, sigs.FrameVerified(fi), newID); err != nil {
+				return imported, err
+			}
+			if cid != ws.ID {
+				if err := db.InsertSessionAlias(dataDB, cid, ws.ID); err != nil {
+					return imported, err
 				}
 			}
 
@@ -165,14 +164,17 @@ func importBranch(gitRoot string, dataDB *sql.DB, branch string) (int, error) {
 			}
 
 			// Insert checkpoint_sessions junction rows.
+			linked := make(map[string]bool)
 			for _, ref := range cf.SessionRefs {
 				sessionID, err := dict.Get(codec.NSSessions, ref)
 				if err != nil {
 					continue
 				}
-				// Only link if the session exists in DB.
-				exists, _ := db.SessionExistsByID(dataDB, sessionID)
-				if exists {
+				// Only link if the session exists in DB, under whichever ID
+				// holds it.
+				sessionID, _ = db.ResolveSessionID(dataDB, sessionID)
+				if sessionID != "" && !linked[sessionID] {
+					linked[sessionID] = true
 					if err := db.InsertCheckpointSession(dataDB, checkpointID, sessionID); err != nil {
 						return imported, fmt.Errorf("insert checkpoint_session: %w", err)
 					}
@@ -190,3 +192,130 @@ func importBranch(gitRoot string, dataDB *sql.DB, branch string) (int, error) {
 
 	return imported, nil
 }
+
+// wireSession is a decoded session frame with its dict refs resolved.
+type wireSession struct {
+	ID              string
+	ParentSessionID string
+	Email           string
+	ActorType       string
+	AgentID         string
+	Branch          string
+	Source          string
+	CapturedAt      time.Time
+	Turns           []wireTurn
+	ToolCalls       []db.ToolCallRow
+}
+
+// ContentID returns the content-addressed ID of ws, as session.ContentID
+// computes it at capture. It differs from the captured ID when learned
+// secrets were redacted from the session's text (see scrub.ContentID).
+func (ws *wireSession) ContentID() string {
+	p := &session.SessionPayload{Source: ws.Source}
+	for _, t := range ws.Turns {
+		p.Turns = append(p.Turns, session.Turn{Role: t.Role, Content: t.Content, Timestamp: t.Ts})
+	}
+	for _, tc := range ws.ToolCalls {
+		p.ToolCalls = append(p.ToolCalls, session.ToolCall{Tool: tc.Tool, Path: tc.Path, CmdPrefix: tc.CmdPrefix})
+	}
+	return session.ContentID(p)
+}
+
+// wireTurn is one turn of a wireSession. Ts is zero when unknown.
+type wireTurn struct {
+	Role    string
+	Content string
+	Ts      time.Time
+}
+
+// resolveSessionFrame looks up the strings sf refers to in dict. Fails only
+// if the session ID itself is missing; other unresolvable refs are empty.
+func resolveSessionFrame(dict *codec.Dict, sf *codec.SessionFrame) (*wireSession, error) {
+	id, err := dict.Get(codec.NSSessions, sf.SessionRef)
+	if err != nil {
+		return nil, err
+	}
+	ws := &wireSession{
+		ID:              id,
+		ParentSessionID: sf.ParentSessionID,
+		ActorType:       "human",
+		Source:          sf.Source,
+		CapturedAt:      sf.CapturedAt,
+	}
+	ws.Email, _ = dict.Get(codec.NSEmails, sf.EmailRef)
+	if sf.ActorType == codec.ActorAgent {
+		ws.ActorType = "agent"
+	}
+	if sf.HasAgentID {
+		ws.AgentID, _ = dict.Get(codec.NSEmails, sf.AgentIDRef)
+	}
+	if sf.HasBranch {
+		ws.Branch, _ = dict.Get(codec.NSBranches, sf.BranchRef)
+	}
+
+	for _, t := range sf.Turns {
+		role := "human"
+		if t.Role == codec.RoleAssistant {
+			role = "assistant"
+		}
+		ws.Turns = append(ws.Turns, wireTurn{Role: role, Content: t.Text, Ts: t.Ts})
+	}
+	for i, tc := range sf.ToolCalls {
+		path := ""
+		switch tc.PathFlag {
+		case codec.PathDictRef:
+			path, _ = dict.Get(codec.NSPaths, tc.PathRef)
+		case codec.PathInline:
+			path = tc.PathInline
+		}
+		ws.ToolCalls = append(ws.ToolCalls, db.ToolCallRow{
+			CallOrder: i,
+			Tool:      dict.ToolName(tc),
+			Path:      path,
+			CmdPrefix: tc.CmdPrefix,
+		})
+	}
+	return ws, nil
+}
+
+// insertWireSession writes an imported session with its turns and tool
+// calls to the data DB.
+func insertWireSession(d db.Execer, ws *wireSession, verified bool, newID func() string) error {
+	sessionHash := "wire:" + ws.ID
+	capturedAt := ws.CapturedAt.UTC().Format(time.RFC3339)
+	if err := db.InsertSession(d, ws.ID, ws.ParentSessionID, sessionHash, ws.ActorType, ws.AgentID, ws.Email, ws.Branch, capturedAt, ws.Source); err != nil {
+		return fmt.Errorf("insert session: %w", err)
+	}
+	if err := db.SetSessionVerified(d, ws.ID, verified); err != nil {
+		return err
+	}
+	for i, t := range ws.Turns {
+		ts := ""
+		if !t.Ts.IsZero() {
+			ts = t.Ts.UTC().Format(time.RFC3339Nano)
+		}
+		if err := db.InsertTurn(d, newID(), ws.ID, i, t.Role, t.Content, ts); err != nil {
+			return fmt.Errorf("insert turn: %w", err)
+		}
+	}
+	for _, tc := range ws.ToolCalls {
+		if err := db.InsertToolCall(d, newID(), ws.ID, tc.CallOrder, tc.Tool, tc.Path, tc.CmdPrefix); err != nil {
+			return fmt.Errorf("insert tool_call: %w", err)
+		}
+	}
+	return nil
+}
+
+// reportSkippedRanges warns on w about corrupt regions of branch's rekal.body
+// that the scanner skipped. Frames on either side are still imported.
+func reportSkippedRanges(w io.Writer, branch string, skipped []codec.SkippedRange) {
+	if len(skipped) == 0 {
+		return
+	}
+	n := 0
+	for _, s := range skipped {
+		n += s.Length
+	}
+	fmt.Fprintf(w, "rekal: warning: %s: skipped %d corrupt byte range(s) (%d bytes) in rekal.body (see 'rekal verify %s')\n",
+		branch, len(skipped), n, branch)
+}
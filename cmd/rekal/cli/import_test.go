@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+)
+
+// duplicateSessionsBody returns a body with the same transcript under two
+// session IDs, as two machines captured it before content IDs, and a
+// checkpoint listing both.
+func duplicateSessionsBody(t *testing.T, ids ...string) ([]byte, []byte) {
+	t.Helper()
+	enc, err := codec.NewEncoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+
+	dict := codec.NewDict()
+	body := codec.NewBody()
+	var refs []uint64
+	for i, id := range ids {
+		sf := &codec.SessionFrame{
+			SessionRef: dict.LookupOrAdd(codec.NSSessions, id),
+			CapturedAt: time.Date(2026, 3, 1, 9+i, 0, 0, 0, time.UTC),
+			EmailRef:   dict.LookupOrAdd(codec.NSEmails, "alice@example.com"),
+			ActorType:  codec.ActorHuman,
+			Source:     "claude",
+			Turns: []codec.TurnRecord{
+				{Role: codec.RoleHuman, Ts: time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC), Text: "fix the parser"},
+			},
+		}
+		body = codec.AppendFrame(body, enc.EncodeSessionFrame(sf))
+		refs = append(refs, sf.SessionRef)
+	}
+	cf := &codec.CheckpointFrame{
+		CheckpointRef: dict.LookupOrAdd(codec.NSSessions, "01JNCKPT000000000000000001"),
+		GitSHA:        strings.Repeat("a", 40),
+		BranchRef:     dict.LookupOrAdd(codec.NSBranches, "main"),
+		EmailRef:      dict.LookupOrAdd(codec.NSEmails, "alice@example.com"),
+		Timestamp:     time.Date(2026, 3, 1, 11, 0, 0, 0, time.UTC),
+		ActorType:     codec.ActorHuman,
+		SessionRefs:   refs,
+		Files:         []codec.FileTouchedRecord{{PathRef: dict.LookupOrAdd(codec.NSPaths, "parser.go"), ChangeType: 'M'}},
+	}
+	body = codec.AppendFrame(body, enc.EncodeCheckpointFrame(cf))
+	return body, dict.Encode()
+}
+
+func TestImportBranch_MergesSessionsByContent(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	const first, second = "01JNSESS000000000000000001", "01JNSESS000000000000000002"
+	body, dict := duplicateSessionsBody(t, first, second)
+	writeRemoteBranch(t, dir, "refs/remotes/origin/rekal/alice", body, dict)
+
+	dataDB, err := db.OpenData(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dataDB.Close()
+	if err := db.InitDataSchema(dataDB, dataMigrateContext(dir)); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := importBranch(dir, dataDB, "origin/rekal/alice", io.Discard)
+	if err != nil {
+		t.Fatalf("importBranch: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("imported %d sessions, want 1", n)
+	}
+
+	scan, err := codec.ScanBody(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := codec.NewDecoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+	loaded, err := codec.LoadDict(dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf, err := dec.DecodeSessionFrame(codec.ExtractFramePayload(body, scan.Frames[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := resolveSessionFrame(loaded, sf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{first, second, ws.ContentID()} {
+		got, err := db.ResolveSessionID(dataDB, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != first {
+			t.Errorf("ResolveSessionID(%s) = %q, want %s", id, got, first)
+		}
+	}
+	var links int
+	if err := dataDB.QueryRow("SELECT count(*) FROM checkpoint_sessions").Scan(&links); err != nil || links != 1 {
+		t.Errorf("checkpoint_sessions = %d, %v; want 1", links, err)
+	}
+
+	// Importing again changes nothing.
+	if n, err := importBranch(dir, dataDB, "origin/rekal/alice", io.Discard); err != nil || n != 0 {
+		t.Errorf("second import = %d, %v; want 0, nil", n, err)
+	}
+}
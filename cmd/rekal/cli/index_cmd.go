@@ -41,7 +41,9 @@ Rebuild when the index is out of date or after importing new data.
 				return NewSilentError(err)
 			}
 
-			return runIndex(cmd, gitRoot)
+			return runLocked(cmd, gitRoot, func() error {
+				return runIndex(cmd, gitRoot)
+			})
 		},
 	}
 }
@@ -76,6 +78,9 @@ func runIndex(cmd *cobra.Command, gitRoot string) error {
 	if err := db.PopulateIndex(indexDB, gitRoot); err != nil {
 		return fmt.Errorf("populate index: %w", err)
 	}
+	if err := applyEmailAliases(gitRoot, indexDB); err != nil {
+		return fmt.Errorf("apply mailmap: %w", err)
+	}
 
 	// Count what we indexed.
 	var sessionCount, turnCount int
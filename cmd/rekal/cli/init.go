@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
@@ -53,12 +54,19 @@ imported into the local data DB automatically.`,
 				return fmt.Errorf("create .rekal/: %w", err)
 			}
 
+			lock, err := lockRekal(cmd, gitRoot, defaultLockTimeout)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			defer lock.Release() //nolint:errcheck
+
 			// Create data DB with schema.
 			dataDB, err := db.OpenData(gitRoot)
 			if err != nil {
 				return fmt.Errorf("create data DB: %w", err)
 			}
-			if err := db.InitDataSchema(dataDB); err != nil {
+			if err := db.InitDataSchema(dataDB, dataMigrateContext(gitRoot)); err != nil {
 				dataDB.Close()
 				return fmt.Errorf("init data schema: %w", err)
 			}
@@ -92,11 +100,10 @@ imported into the local data DB automatically.`,
 
 			// Import existing data from orphan branch into DuckDB.
 			branch := rekalBranchName()
-			bodyData := gitShowFile(gitRoot, branch, "rekal.body")
-			if len(bodyData) > 9 { // more than empty header
+			if branchFrameCount(gitRoot, branch) > 0 {
 				importDB, err := db.OpenData(gitRoot)
 				if err == nil {
-					n, importErr := importBranch(gitRoot, importDB, branch)
+					n, importErr := importBranch(gitRoot, importDB, branch, cmd.ErrOrStderr())
 					importDB.Close()
 					if importErr != nil {
 						fmt.Fprintf(cmd.ErrOrStderr(), "rekal: import error: %v\n", importErr)
@@ -173,22 +180,53 @@ func appendGitignoreEntry(gitRoot, entry string) error {
 	return err
 }
 
+// rekalHooks maps the git hooks rekal installs to their scripts.
+func rekalHooks() map[string]string {
+	return map[string]string{
+		"post-commit": hookScript("checkpoint --hook"),
+		"pre-push":    hookScript("push --hook"),
+	}
+}
+
 func installHooks(gitRoot string) error {
 	hooksDir := filepath.Join(gitRoot, ".git", "hooks")
 	if err := os.MkdirAll(hooksDir, 0o755); err != nil {
 		return err
 	}
-
-	postCommit := filepath.Join(hooksDir, "post-commit")
-	if err := writeHook(postCommit, hookScript("checkpoint")); err != nil {
-		return fmt.Errorf("post-commit hook: %w", err)
+	for name, script := range rekalHooks() {
+		if err := writeHook(filepath.Join(hooksDir, name), script); err != nil {
+			return fmt.Errorf("%s hook: %w", name, err)
+		}
 	}
+	return nil
+}
 
-	prePush := filepath.Join(hooksDir, "pre-push")
-	if err := writeHook(prePush, hookScript("push")); err != nil {
-		return fmt.Errorf("pre-push hook: %w", err)
+// staleHooks returns, sorted, the names of rekal's hooks that differ from the
+// ones this version installs, such as hooks written before they passed
+// --hook. Missing hooks and hooks that are not rekal's are not stale.
+func staleHooks(gitRoot string) []string {
+	hooksDir := filepath.Join(gitRoot, ".git", "hooks")
+	var stale []string
+	for name, script := range rekalHooks() {
+		existing, err := os.ReadFile(filepath.Join(hooksDir, name))
+		if err != nil || string(existing) == script || !strings.Contains(string(existing), rekalHookMarker) {
+			continue
+		}
+		stale = append(stale, name)
 	}
+	sort.Strings(stale)
+	return stale
+}
 
+// refreshHooks rewrites the hooks reported by staleHooks.
+func refreshHooks(gitRoot string) error {
+	hooks := rekalHooks()
+	for _, name := range staleHooks(gitRoot) {
+		path := filepath.Join(gitRoot, ".git", "hooks", name)
+		if err := os.WriteFile(path, []byte(hooks[name]), 0o755); err != nil {
+			return fmt.Errorf("%s hook: %w", name, err)
+		}
+	}
 	return nil
 }
 
@@ -214,14 +252,14 @@ func writeHook(path, content string) error {
 	return os.WriteFile(path, []byte(content), 0o755)
 }
 
-// rekalBranchName returns the orphan branch name for the current user.
-// Format: rekal/<user_email>
+// rekalBranchName returns the name the current user's rekal data is read
+// and written under: the orphan branch rekal/<user_email>, or the custom ref
+// refs/rekal/<user_email> when rekal.layout is refs (see layout.go).
 func rekalBranchName() string {
-	email := strings.TrimSpace(gitConfigValue("user.email"))
-	if email == "" {
-		email = "local"
+	if rekalLayout() == layoutRefs {
+		return rekalRef()
 	}
-	return "rekal/" + email
+	return "rekal/" + rekalEmail()
 }
 
 // gitConfigValue reads a git config value.
@@ -233,50 +271,64 @@ func gitConfigValue(key string) string {
 	return strings.TrimSpace(string(out))
 }
 
-// ensureOrphanBranch creates or fetches the local rekal orphan branch.
+// gitConfigValues reads all values of a multi-valued git config key.
+func gitConfigValues(key string) []string {
+	out, err := exec.Command("git", "config", "--get-all", key).Output()
+	if err != nil {
+		return nil
+	}
+	var values []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			values = append(values, line)
+		}
+	}
+	return values
+}
+
+// ensureOrphanBranch creates or fetches the local rekal orphan branch (or
+// custom ref, see layout.go).
 // If the branch exists locally, it's left as-is.
-// If it exists on the remote, it's fetched.
-// Otherwise, a new orphan branch is created with empty rekal.body and dict.bin.
+// If it exists on the push remote, it's fetched.
+// Otherwise, a new orphan branch is created with an empty dict.bin and a
+// segmented body with no segments yet.
 func ensureOrphanBranch(gitRoot string) error {
 	branch := rekalBranchName()
 
 	// Check if local branch already exists.
-	if err := exec.Command("git", "-C", gitRoot, "rev-parse", "--verify", branch).Run(); err == nil {
+	if refExists(gitRoot, branch) {
 		return nil // already exists locally
 	}
 
 	// Check if remote branch exists and fetch it.
-	remote := "origin"
-	remoteBranch := remote + "/" + branch
+	remote := pushRemote("")
+	remoteBranch := remoteRekalRef(remote)
 	// Fetch the specific branch (ignore errors — remote may not exist or branch may not exist).
-	_ = exec.Command("git", "-C", gitRoot, "fetch", remote, branch).Run()
+	_ = fetchOwnRekalRef(gitRoot, remote)
 
-	// If remote branch now exists locally as a remote-tracking branch, create local from it.
-	if err := exec.Command("git", "-C", gitRoot, "rev-parse", "--verify", remoteBranch).Run(); err == nil {
-		return exec.Command("git", "-C", gitRoot, "branch", branch, remoteBranch).Run()
+	// If remote branch now exists locally as a remote-tracking ref, create local from it.
+	if sha, err := gitRevParse(gitRoot, remoteBranch); err == nil {
+		return exec.Command("git", "-C", gitRoot, "update-ref", rekalRef(), sha).Run()
 	}
 
 	// Create new orphan branch with initial wire format files.
-	bodyData := codec.NewBody()
-	dictData := codec.NewDict().Encode()
-
-	bodyHash, err := gitHashObject(gitRoot, bodyData)
+	manifestHash, err := gitHashObject(gitRoot, (&codec.Manifest{}).Encode())
 	if err != nil {
-		return fmt.Errorf("hash rekal.body: %w", err)
+		return fmt.Errorf("hash manifest: %w", err)
 	}
-	dictHash, err := gitHashObject(gitRoot, dictData)
+	dictHash, err := gitHashObject(gitRoot, codec.NewDict().Encode())
 	if err != nil {
 		return fmt.Errorf("hash dict.bin: %w", err)
 	}
 
-	treeEntry := fmt.Sprintf("100644 blob %s\tdict.bin\n100644 blob %s\trekal.body\n", dictHash, bodyHash)
-	mktreeCmd := exec.Command("git", "-C", gitRoot, "mktree")
-	mktreeCmd.Stdin = strings.NewReader(treeEntry)
-	treeOut, err := mktreeCmd.Output()
+	bodyTree, err := gitMktree(gitRoot, fmt.Sprintf("100644 blob %s\t%s\n", manifestHash, codec.ManifestName))
 	if err != nil {
-		return fmt.Errorf("mktree: %w", err)
+		return err
+	}
+	treeHash, err := gitMktree(gitRoot, fmt.Sprintf("100644 blob %s\tdict.bin\n040000 tree %s\t%s\n", dictHash, bodyTree, codec.SegmentDir))
+	if err != nil {
+		return err
 	}
-	treeHash := strings.TrimSpace(string(treeOut))
 
 	commitOut, err := exec.Command("git", "-C", gitRoot,
 		"commit-tree", treeHash, "-m", "rekal: initialize checkpoint branch",
@@ -286,7 +338,7 @@ func ensureOrphanBranch(gitRoot string) error {
 	}
 	commitHash := strings.TrimSpace(string(commitOut))
 
-	return exec.Command("git", "-C", gitRoot, "update-ref", "refs/heads/"+branch, commitHash).Run()
+	return exec.Command("git", "-C", gitRoot, "update-ref", rekalRef(), commitHash).Run()
 }
 
 // gitHashObject writes data to the git object store and returns its hash.
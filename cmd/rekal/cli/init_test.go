@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRefreshHooks(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	hooksDir := filepath.Join(dir, ".git", "hooks")
+	if err := os.MkdirAll(hooksDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// A hook from before --hook existed, and a hook that is not rekal's.
+	stale := "#!/bin/sh\n" + rekalHookMarker + "\nrekal checkpoint\n"
+	foreign := "#!/bin/sh\nmake lint\n"
+	if err := os.WriteFile(filepath.Join(hooksDir, "post-commit"), []byte(stale), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(hooksDir, "pre-push"), []byte(foreign), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := staleHooks(dir); len(got) != 1 || got[0] != "post-commit" {
+		t.Errorf("staleHooks = %v, want [post-commit]", got)
+	}
+	if err := refreshHooks(dir); err != nil {
+		t.Fatalf("refreshHooks: %v", err)
+	}
+	hooks := rekalHooks()
+	if got, _ := os.ReadFile(filepath.Join(hooksDir, "post-commit")); string(got) != hooks["post-commit"] {
+		t.Errorf("post-commit not rewritten:\n%s", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(hooksDir, "pre-push")); string(got) != foreign {
+		t.Errorf("foreign pre-push hook changed:\n%s", got)
+	}
+	if got := staleHooks(dir); len(got) != 0 {
+		t.Errorf("staleHooks after refresh = %v, want none", got)
+	}
+}
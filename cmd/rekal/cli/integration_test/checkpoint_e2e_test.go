@@ -40,6 +40,30 @@ func gitShow(dir, ref, path string) []byte {
 	return out
 }
 
+// gitShowBody reads the logical rekal.body from a git ref: its segments
+// joined in manifest order, or the single rekal.body of a legacy branch.
+// Returns nil if there is neither.
+func gitShowBody(t *testing.T, dir, ref string) []byte {
+	t.Helper()
+	manifest := gitShow(dir, ref, "body/manifest")
+	if manifest == nil {
+		return gitShow(dir, ref, "rekal.body")
+	}
+	m, err := codec.LoadManifest(manifest)
+	if err != nil {
+		t.Fatalf("LoadManifest: %v", err)
+	}
+	var segs [][]byte
+	for i := range m.Segments {
+		segs = append(segs, gitShow(dir, ref, "body/"+codec.SegmentName(i)))
+	}
+	body, err := codec.JoinSegments(segs)
+	if err != nil {
+		t.Fatalf("JoinSegments: %v", err)
+	}
+	return body
+}
+
 // gitCommit stages all changes and creates a commit.
 func gitCommit(t *testing.T, dir, msg string) {
 	t.Helper()
@@ -86,23 +110,20 @@ func TestCheckpoint_E2E_FullPipeline(t *testing.T) {
 	branch := "rekal/test@rekal.dev"
 
 	// Verify init created wire format files on orphan branch.
-	bodyInit := gitShow(env.RepoDir, branch, "rekal.body")
+	manifestInit := gitShow(env.RepoDir, branch, "body/manifest")
 	dictInit := gitShow(env.RepoDir, branch, "dict.bin")
-	if bodyInit == nil {
-		t.Fatal("rekal.body should exist on orphan branch after init")
+	if manifestInit == nil {
+		t.Fatal("body/manifest should exist on orphan branch after init")
 	}
 	if dictInit == nil {
 		t.Fatal("dict.bin should exist on orphan branch after init")
 	}
-	if string(bodyInit[:7]) != "RKLBODY" {
-		t.Errorf("body magic: got %q", bodyInit[:7])
+	if m, err := codec.LoadManifest(manifestInit); err != nil || len(m.Segments) != 0 {
+		t.Errorf("initial manifest should list no segments: %+v, %v", m, err)
 	}
 	if string(dictInit[:6]) != "RKDICT" {
 		t.Errorf("dict magic: got %q", dictInit[:6])
 	}
-	if len(bodyInit) != 9 {
-		t.Errorf("initial body should be 9 bytes (header only), got %d", len(bodyInit))
-	}
 
 	// --- First checkpoint (DuckDB only, no wire format) ---
 
@@ -130,9 +151,9 @@ func TestCheckpoint_E2E_FullPipeline(t *testing.T) {
 	assertQueryContains(t, env, "SELECT count(*) as n FROM checkpoint_sessions", `"n":1`)
 
 	// Checkpoint should NOT have written to orphan branch (that's push's job now).
-	bodyAfterCp := gitShow(env.RepoDir, branch, "rekal.body")
-	if len(bodyAfterCp) != 9 {
-		t.Errorf("body should still be header-only after checkpoint (no wire format), got %d bytes", len(bodyAfterCp))
+	bodyAfterCp := gitShowBody(t, env.RepoDir, branch)
+	if len(bodyAfterCp) != 0 {
+		t.Errorf("body should still be empty after checkpoint (no wire format), got %d bytes", len(bodyAfterCp))
 	}
 
 	// Checkpoint should be unexported.
@@ -215,9 +236,9 @@ func TestPush_E2E_ExportAndPush(t *testing.T) {
 	}
 
 	// Verify wire format on orphan branch after push.
-	body1 := gitShow(env.RepoDir, branch, "rekal.body")
+	body1 := gitShowBody(t, env.RepoDir, branch)
 	dict1 := gitShow(env.RepoDir, branch, "dict.bin")
-	if body1 == nil || len(body1) <= 9 {
+	if body1 == nil || len(body1) <= codec.BodyHeaderSize {
 		t.Fatal("body should have frames after push")
 	}
 	if dict1 == nil || len(dict1) <= 12 {
@@ -317,7 +338,7 @@ func TestPush_E2E_ExportAndPush(t *testing.T) {
 		t.Fatalf("push 2: %v", err)
 	}
 
-	body2 := gitShow(env.RepoDir, branch, "rekal.body")
+	body2 := gitShowBody(t, env.RepoDir, branch)
 	dict2 := gitShow(env.RepoDir, branch, "dict.bin")
 
 	// Verify append-only: first N bytes of body2 must equal body1.
@@ -329,6 +350,14 @@ func TestPush_E2E_ExportAndPush(t *testing.T) {
 		t.Error("append-only violation: body prefix changed after second push")
 	}
 
+	// The second push added a segment and left the first one as it was.
+	if seg0 := gitShow(env.RepoDir, branch, "body/000000.rkb"); sha256Hex(seg0) != sha256Hex(body1) {
+		t.Error("first segment changed after second push")
+	}
+	if gitShow(env.RepoDir, branch, "body/000001.rkb") == nil {
+		t.Error("second push should add body/000001.rkb")
+	}
+
 	// Should now have 6 frames.
 	frames2, err := codec.ScanFrames(body2)
 	if err != nil {
@@ -400,12 +429,17 @@ func TestPush_E2E_ForceOnConflict(t *testing.T) {
 	} {
 		exec.Command("git", "-C", cloneDir, "config", kv[0], kv[1]).Run()
 	}
-	exec.Command("git", "-C", cloneDir, "fetch", "origin", branch).Run()
-	exec.Command("git", "-C", cloneDir, "checkout", "-b", branch, "origin/"+branch).Run()
-	exec.Command("git", "-C", cloneDir, "commit", "--allow-empty", "--amend", "-m", "divergent").Run()
-	exec.Command("git", "-C", cloneDir, "push", "--force", "origin", branch).Run()
+	diverge := func() string {
+		exec.Command("git", "-C", cloneDir, "fetch", "origin", branch).Run()
+		exec.Command("git", "-C", cloneDir, "checkout", "-B", branch, "origin/"+branch).Run()
+		exec.Command("git", "-C", cloneDir, "commit", "--allow-empty", "--amend", "-m", "divergent").Run()
+		exec.Command("git", "-C", cloneDir, "push", "--force", "origin", branch).Run()
+		out, _ := exec.Command("git", "-C", bareDir, "rev-parse", branch).Output()
+		return strings.TrimSpace(string(out))
+	}
+	divergent := diverge()
 
-	// Second checkpoint + push should detect conflict.
+	// Second checkpoint + push should detect the conflict and merge.
 	cleanup2 := writeSessionFile(t, env.RepoDir, "session2.jsonl", testSessionJSONL2)
 	defer cleanup2()
 	if err := os.WriteFile(filepath.Join(env.RepoDir, "login.go"), []byte("func login() error { log.Println(\"ok\"); return nil }\n"), 0o644); err != nil {
@@ -422,13 +456,16 @@ func TestPush_E2E_ForceOnConflict(t *testing.T) {
 	if err != nil {
 		t.Fatalf("push (conflict): %v", err)
 	}
-	if !strings.Contains(stderr, "non-fast-forward") && !strings.Contains(stderr, "rejected") {
-		if strings.Contains(stderr, "pushed to origin/") {
-			t.Errorf("conflicting push should not succeed without --force, got: %q", stderr)
-		}
+	if !strings.Contains(stderr, "diverged from local — merged 1 session(s) and 1 checkpoint(s)") || !strings.Contains(stderr, "pushed to origin/"+branch) {
+		t.Errorf("conflicting push should merge and push, got: %q", stderr)
+	}
+	if exec.Command("git", "-C", bareDir, "merge-base", "--is-ancestor", divergent, branch).Run() != nil {
+		t.Error("merged push should keep the divergent remote commit")
 	}
 
-	// Force push should succeed.
+	// Force push still overwrites the remote on purpose.
+	diverge()
+	exec.Command("git", "-C", env.RepoDir, "fetch", "origin", branch).Run()
 	_, stderrForce, err := env.RunCLI("push", "--force")
 	if err != nil {
 		t.Fatalf("push --force: %v", err)
@@ -689,3 +726,99 @@ func assertQueryContains(t *testing.T, env *TestEnv, sql, expected string) {
 		t.Errorf("query %q: expected %q in output, got: %q", sql, expected, stdout)
 	}
 }
+
+const testSessionJSONLExtraTools = `{"type":"summary","sessionId":"test-session-003","totalCost":0.01,"totalDuration":30}
+{"type":"user","parentMessageId":"","isSidechain":false,"message":{"role":"user","content":[{"type":"text","text":"open an issue for the flaky test"}]},"timestamp":"2026-02-25T12:00:00Z","gitBranch":"main"}
+{"type":"assistant","parentMessageId":"m1","isSidechain":false,"message":{"role":"assistant","content":[{"type":"tool_use","id":"tu-1","name":"WebFetch","input":{"url":"https://example.com"}},{"type":"tool_use","id":"tu-2","name":"mcp__github__create_issue","input":{"title":"flaky"}}]},"timestamp":"2026-02-25T12:00:10Z"}
+`
+
+func TestPush_E2E_KeepsToolNames(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	cleanup := writeSessionFile(t, env.RepoDir, "session3.jsonl", testSessionJSONLExtraTools)
+	defer cleanup()
+	gitCommit(t, env.RepoDir, "file issue")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+	bareDir := t.TempDir()
+	if err := exec.Command("git", "init", "--bare", bareDir).Run(); err != nil {
+		t.Fatalf("git init --bare: %v", err)
+	}
+	if err := exec.Command("git", "-C", env.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
+
+	// Tools without a fixed code travel by name in the dict tools namespace.
+	branch := "rekal/test@rekal.dev"
+	dict, err := codec.LoadDict(gitShow(env.RepoDir, branch, "dict.bin"))
+	if err != nil {
+		t.Fatalf("LoadDict: %v", err)
+	}
+	body := gitShowBody(t, env.RepoDir, branch)
+	frames, err := codec.ScanFrames(body)
+	if err != nil || len(frames) == 0 {
+		t.Fatalf("ScanFrames: %v (%d frames)", err, len(frames))
+	}
+	dec, err := codec.NewDecoder()
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	defer dec.Close()
+	sf, err := dec.DecodeSessionFrame(codec.ExtractFramePayload(body, frames[0]))
+	if err != nil {
+		t.Fatalf("decode session: %v", err)
+	}
+
+	var names []string
+	for _, tc := range sf.ToolCalls {
+		names = append(names, dict.ToolName(tc))
+	}
+	if strings.Join(names, ",") != "WebFetch,mcp__github__create_issue" {
+		t.Errorf("tool names on the wire: got %v", names)
+	}
+}
+
+func TestPush_E2E_MigratesLegacyBody(t *testing.T) {
+	env, _ := setupPushedRepo(t)
+	branch := "rekal/test@rekal.dev"
+
+	// Turn the branch back into a pre-segment single-file body.
+	legacy := gitShowBody(t, env.RepoDir, branch)
+	legacyHash := writeLegacyBranch(t, env.RepoDir, branch, legacy)
+
+	cleanup := writeSessionFile(t, env.RepoDir, "session2.jsonl", testSessionJSONL2)
+	t.Cleanup(cleanup)
+	gitCommit(t, env.RepoDir, "add logging")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+	if _, stderr, err := env.RunCLI("push", "--force"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
+
+	// The legacy body became segment 0 as the same blob; new frames went
+	// into a new segment.
+	seg0, err := exec.Command("git", "-C", env.RepoDir, "rev-parse", branch+":body/000000.rkb").Output()
+	if err != nil || strings.TrimSpace(string(seg0)) != legacyHash {
+		t.Errorf("segment 0 = %q (%v), want legacy blob %s", strings.TrimSpace(string(seg0)), err, legacyHash)
+	}
+	if gitShow(env.RepoDir, branch, "rekal.body") != nil {
+		t.Error("rekal.body should be gone after migrating to segments")
+	}
+	body := gitShowBody(t, env.RepoDir, branch)
+	if len(body) <= len(legacy) || sha256Hex(body[:len(legacy)]) != sha256Hex(legacy) {
+		t.Fatalf("logical body should extend the legacy body: %d → %d bytes", len(legacy), len(body))
+	}
+
+	if stdout, _, err := env.RunCLI("verify", branch); err != nil || !strings.Contains(stdout, branch+": ok") {
+		t.Errorf("verify after migration: %v, %q", err, stdout)
+	}
+	if stdout, _, err := env.RunCLI("wire", "check", branch); err != nil || !strings.Contains(stdout, branch+": ok") {
+		t.Errorf("wire check after migration: %v, %q", err, stdout)
+	}
+}
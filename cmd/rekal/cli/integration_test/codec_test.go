@@ -0,0 +1,112 @@
+//go:build integration
+
+package integration
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitOutput runs git in dir and returns its stdout, or nil if it fails.
+func gitOutput(t *testing.T, dir string, args ...string) []byte {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+// addPushedSession captures a copy of the test session under a new session
+// ID in a new commit and pushes it.
+func addPushedSession(t *testing.T, env *TestEnv, i int) {
+	t.Helper()
+	content := strings.ReplaceAll(testSessionJSONL, "test-session-001", fmt.Sprintf("test-session-%03d", i))
+	content = strings.ReplaceAll(content, "login.go", fmt.Sprintf("login%d.go", i))
+	cleanup := writeSessionFile(t, env.RepoDir, fmt.Sprintf("session%d.jsonl", i), content)
+	t.Cleanup(cleanup)
+	if err := os.WriteFile(filepath.Join(env.RepoDir, fmt.Sprintf("login%d.go", i)), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, fmt.Sprintf("fix login %d", i))
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint %d: %v (stderr: %s)", i, err, stderr)
+	}
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push %d: %v (stderr: %s)", i, err, stderr)
+	}
+}
+
+func TestCodec_E2E_Train(t *testing.T) {
+	t.Parallel()
+	env, _ := setupPushedRepo(t)
+	branch := "rekal/test@rekal.dev"
+
+	// Too few frames to train on.
+	stdout, stderr, err := env.RunCLI("codec", "train")
+	if err != nil {
+		t.Fatalf("codec train: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stdout, "need at least") {
+		t.Errorf("expected too-few-frames message, got: %q", stdout)
+	}
+
+	for i := 2; i <= 4; i++ {
+		addPushedSession(t, env, i)
+	}
+	before := strings.TrimSpace(string(gitOutput(t, env.RepoDir, "rev-parse", branch)))
+
+	stdout, stderr, err = env.RunCLI("codec", "train", "--dry-run", "--size", "2048")
+	if err != nil {
+		t.Fatalf("codec train --dry-run: %v (stderr: %s)", err, stderr)
+	}
+	for _, want := range []string{"CURRENT", "TRAINED", "session", "checkpoint", "built-in preset", "dry run"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("dry run output missing %q: %s", want, stdout)
+		}
+	}
+	if after := strings.TrimSpace(string(gitOutput(t, env.RepoDir, "rev-parse", branch))); after != before {
+		t.Error("dry run changed the rekal branch")
+	}
+
+	stdout, stderr, err = env.RunCLI("codec", "train", "--size", "2048")
+	if err != nil {
+		t.Fatalf("codec train: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stdout, "stored dictionary") {
+		t.Fatalf("expected the dictionary to be stored, got: %s", stdout)
+	}
+	dicts := strings.Fields(string(gitOutput(t, env.RepoDir, "ls-tree", "--name-only", branch+":dicts")))
+	if len(dicts) != 1 || !strings.HasSuffix(dicts[0], ".zdict") {
+		t.Fatalf("dicts/ = %v, want one .zdict", dicts)
+	}
+	id := strings.TrimSuffix(dicts[0], ".zdict")
+
+	// Frames exported from now on use the trained dictionary and still decode.
+	addPushedSession(t, env, 5)
+	stdout, stderr, err = env.RunCLI("wire", "dump", branch)
+	if err != nil {
+		t.Fatalf("wire dump: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stdout, `"dict_id":"`+id+`"`) {
+		t.Errorf("wire dump has no meta frame naming %s", id)
+	}
+	stdout, stderr, err = env.RunCLI("wire", "check", branch)
+	if err != nil {
+		t.Fatalf("wire check: %v (stdout: %s, stderr: %s)", err, stdout, stderr)
+	}
+	stdout, _, err = env.RunCLI("wire", "stats", branch)
+	if err != nil || !strings.Contains(stdout, "active "+id) {
+		t.Errorf("wire stats should report %s active, got: %s (%v)", id, stdout, err)
+	}
+	if stdout, stderr, err = env.RunCLI("verify", branch); err != nil {
+		t.Fatalf("verify: %v (stdout: %s, stderr: %s)", err, stdout, stderr)
+	}
+	if gitOutput(t, env.RepoDir, "ls-tree", "--name-only", "origin/"+branch+":dicts") == nil {
+		t.Error("push did not publish dicts/")
+	}
+}
@@ -0,0 +1,168 @@
+//go:build integration
+
+package integration
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+)
+
+// keygenTestEnv points env's rekal.identity at a fresh file, runs keygen
+// and returns the recipient it prints.
+func keygenTestEnv(t *testing.T, env *TestEnv, args ...string) string {
+	t.Helper()
+	gitConfig(t, env.RepoDir, "rekal.identity", filepath.Join(t.TempDir(), "identity"))
+	stdout, stderr, err := env.RunCLI(append([]string{"keygen"}, args...)...)
+	if err != nil {
+		t.Fatalf("keygen: %v (stderr: %s)", err, stderr)
+	}
+	rcpt := strings.TrimSpace(stdout)
+	if !strings.HasPrefix(rcpt, "age1") {
+		t.Fatalf("keygen printed %q, want an age1 recipient", stdout)
+	}
+	return rcpt
+}
+
+func TestPush_E2E_EncryptedFrames(t *testing.T) {
+	alice, bareDir := setupPushedRepo(t)
+	branch := "rekal/test@rekal.dev"
+
+	keygenTestEnv(t, alice, "--add")
+	gitCommit(t, alice.RepoDir, "encrypt rekal data")
+	addPushedSession(t, alice, 2)
+
+	if dict := gitOutput(t, bareDir, "show", branch+":dict.bin"); !codec.IsSealed(dict) {
+		t.Error("dict.bin pushed in the clear")
+	}
+	if keys := strings.Fields(string(gitOutput(t, bareDir, "ls-tree", "--name-only", branch+":keys"))); len(keys) != 1 {
+		t.Errorf("keys/ = %v, want one wrapped key", keys)
+	}
+	if _, stderr, err := alice.RunCLI("verify"); err != nil {
+		t.Errorf("verify: %v (stderr: %s)", err, stderr)
+	}
+	if stdout, stderr, err := alice.RunCLI("wire", "check"); err != nil {
+		t.Errorf("wire check: %v (stdout: %s, stderr: %s)", err, stdout, stderr)
+	}
+	if _, _, err := alice.RunCLI("codec", "train"); err == nil {
+		t.Error("codec train allowed with encryption on")
+	}
+
+	// Bob is not a recipient yet: alice's branch is skipped.
+	bob := NewTestEnv(t)
+	gitConfig(t, bob.RepoDir, "user.email", "bob@rekal.dev")
+	bob.Init()
+	bobRcpt := keygenTestEnv(t, bob)
+	if err := exec.Command("git", "-C", bob.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+	_, stderr, err := bob.RunCLI("sync")
+	if err != nil {
+		t.Fatalf("sync: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "skipping origin/"+branch) {
+		t.Errorf("expected alice's branch to be skipped, got: %q", stderr)
+	}
+
+	// Once added, bob reads what alice pushes from then on, plus the frames
+	// from before encryption was turned on.
+	f, err := os.OpenFile(filepath.Join(alice.RepoDir, ".rekal-recipients"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(bobRcpt + " # bob\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	gitCommit(t, alice.RepoDir, "add bob")
+	addPushedSession(t, alice, 3)
+	if keys := strings.Fields(string(gitOutput(t, bareDir, "ls-tree", "--name-only", branch+":keys"))); len(keys) != 2 {
+		t.Errorf("keys/ = %v, want a new key for the new recipient list", keys)
+	}
+
+	_, stderr, err = bob.RunCLI("sync")
+	if err != nil {
+		t.Fatalf("sync: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "2 new remote sessions from 1 team member(s)") {
+		t.Errorf("expected the 2 readable sessions, got: %q", stderr)
+	}
+}
+
+// legacyV1Body rewrites a v2 body as a v1 body: no per-frame checksums, and
+// no meta frames, which v1 bodies predate.
+func legacyV1Body(t *testing.T, body []byte) []byte {
+	t.Helper()
+	frames, err := codec.ScanFrames(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1 := codec.NewBody()
+	v1[7] = 0x01
+	for _, f := range frames {
+		if f.Type == codec.FrameMeta {
+			continue
+		}
+		v1 = append(v1, body[f.Offset:f.Offset+6]...)
+		v1 = append(v1, codec.ExtractFramePayload(body, f)...)
+	}
+	return v1
+}
+
+func TestPush_E2E_EncryptedFramesOnLegacyBody(t *testing.T) {
+	alice, bareDir := setupPushedRepo(t)
+	branch := "rekal/test@rekal.dev"
+
+	writeLegacyBranch(t, alice.RepoDir, branch, legacyV1Body(t, gitShowBody(t, alice.RepoDir, branch)))
+	if out, err := exec.Command("git", "-C", alice.RepoDir, "push", "-q", "-f", "origin", branch).CombinedOutput(); err != nil {
+		t.Fatalf("git push: %v: %s", err, out)
+	}
+
+	keygenTestEnv(t, alice, "--add")
+	gitCommit(t, alice.RepoDir, "encrypt rekal data")
+	addPushedSession(t, alice, 2)
+
+	body := gitShowBody(t, bareDir, branch)
+	r, err := codec.ScanBody(body)
+	if err != nil {
+		t.Fatalf("ScanBody: %v", err)
+	}
+	if len(r.Skipped) != 0 {
+		t.Errorf("skipped ranges %+v: sealed frames not recognised", r.Skipped)
+	}
+	if sessions := sessionFrameCount(t, body); sessions != 2 {
+		t.Errorf("remote has %d session frames, want 2", sessions)
+	}
+	sealed := 0
+	for _, f := range r.Frames {
+		if codec.IsSealed(codec.ExtractFramePayload(body, f)) {
+			sealed++
+		}
+	}
+	if sealed == 0 {
+		t.Error("no sealed frames on the remote")
+	}
+	if _, stderr, err := alice.RunCLI("verify"); err != nil {
+		t.Errorf("verify: %v (stderr: %s)", err, stderr)
+	}
+	if stdout, stderr, err := alice.RunCLI("wire", "check"); err != nil {
+		t.Errorf("wire check: %v (stdout: %s, stderr: %s)", err, stdout, stderr)
+	}
+
+	// A second machine with the same identity imports both sessions back.
+	cloneDir := t.TempDir()
+	cloneDir, _ = filepath.EvalSymlinks(cloneDir)
+	if out, err := exec.Command("git", "clone", "-q", bareDir, cloneDir).CombinedOutput(); err != nil {
+		t.Fatalf("git clone: %v: %s", err, out)
+	}
+	gitConfig(t, cloneDir, "user.email", "test@rekal.dev")
+	gitConfig(t, cloneDir, "user.name", "Rekal Test")
+	gitConfig(t, cloneDir, "rekal.identity", strings.TrimSpace(string(gitOutput(t, alice.RepoDir, "config", "rekal.identity"))))
+	desktop := NewTestEnvAt(t, cloneDir)
+	desktop.Init()
+	assertQueryContains(t, desktop, "SELECT count(*) AS n FROM sessions", `"n":2`)
+}
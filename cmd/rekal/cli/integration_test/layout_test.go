@@ -0,0 +1,120 @@
+//go:build integration
+
+package integration
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrateRefs_E2E_CustomRefLayout(t *testing.T) {
+	env, bareDir := setupPushedRepo(t)
+	branch := "refs/heads/rekal/test@rekal.dev"
+	ref := "refs/rekal/test@rekal.dev"
+	head := strings.TrimSpace(string(gitOutput(t, env.RepoDir, "rev-parse", branch)))
+
+	_, stderr, err := env.RunCLI("migrate-refs", "--delete-remote")
+	if err != nil {
+		t.Fatalf("migrate-refs: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "moved "+branch+" to "+ref) {
+		t.Errorf("expected move message, got: %q", stderr)
+	}
+	if got := strings.TrimSpace(string(gitOutput(t, env.RepoDir, "config", "rekal.layout"))); got != "refs" {
+		t.Errorf("rekal.layout = %q, want refs", got)
+	}
+	if got := strings.TrimSpace(string(gitOutput(t, bareDir, "rev-parse", ref))); got != head {
+		t.Errorf("remote %s = %q, want %s", ref, got, head)
+	}
+	if exec.Command("git", "-C", bareDir, "rev-parse", "--verify", "--quiet", branch).Run() == nil {
+		t.Error("--delete-remote left the branch on the remote")
+	}
+	if branches := string(gitOutput(t, env.RepoDir, "branch", "-a")); strings.Contains(branches, "rekal") {
+		t.Errorf("git branch -a still lists rekal data:\n%s", branches)
+	}
+
+	// Later pushes go to the custom ref.
+	addPushedSession(t, env, 2)
+	if n := sessionFrameCount(t, gitShowBody(t, bareDir, ref)); n != 2 {
+		t.Errorf("remote %s has %d session frames, want 2", ref, n)
+	}
+
+	// A second machine in the refs layout picks the data up on init.
+	cloneDir := t.TempDir()
+	cloneDir, _ = filepath.EvalSymlinks(cloneDir)
+	if out, err := exec.Command("git", "clone", "-q", bareDir, cloneDir).CombinedOutput(); err != nil {
+		t.Fatalf("git clone: %v: %s", err, out)
+	}
+	gitConfig(t, cloneDir, "user.email", "test@rekal.dev")
+	gitConfig(t, cloneDir, "user.name", "Rekal Test")
+	gitConfig(t, cloneDir, "rekal.layout", "refs")
+	desktop := NewTestEnvAt(t, cloneDir)
+	desktop.Init()
+	if got := strings.TrimSpace(string(gitOutput(t, cloneDir, "rev-parse", ref))); got != strings.TrimSpace(string(gitOutput(t, bareDir, "rev-parse", ref))) {
+		t.Errorf("init did not fetch %s", ref)
+	}
+	stdout, stderr, err := desktop.RunCLI("verify")
+	if err != nil {
+		t.Fatalf("verify: %v (stderr: %s)", err, stderr)
+	}
+	for _, want := range []string{ref, "refs/rekal-remotes/origin/test@rekal.dev"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("verify should check %s, got: %q", want, stdout)
+		}
+	}
+
+	// clean drops the fetched copies but keeps the user's own data.
+	if _, stderr, err := desktop.RunCLI("clean"); err != nil {
+		t.Fatalf("clean: %v (stderr: %s)", err, stderr)
+	}
+	if refs := string(gitOutput(t, cloneDir, "for-each-ref", "refs/rekal-remotes/")); refs != "" {
+		t.Errorf("clean left fetched refs:\n%s", refs)
+	}
+	if exec.Command("git", "-C", cloneDir, "rev-parse", "--verify", "--quiet", ref).Run() != nil {
+		t.Errorf("clean removed %s", ref)
+	}
+
+	// And back.
+	if _, stderr, err := env.RunCLI("migrate-refs", "--to", "branches"); err != nil {
+		t.Fatalf("migrate-refs --to branches: %v (stderr: %s)", err, stderr)
+	}
+	if exec.Command("git", "-C", bareDir, "rev-parse", "--verify", "--quiet", branch).Run() != nil {
+		t.Error("branch not pushed back to the remote")
+	}
+}
+
+func TestSync_E2E_CustomRefLayout(t *testing.T) {
+	// Alice moved to the refs layout; Bob still pushes a branch.
+	alice, bareDir := setupPushedRepo(t)
+	if _, stderr, err := alice.RunCLI("migrate-refs", "--delete-remote"); err != nil {
+		t.Fatalf("migrate-refs: %v (stderr: %s)", err, stderr)
+	}
+	bob, _ := setupPushedRepo(t)
+	addPushedSession(t, bob, 2)
+	if out, err := exec.Command("git", "-C", bob.RepoDir, "push", "--no-verify", bareDir, "rekal/test@rekal.dev:rekal/bob@rekal.dev").CombinedOutput(); err != nil {
+		t.Fatalf("push bob: %v: %s", err, out)
+	}
+
+	carol := NewTestEnv(t)
+	gitConfig(t, carol.RepoDir, "user.email", "carol@rekal.dev")
+	carol.Init()
+	if err := exec.Command("git", "-C", carol.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+
+	_, stderr, err := carol.RunCLI("sync")
+	if err != nil {
+		t.Fatalf("sync: %v (stderr: %s)", err, stderr)
+	}
+	for _, want := range []string{
+		"importing refs/rekal-remotes/origin/test@rekal.dev",
+		"importing origin/rekal/bob@rekal.dev",
+		"2 remote sessions from 2 team member(s)",
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("expected %q, got: %q", want, stderr)
+		}
+	}
+}
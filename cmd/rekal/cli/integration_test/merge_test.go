@@ -0,0 +1,123 @@
+//go:build integration
+
+package integration
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+)
+
+// cloneTestEnv clones bareDir as a second machine of the same user and
+// runs init there.
+func cloneTestEnv(t *testing.T, bareDir string) *TestEnv {
+	t.Helper()
+	cloneDir := t.TempDir()
+	cloneDir, _ = filepath.EvalSymlinks(cloneDir)
+	if out, err := exec.Command("git", "clone", "-q", bareDir, cloneDir).CombinedOutput(); err != nil {
+		t.Fatalf("git clone: %v: %s", err, out)
+	}
+	gitConfig(t, cloneDir, "user.email", "test@rekal.dev")
+	gitConfig(t, cloneDir, "user.name", "Rekal Test")
+	env := NewTestEnvAt(t, cloneDir)
+	env.Init()
+	return env
+}
+
+func TestPush_E2E_MergesDivergedBranch(t *testing.T) {
+	laptop, bareDir := setupPushedRepo(t)
+	branch := "rekal/test@rekal.dev"
+
+	// The desktop starts from the pushed branch and pushes first.
+	desktop := cloneTestEnv(t, bareDir)
+	addPushedSession(t, desktop, 2)
+	desktopHead := strings.TrimSpace(string(gitOutput(t, bareDir, "rev-parse", branch)))
+
+	// The laptop's push is rejected and merged instead of overwriting.
+	addPushedSession(t, laptop, 3)
+	laptopHead := strings.TrimSpace(string(gitOutput(t, laptop.RepoDir, "rev-parse", branch)))
+
+	remoteHead := strings.TrimSpace(string(gitOutput(t, bareDir, "rev-parse", branch)))
+	if remoteHead != laptopHead {
+		t.Fatalf("remote head = %s, want the laptop's merge %s", remoteHead, laptopHead)
+	}
+	parents := strings.Fields(string(gitOutput(t, bareDir, "log", "-1", "--format=%P", branch)))
+	if len(parents) != 2 || parents[0] != desktopHead {
+		t.Errorf("merge parents = %v, want the desktop head %s first", parents, desktopHead)
+	}
+
+	// Every session from both machines is on the remote once, and the
+	// desktop's body is an unchanged prefix.
+	body := gitShowBody(t, bareDir, branch)
+	desktopBody := gitShowBody(t, bareDir, desktopHead)
+	if !strings.HasPrefix(string(body), string(desktopBody)) {
+		t.Error("merged body does not start with the remote body")
+	}
+	if sessions := sessionFrameCount(t, body); sessions != 3 {
+		t.Errorf("remote has %d session frames, want 3", sessions)
+	}
+
+	stdout, stderr, err := laptop.RunCLI("verify", "origin/"+branch)
+	if err != nil {
+		t.Errorf("verify merged branch: %v (stdout: %s, stderr: %s)", err, stdout, stderr)
+	}
+
+	// The desktop merges the laptop's session on its next push in turn.
+	addPushedSession(t, desktop, 4)
+	if n := sessionFrameCount(t, gitShowBody(t, bareDir, branch)); n != 4 {
+		t.Errorf("remote has %d session frames after the second merge, want 4", n)
+	}
+	if !isAncestorOf(t, bareDir, remoteHead, branch) {
+		t.Error("second merge does not build on the first")
+	}
+}
+
+func TestPush_E2E_MergeRefusesUnreadableFrames(t *testing.T) {
+	laptop, bareDir := setupPushedRepo(t)
+	branch := "rekal/test@rekal.dev"
+
+	desktop := cloneTestEnv(t, bareDir)
+	addPushedSession(t, desktop, 2)
+	desktopHead := strings.TrimSpace(string(gitOutput(t, bareDir, "rev-parse", branch)))
+
+	// A local session frame whose payload is not zstd: it scans, but
+	// cannot be decoded and so cannot be re-interned into the remote.
+	payload := []byte("not zstd")
+	frame := append(codec.WriteEnvelope(codec.FrameSession, len(payload), len(payload)), payload...)
+	writeLegacyBranch(t, laptop.RepoDir, branch, codec.AppendFrame(gitShowBody(t, laptop.RepoDir, branch), frame))
+
+	// Push reports the failed merge and leaves the remote alone.
+	_, stderr, err := laptop.RunCLI("push")
+	if err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "merging failed") || !strings.Contains(stderr, "1 local frame(s)") {
+		t.Errorf("stderr = %q, want the unreadable frame counted", stderr)
+	}
+	if head := strings.TrimSpace(string(gitOutput(t, bareDir, "rev-parse", branch))); head != desktopHead {
+		t.Errorf("remote head = %s, want it left at %s", head, desktopHead)
+	}
+}
+
+func sessionFrameCount(t *testing.T, body []byte) int {
+	t.Helper()
+	frames, err := codec.ScanFrames(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, f := range frames {
+		if f.Type == codec.FrameSession {
+			n++
+		}
+	}
+	return n
+}
+
+func isAncestorOf(t *testing.T, dir, a, b string) bool {
+	t.Helper()
+	return exec.Command("git", "-C", dir, "merge-base", "--is-ancestor", a, b).Run() == nil
+}
@@ -0,0 +1,83 @@
+//go:build integration
+
+package integration
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestCheckpoint_E2E_GitNotes(t *testing.T) {
+	laptop, bareDir := setupPushedRepo(t)
+
+	// Notes are opt-in.
+	if exec.Command("git", "-C", laptop.RepoDir, "rev-parse", "--verify", "--quiet", "refs/notes/rekal").Run() == nil {
+		t.Fatal("notes written without rekal.notes")
+	}
+
+	gitConfig(t, laptop.RepoDir, "rekal.notes", "true")
+	addPushedSession(t, laptop, 2)
+	laptopCommit := strings.TrimSpace(string(gitOutput(t, laptop.RepoDir, "rev-parse", "HEAD")))
+	note := string(gitOutput(t, laptop.RepoDir, "notes", "--ref=rekal", "show", laptopCommit))
+	for _, want := range []string{"1 session(s)", "Rekal-Checkpoint: ", "Rekal-Session: "} {
+		if !strings.Contains(note, want) {
+			t.Errorf("note missing %q:\n%s", want, note)
+		}
+	}
+
+	// push published the notes ref; plain git can read it.
+	remoteNote := string(gitOutput(t, bareDir, "notes", "--ref=rekal", "show", laptopCommit))
+	if strings.TrimSpace(remoteNote) != strings.TrimSpace(note) {
+		t.Errorf("remote note = %q, want %q", remoteNote, note)
+	}
+
+	// A second writer's notes push is rejected, merged and retried.
+	desktop := cloneTestEnv(t, bareDir)
+	gitConfig(t, desktop.RepoDir, "rekal.notes", "true")
+	addPushedSession(t, desktop, 3)
+	desktopCommit := strings.TrimSpace(string(gitOutput(t, desktop.RepoDir, "rev-parse", "HEAD")))
+	for _, commit := range []string{laptopCommit, desktopCommit} {
+		if err := exec.Command("git", "-C", bareDir, "notes", "--ref=rekal", "show", commit).Run(); err != nil {
+			t.Errorf("remote notes missing %s after merge", commit)
+		}
+	}
+}
+
+func TestSync_E2E_FetchesGitNotes(t *testing.T) {
+	alice, bareDir := setupPushedRepo(t)
+	gitConfig(t, alice.RepoDir, "rekal.notes", "true")
+	addPushedSession(t, alice, 2)
+	commit := strings.TrimSpace(string(gitOutput(t, alice.RepoDir, "rev-parse", "HEAD")))
+
+	bob := NewTestEnv(t)
+	gitConfig(t, bob.RepoDir, "user.email", "bob@rekal.dev")
+	bob.Init()
+	if err := exec.Command("git", "-C", bob.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+	if _, stderr, err := bob.RunCLI("sync"); err != nil {
+		t.Fatalf("sync: %v (stderr: %s)", err, stderr)
+	}
+	if note := string(gitOutput(t, bob.RepoDir, "notes", "--ref=rekal", "show", commit)); !strings.Contains(note, "Rekal-Checkpoint: ") {
+		t.Errorf("sync did not fetch alice's note, got: %q", note)
+	}
+}
+
+func TestCheckpoint_E2E_GitNotesEncrypted(t *testing.T) {
+	env, bareDir := setupPushedRepo(t)
+	gitConfig(t, env.RepoDir, "rekal.notes", "true")
+	keygenTestEnv(t, env, "--add")
+	gitCommit(t, env.RepoDir, "encrypt rekal data")
+
+	// The note is pushed in the clear, so it carries the IDs only.
+	addPushedSession(t, env, 2)
+	commit := strings.TrimSpace(string(gitOutput(t, env.RepoDir, "rev-parse", "HEAD")))
+	note := string(gitOutput(t, bareDir, "notes", "--ref=rekal", "show", commit))
+	if !strings.HasPrefix(note, "Rekal-Checkpoint: ") || !strings.Contains(note, "Rekal-Session: ") {
+		t.Errorf("note = %q, want the trailers only", note)
+	}
+	if strings.Contains(note, "session(s)") {
+		t.Errorf("note has a summary while encrypted:\n%s", note)
+	}
+}
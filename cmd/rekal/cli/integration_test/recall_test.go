@@ -55,6 +55,61 @@ func TestRecall_HybridSearch(t *testing.T) {
 	}
 }
 
+func TestRecall_ExplainAndFusion(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+
+	seedData(t, env)
+
+	if _, _, err := env.RunCLI("index"); err != nil {
+		t.Fatalf("index failed: %v", err)
+	}
+
+	recall := func(args ...string) map[string]interface{} {
+		t.Helper()
+		stdout, stderr, err := env.RunCLI(args...)
+		if err != nil {
+			t.Fatalf("recall %v: %v\nstderr: %s", args, err, stderr)
+		}
+		var output map[string]interface{}
+		if err := json.Unmarshal([]byte(stdout), &output); err != nil {
+			t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+		}
+		return output
+	}
+
+	// Without --explain the output is unchanged.
+	if output := recall("JWT auth"); output["ranking"] != nil {
+		t.Errorf("ranking without --explain: %v", output["ranking"])
+	}
+
+	gitConfig(t, env.RepoDir, "rekal.fusion", "rrf")
+	gitConfig(t, env.RepoDir, "rekal.rrfK", "10")
+	output := recall("JWT auth", "--explain")
+	ranking, _ := output["ranking"].(map[string]interface{})
+	if ranking["fusion"] != "rrf" || ranking["rrf_k"] != 10.0 {
+		t.Errorf("ranking = %v", ranking)
+	}
+	results, _ := output["results"].([]interface{})
+	for _, r := range results {
+		explain, _ := r.(map[string]interface{})["explain"].(map[string]interface{})
+		for _, signal := range []string{"bm25", "lsa", "nomic"} {
+			if _, ok := explain[signal].(map[string]interface{})["rank"]; !ok {
+				t.Errorf("no %s rank in explain: %v", signal, explain)
+			}
+		}
+	}
+
+	// --fusion overrides the repository setting.
+	if ranking, _ := recall("JWT auth", "--explain", "--fusion", "weighted")["ranking"].(map[string]interface{}); ranking["fusion"] != "weighted" {
+		t.Errorf("ranking with --fusion weighted = %v", ranking)
+	}
+
+	if _, _, err := env.RunCLI("JWT auth", "--fusion", "borda"); err == nil {
+		t.Error("unknown fusion should fail")
+	}
+}
+
 func TestRecall_FilterOnly(t *testing.T) {
 	env := NewTestEnv(t)
 	env.Init()
@@ -0,0 +1,144 @@
+//go:build integration
+
+package integration
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newBareRemote creates a bare repository and adds it to dir as remote name.
+func newBareRemote(t *testing.T, dir, name string) string {
+	t.Helper()
+	bareDir := t.TempDir()
+	bareDir, _ = filepath.EvalSymlinks(bareDir)
+	if err := exec.Command("git", "init", "--bare", bareDir).Run(); err != nil {
+		t.Fatalf("git init --bare: %v", err)
+	}
+	if err := exec.Command("git", "-C", dir, "remote", "add", name, bareDir).Run(); err != nil {
+		t.Fatalf("git remote add %s: %v", name, err)
+	}
+	return bareDir
+}
+
+func gitConfig(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	if out, err := exec.Command("git", append([]string{"-C", dir, "config"}, args...)...).CombinedOutput(); err != nil {
+		t.Fatalf("git config %v: %v: %s", args, err, out)
+	}
+}
+
+func TestPush_E2E_ConfiguredRemote(t *testing.T) {
+	env, _ := setupPushedRepo(t)
+	branch := "rekal/test@rekal.dev"
+	forkDir := newBareRemote(t, env.RepoDir, "fork")
+	otherDir := newBareRemote(t, env.RepoDir, "other")
+
+	gitConfig(t, env.RepoDir, "rekal.pushRemote", "fork")
+	_, stderr, err := env.RunCLI("push")
+	if err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "pushed to fork/"+branch) {
+		t.Errorf("push should go to fork, got: %q", stderr)
+	}
+	if gitOutput(t, forkDir, "rev-parse", "--verify", branch) == nil {
+		t.Error("branch missing on fork")
+	}
+
+	// --remote overrides the configuration.
+	_, stderr, err = env.RunCLI("push", "--remote", "other")
+	if err != nil {
+		t.Fatalf("push --remote: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "pushed to other/"+branch) {
+		t.Errorf("push --remote should go to other, got: %q", stderr)
+	}
+	if gitOutput(t, otherDir, "rev-parse", "--verify", branch) == nil {
+		t.Error("branch missing on other")
+	}
+
+	_, stderr, _ = env.RunCLI("push", "--remote", "missing")
+	if !strings.Contains(stderr, "no remote 'missing' configured") {
+		t.Errorf("expected missing remote message, got: %q", stderr)
+	}
+}
+
+func TestSync_E2E_MultipleFetchRemotes(t *testing.T) {
+	// Alice pushes to upstream; her branch is mirrored unchanged on mirror.
+	alice, upstreamDir := setupPushedRepo(t)
+	mirrorDir := newBareRemote(t, alice.RepoDir, "mirror")
+	if out, err := exec.Command("git", "-C", alice.RepoDir, "push", "--no-verify", "mirror", "rekal/test@rekal.dev").CombinedOutput(); err != nil {
+		t.Fatalf("push mirror: %v: %s", err, out)
+	}
+
+	// Bob's branch is only on mirror. It repeats Alice's transcript, which
+	// is indexed once, and adds one of its own.
+	bob, _ := setupPushedRepo(t)
+	addPushedSession(t, bob, 2)
+	if out, err := exec.Command("git", "-C", bob.RepoDir, "push", "--no-verify", mirrorDir, "rekal/test@rekal.dev:rekal/bob@rekal.dev").CombinedOutput(); err != nil {
+		t.Fatalf("push bob: %v: %s", err, out)
+	}
+
+	carol := NewTestEnv(t)
+	gitConfig(t, carol.RepoDir, "user.email", "carol@rekal.dev")
+	carol.Init()
+	for name, dir := range map[string]string{"upstream": upstreamDir, "mirror": mirrorDir} {
+		if err := exec.Command("git", "-C", carol.RepoDir, "remote", "add", name, dir).Run(); err != nil {
+			t.Fatalf("git remote add %s: %v", name, err)
+		}
+	}
+	gitConfig(t, carol.RepoDir, "--add", "rekal.fetchRemote", "upstream")
+	gitConfig(t, carol.RepoDir, "--add", "rekal.fetchRemote", "mirror")
+
+	_, stderr, err := carol.RunCLI("sync")
+	if err != nil {
+		t.Fatalf("sync: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "importing upstream/rekal/test@rekal.dev") {
+		t.Errorf("expected alice's branch from upstream, got: %q", stderr)
+	}
+	if strings.Contains(stderr, "importing mirror/rekal/test@rekal.dev") {
+		t.Errorf("mirrored branch should be imported once, got: %q", stderr)
+	}
+	if !strings.Contains(stderr, "importing mirror/rekal/bob@rekal.dev") {
+		t.Errorf("expected bob's branch from mirror, got: %q", stderr)
+	}
+	if !strings.Contains(stderr, "2 remote sessions from 2 team member(s)") {
+		t.Errorf("expected sessions merged across remotes, got: %q", stderr)
+	}
+
+	// --remote limits the fetch to one remote.
+	_, stderr, err = carol.RunCLI("sync", "--remote", "upstream")
+	if err != nil {
+		t.Fatalf("sync --remote: %v (stderr: %s)", err, stderr)
+	}
+	if strings.Contains(stderr, "importing mirror/") {
+		t.Errorf("sync --remote upstream should not import mirror, got: %q", stderr)
+	}
+}
+
+func TestSyncSelf_E2E_ConfiguredRemote(t *testing.T) {
+	_, bareDir := setupPushedRepo(t)
+
+	other := NewTestEnv(t)
+	other.Init()
+	if err := exec.Command("git", "-C", other.RepoDir, "remote", "add", "upstream", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+
+	if _, _, err := other.RunCLI("sync", "--self"); err == nil {
+		t.Fatal("sync --self without origin should fail")
+	}
+
+	gitConfig(t, other.RepoDir, "rekal.remote", "upstream")
+	_, stderr, err := other.RunCLI("sync", "--self")
+	if err != nil {
+		t.Fatalf("sync --self: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "imported 1 session(s) from upstream/rekal/test@rekal.dev") {
+		t.Errorf("expected import from upstream, got: %q", stderr)
+	}
+}
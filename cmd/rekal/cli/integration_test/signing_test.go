@@ -0,0 +1,107 @@
+//go:build integration
+
+package integration
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// configureSSHSigning generates an ed25519 key, enables commit signing with
+// it in dir and returns the allowed_signers line for test@rekal.dev.
+func configureSSHSigning(t *testing.T, dir string) string {
+	t.Helper()
+	keyDir := t.TempDir()
+	key := filepath.Join(keyDir, "id_ed25519")
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", "test@rekal.dev", "-f", key).CombinedOutput(); err != nil {
+		t.Skipf("ssh-keygen unavailable: %v: %s", err, out)
+	}
+	for _, kv := range [][2]string{
+		{"gpg.format", "ssh"},
+		{"user.signingkey", key + ".pub"},
+		{"commit.gpgsign", "true"},
+	} {
+		if err := exec.Command("git", "-C", dir, "config", kv[0], kv[1]).Run(); err != nil {
+			t.Fatalf("git config %s: %v", kv[0], err)
+		}
+	}
+	pub, err := os.ReadFile(key + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "test@rekal.dev " + strings.TrimSpace(string(pub)) + "\n"
+}
+
+func TestSigning_E2E_SSH(t *testing.T) {
+	env := NewTestEnv(t)
+	env.Init()
+	allowed := configureSSHSigning(t, env.RepoDir)
+	if err := os.WriteFile(filepath.Join(env.RepoDir, ".rekal-allowed-signers"), []byte(allowed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "add allowed signers")
+
+	cleanup := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	defer cleanup()
+	gitCommit(t, env.RepoDir, "fix auth bug")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+
+	bareDir := t.TempDir()
+	bareDir, _ = filepath.EvalSymlinks(bareDir)
+	if err := exec.Command("git", "init", "--bare", bareDir).Run(); err != nil {
+		t.Fatalf("git init --bare: %v", err)
+	}
+	if err := exec.Command("git", "-C", env.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+	currentBranch, _ := exec.Command("git", "-C", env.RepoDir, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err := exec.Command("git", "-C", env.RepoDir, "push", "--no-verify", "origin", strings.TrimSpace(string(currentBranch))).Run(); err != nil {
+		t.Fatalf("git push: %v", err)
+	}
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
+
+	// The export commit is signed and verifies against the allowed signers.
+	stdout, stderr, err := env.RunCLI("verify", "rekal/test@rekal.dev")
+	if err != nil {
+		t.Fatalf("verify: %v (stdout: %s, stderr: %s)", err, stdout, stderr)
+	}
+	if !strings.Contains(stdout, "1 of 2 commit(s) signed by test@rekal.dev") {
+		t.Errorf("verify should count the signed export commit, got: %q", stdout)
+	}
+
+	// A key missing from allowed_signers does not verify.
+	emptySigners := filepath.Join(t.TempDir(), "allowed_signers")
+	if err := os.WriteFile(emptySigners, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	exec.Command("git", "-C", env.RepoDir, "config", "rekal.allowedSigners", emptySigners).Run()
+	stdout, _, _ = env.RunCLI("verify", "rekal/test@rekal.dev")
+	if !strings.Contains(stdout, "0 of 2 commit(s) signed by test@rekal.dev") {
+		t.Errorf("unknown key should not verify, got: %q", stdout)
+	}
+
+	// A clone importing the branch marks the session verified.
+	cloneDir := t.TempDir()
+	cloneDir, _ = filepath.EvalSymlinks(cloneDir)
+	if err := exec.Command("git", "clone", bareDir, cloneDir).Run(); err != nil {
+		t.Fatalf("git clone: %v", err)
+	}
+	for _, kv := range [][2]string{
+		{"user.email", "test@rekal.dev"},
+		{"user.name", "Test User"},
+	} {
+		exec.Command("git", "-C", cloneDir, "config", kv[0], kv[1]).Run()
+	}
+	env2 := NewTestEnvAt(t, cloneDir)
+	if _, stderr, err := env2.RunCLI("init"); err != nil {
+		t.Fatalf("init (clone): %v (stderr: %s)", err, stderr)
+	}
+	assertQueryContains(t, env2, "SELECT count(*) AS n FROM sessions WHERE verified", `"n":1`)
+}
@@ -3,6 +3,10 @@
 package integration
 
 import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -49,3 +53,247 @@ func TestSync_Team_RebuildIndex(t *testing.T) {
 		t.Errorf("expected index rebuild message, got: %q", stderr)
 	}
 }
+
+func TestSync_E2E_Incremental(t *testing.T) {
+	alice, bareDir := setupPushedRepo(t)
+
+	carol := NewTestEnv(t)
+	gitConfig(t, carol.RepoDir, "user.email", "carol@rekal.dev")
+	carol.Init()
+	if err := exec.Command("git", "-C", carol.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+	sync := func(args ...string) string {
+		t.Helper()
+		_, stderr, err := carol.RunCLI(append([]string{"sync"}, args...)...)
+		if err != nil {
+			t.Fatalf("sync: %v (stderr: %s)", err, stderr)
+		}
+		return stderr
+	}
+
+	// The first team sync builds the index.
+	if stderr := sync(); !strings.Contains(stderr, "indexing local data") || !strings.Contains(stderr, "1 remote sessions from 1 team member(s)") {
+		t.Errorf("first sync: %q", stderr)
+	}
+
+	// Nothing new: no branch is decoded again.
+	stderr := sync()
+	if !strings.Contains(stderr, "updating index with new remote frames") || strings.Contains(stderr, "importing") {
+		t.Errorf("second sync: %q", stderr)
+	}
+
+	// Only alice's new session is imported.
+	addPushedSession(t, alice, 2)
+	stderr = sync()
+	for _, want := range []string{"importing origin/rekal/test@rekal.dev", "1 new remote sessions from 1 team member(s)"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("expected %q, got: %q", want, stderr)
+		}
+	}
+
+	// The new session has an LSA vector, and the import history continued
+	// from the watermark matches the one a full import computes.
+	assertIndexQueryContains(t, carol, "SELECT count(*) AS n FROM session_embeddings WHERE model = 'lsa-v1'", `"n":2`)
+	heads := func() string {
+		t.Helper()
+		stdout, _, err := carol.RunCLI("query", "--index", "SELECT n_frames, chain_head FROM imported_heads")
+		if err != nil {
+			t.Fatalf("query imported_heads: %v", err)
+		}
+		return stdout
+	}
+	incremental := heads()
+	if !strings.Contains(incremental, "chain_head") {
+		t.Fatalf("no import history recorded: %q", incremental)
+	}
+
+	// --full starts over.
+	if stderr := sync("--full"); !strings.Contains(stderr, "indexing local data") || !strings.Contains(stderr, "2 remote sessions from 1 team member(s)") {
+		t.Errorf("sync --full: %q", stderr)
+	}
+	if full := heads(); full != incremental {
+		t.Errorf("imported_heads after incremental sync = %s, after --full = %s", incremental, full)
+	}
+
+	// A rebuild by 'rekal index' drops the remote sessions, so the next
+	// sync rebuilds too.
+	if _, stderr, err := carol.RunCLI("index"); err != nil {
+		t.Fatalf("index: %v (stderr: %s)", err, stderr)
+	}
+	if stderr := sync(); !strings.Contains(stderr, "2 remote sessions from 1 team member(s)") || strings.Contains(stderr, "new remote") {
+		t.Errorf("sync after index: %q", stderr)
+	}
+}
+
+func TestSync_E2E_Scope(t *testing.T) {
+	_, bareDir := setupPushedRepo(t)
+
+	carol := NewTestEnv(t)
+	gitConfig(t, carol.RepoDir, "user.email", "carol@rekal.dev")
+	carol.Init()
+	if err := exec.Command("git", "-C", carol.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+
+	// --list asks the remote, so it shows branches not fetched yet.
+	stdout, stderr, err := carol.RunCLI("sync", "--list", "--author", "bob@*")
+	if err != nil {
+		t.Fatalf("sync --list: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stdout, "test@rekal.dev") || !strings.Contains(stdout, "not fetched") || !strings.HasSuffix(strings.TrimSpace(stdout), "no") {
+		t.Errorf("sync --list: %q", stdout)
+	}
+
+	// Out of scope: the branch is fetched but nothing is imported.
+	_, stderr, err = carol.RunCLI("sync", "--author", "bob@*")
+	if err != nil {
+		t.Fatalf("sync --author: %v (stderr: %s)", err, stderr)
+	}
+	if strings.Contains(stderr, "importing") || strings.Contains(stderr, "remote sessions") {
+		t.Errorf("sync --author bob@*: %q", stderr)
+	}
+
+	// A wider scope rebuilds to pick up what the narrow one skipped.
+	_, stderr, err = carol.RunCLI("sync", "--author", "test@*")
+	if err != nil {
+		t.Fatalf("sync --author: %v (stderr: %s)", err, stderr)
+	}
+	for _, want := range []string{"the sync scope changed", "1 remote sessions from 1 team member(s)"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("expected %q, got: %q", want, stderr)
+		}
+	}
+
+	// A window that excludes the session also changes the scope.
+	_, stderr, err = carol.RunCLI("sync", "--since", "2999-01-01")
+	if err != nil {
+		t.Fatalf("sync --since: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "the sync scope changed") || strings.Contains(stderr, "remote sessions") {
+		t.Errorf("sync --since: %q", stderr)
+	}
+
+	if _, _, err := carol.RunCLI("sync", "--self", "--author", "test@*"); err == nil {
+		t.Error("sync --self --author should fail")
+	}
+}
+
+func TestSync_E2E_FromBundle(t *testing.T) {
+	alice, _ := setupPushedRepo(t)
+	bundle := filepath.Join(t.TempDir(), "alice.bundle")
+	if _, stderr, err := alice.RunCLI("bundle", "create", bundle); err != nil {
+		t.Fatalf("bundle create: %v (stderr: %s)", err, stderr)
+	}
+
+	// A teammate with no remote imports alice's data into the index.
+	carol := NewTestEnv(t)
+	gitConfig(t, carol.RepoDir, "user.email", "carol@rekal.dev")
+	carol.Init()
+	_, stderr, err := carol.RunCLI("sync", "--from-bundle", bundle)
+	if err != nil {
+		t.Fatalf("sync --from-bundle: %v (stderr: %s)", err, stderr)
+	}
+	for _, want := range []string{"importing bundle/rekal/test@rekal.dev", "1 remote sessions from 1 team member(s)"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("expected %q, got: %q", want, stderr)
+		}
+	}
+
+	// The bundled data stays in the index across later syncs.
+	_, stderr, err = carol.RunCLI("sync")
+	if err != nil {
+		t.Fatalf("sync: %v (stderr: %s)", err, stderr)
+	}
+	if strings.Contains(stderr, "rebuilding the index") || !strings.Contains(stderr, "updating index with new remote frames") {
+		t.Errorf("sync after bundle: %q", stderr)
+	}
+
+	// Alice's other machine imports her own data into data.db.
+	laptop := NewTestEnv(t)
+	laptop.Init()
+	if _, stderr, err := laptop.RunCLI("sync", "--from-bundle", bundle); err != nil || !strings.Contains(stderr, "imported 1 session(s) from bundle/rekal/test@rekal.dev") {
+		t.Errorf("sync --from-bundle on the owner's machine: %v (stderr: %q)", err, stderr)
+	}
+
+	// A corrupt bundle is rejected before anything is imported.
+	if err := os.WriteFile(bundle, []byte("# v2 git bundle\nnot a bundle\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := carol.RunCLI("sync", "--from-bundle", bundle); err == nil {
+		t.Error("sync --from-bundle with a corrupt bundle should fail")
+	}
+}
+
+func TestSync_E2E_Mailmap(t *testing.T) {
+	alice, bareDir := setupPushedRepo(t)
+
+	carol := NewTestEnv(t)
+	gitConfig(t, carol.RepoDir, "user.email", "carol@rekal.dev")
+	carol.Init()
+	if err := exec.Command("git", "-C", carol.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+	if _, stderr, err := carol.RunCLI("sync"); err != nil {
+		t.Fatalf("sync: %v (stderr: %s)", err, stderr)
+	}
+
+	// Alice's branch is named after an alias; the index takes her
+	// canonical email once the mailmap says so.
+	mailmap := "Alice <alice@rekal.dev> <test@rekal.dev>\n"
+	if err := os.WriteFile(filepath.Join(carol.RepoDir, ".rekal-mailmap"), []byte(mailmap), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, err := carol.RunCLI("sync")
+	if err != nil {
+		t.Fatalf("sync: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "the mailmap changed") {
+		t.Errorf("expected a rebuild for the new mailmap, got: %q", stderr)
+	}
+
+	for _, author := range []string{"alice@rekal.dev", "test@rekal.dev"} {
+		stdout, stderr, err := carol.RunCLI("--author", author)
+		if err != nil {
+			t.Fatalf("recall --author %s: %v (stderr: %s)", author, err, stderr)
+		}
+		var output struct {
+			Results []struct {
+				Session struct {
+					Author string `json:"author"`
+				} `json:"session"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal([]byte(stdout), &output); err != nil {
+			t.Fatalf("expected valid JSON: %v\nstdout: %s", err, stdout)
+		}
+		if len(output.Results) != 1 || output.Results[0].Session.Author != "alice@rekal.dev" {
+			t.Errorf("recall --author %s: %s", author, stdout)
+		}
+	}
+
+	// rekal log shows the canonical email; data.db keeps the captured one.
+	if err := os.WriteFile(filepath.Join(alice.RepoDir, ".mailmap"), []byte(mailmap), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdout, _, err := alice.RunCLI("log")
+	if err != nil || !strings.Contains(stdout, "Author:   alice@rekal.dev") {
+		t.Errorf("log: %v: %q", err, stdout)
+	}
+	stdout, _, err = alice.RunCLI("query", "SELECT DISTINCT user_email FROM sessions")
+	if err != nil || !strings.Contains(stdout, "test@rekal.dev") {
+		t.Errorf("data.db email: %v: %q", err, stdout)
+	}
+}
+
+// assertIndexQueryContains is assertQueryContains against the index DB.
+func assertIndexQueryContains(t *testing.T, env *TestEnv, sql, expected string) {
+	t.Helper()
+	stdout, _, err := env.RunCLI("query", "--index", sql)
+	if err != nil {
+		t.Fatalf("query --index %q: %v", sql, err)
+	}
+	if !strings.Contains(stdout, expected) {
+		t.Errorf("query --index %q: expected %q in output, got: %q", sql, expected, stdout)
+	}
+}
@@ -0,0 +1,161 @@
+//go:build integration
+
+package integration
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+)
+
+// setupPushedRepo initializes a repo with one checkpoint pushed to a bare
+// remote. Returns the env and the bare remote path.
+func setupPushedRepo(t *testing.T) (*TestEnv, string) {
+	t.Helper()
+	env := NewTestEnv(t)
+	env.Init()
+
+	if err := os.WriteFile(filepath.Join(env.RepoDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit(t, env.RepoDir, "initial")
+
+	cleanup := writeSessionFile(t, env.RepoDir, "session1.jsonl", testSessionJSONL)
+	t.Cleanup(cleanup)
+	gitCommit(t, env.RepoDir, "fix auth bug")
+	if _, stderr, err := env.RunCLI("checkpoint"); err != nil {
+		t.Fatalf("checkpoint: %v (stderr: %s)", err, stderr)
+	}
+
+	bareDir := t.TempDir()
+	bareDir, _ = filepath.EvalSymlinks(bareDir)
+	if err := exec.Command("git", "init", "--bare", bareDir).Run(); err != nil {
+		t.Fatalf("git init --bare: %v", err)
+	}
+	if err := exec.Command("git", "-C", env.RepoDir, "remote", "add", "origin", bareDir).Run(); err != nil {
+		t.Fatalf("git remote add: %v", err)
+	}
+	if _, stderr, err := env.RunCLI("push"); err != nil {
+		t.Fatalf("push: %v (stderr: %s)", err, stderr)
+	}
+	return env, bareDir
+}
+
+// tamperRekalBranch rewrites the current user's rekal branch as a legacy
+// single-file rekal.body in which one byte inside the first frame payload is
+// flipped, keeping dict.bin unchanged.
+func tamperRekalBranch(t *testing.T, dir, branch string) {
+	t.Helper()
+	body := gitShowBody(t, dir, branch)
+	frames, err := codec.ScanFrames(body)
+	if err != nil || len(frames) == 0 {
+		t.Fatalf("scan frames: %v (%d frames)", err, len(frames))
+	}
+	body[frames[0].PayloadOffset+frames[0].CompressedLen-1] ^= 0xFF
+	writeLegacyBranch(t, dir, branch, body)
+}
+
+// writeLegacyBranch commits body as a single rekal.body, the layout used
+// before segmented bodies, on top of branch with its dict.bin unchanged.
+// Returns the blob hash of rekal.body.
+func writeLegacyBranch(t *testing.T, dir, branch string, body []byte) string {
+	t.Helper()
+	hashObject := func(data []byte) string {
+		cmd := exec.Command("git", "-C", dir, "hash-object", "-w", "--stdin")
+		cmd.Stdin = strings.NewReader(string(data))
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("hash-object: %v", err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	bodyHash := hashObject(body)
+	dictHash := hashObject(gitShow(dir, branch, "dict.bin"))
+
+	mktree := exec.Command("git", "-C", dir, "mktree")
+	mktree.Stdin = strings.NewReader("100644 blob " + dictHash + "\tdict.bin\n100644 blob " + bodyHash + "\trekal.body\n")
+	tree, err := mktree.Output()
+	if err != nil {
+		t.Fatalf("mktree: %v", err)
+	}
+	commit, err := exec.Command("git", "-C", dir, "commit-tree", strings.TrimSpace(string(tree)), "-m", "rewritten").Output()
+	if err != nil {
+		t.Fatalf("commit-tree: %v", err)
+	}
+	if err := exec.Command("git", "-C", dir, "update-ref", "refs/heads/"+branch, strings.TrimSpace(string(commit))).Run(); err != nil {
+		t.Fatalf("update-ref: %v", err)
+	}
+	return bodyHash
+}
+
+func TestVerify_E2E_IntactAndTampered(t *testing.T) {
+	env, _ := setupPushedRepo(t)
+	branch := "rekal/test@rekal.dev"
+
+	stdout, stderr, err := env.RunCLI("verify")
+	if err != nil {
+		t.Fatalf("verify: %v (stderr: %s)", err, stderr)
+	}
+	if !strings.Contains(stdout, branch+": ok") || !strings.Contains(stdout, "origin/"+branch+": ok") {
+		t.Errorf("verify should report both branches ok, got: %q", stdout)
+	}
+
+	tamperRekalBranch(t, env.RepoDir, branch)
+
+	stdout, _, err = env.RunCLI("verify", branch)
+	if err == nil {
+		t.Fatal("verify should fail on a tampered branch")
+	}
+	if !strings.Contains(stdout, "BROKEN") {
+		t.Errorf("verify should report the break, got: %q", stdout)
+	}
+	// The flipped byte fails the frame checksum, so the frame is skipped.
+	if !strings.Contains(stdout, "corrupt bytes") {
+		t.Errorf("verify should report the skipped range, got: %q", stdout)
+	}
+}
+
+func TestSyncSelf_E2E_WarnsOnRewrittenHistory(t *testing.T) {
+	env, bareDir := setupPushedRepo(t)
+	branch := "rekal/test@rekal.dev"
+
+	cloneDir := t.TempDir()
+	cloneDir, _ = filepath.EvalSymlinks(cloneDir)
+	if err := exec.Command("git", "clone", bareDir, cloneDir).Run(); err != nil {
+		t.Fatalf("git clone: %v", err)
+	}
+	for _, kv := range [][2]string{
+		{"user.email", "test@rekal.dev"},
+		{"user.name", "Test User"},
+	} {
+		exec.Command("git", "-C", cloneDir, "config", kv[0], kv[1]).Run()
+	}
+	env2 := NewTestEnvAt(t, cloneDir)
+	if _, stderr, err := env2.RunCLI("init"); err != nil {
+		t.Fatalf("init (clone): %v (stderr: %s)", err, stderr)
+	}
+
+	// First import records the branch head; nothing to warn about.
+	_, stderr, _ := env2.RunCLI("sync", "--self")
+	if strings.Contains(stderr, "rewritten") {
+		t.Fatalf("first sync should not warn, got: %q", stderr)
+	}
+
+	// Rewrite the history and force-push it.
+	tamperRekalBranch(t, env.RepoDir, branch)
+	if out, err := exec.Command("git", "-C", env.RepoDir, "push", "--no-verify", "--force", "origin", branch).CombinedOutput(); err != nil {
+		t.Fatalf("force push: %v: %s", err, out)
+	}
+
+	_, stderr, _ = env2.RunCLI("sync", "--self")
+	if !strings.Contains(stderr, "origin/"+branch+" was rewritten since the last import") {
+		t.Errorf("sync should warn about rewritten history, got: %q", stderr)
+	}
+	if !strings.Contains(stderr, "skipped 1 corrupt byte range(s)") {
+		t.Errorf("sync should warn about the corrupt frame, got: %q", stderr)
+	}
+}
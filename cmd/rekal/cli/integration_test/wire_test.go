@@ -0,0 +1,60 @@
+//go:build integration
+
+package integration
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWire_E2E_DumpStatsCheck(t *testing.T) {
+	t.Parallel()
+	env, _ := setupPushedRepo(t)
+	branch := "rekal/test@rekal.dev"
+
+	stdout, stderr, err := env.RunCLI("wire", "dump", branch)
+	if err != nil {
+		t.Fatalf("wire dump: %v (stderr: %s)", err, stderr)
+	}
+	kinds := map[string]int{}
+	types := map[string]int{}
+	sc := bufio.NewScanner(strings.NewReader(stdout))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var rec struct {
+			Kind string `json:"kind"`
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("dump line is not JSON: %q: %v", sc.Text(), err)
+		}
+		kinds[rec.Kind]++
+		types[rec.Type]++
+	}
+	if kinds["header"] != 1 || kinds["dict"] == 0 || kinds["skipped"] != 0 {
+		t.Errorf("dump kinds = %v", kinds)
+	}
+	if types["session"] == 0 || types["checkpoint"] != 1 || types["meta"] != 1 {
+		t.Errorf("dump frame types = %v", types)
+	}
+
+	stdout, stderr, err = env.RunCLI("wire", "stats", branch)
+	if err != nil {
+		t.Fatalf("wire stats: %v (stderr: %s)", err, stderr)
+	}
+	for _, want := range []string{"rekal.body", "dict.bin", "session", "checkpoint", "test@rekal.dev"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("wire stats output missing %q: %s", want, stdout)
+		}
+	}
+
+	stdout, stderr, err = env.RunCLI("wire", "check")
+	if err != nil {
+		t.Fatalf("wire check: %v (stdout: %s, stderr: %s)", err, stdout, stderr)
+	}
+	if !strings.Contains(stdout, branch+": ok") {
+		t.Errorf("wire check should report %s ok, got: %q", branch, stdout)
+	}
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/crypt"
+	"github.com/spf13/cobra"
+)
+
+func newKeygenCmd() *cobra.Command {
+	var add bool
+
+	cmd := &cobra.Command{
+		Use:   "keygen",
+		Short: "Create your encryption identity and print its recipient",
+		Long: `Create an X25519 identity for reading encrypted rekal branches, and print
+the recipient (public key) teammates encrypt for.
+
+The identity is written to rekal.identity, or rekal/identity in your config
+directory (~/.config/rekal/identity on Linux), readable only by you. If it
+already exists, keygen prints its recipient and changes nothing. Keys use
+age's format: an identity from age-keygen works too.
+
+With --add, the recipient is appended to .rekal-recipients at the repository
+root. Commit the file to turn encryption on, or to let a new teammate read
+what is pushed from then on; data already pushed stays encrypted for the old
+list until its author pushes again.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+
+			gitRoot := ""
+			if add {
+				var err error
+				if gitRoot, err = EnsureGitRoot(); err != nil {
+					fmt.Fprintln(cmd.ErrOrStderr(), err)
+					return NewSilentError(err)
+				}
+			}
+			if err := doKeygen(gitRoot, cmd.OutOrStdout(), cmd.ErrOrStderr(), add); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&add, "add", false, "Append the recipient to "+recipientsFile)
+	return cmd
+}
+
+// doKeygen makes sure the identity file exists and prints its recipient on
+// out. With add, it also appends the recipient to gitRoot's
+// .rekal-recipients.
+func doKeygen(gitRoot string, out, w io.Writer, add bool) error {
+	path := identityFile()
+	if path == "" {
+		return errors.New("rekal: no config directory for the identity file — set rekal.identity")
+	}
+	ids, err := loadIdentities()
+	if err != nil {
+		return fmt.Errorf("rekal: %w", err)
+	}
+	var id *crypt.Identity
+	if len(ids) > 0 {
+		id = ids[0]
+		fmt.Fprintf(w, "rekal: using the identity in %s\n", path)
+	} else {
+		if id, err = crypt.GenerateIdentity(); err != nil {
+			return fmt.Errorf("rekal: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return fmt.Errorf("rekal: %w", err)
+		}
+		content := fmt.Sprintf("# public key: %s\n%s\n", id.Recipient(), id)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			return fmt.Errorf("rekal: %w", err)
+		}
+		fmt.Fprintf(w, "rekal: wrote a new identity to %s — back it up; without it your encrypted data cannot be read\n", path)
+	}
+	rcpt := id.Recipient()
+	fmt.Fprintln(out, rcpt)
+
+	if !add {
+		return nil
+	}
+	rcpts, err := encryptionRecipients(gitRoot)
+	if err != nil {
+		return fmt.Errorf("rekal: %w", err)
+	}
+	for _, r := range rcpts {
+		if r.Equal(rcpt) {
+			fmt.Fprintf(w, "rekal: already in %s\n", recipientsFile)
+			return nil
+		}
+	}
+	line := rcpt.String()
+	if email := gitConfigValue("user.email"); email != "" {
+		line += " # " + email
+	}
+	file := filepath.Join(gitRoot, recipientsFile)
+	existing, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("rekal: %w", err)
+	}
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+		line = "\n" + line
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("rekal: %w", err)
+	}
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		f.Close()
+		return fmt.Errorf("rekal: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("rekal: %w", err)
+	}
+	fmt.Fprintf(w, "rekal: added to %s — commit it so teammates encrypt for you\n", recipientsFile)
+	return nil
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Rekal data lives in one ref per user. By default it is the branch
+// rekal/<email>; with
+//
+//	git config rekal.layout refs
+//
+// it is the custom ref refs/rekal/<email>, which branch pickers, PR UIs and
+// 'git branch -a' do not show. Custom refs are not fetched by git's default
+// refspec, so rekal fetches them with an explicit one, like refs/notes, and
+// keeps the fetched copies under refs/rekal-remotes/<remote>/<email>.
+//
+// The layout only decides where your own data is written. Team sync reads
+// both layouts, so teammates can migrate one at a time with
+// 'rekal migrate-refs'.
+
+const (
+	layoutConfigKey = "rekal.layout"
+
+	layoutBranches = "branches"
+	layoutRefs     = "refs"
+
+	branchRefPrefix       = "refs/heads/rekal/"
+	customRefPrefix       = "refs/rekal/"
+	customRemoteRefPrefix = "refs/rekal-remotes/"
+)
+
+// rekalLayout returns the configured layout of the user's own rekal data.
+func rekalLayout() string {
+	if strings.EqualFold(gitConfigValue(layoutConfigKey), layoutRefs) {
+		return layoutRefs
+	}
+	return layoutBranches
+}
+
+// rekalEmail returns the email that names the current user's rekal ref.
+func rekalEmail() string {
+	email := strings.TrimSpace(gitConfigValue("user.email"))
+	if email == "" {
+		email = "local"
+	}
+	return email
+}
+
+// layoutRef returns the full local ref of the current user's data in layout.
+func layoutRef(layout string) string {
+	if layout == layoutRefs {
+		return customRefPrefix + rekalEmail()
+	}
+	return branchRefPrefix + rekalEmail()
+}
+
+// rekalRef returns the full local ref of the current user's data, e.g.
+// refs/heads/rekal/alice@example.com or refs/rekal/alice@example.com.
+func rekalRef() string {
+	return layoutRef(rekalLayout())
+}
+
+// layoutRemoteRef returns the ref the current user's data fetched from
+// remote is kept under in layout: origin/rekal/<email> (a remote-tracking
+// branch) or refs/rekal-remotes/origin/<email>.
+func layoutRemoteRef(layout, remote string) string {
+	if layout == layoutRefs {
+		return customRemoteRefPrefix + remote + "/" + rekalEmail()
+	}
+	return remote + "/rekal/" + rekalEmail()
+}
+
+// remoteRekalRef returns the fetched copy of the current user's data on
+// remote in the configured layout.
+func remoteRekalRef(remote string) string {
+	return layoutRemoteRef(rekalLayout(), remote)
+}
+
+// layoutFetchedRef returns the full name of layoutRemoteRef(layout, remote).
+func layoutFetchedRef(layout, remote string) string {
+	if layout == layoutRefs {
+		return layoutRemoteRef(layout, remote)
+	}
+	return "refs/remotes/" + layoutRemoteRef(layout, remote)
+}
+
+// fetchOwnRekalRef fetches the current user's data in the configured layout
+// from remote, updating remoteRekalRef(remote).
+func fetchOwnRekalRef(gitRoot, remote string) error {
+	src, dst := rekalRef(), layoutFetchedRef(rekalLayout(), remote)
+	cmd := exec.Command("git", "-C", gitRoot, "fetch", remote, "+"+src+":"+dst)
+	cmd.Stdin = nil
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("fetch %s %s: %s", remote, src, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// rekalFetchRefspecs returns the refspecs that fetch every user's data in
+// both layouts from remote.
+func rekalFetchRefspecs(remote string) []string {
+	return []string{
+		branchRefPrefix + "*:refs/remotes/" + remote + "/rekal/*",
+		"+" + customRefPrefix + "*:" + customRemoteRefPrefix + remote + "/*",
+	}
+}
+
+// listRekalRefs returns the refs under the given prefixes. Branches are
+// listed by their short name (origin/rekal/alice@example.com); custom refs
+// by their full name, which never collides with a branch.
+func listRekalRefs(gitRoot string, prefixes ...string) []string {
+	out, err := exec.Command("git", append([]string{"-C", gitRoot, "for-each-ref",
+		"--format=%(refname)"}, prefixes...)...).Output()
+	if err != nil {
+		return nil
+	}
+	var refs []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "refs/heads/"):
+			refs = append(refs, strings.TrimPrefix(line, "refs/heads/"))
+		case strings.HasPrefix(line, "refs/remotes/"):
+			refs = append(refs, strings.TrimPrefix(line, "refs/remotes/"))
+		default:
+			refs = append(refs, line)
+		}
+	}
+	return refs
+}
+
+// refExists reports whether ref resolves to a commit.
+func refExists(gitRoot, ref string) bool {
+	return exec.Command("git", "-C", gitRoot, "rev-parse", "--verify", "--quiet", ref+"^{commit}").Run() == nil
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// defaultLockTimeout is how long an interactive command waits for another
+	// rekal process before giving up.
+	defaultLockTimeout = 30 * time.Second
+
+	// hookLockTimeout is how long a git hook waits before deferring its work
+	// to the background — a commit or push must never stall on rekal.
+	hookLockTimeout = 2 * time.Second
+
+	// deferredLockTimeout is how long a deferred hook run waits in the background.
+	deferredLockTimeout = 10 * time.Minute
+)
+
+// deferredLogName is the file under .rekal/ that receives deferred hook output.
+const deferredLogName = "deferred.log"
+
+// lockRekal takes the .rekal lock for cmd, waiting up to timeout and telling
+// the user who holds it while waiting.
+func lockRekal(cmd *cobra.Command, gitRoot string, timeout time.Duration) (*db.Lock, error) {
+	return db.AcquireLock(gitRoot, cmd.CommandPath(), timeout, func(h db.LockHolder) {
+		fmt.Fprintf(cmd.ErrOrStderr(), "rekal: waiting for %s to release .rekal...\n", h)
+	})
+}
+
+// runLocked runs fn under the .rekal lock with the default timeout.
+func runLocked(cmd *cobra.Command, gitRoot string, fn func() error) error {
+	lock, err := lockRekal(cmd, gitRoot, defaultLockTimeout)
+	if err != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err)
+		return NewSilentError(err)
+	}
+	defer lock.Release() //nolint:errcheck
+	return fn()
+}
+
+// runSharedLocked runs fn under the .rekal lock taken shared, for a command
+// that only reads: it waits for writers but not for other readers.
+func runSharedLocked(cmd *cobra.Command, gitRoot string, fn func() error) error {
+	lock, err := db.AcquireSharedLock(gitRoot, cmd.CommandPath(), defaultLockTimeout, func(h db.LockHolder) {
+		fmt.Fprintf(cmd.ErrOrStderr(), "rekal: waiting for %s to release .rekal...\n", h)
+	})
+	if err != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err)
+		return NewSilentError(err)
+	}
+	defer lock.Release() //nolint:errcheck
+	return fn()
+}
+
+// runHookLocked runs fn under the .rekal lock on behalf of a git hook.
+// With hook set it waits briefly; if .rekal is still locked it re-runs the
+// same command line detached with --deferred and returns success so the git
+// operation proceeds. The deferred run waits up to deferredLockTimeout.
+// With neither flag it behaves like runLocked. Only the --hook flag, passed
+// by the hooks rekal installs, marks a hook run.
+func runHookLocked(cmd *cobra.Command, gitRoot string, hook, deferred bool, fn func() error) error {
+	timeout := defaultLockTimeout
+	switch {
+	case deferred:
+		timeout = deferredLockTimeout
+	case hook:
+		timeout = hookLockTimeout
+	}
+
+	lock, err := lockRekal(cmd, gitRoot, timeout)
+	if err != nil {
+		if hook && !deferred && db.IsLocked(err) {
+			if derr := spawnDeferred(gitRoot, os.Args[1:]); derr != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "rekal: warning: %v; %s skipped (%v)\n", err, cmd.Name(), derr)
+				return nil
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "rekal: %v; %s deferred to background (log: .rekal/%s)\n", err, cmd.Name(), deferredLogName)
+			return nil
+		}
+		fmt.Fprintln(cmd.ErrOrStderr(), err)
+		return NewSilentError(err)
+	}
+	defer lock.Release() //nolint:errcheck
+	return fn()
+}
+
+// spawnDeferred re-runs rekal with args, the hook's own arguments, plus
+// --deferred, detached from the hook, with output appended to
+// .rekal/deferred.log.
+func spawnDeferred(gitRoot string, args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate rekal binary: %w", err)
+	}
+	logFile, err := os.OpenFile(filepath.Join(RekalDir(gitRoot), deferredLogName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open deferred log: %w", err)
+	}
+	defer logFile.Close()
+
+	c := exec.Command(exe, append(append([]string(nil), args...), "--deferred")...)
+	c.Dir = gitRoot
+	c.Stdout = logFile
+	c.Stderr = logFile
+	setDetached(c)
+	if err := c.Start(); err != nil {
+		return fmt.Errorf("start deferred %s: %w", strings.Join(args, " "), err)
+	}
+	return c.Process.Release()
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package cli
+
+import "os/exec"
+
+// setDetached is a no-op on Windows.
+func setDetached(_ *exec.Cmd) {}
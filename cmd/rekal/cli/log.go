@@ -17,7 +17,10 @@ func newLogCmd() *cobra.Command {
 
 Each entry shows the checkpoint ID, timestamp, git commit SHA, branch,
 author email, and number of sessions captured. Use --limit to control
-how many entries are shown.`,
+how many entries are shown.
+
+Author emails are resolved through .mailmap and .rekal-mailmap, so a person
+who commits under several emails shows up under one.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			cmd.SilenceUsage = true
 
@@ -31,7 +34,9 @@ how many entries are shown.`,
 				return NewSilentError(err)
 			}
 
-			return runLog(cmd, gitRoot, limit)
+			return runLocked(cmd, gitRoot, func() error {
+				return runLog(cmd, gitRoot, limit)
+			})
 		},
 	}
 
@@ -46,6 +51,11 @@ func runLog(cmd *cobra.Command, gitRoot string, limit int) error {
 	}
 	defer dataDB.Close()
 
+	aliases, err := loadEmailAliases(gitRoot)
+	if err != nil {
+		return fmt.Errorf("read mailmap: %w", err)
+	}
+
 	rows, err := dataDB.Query(
 		`SELECT c.id, c.git_sha, c.git_branch, c.user_email, c.ts, c.actor_type,
 		        count(cs.session_id) as n_sessions
@@ -71,7 +81,7 @@ func runLog(cmd *cobra.Command, gitRoot string, limit int) error {
 		fmt.Fprintf(cmd.OutOrStdout(), "Date:     %s\n", ts)
 		fmt.Fprintf(cmd.OutOrStdout(), "Commit:   %s\n", gitSHA)
 		fmt.Fprintf(cmd.OutOrStdout(), "Branch:   %s\n", branch)
-		fmt.Fprintf(cmd.OutOrStdout(), "Author:   %s\n", email)
+		fmt.Fprintf(cmd.OutOrStdout(), "Author:   %s\n", aliases.resolve(email))
 		fmt.Fprintf(cmd.OutOrStdout(), "Sessions: %d\n", nSessions)
 		fmt.Fprintln(cmd.OutOrStdout())
 	}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+)
+
+// People commit under several emails, so one person can own several rekal
+// branches. Rekal resolves emails through the repository's .mailmap, the
+// file named by mailmap.file, and .rekal-mailmap — the same format, for
+// aliases only rekal should apply — with later files winning. Only the
+// index and what rekal prints use the canonical email: data.db and the wire
+// format keep the email each session was captured under.
+
+// aliasFile is the rekal-specific mailmap at the repository root.
+const aliasFile = ".rekal-mailmap"
+
+// emailAliasesKey is the index_state key holding the aliases the last team
+// sync applied.
+const emailAliasesKey = "email_aliases"
+
+// emailAliases maps lower-case alias emails to canonical emails.
+type emailAliases map[string]string
+
+// loadEmailAliases reads the mailmaps of gitRoot. Missing files are skipped.
+func loadEmailAliases(gitRoot string) (emailAliases, error) {
+	files := []string{filepath.Join(gitRoot, ".mailmap")}
+	if f := gitConfigValue("mailmap.file"); f != "" {
+		if rest, ok := strings.CutPrefix(f, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				f = filepath.Join(home, rest)
+			}
+		}
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(gitRoot, f)
+		}
+		files = append(files, f)
+	}
+	files = append(files, filepath.Join(gitRoot, aliasFile))
+
+	a := make(emailAliases)
+	for _, name := range files {
+		f, err := os.Open(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = a.parse(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// parse adds the email mappings of a mailmap to a:
+//
+//	Proper Name <proper@email> Commit Name <commit@email>
+//
+// Names are optional and ignored; lines with one email only map a name.
+func (a emailAliases) parse(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		var emails []string
+		for len(emails) < 2 {
+			open := strings.IndexByte(line, '<')
+			end := strings.IndexByte(line, '>')
+			if open < 0 || end < open {
+				break
+			}
+			emails = append(emails, strings.TrimSpace(line[open+1:end]))
+			line = line[end+1:]
+		}
+		if len(emails) == 2 && emails[0] != "" && emails[1] != "" {
+			a[strings.ToLower(emails[1])] = emails[0]
+		}
+	}
+	return sc.Err()
+}
+
+// resolve returns the canonical email of email, or email itself.
+func (a emailAliases) resolve(email string) string {
+	if c, ok := a[strings.ToLower(email)]; ok {
+		return c
+	}
+	return email
+}
+
+// String lists the aliases in a stable order, so team sync can tell when
+// they changed.
+func (a emailAliases) String() string {
+	pairs := make([]string, 0, len(a))
+	for alias, canonical := range a {
+		pairs = append(pairs, alias+"="+canonical)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
+
+// applyEmailAliases resolves user_email in the index through gitRoot's
+// mailmaps.
+func applyEmailAliases(gitRoot string, indexDB *sql.DB) error {
+	aliases, err := loadEmailAliases(gitRoot)
+	if err != nil {
+		return err
+	}
+	return db.ApplyEmailAliases(indexDB, aliases)
+}
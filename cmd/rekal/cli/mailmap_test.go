@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEmailAliases_Parse(t *testing.T) {
+	t.Parallel()
+	a := make(emailAliases)
+	err := a.parse(strings.NewReader(`# comment
+Alice <alice@example.com>
+<alice@example.com> <Alice@Old.example.com>
+Alice <alice@example.com> alice <alice@laptop.local>
+Bob Smith <bob@example.com> Bobby <bob@personal.example> # trailing comment
+<> <nobody@example.com>
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for in, want := range map[string]string{
+		"alice@old.example.com": "alice@example.com",
+		"ALICE@laptop.local":    "alice@example.com",
+		"bob@personal.example":  "bob@example.com",
+		"alice@example.com":     "alice@example.com",
+		"nobody@example.com":    "nobody@example.com",
+		"carol@example.com":     "carol@example.com",
+	} {
+		if got := a.resolve(in); got != want {
+			t.Errorf("resolve(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got, want := a.String(), "alice@laptop.local=alice@example.com alice@old.example.com=alice@example.com bob@personal.example=bob@example.com"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestLoadEmailAliases_AliasFileWins(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a, err := loadEmailAliases(dir); err != nil || len(a) != 0 {
+		t.Fatalf("no files: %v, %v", a, err)
+	}
+
+	write(".mailmap", "<a@example.com> <a@old.example.com>\n<b@example.com> <b@old.example.com>\n")
+	write(aliasFile, "<a@rekal.example> <a@old.example.com>\n")
+	a, err := loadEmailAliases(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := a.resolve("a@old.example.com"); got != "a@rekal.example" {
+		t.Errorf("a = %q, want the %s entry", got, aliasFile)
+	}
+	if got := a.resolve("b@old.example.com"); got != "b@example.com" {
+		t.Errorf("b = %q, want the .mailmap entry", got)
+	}
+}
@@ -0,0 +1,309 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+)
+
+// A rekal branch diverges when two machines of the same user push to it.
+// Instead of overwriting either side, push merges them: the remote body is
+// kept byte for byte, and the frames only the local branch has are appended
+// to it, re-interned into the remote dict.bin, followed by a meta frame that
+// seals the result. The merge commit has the remote head as its first parent
+// and the local head as its second, so the push fast-forwards and readers
+// of the remote see an append, never a rewrite.
+
+// branchMerge is the result of merging a diverged local rekal branch.
+type branchMerge struct {
+	Sessions    int // local session frames appended
+	Checkpoints int // local checkpoint frames appended
+	Duplicates  int // local frames the remote already had
+	Unreadable  int // local frames that could not be decoded
+}
+
+// mergeDivergedBranch merges the local rekal branch with remoteRef, which
+// must have diverged from it, and moves the local branch to the merge
+// commit. Local frames that cannot be decoded cannot be re-interned into
+// the remote dict.bin either; rather than drop them, the merge is aborted
+// and nothing is committed.
+func mergeDivergedBranch(gitRoot, remoteRef string) (*branchMerge, error) {
+	branch := rekalBranchName()
+
+	remoteSHA, err := gitRevParse(gitRoot, remoteRef)
+	if err != nil {
+		return nil, err
+	}
+	remoteBody, err := readBranchBody(gitRoot, remoteRef)
+	if err != nil {
+		return nil, err
+	}
+	if len(remoteBody) == 0 {
+		remoteBody = codec.NewBody()
+	}
+	dict := codec.NewDict()
+	data, err := readBranchDictData(gitRoot, remoteRef)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if dict, err = codec.LoadDict(data); err != nil {
+			return nil, fmt.Errorf("%s: load dict: %w", remoteRef, err)
+		}
+	}
+	remoteDec, err := newBranchDecoder(gitRoot, remoteRef)
+	if err != nil {
+		return nil, err
+	}
+	defer remoteDec.Close()
+
+	localBody, err := readBranchBody(gitRoot, branch)
+	if err != nil {
+		return nil, err
+	}
+	localDict := codec.NewDict()
+	if data, err = readBranchDictData(gitRoot, branch); err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if localDict, err = codec.LoadDict(data); err != nil {
+			return nil, fmt.Errorf("%s: load dict: %w", branch, err)
+		}
+	}
+	localDicts, err := readBranchDicts(gitRoot, branch)
+	if err != nil {
+		return nil, err
+	}
+	localDec, err := newDictDecoder(localDicts)
+	if err != nil {
+		return nil, err
+	}
+	defer localDec.Close()
+	localKeys, err := branchDataKeys(gitRoot, branch)
+	if err != nil {
+		return nil, err
+	}
+	localDec.AddKeys(localKeys)
+
+	// What the remote already has: sessions by session and content ID,
+	// mapped to the ID the remote holds them under, and checkpoints by ID.
+	sessions, checkpoints, err := branchIdentities(remoteDec, dict, remoteBody)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", remoteRef, err)
+	}
+
+	enc, err := newBranchEncoder(gitRoot, remoteRef, remoteBody)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Close()
+	// Local frames are re-encoded, so they only need the remote's keys.
+	seal, err := branchSeal(gitRoot, remoteRef)
+	if err != nil {
+		return nil, err
+	}
+	u := &wireUpdate{
+		Committed: len(remoteBody),
+		Message:   "rekal: merge " + remoteRef,
+		Base:      remoteSHA,
+	}
+	if err := seal.apply(enc, u); err != nil {
+		return nil, err
+	}
+
+	scan, err := codec.ScanBody(localBody)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", branch, err)
+	}
+	body := remoteBody
+	m := &branchMerge{}
+	for _, fs := range scan.Frames {
+		compressed := codec.ExtractFramePayload(localBody, fs)
+		switch fs.Type {
+		case codec.FrameSession:
+			sf, err := localDec.DecodeSessionFrame(compressed)
+			if err != nil {
+				m.Unreadable++
+				continue
+			}
+			ws, err := resolveSessionFrame(localDict, sf)
+			if err != nil {
+				m.Unreadable++
+				continue
+			}
+			cid := ws.ContentID()
+			if id, ok := sessions[ws.ID]; ok {
+				sessions[cid] = id
+				m.Duplicates++
+				continue
+			}
+			if id, ok := sessions[cid]; ok {
+				sessions[ws.ID] = id
+				m.Duplicates++
+				continue
+			}
+			sessions[ws.ID], sessions[cid] = ws.ID, ws.ID
+			body = codec.AppendFrame(body, enc.EncodeSessionFrame(encodeWireSession(dict, ws)))
+			m.Sessions++
+
+		case codec.FrameCheckpoint:
+			cf, err := localDec.DecodeCheckpointFrame(compressed)
+			if err != nil {
+				m.Unreadable++
+				continue
+			}
+			id, err := localDict.Get(codec.NSSessions, cf.CheckpointRef)
+			if err != nil {
+				m.Unreadable++
+				continue
+			}
+			if checkpoints[id] {
+				m.Duplicates++
+				continue
+			}
+			checkpoints[id] = true
+			body = codec.AppendFrame(body, enc.EncodeCheckpointFrame(reinternCheckpointFrame(localDict, dict, cf, sessions)))
+			m.Checkpoints++
+		}
+		// Local meta frames are replaced by the one sealing the merge.
+	}
+	if m.Unreadable > 0 {
+		return m, fmt.Errorf("%d local frame(s) on %s cannot be decoded and would be lost — see 'rekal verify %s'", m.Unreadable, branch, branch)
+	}
+
+	u.Body = appendMetaFrame(enc, body, dict, m.Checkpoints)
+	u.Dict = enc.Seal(dict.Encode())
+	// Dictionaries trained on this machine are kept even though the merged
+	// frames use the remote's.
+	remoteDicts, err := readBranchDicts(gitRoot, remoteRef)
+	if err != nil {
+		return nil, err
+	}
+	for id, d := range localDicts {
+		if _, ok := remoteDicts[id]; !ok {
+			if u.NewDicts == nil {
+				u.NewDicts = make(map[uint32][]byte)
+			}
+			u.NewDicts[id] = d
+		}
+	}
+	if _, err := commitWireFormat(gitRoot, u); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// branchIdentities returns the sessions and checkpoints in body. Sessions
+// are keyed by both session ID and content ID and map to the session ID.
+func branchIdentities(dec *codec.Decoder, dict *codec.Dict, body []byte) (map[string]string, map[string]bool, error) {
+	scan, err := codec.ScanBody(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	sessions := make(map[string]string)
+	checkpoints := make(map[string]bool)
+	for _, fs := range scan.Frames {
+		compressed := codec.ExtractFramePayload(body, fs)
+		switch fs.Type {
+		case codec.FrameSession:
+			sf, err := dec.DecodeSessionFrame(compressed)
+			if err != nil {
+				continue
+			}
+			ws, err := resolveSessionFrame(dict, sf)
+			if err != nil {
+				continue
+			}
+			sessions[ws.ID] = ws.ID
+			if cid := ws.ContentID(); sessions[cid] == "" {
+				sessions[cid] = ws.ID
+			}
+		case codec.FrameCheckpoint:
+			cf, err := dec.DecodeCheckpointFrame(compressed)
+			if err != nil {
+				continue
+			}
+			if id, err := dict.Get(codec.NSSessions, cf.CheckpointRef); err == nil {
+				checkpoints[id] = true
+			}
+		}
+	}
+	return sessions, checkpoints, nil
+}
+
+// reinternCheckpointFrame returns cf with its refs into from replaced by refs
+// into to. Session refs are renamed through sessions, so a checkpoint points
+// at the ID the merged branch holds its session under.
+func reinternCheckpointFrame(from, to *codec.Dict, cf *codec.CheckpointFrame, sessions map[string]string) *codec.CheckpointFrame {
+	ref := func(ns codec.Namespace, r uint64) uint64 {
+		s, _ := from.Get(ns, r)
+		return to.LookupOrAdd(ns, s)
+	}
+	out := *cf
+	out.CheckpointRef = ref(codec.NSSessions, cf.CheckpointRef)
+	out.BranchRef = ref(codec.NSBranches, cf.BranchRef)
+	out.EmailRef = ref(codec.NSEmails, cf.EmailRef)
+	if cf.ActorType == codec.ActorAgent {
+		out.AgentIDRef = ref(codec.NSEmails, cf.AgentIDRef)
+	}
+	out.SessionRefs = nil
+	for _, r := range cf.SessionRefs {
+		sid, err := from.Get(codec.NSSessions, r)
+		if err != nil {
+			continue
+		}
+		if id, ok := sessions[sid]; ok {
+			sid = id
+		}
+		out.SessionRefs = append(out.SessionRefs, to.LookupOrAdd(codec.NSSessions, sid))
+	}
+	out.Files = make([]codec.FileTouchedRecord, len(cf.Files))
+	for i, f := range cf.Files {
+		out.Files[i] = codec.FileTouchedRecord{PathRef: ref(codec.NSPaths, f.PathRef), ChangeType: f.ChangeType}
+	}
+	return &out
+}
+
+// isAncestor reports whether commit a is an ancestor of (or equal to) b.
+func isAncestor(gitRoot, a, b string) bool {
+	return exec.Command("git", "-C", gitRoot, "merge-base", "--is-ancestor", a, b).Run() == nil
+}
+
+// reconcileDivergedBranch is called when pushing branch to remote was
+// rejected as non-fast-forward. It fetches the remote branch and either
+// fast-forwards the local branch to it, if the remote already has every
+// local frame, or merges the two. Reports whether there is something left
+// to push.
+func reconcileDivergedBranch(gitRoot string, w io.Writer, remote, branch string) (bool, error) {
+	if err := fetchOwnRekalRef(gitRoot, remote); err != nil {
+		return false, err
+	}
+	remoteRef := remoteRekalRef(remote)
+	switch {
+	case isAncestor(gitRoot, remoteRef, branch):
+		return true, nil // the remote moved back; a plain push is enough
+	case isAncestor(gitRoot, branch, remoteRef):
+		sha, err := gitRevParse(gitRoot, remoteRef)
+		if err != nil {
+			return false, err
+		}
+		if err := exec.Command("git", "-C", gitRoot, "update-ref", rekalRef(), sha).Run(); err != nil {
+			return false, fmt.Errorf("update-ref: %w", err)
+		}
+		fmt.Fprintf(w, "rekal: %s is ahead of local — fast-forwarded %s\n", remoteRef, branch)
+		return false, nil
+	}
+
+	m, err := mergeDivergedBranch(gitRoot, remoteRef)
+	if err != nil {
+		return false, fmt.Errorf("merge %s: %w", remoteRef, err)
+	}
+	fmt.Fprintf(w, "rekal: %s diverged from local — merged %d session(s) and %d checkpoint(s) onto it", remoteRef, m.Sessions, m.Checkpoints)
+	if m.Duplicates > 0 {
+		fmt.Fprintf(w, " (%d frame(s) already there)", m.Duplicates)
+	}
+	fmt.Fprintln(w)
+	return true, nil
+}
@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/scrub"
+	"github.com/spf13/cobra"
+)
+
+func newMigrateCmd() *cobra.Command {
+	var status bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply pending schema migrations to the local databases",
+		Long: `Apply pending schema migrations to .rekal/data.db and .rekal/index.db.
+
+Each database records the migrations applied to it in a schema_version table.
+Migrations are numbered, forward-only and run in order, each in its own
+transaction. Checkpoint applies pending data DB migrations automatically; run
+this after upgrading rekal to migrate everything up front.
+
+A database written by a newer rekal is never opened — upgrade rekal instead.
+
+Use --status to list applied and pending migrations without changing anything.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+
+			gitRoot, err := EnsureGitRoot()
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			if err := EnsureInitDone(gitRoot); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+
+			return runLocked(cmd, gitRoot, func() error {
+				if err := doMigrate(gitRoot, cmd.OutOrStdout(), status); err != nil {
+					fmt.Fprintln(cmd.ErrOrStderr(), err)
+					return NewSilentError(err)
+				}
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().BoolVar(&status, "status", false, "Show applied and pending migrations without applying them")
+	return cmd
+}
+
+// dataMigrateContext describes the repository at gitRoot to data DB
+// migrations, which canonicalize paths stored by older versions against it.
+func dataMigrateContext(gitRoot string) db.MigrateContext {
+	return db.MigrateContext{
+		RepoRoots:       scrub.RepoRootSpellings(gitRoot),
+		OutOfRepoPrefix: scrub.OutOfRepoPrefix,
+	}
+}
+
+// migrateTarget pairs a DB opener with its migration functions.
+type migrateTarget struct {
+	open    func(gitRoot string) (*sql.DB, error)
+	migrate func(d *sql.DB, gitRoot string) (int, error)
+	status  func(*sql.DB) (db.SchemaStatus, error)
+}
+
+var migrateTargets = []migrateTarget{
+	{
+		db.OpenData,
+		func(d *sql.DB, gitRoot string) (int, error) { return db.MigrateData(d, dataMigrateContext(gitRoot)) },
+		db.DataSchemaStatus,
+	},
+	{
+		db.OpenIndex,
+		func(d *sql.DB, _ string) (int, error) { return db.MigrateIndex(d) },
+		db.IndexSchemaStatus,
+	},
+}
+
+// doMigrate migrates (or, with statusOnly, reports on) data.db and index.db.
+func doMigrate(gitRoot string, w io.Writer, statusOnly bool) error {
+	for _, t := range migrateTargets {
+		d, err := t.open(gitRoot)
+		if err != nil {
+			return err
+		}
+		err = migrateOne(d, gitRoot, t, w, statusOnly)
+		d.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateOne(d *sql.DB, gitRoot string, t migrateTarget, w io.Writer, statusOnly bool) error {
+	if !statusOnly {
+		n, err := t.migrate(d, gitRoot)
+		if err != nil {
+			return err
+		}
+		st, err := t.status(d)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			fmt.Fprintf(w, "%s: up to date (v%d)\n", st.DB, st.Current)
+		} else {
+			fmt.Fprintf(w, "%s: applied %d migration(s), now v%d\n", st.DB, n, st.Current)
+		}
+		return nil
+	}
+
+	st, err := t.status(d)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s: v%d (latest v%d, %d pending)\n", st.DB, st.Current, st.Latest, st.Pending())
+	for _, m := range st.Migrations {
+		state := "pending"
+		switch {
+		case m.Applied && !m.AppliedAt.IsZero():
+			state = "applied " + m.AppliedAt.UTC().Format(time.RFC3339)
+		case m.Applied:
+			state = "applied (pre-versioning)"
+		}
+		fmt.Fprintf(w, "  %3d  %-24s %s\n", m.Version, m.Name, state)
+	}
+	return nil
+}
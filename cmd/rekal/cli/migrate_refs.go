@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newMigrateRefsCmd() *cobra.Command {
+	var to, remote string
+	var deleteRemote bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate-refs",
+		Short: "Move your rekal data between the branch and custom ref layouts",
+		Long: `Move your rekal data from the branch rekal/<email> to the custom ref
+refs/rekal/<email>, or back with --to branches.
+
+Custom refs hold the same commits as the branch but are hidden from branch
+pickers, PR UIs and 'git branch -a'. rekal fetches them with an explicit
+refspec, like refs/notes, and keeps fetched copies under
+refs/rekal-remotes/<remote>/<email>.
+
+migrate-refs renames the local ref, sets rekal.layout in the repository's git
+config and pushes the new ref. The old ref on the remote is left in place, so
+teammates on an older rekal keep seeing your data; pass --delete-remote to
+remove it once it is no longer needed. Team sync reads both layouts, so
+teammates can migrate one at a time.
+
+Run it on each machine you use: rekal.layout is per-clone configuration.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+
+			if to != layoutRefs && to != layoutBranches {
+				err := fmt.Errorf("--to must be %q or %q", layoutRefs, layoutBranches)
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+
+			gitRoot, err := EnsureGitRoot()
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			if err := EnsureInitDone(gitRoot); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+
+			return runLocked(cmd, gitRoot, func() error {
+				if err := doMigrateRefs(gitRoot, cmd.ErrOrStderr(), to, pushRemote(remote), deleteRemote); err != nil {
+					fmt.Fprintln(cmd.ErrOrStderr(), err)
+					return NewSilentError(err)
+				}
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&to, "to", layoutRefs, "Target layout: refs or branches")
+	cmd.Flags().StringVar(&remote, "remote", "", "Remote to push to (default: rekal.pushRemote, rekal.remote or origin)")
+	cmd.Flags().BoolVar(&deleteRemote, "delete-remote", false, "Delete the old ref from the remote after pushing the new one")
+	return cmd
+}
+
+// doMigrateRefs moves the current user's data to layout to, records it in
+// rekal.layout and pushes the new ref to remote.
+func doMigrateRefs(gitRoot string, w io.Writer, to, remote string, deleteRemote bool) error {
+	from := layoutBranches
+	if to == layoutBranches {
+		from = layoutRefs
+	}
+	oldRef, newRef := layoutRef(from), layoutRef(to)
+
+	oldSHA, oldErr := gitRevParse(gitRoot, oldRef)
+	newSHA, newErr := gitRevParse(gitRoot, newRef)
+	hasOld, hasNew := oldErr == nil, newErr == nil
+	if hasOld && hasNew && oldSHA != newSHA {
+		return fmt.Errorf("both %s and %s exist and differ — delete the stale one with 'git update-ref -d' and rerun", oldRef, newRef)
+	}
+
+	if hasOld && !hasNew {
+		// The empty old value makes update-ref fail if newRef appeared meanwhile.
+		if out, err := exec.Command("git", "-C", gitRoot, "update-ref", newRef, oldSHA, "").CombinedOutput(); err != nil {
+			return fmt.Errorf("create %s: %s", newRef, strings.TrimSpace(string(out)))
+		}
+	}
+	if out, err := exec.Command("git", "-C", gitRoot, "config", layoutConfigKey, to).CombinedOutput(); err != nil {
+		return fmt.Errorf("set %s: %s", layoutConfigKey, strings.TrimSpace(string(out)))
+	}
+	if hasOld {
+		if out, err := exec.Command("git", "-C", gitRoot, "update-ref", "-d", oldRef, oldSHA).CombinedOutput(); err != nil {
+			return fmt.Errorf("delete %s: %s", oldRef, strings.TrimSpace(string(out)))
+		}
+		fmt.Fprintf(w, "rekal: moved %s to %s\n", oldRef, newRef)
+	} else {
+		fmt.Fprintf(w, "rekal: rekal data is now stored under %s\n", newRef)
+	}
+
+	if !remoteExists(gitRoot, remote) {
+		fmt.Fprintf(w, "rekal: no remote '%s' configured — skipping push\n", remote)
+		return nil
+	}
+	if err := doPush(gitRoot, w, remote, false); err != nil {
+		return err
+	}
+	if !deleteRemote {
+		return nil
+	}
+
+	// Only drop the old ref once the remote holds everything under the new one.
+	local, err := gitRevParse(gitRoot, newRef)
+	if err != nil {
+		return nil // nothing was ever written
+	}
+	if pushed, err := gitRevParse(gitRoot, layoutFetchedRef(to, remote)); err != nil || pushed != local {
+		return fmt.Errorf("%s was not pushed to %s — not deleting %s there", newRef, remote, oldRef)
+	}
+	pushCmd := exec.Command("git", "-C", gitRoot, "push", "--no-verify", remote, "--delete", oldRef)
+	pushCmd.Stdin = nil
+	if out, err := pushCmd.CombinedOutput(); err != nil {
+		fmt.Fprintf(w, "rekal: warning: delete %s on %s: %s\n", oldRef, remote, strings.TrimSpace(string(out)))
+		return nil
+	}
+	_ = exec.Command("git", "-C", gitRoot, "update-ref", "-d", layoutFetchedRef(from, remote)).Run()
+	fmt.Fprintf(w, "rekal: deleted %s on %s\n", oldRef, remote)
+	return nil
+}
@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"unicode/utf8"
+)
+
+// With
+//
+//	git config rekal.notes true
+//
+// every checkpoint also adds a git note to the commit it is anchored to,
+// under refs/notes/rekal:
+//
+//	2 session(s), 14 turn(s): Fix the login redirect loop
+//
+//	Rekal-Checkpoint: 01JB8Q3M5ZK6T4W2X9V7R1N0PC
+//	Rekal-Session: 3f9a…
+//	Rekal-Session: 8c21…
+//
+// so 'git log --notes=rekal' shows which sessions produced a commit without
+// rekal installed. push pushes the notes ref next to the rekal branch, and
+// sync fetches it into refs/notes/rekal-remotes/<remote> and merges it into
+// the local one. The notes ref is shared by the whole team, so a rejected
+// push is merged and retried, like a diverged rekal branch.
+//
+// Notes are never encrypted. While .rekal-recipients exists they hold the
+// trailers only: the summary quotes a prompt, which the encrypted branch
+// hides.
+
+const (
+	notesConfigKey    = "rekal.notes"
+	notesRef          = "refs/notes/rekal"
+	remoteNotesPrefix = "refs/notes/rekal-remotes/"
+
+	noteSummaryMax = 72
+)
+
+// notesEnabled reports whether checkpoints write git notes.
+func notesEnabled() bool {
+	return strings.EqualFold(gitConfigValue(notesConfigKey), "true")
+}
+
+// checkpointNote returns the note for a checkpoint: a one-line summary
+// followed by trailers naming the checkpoint and its sessions. An empty
+// summary leaves just the trailers.
+func checkpointNote(checkpointID string, sessionIDs []string, summary string) string {
+	var b strings.Builder
+	if summary != "" {
+		b.WriteString(summary)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("Rekal-Checkpoint: ")
+	b.WriteString(checkpointID)
+	b.WriteString("\n")
+	for _, sid := range sessionIDs {
+		b.WriteString("Rekal-Session: ")
+		b.WriteString(sid)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// checkpointSummary summarizes the captured sessions in one line: counts,
+// then the first line of the first human prompt.
+func checkpointSummary(captured []capturedSession) string {
+	turns := 0
+	prompt := ""
+	for _, cs := range captured {
+		turns += len(cs.payload.Turns)
+		for _, t := range cs.payload.Turns {
+			if prompt == "" && t.Role == "human" {
+				prompt = strings.TrimSpace(t.Content)
+			}
+		}
+	}
+	summary := fmt.Sprintf("%d session(s), %d turn(s)", len(captured), turns)
+	if line, _, _ := strings.Cut(prompt, "\n"); line != "" {
+		summary += ": " + truncateRunes(strings.TrimSpace(line), noteSummaryMax-len(summary)-2)
+	}
+	return summary
+}
+
+// noteSummary returns the summary for a checkpoint note on gitRoot: the
+// checkpointSummary of captured, or empty while encryption is on — or the
+// recipients file cannot be read — since notes are pushed in the clear.
+func noteSummary(gitRoot string, captured []capturedSession) string {
+	if rcpts, err := encryptionRecipients(gitRoot); err != nil || len(rcpts) > 0 {
+		return ""
+	}
+	return checkpointSummary(captured)
+}
+
+// truncateRunes shortens s to at most n runes, marking the cut with "…".
+func truncateRunes(s string, n int) string {
+	if n < 1 {
+		n = 1
+	}
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+// addCheckpointNote appends note to the notes of commit. A commit
+// checkpointed more than once gets one paragraph per checkpoint.
+func addCheckpointNote(gitRoot, commit, note string) error {
+	cmd := exec.Command("git", "-C", gitRoot, "notes", "--ref="+notesRef, "append", "-F", "-", commit)
+	cmd.Stdin = strings.NewReader(note)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git notes append: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// fetchRekalNotes fetches remote's notes ref into
+// refs/notes/rekal-remotes/<remote>. Reports false if the remote has none.
+func fetchRekalNotes(gitRoot, remote string) (bool, error) {
+	cmd := exec.Command("git", "-C", gitRoot, "fetch", remote, "+"+notesRef+":"+remoteNotesPrefix+remote)
+	cmd.Stdin = nil
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if strings.Contains(string(out), "couldn't find remote ref") {
+			return false, nil
+		}
+		return false, fmt.Errorf("fetch %s %s: %s", remote, notesRef, strings.TrimSpace(string(out)))
+	}
+	return true, nil
+}
+
+// mergeRekalNotes merges the notes ref fetched from remote into the local
+// one. Notes both sides added to the same commit are concatenated.
+func mergeRekalNotes(gitRoot, remote string) error {
+	fetched := remoteNotesPrefix + remote
+	sha, err := gitRevParse(gitRoot, fetched)
+	if err != nil {
+		return err
+	}
+	if !refExists(gitRoot, notesRef) {
+		if out, err := exec.Command("git", "-C", gitRoot, "update-ref", notesRef, sha).CombinedOutput(); err != nil {
+			return fmt.Errorf("update-ref %s: %s", notesRef, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	if isAncestor(gitRoot, fetched, notesRef) {
+		return nil
+	}
+	cmd := exec.Command("git", "-C", gitRoot, "notes", "--ref="+notesRef, "merge", "-q", "-s", "union", fetched)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git notes merge %s: %s", fetched, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// syncRekalNotes fetches and merges the notes ref of each remote.
+// Non-fatal: failures are reported on w.
+func syncRekalNotes(gitRoot string, w io.Writer, remotes []string) {
+	for _, remote := range remotes {
+		if !remoteExists(gitRoot, remote) {
+			continue
+		}
+		found, err := fetchRekalNotes(gitRoot, remote)
+		if err == nil && found {
+			err = mergeRekalNotes(gitRoot, remote)
+		}
+		if err != nil {
+			fmt.Fprintf(w, "rekal: warning: notes from %s: %v\n", remote, err)
+		}
+	}
+}
+
+// pushRekalNotes pushes the local notes ref to remote if notes are enabled
+// and the remote is behind. A rejected push is merged with the remote's
+// notes and retried once. Non-fatal: failures are reported on w.
+func pushRekalNotes(gitRoot string, w io.Writer, remote string) {
+	if !notesEnabled() || !refExists(gitRoot, notesRef) {
+		return
+	}
+	fetched := remoteNotesPrefix + remote
+	local, err := gitRevParse(gitRoot, notesRef)
+	if err != nil {
+		return
+	}
+	if sha, err := gitRevParse(gitRoot, fetched); err == nil && sha == local {
+		return
+	}
+
+	push := func() ([]byte, error) {
+		cmd := exec.Command("git", "-C", gitRoot, "push", "--no-verify", remote, notesRef)
+		cmd.Stdin = nil
+		return cmd.CombinedOutput()
+	}
+	out, err := push()
+	if err != nil && isNonFastForward(string(out)) {
+		if _, fetchErr := fetchRekalNotes(gitRoot, remote); fetchErr != nil {
+			fmt.Fprintf(w, "rekal: warning: notes push rejected and %v\n", fetchErr)
+			return
+		}
+		if mergeErr := mergeRekalNotes(gitRoot, remote); mergeErr != nil {
+			fmt.Fprintf(w, "rekal: warning: notes push rejected and %v\n", mergeErr)
+			return
+		}
+		out, err = push()
+	}
+	if err != nil {
+		fmt.Fprintf(w, "rekal: warning: notes push failed: %s\n", strings.TrimSpace(string(out)))
+		return
+	}
+	if local, err = gitRevParse(gitRoot, notesRef); err == nil {
+		_ = exec.Command("git", "-C", gitRoot, "update-ref", fetched, local).Run()
+	}
+	fmt.Fprintf(w, "rekal: pushed notes to %s\n", remote)
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/session"
+)
+
+func TestCheckpointNote(t *testing.T) {
+	t.Parallel()
+	got := checkpointNote("01CP", []string{"s1", "s2"}, "2 session(s), 3 turn(s): fix login")
+	want := "2 session(s), 3 turn(s): fix login\n\nRekal-Checkpoint: 01CP\nRekal-Session: s1\nRekal-Session: s2\n"
+	if got != want {
+		t.Errorf("checkpointNote = %q, want %q", got, want)
+	}
+
+	// Without a summary, as while encryption is on: the trailers only.
+	if got, want := checkpointNote("01CP", []string{"s1"}, ""), "Rekal-Checkpoint: 01CP\nRekal-Session: s1\n"; got != want {
+		t.Errorf("checkpointNote without summary = %q, want %q", got, want)
+	}
+}
+
+func TestCheckpointSummary(t *testing.T) {
+	t.Parallel()
+	captured := []capturedSession{
+		{payload: &session.SessionPayload{Turns: []session.Turn{
+			{Role: "assistant", Content: "ready"},
+			{Role: "human", Content: "  Fix the login redirect loop\nIt happens after logout."},
+		}}},
+		{payload: &session.SessionPayload{Turns: []session.Turn{
+			{Role: "human", Content: "second prompt"},
+		}}},
+	}
+	if got, want := checkpointSummary(captured), "2 session(s), 3 turn(s): Fix the login redirect loop"; got != want {
+		t.Errorf("checkpointSummary = %q, want %q", got, want)
+	}
+
+	long := []capturedSession{{payload: &session.SessionPayload{Turns: []session.Turn{
+		{Role: "human", Content: strings.Repeat("é", 200)},
+	}}}}
+	got := checkpointSummary(long)
+	if n := len([]rune(got)); n != noteSummaryMax {
+		t.Errorf("summary is %d runes, want %d: %q", n, noteSummaryMax, got)
+	}
+	if !strings.HasSuffix(got, "…") {
+		t.Errorf("truncated summary should end with …: %q", got)
+	}
+
+	if got, want := checkpointSummary([]capturedSession{{payload: &session.SessionPayload{}}}), "1 session(s), 0 turn(s)"; got != want {
+		t.Errorf("checkpointSummary = %q, want %q", got, want)
+	}
+}
@@ -10,7 +10,8 @@ import (
 )
 
 func newPushCmd() *cobra.Command {
-	var force bool
+	var force, hook, deferred bool
+	var remote string
 
 	cmd := &cobra.Command{
 		Use:   "push",
@@ -25,8 +26,17 @@ metadata anchored to git commits. They are encoded into a compact binary wire
 format (rekal.body + dict.bin) using zstd compression and string interning —
 a 2-10 MB session compresses to ~300 bytes on the wire.
 
-Use --force to overwrite the remote branch when it has diverged from local
-(e.g. after a rebuild or conflict).
+If the remote branch has diverged from local (another machine pushed
+first), push fetches it, appends the frames only the local branch has and
+pushes a merge commit — no frames are lost on either side. Use --force only
+to overwrite the remote branch with local data on purpose (e.g. after a
+rebuild).
+
+With rekal.notes enabled, the refs/notes/rekal notes ref is pushed too; a
+rejected notes push is merged with the remote's notes and retried.
+
+The branch is pushed to origin unless rekal.pushRemote or rekal.remote names
+another remote (git config rekal.pushRemote fork). --remote overrides both.
 
 Normally runs automatically via the pre-push git hook installed by 'rekal init'.
 You do not need to run this manually.`,
@@ -43,17 +53,24 @@ You do not need to run this manually.`,
 				return NewSilentError(err)
 			}
 
-			return doPush(gitRoot, cmd.ErrOrStderr(), force)
+			return runHookLocked(cmd, gitRoot, hook, deferred, func() error {
+				return doPush(gitRoot, cmd.ErrOrStderr(), pushRemote(remote), force)
+			})
 		},
 	}
 
 	cmd.Flags().BoolVarP(&force, "force", "f", false, "Force push (overwrite remote with local data)")
+	cmd.Flags().StringVar(&remote, "remote", "", "Remote to push to (default: rekal.pushRemote, rekal.remote or origin)")
+	cmd.Flags().BoolVar(&hook, "hook", false, "Invoked from a git hook: defer to the background if .rekal is locked")
+	cmd.Flags().BoolVar(&deferred, "deferred", false, "Background re-run of a deferred hook")
+	_ = cmd.Flags().MarkHidden("hook")
+	_ = cmd.Flags().MarkHidden("deferred")
 	return cmd
 }
 
-// doPush pushes Rekal data to the remote orphan branch.
+// doPush pushes Rekal data to the orphan branch on remote.
 // Extracted so sync can call it without a cobra.Command.
-func doPush(gitRoot string, w io.Writer, force bool) error {
+func doPush(gitRoot string, w io.Writer, remote string, force bool) error {
 	branch := rekalBranchName()
 
 	// Check if local branch exists — if not, nothing to push.
@@ -63,18 +80,19 @@ func doPush(gitRoot string, w io.Writer, force bool) error {
 	}
 
 	// Check if remote is configured.
-	if err := exec.Command("git", "-C", gitRoot, "remote", "get-url", "origin").Run(); err != nil {
-		fmt.Fprintln(w, "rekal: no remote 'origin' configured — skipping push")
+	if !remoteExists(gitRoot, remote) {
+		fmt.Fprintf(w, "rekal: no remote '%s' configured — skipping push\n", remote)
 		return nil
 	}
+	defer pushRekalNotes(gitRoot, w, remote)
 
 	// Export unexported checkpoints from DuckDB → wire format → orphan branch.
-	body, dict, err := exportNewFrames(gitRoot)
+	update, err := exportNewFrames(gitRoot)
 	if err != nil {
 		return fmt.Errorf("export: %w", err)
 	}
-	if body != nil {
-		if _, err := commitWireFormat(gitRoot, body, dict); err != nil {
+	if update != nil {
+		if _, err := commitWireFormat(gitRoot, update); err != nil {
 			return fmt.Errorf("commit to rekal branch: %w", err)
 		}
 	} else {
@@ -86,38 +104,46 @@ func doPush(gitRoot string, w io.Writer, force bool) error {
 	if err != nil {
 		return nil
 	}
-	remoteSHA, err := exec.Command("git", "-C", gitRoot, "rev-parse", "origin/"+branch).Output()
+	remoteSHA, err := exec.Command("git", "-C", gitRoot, "rev-parse", remoteRekalRef(remote)).Output()
 	if err == nil && strings.TrimSpace(string(localSHA)) == strings.TrimSpace(string(remoteSHA)) {
 		fmt.Fprintln(w, "rekal: already up to date")
 		return nil
 	}
 
 	if force {
-		forceCmd := exec.Command("git", "-C", gitRoot, "push", "--no-verify", "--force", "origin", branch)
-		forceCmd.Stdin = nil
-		if output, err := forceCmd.CombinedOutput(); err != nil {
+		if output, err := gitPushBranch(gitRoot, remote, branch, true); err != nil {
 			fmt.Fprintf(w, "rekal: force push failed: %s\n", strings.TrimSpace(string(output)))
 			return nil
 		}
-		fmt.Fprintf(w, "rekal: force pushed to origin/%s\n", branch)
+		fmt.Fprintf(w, "rekal: force pushed to %s/%s\n", remote, branch)
 		return nil
 	}
 
-	// Push with --no-verify to prevent recursive pre-push hook.
-	pushCmd := exec.Command("git", "-C", gitRoot, "push", "--no-verify", "origin", branch)
-	pushCmd.Stdin = nil // disconnect stdin so git doesn't hang in hook context
-	output, err := pushCmd.CombinedOutput()
+	// Push with --no-verify to prevent recursive pre-push hook. A branch
+	// that diverged (another machine pushed first) is merged with the
+	// remote and pushed again, so neither side loses frames.
+	output, err := gitPushBranch(gitRoot, remote, branch, false)
+	if err != nil && isNonFastForward(string(output)) {
+		pending, mergeErr := reconcileDivergedBranch(gitRoot, w, remote, branch)
+		if mergeErr != nil {
+			fmt.Fprintf(w, "rekal: push rejected (non-fast-forward) for %s/%s and merging failed: %v\n", remote, branch, mergeErr)
+			return nil
+		}
+		if !pending {
+			return nil
+		}
+		output, err = gitPushBranch(gitRoot, remote, branch, false)
+	}
 	if err != nil {
 		if isNonFastForward(string(output)) {
-			fmt.Fprintf(w, "rekal: push rejected (non-fast-forward) for origin/%s\n", branch)
-			fmt.Fprintln(w, "rekal: your remote branch has diverged from local — review and run 'rekal push --force' to overwrite remote with local data")
+			fmt.Fprintf(w, "rekal: push rejected (non-fast-forward) for %s/%s — the remote changed again, run 'rekal push' to retry\n", remote, branch)
 			return nil
 		}
 		fmt.Fprintf(w, "rekal: push failed: %s\n", strings.TrimSpace(string(output)))
 		return nil
 	}
 
-	fmt.Fprintf(w, "rekal: pushed to origin/%s\n", branch)
+	fmt.Fprintf(w, "rekal: pushed to %s/%s\n", remote, branch)
 	return nil
 }
 
@@ -127,3 +153,22 @@ func isNonFastForward(output string) bool {
 		strings.Contains(output, "[rejected]") ||
 		strings.Contains(output, "fetch first")
 }
+
+// gitPushBranch pushes branch to remote without running the pre-push hook.
+// git updates the remote-tracking branch itself; for a custom ref, which
+// has no tracking ref in git's eyes, the fetched copy is updated here.
+func gitPushBranch(gitRoot, remote, branch string, force bool) ([]byte, error) {
+	args := []string{"-C", gitRoot, "push", "--no-verify"}
+	if force {
+		args = append(args, "--force")
+	}
+	pushCmd := exec.Command("git", append(args, remote, branch)...)
+	pushCmd.Stdin = nil // disconnect stdin so git doesn't hang in hook context
+	out, err := pushCmd.CombinedOutput()
+	if err == nil && rekalLayout() == layoutRefs {
+		if sha, revErr := gitRevParse(gitRoot, branch); revErr == nil {
+			_ = exec.Command("git", "-C", gitRoot, "update-ref", remoteRekalRef(remote), sha).Run()
+		}
+	}
+	return out, err
+}
@@ -111,15 +111,16 @@ INDEX DB SCHEMA (.rekal/index.db):
 				return fmt.Errorf("--role must be \"human\" or \"assistant\"")
 			}
 
-			if sessionID != "" {
-				return runSessionDrilldown(cmd, gitRoot, sessionID, full, offset, limit, role)
-			}
-
-			if len(args) == 0 {
+			if sessionID == "" && len(args) == 0 {
 				return fmt.Errorf("provide a SQL query or use --session <id>")
 			}
 
-			return runQuery(cmd, gitRoot, args[0], useIndex)
+			return runLocked(cmd, gitRoot, func() error {
+				if sessionID != "" {
+					return runSessionDrilldown(cmd, gitRoot, sessionID, full, offset, limit, role)
+				}
+				return runQuery(cmd, gitRoot, args[0], useIndex)
+			})
 		},
 	}
 
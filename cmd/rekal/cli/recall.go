@@ -3,8 +3,11 @@ package cli
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 
@@ -36,6 +39,9 @@ type RecallFilters struct {
 	Author string // email
 	Actor  string // "human" | "agent"
 	Limit  int
+
+	Fusion  string // overrides rekal.fusion when set
+	Explain bool   // add per-signal scores and ranks to hybrid results
 }
 
 // searchResult is a single search result for JSON output.
@@ -46,6 +52,7 @@ type searchResult struct {
 	SnippetTurnIdx int           `json:"snippet_turn_index"`
 	SnippetRole    string        `json:"snippet_role"`
 	Session        sessionDetail `json:"session"`
+	Explain        *scoreExplain `json:"explain,omitempty"`
 }
 
 type sessionDetail struct {
@@ -57,6 +64,9 @@ type sessionDetail struct {
 	TurnCount  int      `json:"turn_count"`
 	ToolCalls  int      `json:"tool_call_count"`
 	Files      []string `json:"files"`
+	// Verified is false for a teammate's session whose rekal branch commit
+	// was not signed by them. Sessions captured locally are always verified.
+	Verified bool `json:"verified"`
 }
 
 type searchOutput struct {
@@ -65,6 +75,7 @@ type searchOutput struct {
 	Filters map[string]string `json:"filters"`
 	Mode    string            `json:"mode"`
 	Total   int               `json:"total"`
+	Ranking *rankingExplain   `json:"ranking,omitempty"`
 }
 
 // bm25Hit represents a BM25 match from the FTS index.
@@ -77,33 +88,62 @@ type bm25Hit struct {
 	score     float64
 }
 
+// errIndexNotBuilt is returned by queryRecall when the index DB is missing,
+// empty, or has index migrations pending.
+var errIndexNotBuilt = errors.New("index not built")
+
+// runRecall answers a recall query. Recall only reads, so it holds the .rekal
+// lock shared and opens the index read-only; a missing, empty or outdated
+// index is rebuilt first under the exclusive lock.
 func runRecall(cmd *cobra.Command, gitRoot string, filters RecallFilters) error {
-	indexDB, err := db.OpenIndex(gitRoot)
+	query := func() error { return queryRecall(cmd, gitRoot, filters) }
+	err := runSharedLocked(cmd, gitRoot, query)
+	if !errors.Is(err, errIndexNotBuilt) {
+		return err
+	}
+	fmt.Fprintln(cmd.ErrOrStderr(), "index not built, rebuilding...")
+	if err := runLocked(cmd, gitRoot, func() error { return runIndex(cmd, gitRoot) }); err != nil {
+		return err
+	}
+	return runSharedLocked(cmd, gitRoot, query)
+}
+
+func queryRecall(cmd *cobra.Command, gitRoot string, filters RecallFilters) error {
+	if _, err := os.Stat(filepath.Join(RekalDir(gitRoot), "index.db")); os.IsNotExist(err) {
+		return errIndexNotBuilt
+	}
+	indexDB, err := db.OpenIndexReadOnly(gitRoot)
 	if err != nil {
 		return fmt.Errorf("open index db: %w", err)
 	}
 	defer indexDB.Close()
 
+	// A read-only index cannot be migrated, and an older one lacks columns
+	// recall reads. Rebuilding also fills them from the data DB.
+	st, err := db.IndexSchemaStatus(indexDB)
+	if err != nil {
+		return fmt.Errorf("index schema: %w", err)
+	}
+	if st.Pending() > 0 {
+		return errIndexNotBuilt
+	}
+
 	// Load FTS extension.
 	if err := db.LoadFTSExtension(indexDB); err != nil {
 		return fmt.Errorf("load fts extension: %w", err)
 	}
-
-	// Auto-rebuild if index is empty.
 	if !db.IsIndexPopulated(indexDB) {
-		fmt.Fprintln(cmd.ErrOrStderr(), "index not built, rebuilding...")
-		indexDB.Close()
-		if err := runIndex(cmd, gitRoot); err != nil {
-			return err
-		}
-		indexDB, err = db.OpenIndex(gitRoot)
+		return errIndexNotBuilt
+	}
+
+	// The index holds canonical emails, so an alias finds its owner's
+	// sessions.
+	if filters.Author != "" {
+		aliases, err := loadEmailAliases(gitRoot)
 		if err != nil {
-			return fmt.Errorf("reopen index db: %w", err)
-		}
-		defer indexDB.Close()
-		if err := db.LoadFTSExtension(indexDB); err != nil {
-			return fmt.Errorf("reload fts extension: %w", err)
+			return fmt.Errorf("read mailmap: %w", err)
 		}
+		filters.Author = aliases.resolve(filters.Author)
 	}
 
 	limit := filters.Limit
@@ -112,11 +152,19 @@ func runRecall(cmd *cobra.Command, gitRoot string, filters RecallFilters) error
 	}
 
 	var results []searchResult
+	var ranking *rankingExplain
 	mode := "filter"
 
 	if filters.Query != "" {
 		mode = "hybrid"
-		results, err = hybridSearch(indexDB, filters, limit, gitRoot)
+		var cfg rankingConfig
+		if cfg, err = loadRankingConfig(filters.Fusion); err != nil {
+			return err
+		}
+		results, ranking, err = hybridSearch(indexDB, filters, cfg, limit, gitRoot)
+		if !filters.Explain {
+			ranking = nil
+		}
 	} else {
 		results, err = filterSearch(indexDB, filters, limit)
 	}
@@ -133,8 +181,9 @@ func runRecall(cmd *cobra.Command, gitRoot string, filters RecallFilters) error
 			"commit": filters.Commit,
 			"author": filters.Author,
 		},
-		Mode:  mode,
-		Total: len(results),
+		Mode:    mode,
+		Total:   len(results),
+		Ranking: ranking,
 	}
 
 	data, err := json.MarshalIndent(output, "", "  ")
@@ -145,11 +194,13 @@ func runRecall(cmd *cobra.Command, gitRoot string, filters RecallFilters) error
 	return nil
 }
 
-func hybridSearch(indexDB *sql.DB, filters RecallFilters, limit int, gitRoot string) ([]searchResult, error) {
+// hybridSearch ranks sessions by BM25, LSA and Nomic scores fused as
+// ranking says, and returns the results with a description of the fusion.
+func hybridSearch(indexDB *sql.DB, filters RecallFilters, ranking rankingConfig, limit int, gitRoot string) ([]searchResult, *rankingExplain, error) {
 	// Step 1: BM25 search.
 	bm25Hits, err := bm25Search(indexDB, filters.Query)
 	if err != nil {
-		return nil, fmt.Errorf("bm25 search: %w", err)
+		return nil, nil, fmt.Errorf("bm25 search: %w", err)
 	}
 
 	// Step 2: LSA search.
@@ -177,14 +228,6 @@ func hybridSearch(indexDB *sql.DB, filters RecallFilters, limit int, gitRoot str
 		}
 	}
 
-	// Normalize BM25 scores to [0,1].
-	var maxBM25 float64
-	for _, sh := range sessions {
-		if sh.bm25Max > maxBM25 {
-			maxBM25 = sh.bm25Max
-		}
-	}
-
 	// Add LSA scores.
 	for sid, score := range lsaScores {
 		sh, ok := sessions[sid]
@@ -196,14 +239,6 @@ func hybridSearch(indexDB *sql.DB, filters RecallFilters, limit int, gitRoot str
 		sh.lsaScore = score
 	}
 
-	// Normalize LSA scores to [0,1].
-	var maxLSA float64
-	for _, sh := range sessions {
-		if sh.lsaScore > maxLSA {
-			maxLSA = sh.lsaScore
-		}
-	}
-
 	// Add nomic scores.
 	for sid, score := range nomicScores {
 		sh, ok := sessions[sid]
@@ -214,52 +249,23 @@ func hybridSearch(indexDB *sql.DB, filters RecallFilters, limit int, gitRoot str
 		sh.nomicScore = score
 	}
 
-	// Normalize nomic scores to [0,1].
-	var maxNomic float64
-	for _, sh := range sessions {
-		if sh.nomicScore > maxNomic {
-			maxNomic = sh.nomicScore
-		}
-	}
-
-	// Compute hybrid scores — 3-way when nomic available, 2-way fallback.
+	// Fuse into hybrid scores — Nomic only counts when available.
 	useNomic := len(nomicScores) > 0
-	var scoredResults []scored
-	for sid, sh := range sessions {
-		bm25Norm := 0.0
-		if maxBM25 > 0 {
-			bm25Norm = sh.bm25Max / maxBM25
-		}
-		lsaNorm := 0.0
-		if maxLSA > 0 {
-			lsaNorm = sh.lsaScore / maxLSA
-		}
-
-		var hybrid float64
-		if useNomic {
-			nomicNorm := 0.0
-			if maxNomic > 0 {
-				nomicNorm = sh.nomicScore / maxNomic
-			}
-			hybrid = bm25Weight3Way*bm25Norm + lsaWeight3Way*lsaNorm + nomicWeight3Way*nomicNorm
-		} else {
-			hybrid = bm25Weight2Way*bm25Norm + lsaWeight2Way*lsaNorm
-		}
-		scoredResults = append(scoredResults, scored{sid, hybrid, sh})
-	}
+	scoredResults := fuseScores(sessions, ranking, useNomic)
 
 	// Sort by score descending.
 	sortScored(scoredResults)
 
 	// Apply filters and build results.
-	return buildResults(indexDB, scoredResults, filters, limit)
+	results, err := buildResults(indexDB, scoredResults, filters, limit)
+	return results, ranking.explain(useNomic), err
 }
 
 func filterSearch(indexDB *sql.DB, filters RecallFilters, limit int) ([]searchResult, error) {
 	// Build WHERE clause from filters.
 	where, args := buildFilterWhere(filters)
 
-	query := "SELECT session_id, user_email, git_branch, actor_type, captured_at, turn_count, tool_call_count, file_count, checkpoint_id, git_sha FROM session_facets"
+	query := "SELECT session_id, user_email, git_branch, actor_type, captured_at, turn_count, tool_call_count, file_count, checkpoint_id, git_sha, verified FROM session_facets"
 	if where != "" {
 		query += " WHERE " + where
 	}
@@ -274,7 +280,7 @@ func filterSearch(indexDB *sql.DB, filters RecallFilters, limit int) ([]searchRe
 	var results []searchResult
 	for rows.Next() {
 		var sf sessionFacetRow
-		if err := rows.Scan(&sf.sessionID, &sf.email, &sf.branch, &sf.actorType, &sf.capturedAt, &sf.turnCount, &sf.toolCallCount, &sf.fileCount, &sf.checkpointID, &sf.gitSHA); err != nil {
+		if err := rows.Scan(&sf.sessionID, &sf.email, &sf.branch, &sf.actorType, &sf.capturedAt, &sf.turnCount, &sf.toolCallCount, &sf.fileCount, &sf.checkpointID, &sf.gitSHA, &sf.verified); err != nil {
 			return nil, fmt.Errorf("scan facet: %w", err)
 		}
 
@@ -296,6 +302,7 @@ func filterSearch(indexDB *sql.DB, filters RecallFilters, limit int) ([]searchRe
 				TurnCount:  sf.turnCount,
 				ToolCalls:  sf.toolCallCount,
 				Files:      files,
+				Verified:   !sf.verified.Valid || sf.verified.Bool,
 			},
 		})
 	}
@@ -313,6 +320,7 @@ type sessionFacetRow struct {
 	fileCount     int
 	checkpointID  sql.NullString
 	gitSHA        sql.NullString
+	verified      sql.NullBool
 }
 
 func buildFilterWhere(filters RecallFilters) (string, []interface{}) {
@@ -464,9 +472,9 @@ func buildResults(indexDB *sql.DB, scored []scored, filters RecallFilters, limit
 		// Load session facets.
 		var sf sessionFacetRow
 		err := indexDB.QueryRow(
-			"SELECT session_id, user_email, git_branch, actor_type, captured_at, turn_count, tool_call_count, file_count, checkpoint_id, git_sha FROM session_facets WHERE session_id = $1",
+			"SELECT session_id, user_email, git_branch, actor_type, captured_at, turn_count, tool_call_count, file_count, checkpoint_id, git_sha, verified FROM session_facets WHERE session_id = $1",
 			s.sessionID,
-		).Scan(&sf.sessionID, &sf.email, &sf.branch, &sf.actorType, &sf.capturedAt, &sf.turnCount, &sf.toolCallCount, &sf.fileCount, &sf.checkpointID, &sf.gitSHA)
+		).Scan(&sf.sessionID, &sf.email, &sf.branch, &sf.actorType, &sf.capturedAt, &sf.turnCount, &sf.toolCallCount, &sf.fileCount, &sf.checkpointID, &sf.gitSHA, &sf.verified)
 		if err != nil {
 			continue // session not in facets (shouldn't happen)
 		}
@@ -510,9 +518,14 @@ func buildResults(indexDB *sql.DB, scored []scored, filters RecallFilters, limit
 			snippet, snippetIdx, snippetRole = firstTurnSnippet(indexDB, s.sessionID)
 		}
 
+		var explain *scoreExplain
+		if filters.Explain {
+			explain = s.explain
+		}
 		results = append(results, searchResult{
 			SessionID:      s.sessionID,
 			Score:          math.Round(s.score*100) / 100,
+			Explain:        explain,
 			Snippet:        snippet,
 			SnippetTurnIdx: snippetIdx,
 			SnippetRole:    snippetRole,
@@ -525,6 +538,7 @@ func buildResults(indexDB *sql.DB, scored []scored, filters RecallFilters, limit
 				TurnCount:  sf.turnCount,
 				ToolCalls:  sf.toolCallCount,
 				Files:      files,
+				Verified:   !sf.verified.Valid || sf.verified.Bool,
 			},
 		})
 	}
@@ -536,6 +550,7 @@ type scored struct {
 	sessionID string
 	score     float64
 	hit       *sessionHit
+	explain   *scoreExplain
 }
 
 type sessionHit struct {
@@ -1,7 +1,13 @@
 package cli
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/spf13/cobra"
 )
 
 func TestExtractSnippet_ShortContent(t *testing.T) {
@@ -75,3 +81,28 @@ type nullableString struct {
 	String string
 	Valid  bool
 }
+
+func TestQueryRecall_PendingIndexMigration(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	indexDB, err := db.OpenIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InitIndexSchema(indexDB); err != nil {
+		t.Fatal(err)
+	}
+	// An index written before the latest index migration.
+	if _, err := indexDB.Exec("DELETE FROM schema_version WHERE version = (SELECT max(version) FROM schema_version)"); err != nil {
+		t.Fatal(err)
+	}
+	indexDB.Close()
+
+	err = queryRecall(&cobra.Command{}, dir, RecallFilters{})
+	if !errors.Is(err, errIndexNotBuilt) {
+		t.Errorf("queryRecall = %v, want errIndexNotBuilt", err)
+	}
+}
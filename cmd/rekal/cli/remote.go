@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// Rekal branches are pushed to one remote and fetched from one or more. Both
+// default to origin and are configured with git config:
+//
+//	git config rekal.remote upstream            # push and fetch
+//	git config rekal.pushRemote fork            # push only
+//	git config --add rekal.fetchRemote upstream # fetch; repeat for more
+//	git config --add rekal.fetchRemote mirror
+//
+// The --remote flags of push and sync override the configuration for one run.
+
+const (
+	defaultRemote = "origin"
+
+	remoteConfigKey      = "rekal.remote"
+	pushRemoteConfigKey  = "rekal.pushRemote"
+	fetchRemoteConfigKey = "rekal.fetchRemote"
+)
+
+// pushRemote returns the remote rekal/<email> is pushed to: override, then
+// rekal.pushRemote, then rekal.remote, then origin.
+func pushRemote(override string) string {
+	for _, r := range []string{override, gitConfigValue(pushRemoteConfigKey), gitConfigValue(remoteConfigKey)} {
+		if r != "" {
+			return r
+		}
+	}
+	return defaultRemote
+}
+
+// fetchRemotes returns the remotes rekal branches are fetched from:
+// overrides, then every rekal.fetchRemote, then rekal.remote, then origin.
+// Duplicates are dropped, keeping the first occurrence.
+func fetchRemotes(overrides []string) []string {
+	remotes := overrides
+	if len(remotes) == 0 {
+		remotes = gitConfigValues(fetchRemoteConfigKey)
+	}
+	if len(remotes) == 0 {
+		if r := gitConfigValue(remoteConfigKey); r != "" {
+			remotes = []string{r}
+		}
+	}
+	if len(remotes) == 0 {
+		return []string{defaultRemote}
+	}
+
+	seen := make(map[string]bool, len(remotes))
+	var unique []string
+	for _, r := range remotes {
+		if r = strings.TrimSpace(r); r != "" && !seen[r] {
+			seen[r] = true
+			unique = append(unique, r)
+		}
+	}
+	return unique
+}
+
+// remoteExists reports whether remote is configured in the repository.
+func remoteExists(gitRoot, remote string) bool {
+	return exec.Command("git", "-C", gitRoot, "remote", "get-url", remote).Run() == nil
+}
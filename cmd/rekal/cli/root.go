@@ -35,6 +35,8 @@ func NewRootCmd() *cobra.Command {
 		authorFilter     string
 		actorFilter      string
 		limitFlag        int
+		fusionFlag       string
+		explainFlag      bool
 	)
 
 	cmd := &cobra.Command{
@@ -75,6 +77,9 @@ func NewRootCmd() *cobra.Command {
 				Author: authorFilter,
 				Actor:  actorFilter,
 				Limit:  limitFlag,
+
+				Fusion:  fusionFlag,
+				Explain: explainFlag,
 			}
 
 			_ = checkpointFilter // reserved for future use
@@ -90,6 +95,8 @@ func NewRootCmd() *cobra.Command {
 	cmd.Flags().StringVar(&authorFilter, "author", "", "Filter by author email")
 	cmd.Flags().StringVar(&actorFilter, "actor", "", "Filter by actor type (human|agent)")
 	cmd.Flags().IntVarP(&limitFlag, "limit", "n", 0, "Max results (0 = no limit)")
+	cmd.Flags().StringVar(&fusionFlag, "fusion", "", "How to fuse BM25/LSA/Nomic scores: weighted|rrf (default: rekal.fusion or weighted)")
+	cmd.Flags().BoolVar(&explainFlag, "explain", false, "Add per-signal raw and normalized scores and ranks to each result")
 
 	cmd.SetVersionTemplate("rekal {{.Version}}\n")
 	cmd.Version = Version
@@ -120,10 +127,26 @@ func NewRootCmd() *cobra.Command {
 	queryCmd.GroupID = "advanced"
 	indexCmd := newIndexCmd()
 	indexCmd.GroupID = "advanced"
+	doctorCmd := newDoctorCmd()
+	doctorCmd.GroupID = "advanced"
+	migrateCmd := newMigrateCmd()
+	migrateCmd.GroupID = "advanced"
+	migrateRefsCmd := newMigrateRefsCmd()
+	migrateRefsCmd.GroupID = "advanced"
+	verifyCmd := newVerifyCmd()
+	verifyCmd.GroupID = "advanced"
+	wireCmd := newWireCmd()
+	wireCmd.GroupID = "advanced"
+	codecCmd := newCodecCmd()
+	codecCmd.GroupID = "advanced"
+	keygenCmd := newKeygenCmd()
+	keygenCmd.GroupID = "advanced"
+	bundleCmd := newBundleCmd()
+	bundleCmd.GroupID = "advanced"
 
 	cmd.AddCommand(initCmd, cleanCmd, versionCmd)
 	cmd.AddCommand(checkpointCmd, pushCmd, syncCmd, logCmd)
-	cmd.AddCommand(queryCmd, indexCmd)
+	cmd.AddCommand(queryCmd, indexCmd, doctorCmd, migrateCmd, migrateRefsCmd, verifyCmd, wireCmd, codecCmd, keygenCmd, bundleCmd)
 	cmd.AddCommand(nomic.NewDaemonCmd())
 
 	return cmd
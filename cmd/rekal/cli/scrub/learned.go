@@ -0,0 +1,207 @@
+package scrub
+
+import (
+	"bufio"
+	"bytes"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// minLearnedSecretLen is the shortest value learned from a sensitive file.
+// Shorter values ("true", "8080", "dev") match too much unrelated text.
+const minLearnedSecretLen = 8
+
+// maxSensitiveFileSize caps how much of a sensitive file is parsed.
+const maxSensitiveFileSize = 1 << 20
+
+// secretKeyHints mark a key whose value is secret even if it looks like a word.
+// They are matched against whole key segments (see keySegments), so AUTH_TOKEN
+// matches but AUTHOR_NAME, MONKEY_ENABLED and PASSTHROUGH do not.
+var secretKeyHints = map[string]bool{
+	"SECRET": true, "TOKEN": true, "PASSWORD": true, "PASSWD": true, "PASS": true, "KEY": true,
+	"CREDENTIAL": true, "CREDENTIALS": true, "AUTH": true, "DSN": true, "PRIVATE": true,
+}
+
+// KnownSecrets holds literal secret values learned from the repository's
+// sensitive files for one checkpoint run. Values live only in memory: they
+// are never logged, stored in a database or written to the wire format.
+type KnownSecrets struct {
+	replacer *strings.Replacer
+	n        int
+}
+
+// LearnSecrets extracts candidate secret values from the contents of
+// sensitive files (.env, YAML, INI such as ~/.aws/credentials). Returns nil
+// when no value qualifies.
+func LearnSecrets(contents [][]byte) *KnownSecrets {
+	seen := make(map[string]bool)
+	var values []string
+	for _, data := range contents {
+		if len(data) > maxSensitiveFileSize {
+			data = data[:maxSensitiveFileSize]
+		}
+		for _, v := range parseSecretValues(data) {
+			if !seen[v] {
+				seen[v] = true
+				values = append(values, v)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	// Longest first so a value containing another is replaced whole.
+	sort.SliceStable(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	pairs := make([]string, 0, 2*len(values))
+	for _, v := range values {
+		pairs = append(pairs, v, redacted)
+	}
+	return &KnownSecrets{replacer: strings.NewReplacer(pairs...), n: len(values)}
+}
+
+// Len returns the number of learned values.
+func (k *KnownSecrets) Len() int {
+	if k == nil {
+		return 0
+	}
+	return k.n
+}
+
+// Redact replaces every exact occurrence of a learned value with [REDACTED].
+func (k *KnownSecrets) Redact(text string) string {
+	if k == nil || text == "" {
+		return text
+	}
+	return k.replacer.Replace(text)
+}
+
+// parseSecretValues returns the qualifying values of KEY=VALUE, KEY: VALUE
+// and key = value lines. Comments, section headers and blank lines are skipped.
+func parseSecretValues(data []byte) []string {
+	var values []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), maxSensitiveFileSize)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' || line[0] == '[' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := splitAssignment(line)
+		if !ok {
+			continue
+		}
+		value = unquoteValue(value)
+		if isLearnableSecret(key, value) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// splitAssignment splits a line at the first '=' or ': ' separator.
+func splitAssignment(line string) (key, value string, ok bool) {
+	eq := strings.IndexByte(line, '=')
+	colon := strings.Index(line, ": ")
+	switch {
+	case eq > 0 && (colon < 0 || eq < colon):
+		key, value = line[:eq], line[eq+1:]
+	case colon > 0:
+		key, value = line[:colon], line[colon+2:]
+	default:
+		return "", "", false
+	}
+	key = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(key), "- "))
+	return key, strings.TrimSpace(value), key != ""
+}
+
+// unquoteValue strips matching quotes, or a trailing " # comment" from an
+// unquoted value.
+func unquoteValue(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = strings.TrimSpace(v[:i])
+	}
+	return v
+}
+
+// isLearnableSecret filters out values that are too short or too common to
+// redact verbatim. Plain words only qualify under a secret-looking key.
+func isLearnableSecret(key, value string) bool {
+	if len(value) < minLearnedSecretLen || strings.ContainsAny(value, "\n\r") {
+		return false
+	}
+	if strings.HasPrefix(value, "${") || strings.HasPrefix(value, "$(") {
+		return false // interpolation, not a literal
+	}
+	if isDigits(value) {
+		return false
+	}
+	if strings.HasPrefix(value, "/") || strings.HasPrefix(value, "./") {
+		return false // filesystem path
+	}
+	if strings.Contains(value, "://") && !strings.Contains(value, "@") {
+		return false // URL without credentials
+	}
+	if isPlainWord(value) {
+		for _, seg := range keySegments(key) {
+			if secretKeyHints[seg] {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// keySegments splits key into upper-cased words at '_', '-', '.' and other
+// separators and at camelCase boundaries: "aws_secret_access_key" and
+// "awsSecretAccessKey" both give AWS SECRET ACCESS KEY.
+func keySegments(key string) []string {
+	var segs []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			segs = append(segs, strings.ToUpper(string(cur)))
+			cur = cur[:0]
+		}
+	}
+	var prev rune
+	for _, c := range key {
+		switch {
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			flush()
+		case unicode.IsUpper(c) && unicode.IsLower(prev):
+			flush()
+			cur = append(cur, c)
+		default:
+			cur = append(cur, c)
+		}
+		prev = c
+	}
+	flush()
+	return segs
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isPlainWord reports whether s is letters with optional '-' or '_'
+// separators, e.g. "production" or "staging-eu".
+func isPlainWord(s string) bool {
+	for _, c := range s {
+		if !unicode.IsLetter(c) && c != '-' && c != '_' {
+			return false
+		}
+	}
+	return true
+}
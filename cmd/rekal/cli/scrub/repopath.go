@@ -0,0 +1,129 @@
+package scrub
+
+import (
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// OutOfRepoPrefix marks a stored path that resolves outside the repository
+// root (e.g. "ext:/home/user_1a2b3c4d/.aws/credentials"). Such paths are kept
+// for context but are never comparable across machines.
+const OutOfRepoPrefix = "ext:"
+
+// repoRelativizer rewrites paths under a repository root to repo-relative form.
+type repoRelativizer struct {
+	roots    []string       // absolute root spellings, longest first
+	prefixRe *regexp.Regexp // root + "/" not preceded by a path character
+	bareRe   *regexp.Regexp // bare root neither preceded nor followed by a path character
+}
+
+// notPathChar matches a character that cannot continue a path. A root only
+// matches in text between such characters, so /work/acme/rekal does not
+// match inside /mnt/work/acme/rekal or /work/acme/rekal-docs.
+const notPathChar = `[^A-Za-z0-9._/\-]`
+
+// newRepoRelativizer builds a relativizer for gitRoot. Both the given root and
+// its symlink-resolved form are recognised (macOS /var vs /private/var).
+func newRepoRelativizer(gitRoot string) *repoRelativizer {
+	roots := RepoRootSpellings(gitRoot)
+	if len(roots) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(roots))
+	for i, r := range roots {
+		quoted[i] = regexp.QuoteMeta(r)
+	}
+	alt := `(?:` + strings.Join(quoted, "|") + `)`
+	return &repoRelativizer{
+		roots:    roots,
+		prefixRe: regexp.MustCompile(`(^|` + notPathChar + `)` + alt + `/`),
+		bareRe:   regexp.MustCompile(`(^|` + notPathChar + `)` + alt + `($|` + notPathChar + `)`),
+	}
+}
+
+// RepoRootSpellings returns the distinct absolute spellings of gitRoot that
+// may appear in transcripts: as given, symlink-resolved, and with the username
+// anonymized (for rows written before paths were made repo-relative).
+// Longest spellings come first so prefix replacement is unambiguous.
+func RepoRootSpellings(gitRoot string) []string {
+	gitRoot = strings.TrimSuffix(gitRoot, "/")
+	if gitRoot == "" {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var roots []string
+	add := func(r string) {
+		r = strings.TrimSuffix(r, "/")
+		if r == "" || seen[r] {
+			return
+		}
+		seen[r] = true
+		roots = append(roots, r)
+	}
+	add(gitRoot)
+	if resolved, err := filepath.EvalSymlinks(gitRoot); err == nil {
+		add(resolved)
+	}
+	for _, r := range append([]string(nil), roots...) {
+		add(AnonymizePath(r))
+	}
+	// Longest first: /private/var/x must be tried before /var/x.
+	sort.SliceStable(roots, func(i, j int) bool { return len(roots[i]) > len(roots[j]) })
+	return roots
+}
+
+// RepoRelativePath canonicalises a tool call path against gitRoot.
+// Paths inside the repository become slash-separated and repo-relative
+// ("src/auth/login.go"); relative paths are assumed to be relative to the
+// repository root already. Paths outside the repository are prefixed with
+// OutOfRepoPrefix. Empty paths and already-marked paths are returned as-is.
+func RepoRelativePath(gitRoot, p string) string {
+	r := newRepoRelativizer(gitRoot)
+	if r == nil {
+		return p
+	}
+	return r.relativePath(p)
+}
+
+func (r *repoRelativizer) relativePath(p string) string {
+	if p == "" || strings.HasPrefix(p, OutOfRepoPrefix) {
+		return p
+	}
+	p = filepath.ToSlash(p)
+	if !strings.HasPrefix(p, "/") {
+		clean := path.Clean(p)
+		if clean == ".." || strings.HasPrefix(clean, "../") {
+			return OutOfRepoPrefix + clean
+		}
+		return clean
+	}
+	clean := path.Clean(p)
+	for _, root := range r.roots {
+		if clean == root {
+			return "."
+		}
+		if strings.HasPrefix(clean, root+"/") {
+			return strings.TrimPrefix(clean, root+"/")
+		}
+	}
+	return OutOfRepoPrefix + clean
+}
+
+// RepoRelativeText rewrites absolute paths under gitRoot inside free text to
+// repo-relative form. A bare reference to the root itself becomes ".".
+// Paths outside the repository are left untouched.
+func RepoRelativeText(gitRoot, text string) string {
+	r := newRepoRelativizer(gitRoot)
+	if r == nil {
+		return text
+	}
+	return r.relativeText(text)
+}
+
+func (r *repoRelativizer) relativeText(text string) string {
+	text = r.prefixRe.ReplaceAllString(text, "$1")
+	return r.bareRe.ReplaceAllString(text, "$1.$2")
+}
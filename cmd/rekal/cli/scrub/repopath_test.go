@@ -0,0 +1,116 @@
+package scrub
+
+import (
+	"testing"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/session"
+)
+
+func TestRepoRelativePath(t *testing.T) {
+	t.Parallel()
+	root := "/work/acme/rekal"
+	cases := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"/work/acme/rekal/src/auth/login.go", "src/auth/login.go"},
+		{"/work/acme/rekal/./src/../main.go", "main.go"},
+		{"/work/acme/rekal", "."},
+		{"/work/acme/rekal/", "."},
+		{"src/auth/login.go", "src/auth/login.go"},
+		{"./src/auth/login.go", "src/auth/login.go"},
+		{"../other/file.go", "ext:../other/file.go"},
+		{"/work/acme/rekal-other/file.go", "ext:/work/acme/rekal-other/file.go"},
+		{"/etc/hosts", "ext:/etc/hosts"},
+		{"ext:/etc/hosts", "ext:/etc/hosts"},
+	}
+	for _, c := range cases {
+		if got := RepoRelativePath(root, c.in); got != c.want {
+			t.Errorf("RepoRelativePath(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestRepoRelativePath_Idempotent(t *testing.T) {
+	t.Parallel()
+	root := "/work/acme/rekal"
+	for _, in := range []string{"/work/acme/rekal/a/b.go", "/tmp/x", "../y", "a.go"} {
+		once := RepoRelativePath(root, in)
+		twice := RepoRelativePath(root, once)
+		if once != twice {
+			t.Errorf("not idempotent for %q: %q then %q", in, once, twice)
+		}
+	}
+}
+
+func TestRepoRelativeText(t *testing.T) {
+	t.Parallel()
+	root := "/work/acme/rekal"
+	cases := []struct {
+		in, want string
+	}{
+		{"edit /work/acme/rekal/src/main.go please", "edit src/main.go please"},
+		{"cd /work/acme/rekal && make", "cd . && make"},
+		{"cwd is /work/acme/rekal", "cwd is ."},
+		{"see /work/acme/rekal-docs/readme", "see /work/acme/rekal-docs/readme"},
+		{"see /etc/hosts", "see /etc/hosts"},
+		{"/work/acme/rekal/go.mod", "go.mod"},
+		{"mounted at /mnt/work/acme/rekal/x", "mounted at /mnt/work/acme/rekal/x"},
+		{"mounted at /mnt/work/acme/rekal", "mounted at /mnt/work/acme/rekal"},
+		{`open("/work/acme/rekal/a.go")`, `open("a.go")`},
+	}
+	for _, c := range cases {
+		if got := RepoRelativeText(root, c.in); got != c.want {
+			t.Errorf("RepoRelativeText(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestRepoRootSpellings_LongestFirst(t *testing.T) {
+	t.Parallel()
+	roots := RepoRootSpellings("/home/alice/src/rekal/")
+	if len(roots) == 0 || roots[0] == "" {
+		t.Fatalf("expected root spellings, got %v", roots)
+	}
+	for i := 1; i < len(roots); i++ {
+		if len(roots[i]) > len(roots[i-1]) {
+			t.Errorf("roots not sorted longest first: %v", roots)
+		}
+	}
+	found := false
+	for _, r := range roots {
+		if r == "/home/alice/src/rekal" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("trailing slash not trimmed: %v", roots)
+	}
+}
+
+func TestScrub_RepoRelative(t *testing.T) {
+	t.Parallel()
+	root := "/work/acme/rekal"
+	p := &session.SessionPayload{
+		Turns: []session.Turn{{Role: "human", Content: "fix /work/acme/rekal/db/db.go"}},
+		ToolCalls: []session.ToolCall{
+			{Tool: "Edit", Path: "/work/acme/rekal/db/db.go"},
+			{Tool: "Read", Path: "/opt/shared/notes.txt"},
+			{Tool: "Bash", CmdPrefix: "go test /work/acme/rekal/..."},
+		},
+	}
+	Scrub(p, root, nil)
+
+	if got := p.Turns[0].Content; got != "fix db/db.go" {
+		t.Errorf("turn content = %q", got)
+	}
+	if got := p.ToolCalls[0].Path; got != "db/db.go" {
+		t.Errorf("edit path = %q", got)
+	}
+	if got := p.ToolCalls[1].Path; got != "ext:/opt/shared/notes.txt" {
+		t.Errorf("out-of-repo path = %q", got)
+	}
+	if got := p.ToolCalls[2].CmdPrefix; got != "go test ..." {
+		t.Errorf("cmd prefix = %q", got)
+	}
+}
@@ -4,21 +4,49 @@ import (
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/session"
 )
 
-// Scrub applies secret redaction and path anonymization to a SessionPayload
-// in place. Call this after session.ParseTranscript and before DB insertion.
-func Scrub(payload *session.SessionPayload) {
+// Scrub applies path canonicalisation, secret redaction and path anonymization
+// to a SessionPayload in place. Call this after session.ParseTranscript and
+// before DB insertion. Paths under gitRoot become repo-relative so that stored
+// data is identical across teammates with different checkout locations.
+// Exact occurrences of values in known (may be nil) are redacted before the
+// pattern-based pass.
+func Scrub(payload *session.SessionPayload, gitRoot string, known *KnownSecrets) {
 	if payload == nil {
 		return
 	}
 
+	rel := newRepoRelativizer(gitRoot)
+
 	for i := range payload.Turns {
+		if rel != nil {
+			payload.Turns[i].Content = rel.relativeText(payload.Turns[i].Content)
+		}
+		payload.Turns[i].Content = known.Redact(payload.Turns[i].Content)
 		payload.Turns[i].Content = RedactText(payload.Turns[i].Content)
 		payload.Turns[i].Content = AnonymizeText(payload.Turns[i].Content)
 	}
 
 	for i := range payload.ToolCalls {
+		if rel != nil {
+			payload.ToolCalls[i].Path = rel.relativePath(payload.ToolCalls[i].Path)
+			payload.ToolCalls[i].CmdPrefix = rel.relativeText(payload.ToolCalls[i].CmdPrefix)
+		}
 		payload.ToolCalls[i].Path = AnonymizePath(payload.ToolCalls[i].Path)
+		payload.ToolCalls[i].CmdPrefix = known.Redact(payload.ToolCalls[i].CmdPrefix)
 		payload.ToolCalls[i].CmdPrefix = RedactText(payload.ToolCalls[i].CmdPrefix)
 		payload.ToolCalls[i].CmdPrefix = AnonymizeText(payload.ToolCalls[i].CmdPrefix)
 	}
 }
+
+// ContentID returns the content-addressed ID of payload (see
+// session.ContentID) as scrubbed for gitRoot, but without learned secrets:
+// those come from each machine's own sensitive files, so redacting them
+// would give one transcript a different ID on every machine. Call it on the
+// raw payload, before Scrub; payload is not modified.
+func ContentID(payload *session.SessionPayload, gitRoot string) string {
+	p := *payload
+	p.Turns = append([]session.Turn(nil), payload.Turns...)
+	p.ToolCalls = append([]session.ToolCall(nil), payload.ToolCalls...)
+	Scrub(&p, gitRoot, nil)
+	return session.ContentID(&p)
+}
@@ -0,0 +1,481 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+)
+
+// Branches written since segmented bodies store rekal.body as immutable
+// segment files under body/ (see codec/segment.go); older branches hold a
+// single rekal.body. Readers go through readBranchBody and see the same
+// logical body either way.
+
+// legacyBodyFile is the single-file body of branches written before
+// segmented bodies.
+const legacyBodyFile = "rekal.body"
+
+// readBranchManifest returns the segment manifest on ref, or nil if ref
+// has none — a legacy branch, or no branch at all.
+func readBranchManifest(gitRoot, ref string) (*codec.Manifest, error) {
+	objs, err := openGitObjects(gitRoot)
+	if err != nil {
+		return nil, err
+	}
+	defer objs.Close()
+	return objs.manifest(ref)
+}
+
+// readBranchBody returns the logical rekal.body on ref: its segments joined
+// in manifest order, or the legacy rekal.body. Nil if ref has neither.
+func readBranchBody(gitRoot, ref string) ([]byte, error) {
+	objs, err := openGitObjects(gitRoot)
+	if err != nil {
+		return nil, err
+	}
+	defer objs.Close()
+	return objs.body(ref)
+}
+
+// readBranchTail returns the end of the logical body on ref for an import
+// that got as far as since: the segments from the one holding frame
+// since.Frames on, joined into a body, and how many frames of ref come
+// before that body's first. Segments are never rewritten, so the frames
+// since.Commit had are the same on ref when its segments still are. When
+// they are not — history was rewritten — or either side is a legacy
+// branch, or since is unset, it returns the whole body and 0.
+func readBranchTail(gitRoot, ref string, since branchWatermark) ([]byte, int, error) {
+	objs, err := openGitObjects(gitRoot)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer objs.Close()
+	if since.Commit != "" && since.Frames > 0 {
+		old, err := objs.manifest(since.Commit)
+		if err != nil {
+			return nil, 0, err
+		}
+		m, err := objs.manifest(ref)
+		if err != nil {
+			return nil, 0, err
+		}
+		if old != nil && m != nil && segmentsKept(gitRoot, since.Commit, ref, old, m) {
+			// Whole segments before the watermark are skipped; the last
+			// segment is always read, so the tail is never empty.
+			first, from := 0, 0
+			for from < len(m.Segments)-1 && first+m.Segments[from].Frames <= since.Frames {
+				first += m.Segments[from].Frames
+				from++
+			}
+			body, err := objs.segments(ref, m, from)
+			return body, first, err
+		}
+	}
+	body, err := objs.body(ref)
+	return body, 0, err
+}
+
+// segmentsKept reports whether ref holds every segment of base, as listed
+// by their manifests old and m, unchanged.
+func segmentsKept(gitRoot, base, ref string, old, m *codec.Manifest) bool {
+	if len(m.Segments) < len(old.Segments) {
+		return false
+	}
+	baseEntries, err := listSegments(gitRoot, base)
+	if err != nil {
+		return false
+	}
+	refEntries, err := listSegments(gitRoot, ref)
+	if err != nil {
+		return false
+	}
+	for i, info := range old.Segments {
+		name := codec.SegmentName(i)
+		if m.Segments[i] != info || baseEntries[name] == "" || refEntries[name] != baseEntries[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// listSegments returns the ls-tree entries of the segment directory on rev
+// by file name, in one git process however many segments there are.
+func listSegments(gitRoot, rev string) (map[string]string, error) {
+	out, err := exec.Command("git", "-C", gitRoot, "ls-tree", rev+":"+codec.SegmentDir).Output()
+	if err != nil {
+		return nil, fmt.Errorf("list %s on %s: %w", codec.SegmentDir, rev, err)
+	}
+	entries := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if _, name, ok := strings.Cut(line, "\t"); ok {
+			entries[name] = line + "\n"
+		}
+	}
+	return entries, nil
+}
+
+// branchFrameCount returns the number of readable frames on ref. Segmented
+// branches answer from the manifest without reading any segment.
+func branchFrameCount(gitRoot, ref string) int {
+	objs, err := openGitObjects(gitRoot)
+	if err != nil {
+		return 0
+	}
+	defer objs.Close()
+	return objs.frameCount(ref)
+}
+
+// manifest is readBranchManifest through g.
+func (g *gitObjects) manifest(ref string) (*codec.Manifest, error) {
+	data, err := g.read(ref + ":" + codec.SegmentDir + "/" + codec.ManifestName)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	m, err := codec.LoadManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ref, err)
+	}
+	return m, nil
+}
+
+// body is readBranchBody through g.
+func (g *gitObjects) body(ref string) ([]byte, error) {
+	m, err := g.manifest(ref)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return g.read(ref + ":" + legacyBodyFile)
+	}
+	return g.segments(ref, m, 0)
+}
+
+// segments joins the segments of ref listed in m from segment from on.
+func (g *gitObjects) segments(ref string, m *codec.Manifest, from int) ([]byte, error) {
+	var segs [][]byte
+	for i := from; i < len(m.Segments); i++ {
+		name := codec.SegmentDir + "/" + codec.SegmentName(i)
+		seg, err := g.read(ref + ":" + name)
+		if err != nil {
+			return nil, err
+		}
+		if len(seg) != m.Segments[i].Len {
+			return nil, fmt.Errorf("%s: %s is %d bytes, manifest says %d", ref, name, len(seg), m.Segments[i].Len)
+		}
+		segs = append(segs, seg)
+	}
+	body, err := codec.JoinSegments(segs)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ref, err)
+	}
+	return body, nil
+}
+
+// frameCount is branchFrameCount through g.
+func (g *gitObjects) frameCount(ref string) int {
+	if m, err := g.manifest(ref); err == nil && m != nil {
+		return m.Frames()
+	}
+	body, err := g.body(ref)
+	if err != nil || len(body) == 0 {
+		return 0
+	}
+	frames, err := codec.ScanFrames(body)
+	if err != nil {
+		return 0
+	}
+	return len(frames)
+}
+
+// dictDir is the tree entry that holds the zstd dictionaries trained by
+// 'rekal codec train', one file per dictionary ID.
+const dictDir = "dicts"
+
+// dictFileName returns the file name of dictionary id within dictDir.
+func dictFileName(id uint32) string {
+	return fmt.Sprintf("%08x.zdict", id)
+}
+
+// readBranchDicts returns the trained zstd dictionaries on ref by ID. Nil
+// if ref has none.
+func readBranchDicts(gitRoot, ref string) (map[uint32][]byte, error) {
+	out, err := exec.Command("git", "-C", gitRoot, "ls-tree", "--name-only", ref+":"+dictDir).Output()
+	if err != nil {
+		return nil, nil // no dicts/ on ref
+	}
+	var dicts map[uint32][]byte
+	for _, name := range strings.Fields(string(out)) {
+		data := gitShowFile(gitRoot, ref, dictDir+"/"+name)
+		id, err := codec.DictID(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s/%s: %w", ref, dictDir, name, err)
+		}
+		if name != dictFileName(id) {
+			return nil, fmt.Errorf("%s: %s/%s holds dictionary %08x", ref, dictDir, name, id)
+		}
+		if dicts == nil {
+			dicts = make(map[uint32][]byte)
+		}
+		dicts[id] = data
+	}
+	return dicts, nil
+}
+
+// newDictDecoder returns a decoder for frames compressed with the preset or
+// any of dicts.
+func newDictDecoder(dicts map[uint32][]byte) (*codec.Decoder, error) {
+	var all [][]byte
+	for _, d := range dicts {
+		all = append(all, d)
+	}
+	dec, err := codec.NewDecoder(all...)
+	if err != nil {
+		return nil, fmt.Errorf("create decoder: %w", err)
+	}
+	return dec, nil
+}
+
+// newBranchDecoder returns a decoder for the frames on ref: the preset
+// dictionary plus every dictionary stored on ref, and the data keys on ref
+// the local identities unwrap.
+func newBranchDecoder(gitRoot, ref string) (*codec.Decoder, error) {
+	dicts, err := readBranchDicts(gitRoot, ref)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := branchDataKeys(gitRoot, ref)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := newDictDecoder(dicts)
+	if err != nil {
+		return nil, err
+	}
+	dec.AddKeys(keys)
+	return dec, nil
+}
+
+// activeDictID returns the dictionary ID named by the last readable meta
+// frame of body, or 0 for the preset.
+func activeDictID(dec *codec.Decoder, body []byte, frames []codec.FrameSlice) uint32 {
+	for i := len(frames) - 1; i >= 0; i-- {
+		if frames[i].Type != codec.FrameMeta {
+			continue
+		}
+		mf, err := dec.DecodeMetaFrame(codec.ExtractFramePayload(body, frames[i]))
+		if err == nil {
+			return mf.DictID
+		}
+	}
+	return 0
+}
+
+// wireUpdate is a new version of the wire format on the rekal branch: the
+// full logical body, how many of its bytes are already committed, dict.bin,
+// and any dictionaries to add under dicts/ and wrapped data keys to add
+// under keys/. Message is the commit message;
+// empty means the subject of HEAD. Base, if set, is the commit the update is
+// written on top of instead of the branch tip; the tip becomes the second
+// parent of a merge commit.
+type wireUpdate struct {
+	Body      []byte
+	Committed int
+	Dict      []byte
+	NewDicts  map[uint32][]byte
+	NewKeys   map[uint32][]byte
+	Message   string
+	Base      string
+}
+
+// writeWireTree writes the tree for u on top of the rekal branch at parent
+// and returns its hash. Segments already on parent are reused as they are;
+// a legacy rekal.body becomes segment 0 without being rewritten. Only the
+// bytes after u.Committed are written, as new segments. Dictionaries and
+// wrapped keys on parent are kept and u.NewDicts and u.NewKeys added to
+// them.
+func writeWireTree(gitRoot, parent string, u *wireUpdate) (string, error) {
+	m, err := readBranchManifest(gitRoot, parent)
+	if err != nil {
+		return "", err
+	}
+
+	var entries []string
+	addEntry := func(name string, data []byte) error {
+		hash, err := gitHashObject(gitRoot, data)
+		if err != nil {
+			return fmt.Errorf("hash %s: %w", name, err)
+		}
+		entries = append(entries, fmt.Sprintf("100644 blob %s\t%s\n", hash, name))
+		return nil
+	}
+
+	if m != nil {
+		existing, err := listSegments(gitRoot, parent)
+		if err != nil {
+			return "", err
+		}
+		for i := range m.Segments {
+			entry, ok := existing[codec.SegmentName(i)]
+			if !ok {
+				return "", fmt.Errorf("%s: %s/%s is missing", parent, codec.SegmentDir, codec.SegmentName(i))
+			}
+			entries = append(entries, entry)
+		}
+	} else {
+		m = &codec.Manifest{}
+		if u.Committed > codec.BodyHeaderSize {
+			hash, err := gitRevParse(gitRoot, parent+":"+legacyBodyFile)
+			if err != nil {
+				return "", err
+			}
+			frames, err := codec.ScanFrames(u.Body[:u.Committed])
+			if err != nil {
+				return "", err
+			}
+			entries = append(entries, fmt.Sprintf("100644 blob %s\t%s\n", hash, codec.SegmentName(0)))
+			m.Segments = append(m.Segments, codec.SegmentInfo{Len: u.Committed, Frames: len(frames)})
+		}
+	}
+
+	segs, err := codec.SplitBody(u.Body, u.Committed, codec.SegmentSize)
+	if err != nil {
+		return "", err
+	}
+	for _, seg := range segs {
+		if err := addEntry(codec.SegmentName(len(m.Segments)), seg.Data); err != nil {
+			return "", err
+		}
+		m.Segments = append(m.Segments, codec.SegmentInfo{Len: len(seg.Data), Frames: seg.Frames})
+	}
+	if err := addEntry(codec.ManifestName, m.Encode()); err != nil {
+		return "", err
+	}
+
+	bodyTree, err := gitMktree(gitRoot, strings.Join(entries, ""))
+	if err != nil {
+		return "", err
+	}
+	entries = nil
+	if err := addEntry("dict.bin", u.Dict); err != nil {
+		return "", err
+	}
+	entries = append(entries, fmt.Sprintf("040000 tree %s\t%s\n", bodyTree, codec.SegmentDir))
+	rootEntries := entries
+
+	// Existing dictionaries and keys are never rewritten, like segments.
+	for _, dir := range []struct {
+		name  string
+		files map[uint32][]byte
+		file  func(uint32) string
+	}{
+		{dictDir, u.NewDicts, dictFileName},
+		{keyDir, u.NewKeys, keyFileName},
+	} {
+		entries = nil
+		if out, err := exec.Command("git", "-C", gitRoot, "ls-tree", parent+":"+dir.name).Output(); err == nil {
+			entries = append(entries, string(out))
+		}
+		ids := make([]uint32, 0, len(dir.files))
+		for id := range dir.files {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+		for _, id := range ids {
+			if err := addEntry(dir.file(id), dir.files[id]); err != nil {
+				return "", err
+			}
+		}
+		if len(entries) > 0 {
+			tree, err := gitMktree(gitRoot, strings.Join(entries, ""))
+			if err != nil {
+				return "", err
+			}
+			rootEntries = append(rootEntries, fmt.Sprintf("040000 tree %s\t%s\n", tree, dir.name))
+		}
+	}
+	return gitMktree(gitRoot, strings.Join(rootEntries, ""))
+}
+
+// gitRevParse resolves rev to an object hash.
+func gitRevParse(gitRoot, rev string) (string, error) {
+	out, err := exec.Command("git", "-C", gitRoot, "rev-parse", "--verify", rev).Output()
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", rev, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gitMktree writes a tree from ls-tree formatted entries and returns its hash.
+func gitMktree(gitRoot, entries string) (string, error) {
+	cmd := exec.Command("git", "-C", gitRoot, "mktree")
+	cmd.Stdin = strings.NewReader(entries)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("mktree: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gitObjects reads objects through one 'git cat-file --batch' process, so
+// reading every segment of a body, or a file from every commit of a
+// branch, costs one process rather than one per object.
+type gitObjects struct {
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	out *bufio.Reader
+}
+
+// openGitObjects starts a batch reader in gitRoot. Close it when done.
+func openGitObjects(gitRoot string) (*gitObjects, error) {
+	cmd := exec.Command("git", "-C", gitRoot, "cat-file", "--batch")
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("cat-file: %w", err)
+	}
+	return &gitObjects{cmd: cmd, in: in, out: bufio.NewReader(out)}, nil
+}
+
+// read returns the contents of rev, e.g. "<commit>:body/manifest", or nil
+// if it does not exist.
+func (g *gitObjects) read(rev string) ([]byte, error) {
+	if _, err := io.WriteString(g.in, rev+"\n"); err != nil {
+		return nil, fmt.Errorf("cat-file %s: %w", rev, err)
+	}
+	header, err := g.out.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("cat-file %s: %w", rev, err)
+	}
+	// "<oid> <type> <size>", or "<rev> missing" (or "ambiguous").
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, nil
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("cat-file %s: bad header %q", rev, header)
+	}
+	data := make([]byte, size+1) // the contents and a newline
+	if _, err := io.ReadFull(g.out, data); err != nil {
+		return nil, fmt.Errorf("cat-file %s: %w", rev, err)
+	}
+	return data[:size], nil
+}
+
+// Close stops the batch reader.
+func (g *gitObjects) Close() error {
+	g.in.Close()
+	return g.cmd.Wait()
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/scrub"
+)
+
+// secretFilesConfigKey is the multi-valued git config key listing extra
+// sensitive file globs, relative to the repo root or starting with "~/".
+//
+//	git config --add rekal.secretFiles 'config/*.secret.yaml'
+//	git config --add rekal.secretFiles '~/.aws/credentials'
+const secretFilesConfigKey = "rekal.secretFiles"
+
+// envFilePatterns match env-style files by base name. Only gitignored
+// matches are used — a tracked .env is already public to the team.
+var envFilePatterns = []string{".env", ".env.*", "*.env", ".envrc"}
+
+// learnRepoSecrets reads the repository's sensitive files and returns the
+// literal values found in them for exact-match redaction. Unreadable files
+// are skipped; the result may be nil.
+func learnRepoSecrets(gitRoot string) *scrub.KnownSecrets {
+	var contents [][]byte
+	for _, p := range sensitiveFiles(gitRoot, gitConfigValues(secretFilesConfigKey)) {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		contents = append(contents, data)
+	}
+	return scrub.LearnSecrets(contents)
+}
+
+// sensitiveFiles returns absolute paths of gitignored env files plus files
+// matching the configured globs, deduplicated and sorted.
+func sensitiveFiles(gitRoot string, globs []string) []string {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(p string) {
+		if seen[p] {
+			return
+		}
+		if info, err := os.Stat(p); err != nil || !info.Mode().IsRegular() {
+			return
+		}
+		seen[p] = true
+		files = append(files, p)
+	}
+
+	for _, rel := range gitIgnoredFiles(gitRoot, envFilePatterns) {
+		if isEnvFile(rel) {
+			add(filepath.Join(gitRoot, filepath.FromSlash(rel)))
+		}
+	}
+
+	home, _ := os.UserHomeDir()
+	for _, g := range globs {
+		switch {
+		case strings.HasPrefix(g, "~/") && home != "":
+			g = filepath.Join(home, g[2:])
+		case !filepath.IsAbs(g):
+			g = filepath.Join(gitRoot, g)
+		}
+		matches, err := filepath.Glob(g)
+		if err != nil {
+			continue
+		}
+		for _, m := range matches {
+			add(m)
+		}
+	}
+
+	sort.Strings(files)
+	return files
+}
+
+// gitIgnoredFiles lists untracked, ignored files relative to gitRoot whose
+// base name matches one of patterns. Ignored directories are not collapsed:
+// an env file inside an ignored directory (config/.env) must still be found.
+// The patterns go to git as pathspecs so the contents of large ignored trees
+// (node_modules, build output) are never listed.
+func gitIgnoredFiles(gitRoot string, patterns []string) []string {
+	args := []string{"-C", gitRoot, "ls-files",
+		"--others", "--ignored", "--exclude-standard", "-z", "--"}
+	for _, pat := range patterns {
+		args = append(args, ":(glob)**/"+pat)
+	}
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, f := range strings.Split(string(out), "\x00") {
+		if f != "" && !strings.HasSuffix(f, "/") {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// isEnvFile reports whether rel's base name looks like an env file.
+// Committed templates (.env.example) are excluded by the gitignore filter.
+func isEnvFile(rel string) bool {
+	base := path.Base(rel)
+	for _, pat := range envFilePatterns {
+		if ok, _ := path.Match(pat, base); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestSensitiveFiles_IgnoredEnvAndGlobs(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := exec.Command("git", "-C", dir, "init", "-q").Run(); err != nil {
+		t.Fatalf("git init: %v", err)
+	}
+	files := map[string]string{
+		".gitignore":           ".env\n.env.local\nconfig/secrets.yaml\nsecrets/\n",
+		".env":                 "API_TOKEN=zq9x8c7v6b5n\n",
+		".env.local":           "OTHER=abc123def456\n",
+		".env.example":         "API_TOKEN=changeme123\n", // tracked-style template, not ignored
+		"config/secrets.yaml":  "password: hunter2hunter2\n",
+		"config/settings.yaml": "debug: true\n",
+		"secrets/prod.env":     "API_TOKEN=k3j4h5g6f7d8\n", // inside an ignored directory
+		"secrets/notes.txt":    "not an env file\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := sensitiveFiles(dir, []string{"config/secrets.*"})
+	want := []string{
+		filepath.Join(dir, ".env"),
+		filepath.Join(dir, ".env.local"),
+		filepath.Join(dir, "config/secrets.yaml"),
+		filepath.Join(dir, "secrets/prod.env"),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("file %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
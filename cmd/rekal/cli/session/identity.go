@@ -0,0 +1,72 @@
+package session
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// ContentID returns the content-addressed ID of a session: a ULID whose time
+// is the first turn timestamp and whose entropy is a SHA-256 over the source,
+// turns and tool calls. The same transcript captured on two machines gets the
+// same ID, so it is stored, exported and imported once.
+//
+// Only data that survives the wire is hashed — timestamps at microsecond
+// precision in UTC, tool names, paths and command prefixes — so a session
+// decoded from a rekal branch has the ID it was captured with. Call it on the
+// scrubbed payload: scrubbing makes paths repo-relative, which is what lets
+// two checkouts agree. Capture uses scrub.ContentID, which leaves out the
+// secrets each machine learns from its own files; a session whose text had
+// such a secret redacted therefore decodes to a different ID than it was
+// captured with, and is found by its captured ID instead.
+func ContentID(p *SessionPayload) string {
+	h := sha256.New()
+	h.Write([]byte("rekal-session-v1"))
+
+	source := p.Source
+	if source == "" {
+		source = "claude"
+	}
+	writeField(h, source)
+
+	var ms uint64
+	writeUvarint(h, uint64(len(p.Turns)))
+	for _, t := range p.Turns {
+		writeField(h, t.Role)
+		writeField(h, t.Content)
+		var us int64
+		if !t.Timestamp.IsZero() {
+			us = t.Timestamp.UTC().Truncate(time.Microsecond).UnixMicro()
+			if ms == 0 && us > 0 {
+				ms = uint64(us / 1000)
+			}
+		}
+		writeUvarint(h, uint64(us))
+	}
+	writeUvarint(h, uint64(len(p.ToolCalls)))
+	for _, tc := range p.ToolCalls {
+		writeField(h, tc.Tool)
+		writeField(h, tc.Path)
+		writeField(h, tc.CmdPrefix)
+	}
+
+	id, err := ulid.New(ms, bytes.NewReader(h.Sum(nil)))
+	if err != nil {
+		// Only a timestamp past the year 10889 gets here.
+		id, _ = ulid.New(0, bytes.NewReader(h.Sum(nil)))
+	}
+	return id.String()
+}
+
+func writeField(h hash.Hash, s string) {
+	writeUvarint(h, uint64(len(s)))
+	h.Write([]byte(s))
+}
+
+func writeUvarint(h hash.Hash, x uint64) {
+	h.Write(binary.AppendUvarint(nil, x))
+}
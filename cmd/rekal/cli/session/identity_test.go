@@ -0,0 +1,70 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+func TestContentID(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 2, 25, 10, 0, 0, 123456000, time.UTC)
+	payload := func() *SessionPayload {
+		return &SessionPayload{
+			SessionID:  "sess-001",
+			Source:     "claude",
+			Branch:     "main",
+			CapturedAt: time.Now(),
+			Turns: []Turn{
+				{Role: "human", Content: "fix the auth bug", Timestamp: start},
+				{Role: "assistant", Content: "Let me read login.go.", Timestamp: start.Add(30 * time.Second)},
+			},
+			ToolCalls: []ToolCall{{Tool: "Read", Path: "login.go"}},
+		}
+	}
+
+	a := ContentID(payload())
+	id, err := ulid.ParseStrict(a)
+	if err != nil {
+		t.Fatalf("ContentID %q is not a ULID: %v", a, err)
+	}
+	if got := ulid.Time(id.Time()); !got.Equal(start.Truncate(time.Millisecond)) {
+		t.Errorf("ULID time = %v, want first turn %v", got, start)
+	}
+
+	// Capture details and sub-microsecond precision do not change the ID.
+	other := payload()
+	other.SessionID = "sess-other"
+	other.CapturedAt = time.Now().Add(time.Hour)
+	other.Turns[0].Timestamp = start.Add(500 * time.Nanosecond).In(time.FixedZone("CET", 3600))
+	if b := ContentID(other); b != a {
+		t.Errorf("same content: %s != %s", b, a)
+	}
+
+	// An empty source is the legacy default.
+	other = payload()
+	other.Source = ""
+	if b := ContentID(other); b != a {
+		t.Errorf("empty source: %s != %s", b, a)
+	}
+
+	for name, mutate := range map[string]func(p *SessionPayload){
+		"source":    func(p *SessionPayload) { p.Source = "codex" },
+		"content":   func(p *SessionPayload) { p.Turns[1].Content += "!" },
+		"role":      func(p *SessionPayload) { p.Turns[1].Role = "human" },
+		"timestamp": func(p *SessionPayload) { p.Turns[1].Timestamp = p.Turns[1].Timestamp.Add(time.Microsecond) },
+		"turn":      func(p *SessionPayload) { p.Turns = append(p.Turns, Turn{Role: "human", Content: "thanks"}) },
+		"tool path": func(p *SessionPayload) { p.ToolCalls[0].Path = "auth.go" },
+		"split": func(p *SessionPayload) {
+			p.Turns[0].Content, p.Turns[1].Content = "fix the auth bugL", "et me read login.go."
+		},
+	} {
+		p := payload()
+		mutate(p)
+		if b := ContentID(p); b == a {
+			t.Errorf("%s: changed content kept ID %s", name, a)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Rekal branch commits are signed the same way as any other commit: when
+// commit.gpgsign is set, commitWireFormat passes -S to commit-tree, which
+// uses user.signingkey and gpg.format (openpgp, ssh or x509). A commit
+// attests to every frame it added to rekal.body.
+//
+// Verification uses git's own machinery. SSH signatures are checked against
+// an allowed_signers file (see allowedSignersPath); OpenPGP/x509 against the
+// local keyring. A frame is verified when the commit that added it has a good
+// signature whose signer is the owner of the branch.
+
+const (
+	// allowedSignersConfigKey overrides the allowed_signers path.
+	allowedSignersConfigKey = "rekal.allowedSigners"
+	// allowedSignersFileName is the repo-root allowed_signers file, committed
+	// so the whole team verifies against the same keys.
+	allowedSignersFileName = ".rekal-allowed-signers"
+)
+
+// signingEnabled reports whether rekal branch commits should be signed.
+func signingEnabled() bool {
+	return strings.EqualFold(gitConfigValue("commit.gpgsign"), "true")
+}
+
+// allowedSignersPath returns the allowed_signers file used to verify SSH
+// signatures: rekal.allowedSigners (relative to the repo root), then
+// .rekal-allowed-signers in the repo root, then gpg.ssh.allowedSignersFile.
+// Empty if none is configured.
+func allowedSignersPath(gitRoot string) string {
+	if p := gitConfigValue(allowedSignersConfigKey); p != "" {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(gitRoot, p)
+		}
+		return p
+	}
+	if p := filepath.Join(gitRoot, allowedSignersFileName); fileExists(p) {
+		return p
+	}
+	return gitConfigValue("gpg.ssh.allowedSignersFile")
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// commitSignature is git's verdict on one rekal branch commit.
+type commitSignature struct {
+	SHA    string
+	Status string // git %G?: G good, B bad, U/X/Y/R good but untrusted/expired/revoked, E cannot check, N none
+	Signer string // git %GS
+}
+
+// branchSignatures holds per-frame verification for one rekal branch.
+type branchSignatures struct {
+	Owner    string
+	Commits  int
+	Signed   int // commits with any signature
+	Verified int // commits with a good signature from Owner
+	Bad      int // commits with a bad signature
+	frames   []bool
+}
+
+// FrameVerified reports whether frame i was added by a verified commit.
+func (b *branchSignatures) FrameVerified(i int) bool {
+	return b != nil && i < len(b.frames) && b.frames[i]
+}
+
+// branchOwner returns the email a rekal branch or ref belongs to, e.g.
+// "origin/rekal/alice@example.com" or
+// "refs/rekal-remotes/origin/alice@example.com" → "alice@example.com".
+func branchOwner(branch string) string {
+	if rest, ok := strings.CutPrefix(branch, customRemoteRefPrefix); ok {
+		_, email, _ := strings.Cut(rest, "/")
+		return email
+	}
+	if i := strings.Index(branch, "rekal/"); i >= 0 {
+		return branch[i+len("rekal/"):]
+	}
+	return branch
+}
+
+// signerMatches reports whether a git signer string identifies email. SSH
+// signers are allowed_signers principals; OpenPGP/x509 signers are user IDs
+// of the form "Name <email>".
+func signerMatches(signer, email string) bool {
+	signer = strings.ToLower(strings.TrimSpace(signer))
+	email = strings.ToLower(email)
+	return signer == email || strings.Contains(signer, "<"+email+">")
+}
+
+// readCommitSignatures returns the first-parent history of branch, oldest
+// first, with git's signature verdict for each commit. With since set, only
+// the commits after it.
+func readCommitSignatures(gitRoot, branch, since string) ([]commitSignature, error) {
+	args := []string{"-C", gitRoot}
+	if p := allowedSignersPath(gitRoot); p != "" {
+		args = append(args, "-c", "gpg.ssh.allowedSignersFile="+p)
+	}
+	rev := branch
+	if since != "" {
+		rev = since + ".." + branch
+	}
+	args = append(args, "log", "--first-parent", "--reverse", "--format=%H%x00%G?%x00%GS", rev, "--")
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("read signatures of %s: %w", branch, err)
+	}
+
+	var sigs []commitSignature
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		parts := strings.SplitN(line, "\x00", 3)
+		if len(parts) < 2 {
+			continue
+		}
+		sig := commitSignature{SHA: parts[0], Status: parts[1]}
+		if len(parts) == 3 {
+			sig.Signer = parts[2]
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
+
+// verifyBranchSignatures attributes each frame of branch's rekal.body to the
+// commit that added it and records whether that commit is verified. With
+// since set — how far an earlier import got — only the commits after
+// since.Commit are checked and counted, and the since.Frames frames before
+// them are left unverified: the caller has already imported them.
+func verifyBranchSignatures(gitRoot, branch string, since branchWatermark) (*branchSignatures, error) {
+	sigs, err := readCommitSignatures(gitRoot, branch, since.Commit)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := openGitObjects(gitRoot)
+	if err != nil {
+		return nil, err
+	}
+	defer objs.Close()
+
+	b := &branchSignatures{Owner: branchOwner(branch), Commits: len(sigs), frames: make([]bool, since.Frames)}
+	prev := since.Commit
+	for _, sig := range sigs {
+		verified := sig.Status == "G" && signerMatches(sig.Signer, b.Owner)
+		switch {
+		case verified:
+			b.Verified++
+			b.Signed++
+		case sig.Status == "B":
+			b.Bad++
+			b.Signed++
+		case sig.Status != "N":
+			b.Signed++
+		}
+
+		n := objs.frameCount(sig.SHA)
+		// Frames beyond what earlier commits held were added by this one.
+		// A commit that did more than append — shrank the body, or changed
+		// frames already there, even keeping their count — rewrote history;
+		// every frame is re-attributed to it.
+		if n < len(b.frames) || (prev != "" && !bodyKept(gitRoot, objs, prev, sig.SHA)) {
+			b.frames = b.frames[:0]
+		}
+		for len(b.frames) < n {
+			b.frames = append(b.frames, verified)
+		}
+		prev = sig.SHA
+	}
+	return b, nil
+}
+
+// bodyKept reports whether the logical body on ref starts with the whole
+// body on base, byte for byte. Segmented branches compare segment entries
+// without reading the segments.
+func bodyKept(gitRoot string, objs *gitObjects, base, ref string) bool {
+	old, err := objs.manifest(base)
+	if err != nil {
+		return false
+	}
+	m, err := objs.manifest(ref)
+	if err != nil {
+		return false
+	}
+	if old != nil && m != nil {
+		return segmentsKept(gitRoot, base, ref, old, m)
+	}
+	baseBody, err := objs.body(base)
+	if err != nil {
+		return false
+	}
+	body, err := objs.body(ref)
+	if err != nil {
+		return false
+	}
+	return bytes.HasPrefix(body, baseBody)
+}
+
+// reportBranchSignatures prints a one-line signature summary for branch when
+// any of its commits are signed, and warns on bad signatures. Unsigned
+// branches print nothing — signing is opt-in.
+func reportBranchSignatures(w io.Writer, branch string, b *branchSignatures) {
+	if b == nil || b.Signed == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s: %d of %d commit(s) signed by %s\n", branch, b.Verified, b.Commits, b.Owner)
+	if b.Bad > 0 {
+		fmt.Fprintf(w, "rekal: warning: %s has %d commit(s) with a bad signature\n", branch, b.Bad)
+	}
+}
@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+)
+
+func TestBranchOwner(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"rekal/alice@example.com":                     "alice@example.com",
+		"origin/rekal/alice@example.com":              "alice@example.com",
+		"upstream/rekal/bob.smith@example.org":        "bob.smith@example.org",
+		"refs/rekal/alice@example.com":                "alice@example.com",
+		"refs/rekal-remotes/origin/carol@example.com": "carol@example.com",
+	}
+	for branch, want := range tests {
+		if got := branchOwner(branch); got != want {
+			t.Errorf("branchOwner(%q) = %q, want %q", branch, got, want)
+		}
+	}
+}
+
+func TestSignerMatches(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		signer, email string
+		want          bool
+	}{
+		{"alice@example.com", "alice@example.com", true},         // ssh principal
+		{"Alice@Example.com", "alice@example.com", true},         // case-insensitive
+		{"Alice <alice@example.com>", "alice@example.com", true}, // openpgp uid
+		{"evilalice@example.com", "alice@example.com", false},
+		{"Mallory <mallory@example.com>", "alice@example.com", false},
+		{"", "alice@example.com", false},
+	}
+	for _, tt := range tests {
+		if got := signerMatches(tt.signer, tt.email); got != tt.want {
+			t.Errorf("signerMatches(%q, %q) = %v, want %v", tt.signer, tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestBranchSignatures_FrameVerified(t *testing.T) {
+	t.Parallel()
+
+	var none *branchSignatures
+	if none.FrameVerified(0) {
+		t.Error("nil signatures should verify nothing")
+	}
+	b := &branchSignatures{frames: []bool{true, true, false}}
+	for i, want := range []bool{true, true, false, false} {
+		if got := b.FrameVerified(i); got != want {
+			t.Errorf("FrameVerified(%d) = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestVerifyBranchSignatures_Since(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	git := func(stdin []byte, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir,
+			"-c", "user.name=Alice", "-c", "user.email=alice@example.com"}, args...)...)
+		if stdin != nil {
+			cmd.Stdin = strings.NewReader(string(stdin))
+		}
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("git %v: %v", args, err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git(nil, "init", "-q")
+
+	enc, err := codec.NewEncoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+	body := codec.NewBody()
+	var commits []string
+	for i := range 3 {
+		sf := &codec.SessionFrame{SessionRef: uint64(i), CapturedAt: time.Date(2026, 3, 1, 9, i, 0, 0, time.UTC)}
+		body = codec.AppendFrame(body, enc.EncodeSessionFrame(sf))
+		blob := git(body, "hash-object", "-w", "--stdin")
+		tree := git([]byte("100644 blob "+blob+"\trekal.body\n"), "mktree")
+		args := []string{"commit-tree", tree, "-m", "rekal: checkpoint"}
+		if len(commits) > 0 {
+			args = append(args, "-p", commits[len(commits)-1])
+		}
+		commits = append(commits, git(nil, args...))
+	}
+	git(nil, "update-ref", "refs/heads/rekal/alice@example.com", commits[2])
+
+	b, err := verifyBranchSignatures(dir, "rekal/alice@example.com", branchWatermark{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Commits != 3 || len(b.frames) != 3 {
+		t.Errorf("full walk: %d commits, %d frames; want 3, 3", b.Commits, len(b.frames))
+	}
+
+	// From a watermark only the later commits are read.
+	b, err = verifyBranchSignatures(dir, "rekal/alice@example.com", branchWatermark{Commit: commits[1], Frames: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Commits != 1 || len(b.frames) != 3 {
+		t.Errorf("since watermark: %d commits, %d frames; want 1, 3", b.Commits, len(b.frames))
+	}
+}
+
+func TestVerifyBranchSignatures_RewriteInPlace(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	key := filepath.Join(t.TempDir(), "id_ed25519")
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", "alice", "-f", key).CombinedOutput(); err != nil {
+		t.Skipf("ssh-keygen unavailable: %v: %s", err, out)
+	}
+	git := func(stdin []byte, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir,
+			"-c", "user.name=Alice", "-c", "user.email=alice@example.com",
+			"-c", "gpg.format=ssh", "-c", "user.signingkey=" + key + ".pub"}, args...)...)
+		if stdin != nil {
+			cmd.Stdin = strings.NewReader(string(stdin))
+		}
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("git %v: %v", args, err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git(nil, "init", "-q")
+	pub, err := os.ReadFile(key + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowed := "alice@example.com " + strings.TrimSpace(string(pub)) + "\n"
+	if err := os.WriteFile(filepath.Join(dir, allowedSignersFileName), []byte(allowed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := codec.NewEncoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+	bodyOf := func(refs ...uint64) []byte {
+		body := codec.NewBody()
+		for _, r := range refs {
+			sf := &codec.SessionFrame{SessionRef: r, CapturedAt: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)}
+			body = codec.AppendFrame(body, enc.EncodeSessionFrame(sf))
+		}
+		return body
+	}
+	commit := func(body []byte, parent string, sign bool) string {
+		blob := git(body, "hash-object", "-w", "--stdin")
+		tree := git([]byte("100644 blob "+blob+"\trekal.body\n"), "mktree")
+		args := []string{"commit-tree", tree, "-m", "rekal: checkpoint"}
+		if parent != "" {
+			args = append(args, "-p", parent)
+		}
+		if sign {
+			args = append(args, "-S")
+		}
+		return git(nil, args...)
+	}
+
+	// A signed commit adds two frames; an unsigned one then replaces the
+	// first in place, keeping the frame count.
+	signed := commit(bodyOf(1, 2), "", true)
+	forged := commit(bodyOf(9, 2), signed, false)
+	git(nil, "update-ref", "refs/heads/rekal/alice@example.com", forged)
+
+	b, err := verifyBranchSignatures(dir, "rekal/alice@example.com", branchWatermark{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Verified != 1 || len(b.frames) != 2 {
+		t.Fatalf("%d verified commits, %d frames; want 1, 2", b.Verified, len(b.frames))
+	}
+	for i := range 2 {
+		if b.FrameVerified(i) {
+			t.Errorf("frame %d verified after an unsigned rewrite", i)
+		}
+	}
+}
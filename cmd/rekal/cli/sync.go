@@ -1,10 +1,13 @@
 package cli
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
-	"os/exec"
+	"io"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/lsa"
@@ -12,7 +15,9 @@ import (
 )
 
 func newSyncCmd() *cobra.Command {
-	var selfOnly bool
+	var selfOnly, full, list bool
+	var remotes, authors, branches []string
+	var since, bundle string
 
 	cmd := &cobra.Command{
 		Use:   "sync",
@@ -33,7 +38,36 @@ only your own rekal branch — useful when syncing across your own machines
 Typical usage:
   Developer:  Run 'rekal sync' at the start of the day
   Agent:      Run 'rekal sync' at the start of a session if team context matters
-  Ad-hoc:     Run 'rekal sync --self' to pull your own data from another machine`,
+  Ad-hoc:     Run 'rekal sync --self' to pull your own data from another machine
+
+Branches are fetched from origin unless rekal.fetchRemote (repeatable) or
+rekal.remote names other remotes; --remote overrides both and can be given
+more than once. Branches from every fetch remote are merged into one index,
+and a branch mirrored on several remotes is imported once. Your own data is
+pushed to rekal.pushRemote (see 'rekal push --help').
+
+Team sync keeps a watermark per remote branch in the index and only decodes
+the frames added since the last sync, embedding just the new sessions. It
+rebuilds the index instead when --full is given, the index was rebuilt by
+'rekal index' or 'rekal sync --self', local sessions are missing from it, or a
+branch imported before was deleted or rewritten.
+
+Team sync can be scoped. --author keeps the sessions of teammates whose email
+matches a glob, --branch the sessions captured on matching code branches, and
+--since those captured after a date or within a window (30d, 2w, 36h). A
+committed .rekal-team file, one email glob per line, limits every team sync
+to the teammates it lists. Changing the scope rebuilds the index. Use --list
+to see the teammate branches on each remote, with the last update time and
+size of the local copy, before fetching anything.
+
+The refs/notes/rekal notes ref is fetched from each remote and merged into
+the local one, so 'git log --notes=rekal' shows the team's checkpoints.
+
+With --from-bundle, nothing is pushed or fetched over the network: the git
+bundle written by 'rekal bundle create' is verified and read like a remote
+named "bundle". Your own data in it is imported into the local database as
+with --self, the team's into the index as with team sync; later syncs keep
+the team's bundled data in the index.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			cmd.SilenceUsage = true
 
@@ -47,21 +81,69 @@ Typical usage:
 				return NewSilentError(err)
 			}
 
-			if selfOnly {
-				return runSyncSelf(cmd, gitRoot)
+			if selfOnly && (len(authors) > 0 || len(branches) > 0 || since != "") {
+				err := errors.New("rekal: --author, --branch and --since scope team sync and cannot be used with --self")
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			scope, err := loadSyncScope(gitRoot, authors, branches, since, time.Now())
+			if err != nil {
+				err = fmt.Errorf("rekal: %w", err)
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			if bundle != "" && (selfOnly || list) {
+				err := errors.New("rekal: --from-bundle cannot be used with --self or --list")
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			if list {
+				if err := doSyncList(gitRoot, cmd.OutOrStdout(), fetchRemotes(remotes), scope); err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "rekal: %v\n", err)
+					return NewSilentError(err)
+				}
+				return nil
 			}
-			return runSyncTeam(cmd, gitRoot)
+
+			return runLocked(cmd, gitRoot, func() error {
+				fetch := fetchRemotes(remotes)
+				if selfOnly {
+					return runSyncSelf(cmd, gitRoot, fetch)
+				}
+				if bundle != "" {
+					return runSyncBundle(cmd, gitRoot, bundle, fetch, full, scope)
+				}
+				return runSyncTeam(cmd, gitRoot, fetch, full, scope)
+			})
 		},
 	}
 
 	cmd.Flags().BoolVar(&selfOnly, "self", false, "Only fetch your own rekal branch (not the whole team)")
+	cmd.Flags().BoolVar(&full, "full", false, "Rebuild the index from scratch instead of importing only new frames")
+	cmd.Flags().StringArrayVar(&remotes, "remote", nil, "Remote to fetch rekal branches from (repeatable; default: rekal.fetchRemote, rekal.remote or origin)")
+	cmd.Flags().StringArrayVar(&authors, "author", nil, "Only import teammates whose email matches this glob (repeatable)")
+	cmd.Flags().StringArrayVar(&branches, "branch", nil, "Only import sessions captured on code branches matching this glob (repeatable)")
+	cmd.Flags().StringVar(&since, "since", "", "Only import sessions captured after a date (2026-01-02) or within a window (30d)")
+	cmd.Flags().BoolVar(&list, "list", false, "List teammate rekal branches on the remotes without fetching")
+	cmd.Flags().StringVar(&bundle, "from-bundle", "", "Import rekal data from a git bundle file instead of fetching from remotes")
 
 	return cmd
 }
 
-// runSyncTeam checkpoints + pushes local data, fetches all remote rekal branches,
-// and rebuilds the index from local data.db plus decoded remote wire format.
-func runSyncTeam(cmd *cobra.Command, gitRoot string) error {
+// lastTeamSyncKey is the index_state key team sync sets. Its absence means
+// the index was built without remote data and team sync must rebuild it.
+const lastTeamSyncKey = "last_team_sync_at"
+
+// syncScopeKey is the index_state key holding the scope of the last team
+// sync.
+const syncScopeKey = "sync_scope"
+
+// runSyncTeam checkpoints + pushes local data, fetches all rekal branches
+// from remotes, and imports the frames added to them since the last sync
+// into the index, skipping sessions outside scope. With full, or when that
+// is not possible, it rebuilds the index from local data.db plus decoded
+// remote wire format.
+func runSyncTeam(cmd *cobra.Command, gitRoot string, remotes []string, full bool, scope *syncScope) error {
 	w := cmd.ErrOrStderr()
 
 	// Step 1: Checkpoint (non-fatal).
@@ -70,23 +152,34 @@ func runSyncTeam(cmd *cobra.Command, gitRoot string) error {
 	}
 
 	// Step 2: Push (non-fatal).
-	if err := doPush(gitRoot, w, false); err != nil {
+	if err := doPush(gitRoot, w, pushRemote(""), false); err != nil {
 		fmt.Fprintf(w, "rekal: warning: push failed: %v\n", err)
 	}
 
 	// Step 3: Fetch remote rekal refs (non-fatal).
 	fmt.Fprintln(w, "fetching remote rekal branches...")
-	if err := fetchRemoteRekalRefs(gitRoot); err != nil {
+	if err := fetchRemoteRekalRefs(gitRoot, remotes); err != nil {
 		fmt.Fprintf(w, "rekal: warning: fetch failed: %v\n", err)
 	}
+	syncRekalNotes(gitRoot, w, remotes)
+
+	return updateTeamIndex(gitRoot, w, remotes, full, scope)
+}
 
-	// Step 4: List remote branches (excluding self).
-	remoteBranches, err := listRemoteRekalBranches(gitRoot)
+// updateTeamIndex imports the fetched rekal branches of remotes, and those
+// last fetched from a bundle, into the index — incrementally from their
+// watermarks unless full is set or a rebuild is needed — and prints the
+// sync summary on w.
+func updateTeamIndex(gitRoot string, w io.Writer, remotes []string, full bool, scope *syncScope) error {
+	// Step 4: List remote branches (excluding self). Bundle copies are
+	// listed last, so a remote holding the same head wins.
+	remoteBranches, err := listRemoteRekalBranches(gitRoot, append(append([]string{}, remotes...), bundleRemote))
 	if err != nil {
 		fmt.Fprintf(w, "rekal: warning: listing remote branches failed: %v\n", err)
 	}
 
-	// Step 5: Rebuild index.
+	// Step 5: Update the index — import only new frames when the last team
+	// sync left watermarks, rebuild it otherwise.
 	indexDB, err := db.OpenIndex(gitRoot)
 	if err != nil {
 		return fmt.Errorf("open index db: %w", err)
@@ -97,41 +190,80 @@ func runSyncTeam(cmd *cobra.Command, gitRoot string) error {
 		return fmt.Errorf("load fts extension: %w", err)
 	}
 
-	// Clean slate.
-	if err := db.DropIndexTables(indexDB); err != nil {
-		return fmt.Errorf("drop index tables: %w", err)
-	}
-	if err := db.InitIndexSchema(indexDB); err != nil {
-		return fmt.Errorf("create index schema: %w", err)
+	aliases, err := loadEmailAliases(gitRoot)
+	if err != nil {
+		return fmt.Errorf("read mailmap: %w", err)
 	}
 
-	// 5a: Populate from local data.db.
-	fmt.Fprintln(w, "indexing local data...")
-	if err := db.PopulateIndex(indexDB, gitRoot); err != nil {
-		return fmt.Errorf("populate index: %w", err)
+	rebuild := full
+	if !rebuild {
+		reason, needed, err := fullSyncReason(gitRoot, indexDB, remoteBranches, scope, aliases)
+		if err != nil {
+			fmt.Fprintf(w, "rekal: warning: %v\n", err)
+			needed = true
+		}
+		if reason != "" {
+			fmt.Fprintf(w, "rekal: rebuilding the index: %s\n", reason)
+		}
+		rebuild = needed
 	}
 
-	// Count local sessions.
 	var localSessions int
-	if err := indexDB.QueryRow("SELECT count(*) FROM session_facets").Scan(&localSessions); err != nil {
-		return fmt.Errorf("count local sessions: %w", err)
+	if rebuild {
+		// Clean slate.
+		if err := db.DropIndexTables(indexDB); err != nil {
+			return fmt.Errorf("drop index tables: %w", err)
+		}
+		if err := db.InitIndexSchema(indexDB); err != nil {
+			return fmt.Errorf("create index schema: %w", err)
+		}
+
+		// 5a: Populate from local data.db.
+		fmt.Fprintln(w, "indexing local data...")
+		if err := db.PopulateIndex(indexDB, gitRoot); err != nil {
+			return fmt.Errorf("populate index: %w", err)
+		}
+		if err := indexDB.QueryRow("SELECT count(*) FROM session_facets").Scan(&localSessions); err != nil {
+			return fmt.Errorf("count local sessions: %w", err)
+		}
+	} else {
+		// Checkpoint keeps local sessions indexed as they are captured.
+		fmt.Fprintln(w, "updating index with new remote frames...")
+		if localSessions, _, err = db.CountLocalSessions(indexDB, gitRoot); err != nil {
+			return err
+		}
+	}
+	before, err := indexedSessionIDs(indexDB)
+	if err != nil {
+		return err
 	}
 
-	// 5b: Import each remote branch into index.
+	// 5b: Import each remote branch into index, from its watermark on.
 	var remoteSessions int
-	teamMembers := 0
+	teamMembers := make(map[string]bool)
 	for _, branch := range remoteBranches {
+		if !scope.matchAuthor(branchOwner(branch)) {
+			continue
+		}
+		if mark, found, _ := readBranchWatermark(indexDB, branch); found {
+			if head, err := gitRevParse(gitRoot, branch); err == nil && head == mark.Commit {
+				continue
+			}
+		}
 		fmt.Fprintf(w, "importing %s...\n", branch)
-		n, err := importBranchToIndex(gitRoot, indexDB, branch)
+		n, err := importBranchToIndex(gitRoot, indexDB, branch, scope, w)
 		if err != nil {
 			fmt.Fprintf(w, "rekal: warning: import %s failed: %v\n", branch, err)
 			continue
 		}
 		if n > 0 {
 			remoteSessions += n
-			teamMembers++
+			teamMembers[aliases.resolve(branchOwner(branch))] = true
 		}
 	}
+	if err := db.ApplyEmailAliases(indexDB, aliases); err != nil {
+		return err
+	}
 
 	// Count totals.
 	var sessionCount, turnCount int
@@ -142,35 +274,71 @@ func runSyncTeam(cmd *cobra.Command, gitRoot string) error {
 		return fmt.Errorf("count turns: %w", err)
 	}
 
-	// 5c: Create FTS index.
-	if turnCount > 0 {
+	// 5c: Create FTS index. An incremental sync only re-runs it when new
+	// turns arrived.
+	if turnCount > 0 && (rebuild || remoteSessions > 0) {
 		fmt.Fprintln(w, "creating full-text search index...")
 		if err := db.CreateFTSIndex(indexDB); err != nil {
 			return fmt.Errorf("create fts index: %w", err)
 		}
 	}
 
-	// 5d: LSA pass.
-	embeddingDim := 0
-	if sessionCount >= 2 {
-		fmt.Fprintln(w, "building LSA embeddings...")
-		sessionContent, err := db.QuerySessionContent(indexDB)
-		if err != nil {
-			return fmt.Errorf("query session content: %w", err)
+	if rebuild {
+		// 5d: LSA pass.
+		embeddingDim := 0
+		if sessionCount >= 2 {
+			fmt.Fprintln(w, "building LSA embeddings...")
+			sessionContent, err := db.QuerySessionContent(indexDB)
+			if err != nil {
+				return fmt.Errorf("query session content: %w", err)
+			}
+
+			if embeddingDim, err = storeLSAEmbeddings(indexDB, sessionContent, w); err != nil {
+				return err
+			}
+
+			// 5d-ii: Nomic pass (non-fatal).
+			if err := buildNomicEmbeddings(indexDB, sessionContent, w, gitRoot); err != nil {
+				fmt.Fprintf(w, "warning: nomic embeddings skipped: %v\n", err)
+			}
+		}
+		if err := db.WriteIndexState(indexDB, "embedding_dim", strconv.Itoa(embeddingDim)); err != nil {
+			return err
+		}
+	} else if remoteSessions > 0 {
+		// 5d: LSA vectors come from one model over the whole corpus — the
+		// model recall rebuilds to project queries — so they are all
+		// rebuilt; it costs what a single recall does.
+		embeddingDim := 0
+		if sessionCount >= 2 {
+			fmt.Fprintln(w, "building LSA embeddings...")
+			sessionContent, err := db.QuerySessionContent(indexDB)
+			if err != nil {
+				return fmt.Errorf("query session content: %w", err)
+			}
+			if embeddingDim, err = storeLSAEmbeddings(indexDB, sessionContent, w); err != nil {
+				return err
+			}
+		}
+		if err := db.WriteIndexState(indexDB, "embedding_dim", strconv.Itoa(embeddingDim)); err != nil {
+			return err
 		}
 
-		model, err := lsa.Build(sessionContent, lsa.DefaultDimension)
+		// 5d-ii: Like checkpoint, embed only the new sessions with nomic.
+		var newIDs []string
+		after, err := indexedSessionIDs(indexDB)
 		if err != nil {
-			fmt.Fprintf(w, "warning: LSA build failed: %v\n", err)
-		} else if model != nil {
-			vectors := model.Vectors()
-			if err := db.StoreEmbeddings(indexDB, vectors, "lsa-v1"); err != nil {
-				return fmt.Errorf("store embeddings: %w", err)
+			return err
+		}
+		for id := range after {
+			if !before[id] {
+				newIDs = append(newIDs, id)
 			}
-			embeddingDim = model.Dim
 		}
-
-		// 5d-ii: Nomic pass (non-fatal).
+		sessionContent, err := db.QuerySessionContentByIDs(indexDB, newIDs)
+		if err != nil {
+			return err
+		}
 		if err := buildNomicEmbeddings(indexDB, sessionContent, w, gitRoot); err != nil {
 			fmt.Fprintf(w, "warning: nomic embeddings skipped: %v\n", err)
 		}
@@ -183,54 +351,102 @@ func runSyncTeam(cmd *cobra.Command, gitRoot string) error {
 	if err := db.WriteIndexState(indexDB, "turn_count", strconv.Itoa(turnCount)); err != nil {
 		return err
 	}
-	if err := db.WriteIndexState(indexDB, "embedding_dim", strconv.Itoa(embeddingDim)); err != nil {
+	if err := db.WriteIndexState(indexDB, "last_indexed_at", "now"); err != nil {
 		return err
 	}
-	if err := db.WriteIndexState(indexDB, "last_indexed_at", "now"); err != nil {
+	if err := db.WriteIndexState(indexDB, lastTeamSyncKey, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	if err := db.WriteIndexState(indexDB, syncScopeKey, scope.String()); err != nil {
+		return err
+	}
+	if err := db.WriteIndexState(indexDB, emailAliasesKey, aliases.String()); err != nil {
 		return err
 	}
 
 	// Step 6: Summary.
 	fmt.Fprintf(w, "rekal: synced — %d local sessions", localSessions)
 	if remoteSessions > 0 {
-		fmt.Fprintf(w, ", %d remote sessions from %d team member(s)", remoteSessions, teamMembers)
+		adj := ""
+		if !rebuild {
+			adj = "new "
+		}
+		fmt.Fprintf(w, ", %d %sremote sessions from %d team member(s)", remoteSessions, adj, len(teamMembers))
 	}
 	fmt.Fprintln(w)
 
 	return nil
 }
 
-// runSyncSelf fetches the current user's remote branch, imports into data.db,
-// and performs a full index rebuild.
-func runSyncSelf(cmd *cobra.Command, gitRoot string) error {
+// storeLSAEmbeddings builds the LSA model of sessionContent and replaces
+// the stored LSA vectors with its own. Returns the model's dimension, or 0
+// when there was nothing to build. A failed build only warns.
+func storeLSAEmbeddings(indexDB *sql.DB, sessionContent map[string]string, w io.Writer) (int, error) {
+	model, err := lsa.Build(sessionContent, lsa.DefaultDimension)
+	if err != nil {
+		fmt.Fprintf(w, "warning: LSA build failed: %v\n", err)
+		return 0, nil
+	}
+	if err := db.DeleteEmbeddings(indexDB, "lsa-v1"); err != nil {
+		return 0, err
+	}
+	if model == nil {
+		return 0, nil
+	}
+	if err := db.StoreEmbeddings(indexDB, model.Vectors(), "lsa-v1"); err != nil {
+		return 0, fmt.Errorf("store embeddings: %w", err)
+	}
+	return model.Dim, nil
+}
+
+// runSyncSelf fetches the current user's branch from each remote, imports
+// into data.db, and performs a full index rebuild.
+func runSyncSelf(cmd *cobra.Command, gitRoot string, remotes []string) error {
 	w := cmd.ErrOrStderr()
-	branch := rekalBranchName()
 
-	// Step 1: Fetch own remote branch.
+	// Step 1: Fetch own branch from each remote.
 	fmt.Fprintln(w, "fetching your remote branch...")
-	if err := exec.Command("git", "-C", gitRoot, "remote", "get-url", "origin").Run(); err != nil {
-		return fmt.Errorf("no remote 'origin' configured")
+	var fetched []string
+	var failures []string
+	for _, remote := range remotes {
+		if !remoteExists(gitRoot, remote) {
+			failures = append(failures, fmt.Sprintf("no remote '%s' configured", remote))
+			continue
+		}
+		if err := fetchOwnRekalRef(gitRoot, remote); err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		fetched = append(fetched, remoteRekalRef(remote))
 	}
-
-	fetchCmd := exec.Command("git", "-C", gitRoot, "fetch", "origin", branch)
-	fetchCmd.Stdin = nil
-	if output, err := fetchCmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("fetch origin/%s failed: %s", branch, strings.TrimSpace(string(output)))
+	if len(fetched) == 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	for _, f := range failures {
+		fmt.Fprintf(w, "rekal: warning: %s\n", f)
 	}
+	syncRekalNotes(gitRoot, w, remotes)
 
-	// Step 2: Import from remote branch into data.db.
-	remoteBranch := "origin/" + branch
+	// Step 2: Import from each remote branch into data.db. Sessions and
+	// checkpoints already imported from another remote are skipped.
 	dataDB, err := db.OpenData(gitRoot)
 	if err != nil {
 		return fmt.Errorf("open data db: %w", err)
 	}
+	if err := db.MigrateDataSchema(dataDB, dataMigrateContext(gitRoot)); err != nil {
+		dataDB.Close()
+		return fmt.Errorf("migrate data db: %w", err)
+	}
 
-	n, err := importBranch(gitRoot, dataDB, remoteBranch)
-	dataDB.Close()
-	if err != nil {
-		return fmt.Errorf("import from %s: %w", remoteBranch, err)
+	for _, remoteBranch := range fetched {
+		n, err := importBranch(gitRoot, dataDB, remoteBranch, w)
+		if err != nil {
+			dataDB.Close()
+			return fmt.Errorf("import from %s: %w", remoteBranch, err)
+		}
+		fmt.Fprintf(w, "rekal: imported %d session(s) from %s\n", n, remoteBranch)
 	}
-	fmt.Fprintf(w, "rekal: imported %d session(s) from %s\n", n, remoteBranch)
+	dataDB.Close()
 
 	// Step 3: Full index rebuild.
 	return runIndex(cmd, gitRoot)
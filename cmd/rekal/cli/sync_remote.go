@@ -2,66 +2,112 @@ package cli
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"os/exec"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/oklog/ulid/v2"
 	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
 )
 
-// fetchRemoteRekalRefs fetches all rekal/* branches from origin.
-// Non-fatal: returns nil if no remote or fetch fails.
-func fetchRemoteRekalRefs(gitRoot string) error {
-	// Check if remote is configured.
-	if err := exec.Command("git", "-C", gitRoot, "remote", "get-url", "origin").Run(); err != nil {
-		return nil // no remote configured
+// fetchRemoteRekalRefs fetches every user's rekal data from each remote, in
+// both layouts: rekal/* branches into refs/remotes/<remote>/rekal/* and
+// refs/rekal/* into refs/rekal-remotes/<remote>/*. Non-fatal: remotes that
+// are not configured or cannot be fetched are skipped.
+func fetchRemoteRekalRefs(gitRoot string, remotes []string) error {
+	for _, remote := range remotes {
+		if !remoteExists(gitRoot, remote) {
+			continue
+		}
+		args := append([]string{"-C", gitRoot, "fetch", remote}, rekalFetchRefspecs(remote)...)
+		cmd := exec.Command("git", args...)
+		cmd.Stdin = nil
+		_ = cmd.Run() // non-fatal
 	}
-
-	cmd := exec.Command("git", "-C", gitRoot, "fetch", "origin", "refs/heads/rekal/*:refs/remotes/origin/rekal/*")
-	cmd.Stdin = nil
-	_ = cmd.Run() // non-fatal
 	return nil
 }
 
-// listRemoteRekalBranches returns remote rekal branch refs, excluding the current user's branch.
-func listRemoteRekalBranches(gitRoot string) ([]string, error) {
-	out, err := exec.Command("git", "-C", gitRoot,
-		"for-each-ref", "--format=%(refname:short)", "refs/remotes/origin/rekal/",
-	).Output()
-	if err != nil {
-		return nil, nil // no remote refs
-	}
-
-	selfBranch := "origin/" + rekalBranchName()
-
+// listRemoteRekalBranches returns the fetched rekal refs of remotes in both
+// layouts, excluding the current user's. A user's data mirrored on several
+// remotes at the same commit is listed once, from the first remote; if the
+// copies differ, each is listed and the import skips the sessions it has
+// already seen.
+func listRemoteRekalBranches(gitRoot string, remotes []string) ([]string, error) {
+	self := rekalEmail()
+	seen := make(map[string]bool) // owner + "\x00" + head
 	var branches []string
-	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		if line == selfBranch {
-			continue
+	for _, remote := range remotes {
+		for _, ref := range listRekalRefs(gitRoot, "refs/remotes/"+remote+"/rekal/", customRemoteRefPrefix+remote+"/") {
+			owner := branchOwner(ref)
+			if owner == self {
+				continue
+			}
+			head, err := gitRevParse(gitRoot, ref)
+			if err != nil {
+				continue
+			}
+			key := owner + "\x00" + head
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			branches = append(branches, ref)
 		}
-		branches = append(branches, line)
 	}
 	return branches, nil
 }
 
 // importBranchToIndex decodes wire format from a remote branch and inserts
-// sessions and checkpoints directly into the index DB tables.
-// Tool calls are skipped for remote data.
-// Returns the number of sessions imported.
-func importBranchToIndex(gitRoot string, indexDB *sql.DB, remoteBranch string) (int, error) {
-	bodyData := gitShowFile(gitRoot, remoteBranch, "rekal.body")
-	if len(bodyData) <= 9 {
+// sessions, tool calls and checkpoints directly into the index DB tables,
+// merging the branch's file co-occurrence into file_cooccurrence.
+// Returns the number of sessions imported. Sessions outside scope are
+// skipped. Warns on w if the branch's history was rewritten since it was
+// last imported. Each session is marked verified if the commit that added it
+// was signed by the branch owner. From a watermark, only the segments and
+// commits after it are read.
+func importBranchToIndex(gitRoot string, indexDB *sql.DB, remoteBranch string, scope *syncScope, w io.Writer) (int, error) {
+	head, err := gitRevParse(gitRoot, remoteBranch)
+	if err != nil {
+		return 0, err
+	}
+	mark, _, err := readBranchWatermark(indexDB, remoteBranch)
+	if err != nil || mark.Commit == head {
+		return 0, err
+	}
+
+	// The tail is only trusted when imported_heads agrees with the
+	// watermark; the history check then continues its chain.
+	since := branchWatermark{}
+	prev, found, err := db.QueryImportedHead(indexDB, remoteBranch)
+	if err != nil {
+		return 0, err
+	}
+	if found && prev.NFrames == mark.Frames {
+		since = mark
+	}
+	bodyData, first, err := readBranchTail(gitRoot, remoteBranch, since)
+	if err != nil {
+		return 0, fmt.Errorf("read body: %w", err)
+	}
+	if len(bodyData) <= codec.BodyHeaderSize {
 		return 0, nil
 	}
 
-	dictData := gitShowFile(gitRoot, remoteBranch, "dict.bin")
+	dictData, err := readBranchDictData(gitRoot, remoteBranch)
+	if errors.Is(err, codec.ErrNoKey) {
+		fmt.Fprintf(w, "rekal: skipping %s: encrypted for %s, and no local identity is a recipient\n", remoteBranch, recipientsFile)
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
 	if len(dictData) == 0 {
 		return 0, nil
 	}
@@ -71,14 +117,32 @@ func importBranchToIndex(gitRoot string, indexDB *sql.DB, remoteBranch string) (
 		return 0, fmt.Errorf("load dict: %w", err)
 	}
 
-	frames, err := codec.ScanFrames(bodyData)
+	scan, err := codec.ScanBody(bodyData)
 	if err != nil {
 		return 0, fmt.Errorf("scan frames: %w", err)
 	}
+	// frames[i] is frame first+i of the branch.
+	frames := scan.Frames
+	total := first + len(frames)
+	if mark.Frames > total {
+		return 0, fmt.Errorf("%d frames were imported, %d are left — run 'rekal sync --full'", mark.Frames, total)
+	}
+	reportSkippedRanges(w, remoteBranch, scan.Skipped)
+	if first == 0 {
+		checkImportedHistory(indexDB, remoteBranch, bodyData, frames, w)
+	} else {
+		extendImportedHistory(indexDB, remoteBranch, prev, bodyData, frames[mark.Frames-first:], w)
+	}
 
-	dec, err := codec.NewDecoder()
+	sigs, err := verifyBranchSignatures(gitRoot, remoteBranch, mark)
 	if err != nil {
-		return 0, fmt.Errorf("create decoder: %w", err)
+		fmt.Fprintf(w, "rekal: warning: %v\n", err)
+	}
+	reportBranchSignatures(w, remoteBranch, sigs)
+
+	dec, err := newBranchDecoder(gitRoot, remoteBranch)
+	if err != nil {
+		return 0, err
 	}
 	defer dec.Close()
 
@@ -87,17 +151,38 @@ func importBranchToIndex(gitRoot string, indexDB *sql.DB, remoteBranch string) (
 		return ulid.MustNew(ulid.Timestamp(time.Now()), entropy).String()
 	}
 
-	// Track session → checkpoint mapping for updating facets.
-	type cpInfo struct {
-		checkpointID string
-		gitSHA       string
-		fileCount    int
+	// Facets are written once every frame has been read: checkpoint frames
+	// follow the sessions they reference and DuckDB cannot UPDATE a row it
+	// has indexed in the same connection without tripping its primary key.
+	type facet struct {
+		ws       *wireSession
+		verified bool
+		cpID     any // NULL until a checkpoint frame references the session
+		gitSHA   any
+		files    map[string]struct{}
+	}
+	var facets []*facet
+	facetBySession := make(map[string]*facet)
+	// The first session ID seen for each session and content ID, so a
+	// transcript pushed twice under different IDs is indexed once. Sessions
+	// already indexed — local ones, or a copy of this branch on another
+	// remote — are skipped.
+	indexed, err := indexedSessionIDs(indexDB)
+	if err != nil {
+		return 0, err
+	}
+	holder := make(map[string]string, len(indexed))
+	for id := range indexed {
+		holder[id] = id
 	}
-	sessionCheckpoints := make(map[string]*cpInfo)
+	cooccurrence := make(map[db.FilePair]int)
+	outOfScope := make(map[string]bool)
 
 	var imported int
 
-	for _, fs := range frames {
+	// Frames up to the watermark were imported by an earlier sync.
+	for fi := mark.Frames; fi < total; fi++ {
+		fs := frames[fi-first]
 		compressed := codec.ExtractFramePayload(bodyData, fs)
 
 		switch fs.Type {
@@ -107,50 +192,56 @@ func importBranchToIndex(gitRoot string, indexDB *sql.DB, remoteBranch string) (
 				continue
 			}
 
-			sessionID, err := dict.Get(codec.NSSessions, sf.SessionRef)
+			ws, err := resolveSessionFrame(dict, sf)
 			if err != nil {
 				continue
 			}
-
-			email, _ := dict.Get(codec.NSEmails, sf.EmailRef)
-			actorType := "human"
-			if sf.ActorType == codec.ActorAgent {
-				actorType = "agent"
+			sessionID := ws.ID
+			if !scope.matchSession(ws) {
+				outOfScope[sessionID] = true
+				continue
 			}
-
-			branch := ""
-			if len(sf.Turns) > 0 {
-				branch, _ = dict.Get(codec.NSBranches, sf.Turns[0].BranchRef)
+			cid := ws.ContentID()
+			if h, ok := holder[sessionID]; ok {
+				holder[cid] = h
+				continue
 			}
-
-			capturedAt := sf.CapturedAt.UTC().Format(time.RFC3339)
+			if h, ok := holder[cid]; ok {
+				holder[sessionID] = h
+				continue
+			}
+			holder[sessionID], holder[cid] = sessionID, sessionID
 
 			// Insert turns into turns_ft.
-			for i, t := range sf.Turns {
-				role := "human"
-				if t.Role == codec.RoleAssistant {
-					role = "assistant"
+			for i, t := range ws.Turns {
+				var ts any // NULL when unknown, as PopulateIndex writes it
+				if !t.Ts.IsZero() {
+					ts = t.Ts.UTC().Format(dbTimestampLayout)
 				}
 				if _, err := indexDB.Exec(
 					`INSERT INTO turns_ft (id, session_id, turn_index, role, content, ts)
 					 VALUES ($1, $2, $3, $4, $5, $6)`,
-					newID(), sessionID, i, role, t.Text, "",
+					newID(), sessionID, i, t.Role, t.Content, ts,
 				); err != nil {
 					return imported, fmt.Errorf("insert turn_ft: %w", err)
 				}
 			}
 
-			// Insert session_facets.
-			if _, err := indexDB.Exec(
-				`INSERT INTO session_facets (
-					session_id, user_email, git_branch, actor_type, agent_id,
-					captured_at, turn_count, tool_call_count, file_count
-				) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
-				sessionID, email, branch, actorType, "",
-				capturedAt, len(sf.Turns), 0, 0,
-			); err != nil {
-				return imported, fmt.Errorf("insert session_facet: %w", err)
+			f := &facet{ws: ws, verified: sigs.FrameVerified(fi), files: make(map[string]struct{})}
+			facets = append(facets, f)
+			facetBySession[sessionID] = f
+
+			// Insert tool_calls_index.
+			for _, tc := range ws.ToolCalls {
+				if _, err := indexDB.Exec(
+					`INSERT INTO tool_calls_index (id, session_id, call_order, tool, path, cmd_prefix)
+					 VALUES ($1, $2, $3, $4, $5, $6)`,
+					newID(), sessionID, tc.CallOrder, tc.Tool, tc.Path, tc.CmdPrefix,
+				); err != nil {
+					return imported, fmt.Errorf("insert tool_calls_index: %w", err)
+				}
 			}
+			countCooccurrence(ws.ToolCalls, cooccurrence)
 
 			imported++
 
@@ -166,11 +257,19 @@ func importBranchToIndex(gitRoot string, indexDB *sql.DB, remoteBranch string) (
 			}
 
 			// Insert files_index.
+			linked := make(map[string]bool)
 			for _, ref := range cf.SessionRefs {
 				sid, err := dict.Get(codec.NSSessions, ref)
 				if err != nil {
 					continue
 				}
+				if h, ok := holder[sid]; ok {
+					sid = h
+				}
+				if linked[sid] || indexed[sid] || outOfScope[sid] {
+					continue
+				}
+				linked[sid] = true
 				for _, f := range cf.Files {
 					filePath, _ := dict.Get(codec.NSPaths, f.PathRef)
 					changeType := string(f.ChangeType)
@@ -183,10 +282,18 @@ func importBranchToIndex(gitRoot string, indexDB *sql.DB, remoteBranch string) (
 					}
 				}
 
-				sessionCheckpoints[sid] = &cpInfo{
-					checkpointID: checkpointID,
-					gitSHA:       cf.GitSHA,
-					fileCount:    len(cf.Files),
+				// As in PopulateIndex, the checkpoint branch wins over the
+				// session branch and file_count counts distinct files across
+				// all of the session's checkpoints.
+				if f, ok := facetBySession[sid]; ok {
+					f.cpID, f.gitSHA = checkpointID, cf.GitSHA
+					if gitBranch, err := dict.Get(codec.NSBranches, cf.BranchRef); err == nil && gitBranch != "" {
+						f.ws.Branch = gitBranch
+					}
+					for _, file := range cf.Files {
+						filePath, _ := dict.Get(codec.NSPaths, file.PathRef)
+						f.files[filePath] = struct{}{}
+					}
 				}
 			}
 
@@ -195,17 +302,163 @@ func importBranchToIndex(gitRoot string, indexDB *sql.DB, remoteBranch string) (
 		}
 	}
 
-	// Update session_facets with checkpoint info.
-	for sid, cp := range sessionCheckpoints {
+	for _, f := range facets {
+		ws := f.ws
 		if _, err := indexDB.Exec(
-			`UPDATE session_facets SET checkpoint_id = $1, git_sha = $2, file_count = $3
-			 WHERE session_id = $4`,
-			cp.checkpointID, cp.gitSHA, cp.fileCount, sid,
+			`INSERT INTO session_facets (
+				session_id, user_email, git_branch, actor_type, agent_id,
+				captured_at, turn_count, tool_call_count, file_count,
+				checkpoint_id, git_sha, verified
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+			ws.ID, ws.Email, ws.Branch, ws.ActorType, ws.AgentID,
+			ws.CapturedAt.UTC().Format(time.RFC3339), len(ws.Turns), len(ws.ToolCalls), len(f.files),
+			f.cpID, f.gitSHA, f.verified,
 		); err != nil {
-			// Non-fatal: session may not have been imported (already existed).
-			continue
+			return imported, fmt.Errorf("insert session_facet: %w", err)
 		}
 	}
 
+	if err := db.MergeFileCooccurrence(indexDB, cooccurrence); err != nil {
+		return imported, err
+	}
+	if err := writeBranchWatermark(indexDB, remoteBranch, branchWatermark{Commit: head, Frames: total}); err != nil {
+		return imported, err
+	}
+
 	return imported, nil
 }
+
+// watermarkKeyPrefix prefixes the index_state keys that hold branch
+// watermarks. The watermarks go with the index: a rebuild drops them and
+// the next team sync imports every branch from the start.
+const watermarkKeyPrefix = "imported:"
+
+// branchWatermark is how far team sync has imported a remote branch into the
+// index: its head commit then and the number of frames in its body.
+type branchWatermark struct {
+	Commit string
+	Frames int
+}
+
+// readBranchWatermark returns the watermark of branch. found is false when
+// the branch was never imported into this index.
+func readBranchWatermark(indexDB *sql.DB, branch string) (mark branchWatermark, found bool, err error) {
+	v, found, err := db.ReadIndexState(indexDB, watermarkKeyPrefix+branch)
+	if err != nil || !found {
+		return mark, false, err
+	}
+	mark, err = parseBranchWatermark(v)
+	if err != nil {
+		return mark, false, fmt.Errorf("%s: %w", branch, err)
+	}
+	return mark, true, nil
+}
+
+// writeBranchWatermark records that branch is imported up to mark.
+func writeBranchWatermark(indexDB *sql.DB, branch string, mark branchWatermark) error {
+	return db.WriteIndexState(indexDB, watermarkKeyPrefix+branch, fmt.Sprintf("%s %d", mark.Commit, mark.Frames))
+}
+
+// parseBranchWatermark parses an index_state watermark value,
+// "<commit> <frames>".
+func parseBranchWatermark(v string) (branchWatermark, error) {
+	commit, frames, ok := strings.Cut(v, " ")
+	n, err := strconv.Atoi(frames)
+	if !ok || err != nil || n < 0 {
+		return branchWatermark{}, fmt.Errorf("malformed import watermark %q", v)
+	}
+	return branchWatermark{Commit: commit, Frames: n}, nil
+}
+
+// fullSyncReason reports why team sync must rebuild the index rather than
+// import new frames into it: the index was not built by a team sync, local
+// sessions are missing from it, or a branch imported before was deleted or
+// rewritten — the index cannot drop the sessions it took from there — or
+// scope or aliases differ from those the index was built with. Empty when an
+// incremental sync is safe. rebuild without a reason is a first sync.
+func fullSyncReason(gitRoot string, indexDB *sql.DB, branches []string, scope *syncScope, aliases emailAliases) (reason string, rebuild bool, err error) {
+	if _, found, err := db.ReadIndexState(indexDB, lastTeamSyncKey); err != nil || !found {
+		return "", true, err
+	}
+	if prev, _, err := db.ReadIndexState(indexDB, syncScopeKey); err != nil {
+		return "", false, err
+	} else if prev != scope.String() {
+		return "the sync scope changed", true, nil
+	}
+	// Without a key the index predates aliases; it has none applied.
+	if prev, _, err := db.ReadIndexState(indexDB, emailAliasesKey); err != nil {
+		return "", false, err
+	} else if prev != aliases.String() {
+		return "the mailmap changed", true, nil
+	}
+	_, unindexed, err := db.CountLocalSessions(indexDB, gitRoot)
+	if err != nil {
+		return "", false, err
+	}
+	if unindexed > 0 {
+		return fmt.Sprintf("%d local session(s) are not in the index", unindexed), true, nil
+	}
+
+	marks, err := db.IndexStateWithPrefix(indexDB, watermarkKeyPrefix)
+	if err != nil {
+		return "", false, err
+	}
+	current := make(map[string]bool, len(branches))
+	for _, b := range branches {
+		current[b] = true
+	}
+	keys := make([]string, 0, len(marks))
+	for k := range marks {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		branch := strings.TrimPrefix(k, watermarkKeyPrefix)
+		if !current[branch] {
+			return fmt.Sprintf("%s is gone", branch), true, nil
+		}
+		mark, err := parseBranchWatermark(marks[k])
+		if err != nil {
+			return err.Error(), true, nil
+		}
+		head, err := gitRevParse(gitRoot, branch)
+		if err != nil || !isAncestor(gitRoot, mark.Commit, head) {
+			return fmt.Sprintf("%s was rewritten", branch), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// indexedSessionIDs returns the session IDs already in session_facets.
+func indexedSessionIDs(indexDB *sql.DB) (map[string]bool, error) {
+	rows, err := indexDB.Query("SELECT session_id FROM session_facets")
+	if err != nil {
+		return nil, fmt.Errorf("query indexed sessions: %w", err)
+	}
+	defer rows.Close()
+	ids := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// countCooccurrence adds every pair of paths touched by one session's tool
+// calls to counts, the same pairs PopulateIndex derives for local sessions.
+func countCooccurrence(toolCalls []db.ToolCallRow, counts map[db.FilePair]int) {
+	for i, a := range toolCalls {
+		for _, b := range toolCalls[i+1:] {
+			switch {
+			case a.Path == "" || b.Path == "" || a.Path == b.Path:
+			case a.Path < b.Path:
+				counts[db.FilePair{A: a.Path, B: b.Path}]++
+			default:
+				counts[db.FilePair{A: b.Path, B: a.Path}]++
+			}
+		}
+	}
+}
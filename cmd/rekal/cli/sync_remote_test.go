@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+)
+
+func TestCountCooccurrence(t *testing.T) {
+	t.Parallel()
+	counts := make(map[db.FilePair]int)
+	countCooccurrence([]db.ToolCallRow{
+		{Tool: "Read", Path: "b.go"},
+		{Tool: "Edit", Path: "a.go"},
+		{Tool: "Bash"},
+		{Tool: "Edit", Path: "b.go"},
+	}, counts)
+
+	// Same pairs as the tool_calls self-join in PopulateIndex: every row
+	// pair with different, non-empty paths, ordered A < B.
+	want := map[db.FilePair]int{{A: "a.go", B: "b.go"}: 2}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("counts = %v, want %v", counts, want)
+	}
+}
+
+// writeRemoteBranch commits body and dict to ref in a fresh repo at dir.
+func writeRemoteBranch(t *testing.T, dir, ref string, body, dict []byte) {
+	t.Helper()
+	git := func(stdin []byte, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir,
+			"-c", "user.name=Alice", "-c", "user.email=alice@example.com"}, args...)...)
+		if stdin != nil {
+			cmd.Stdin = strings.NewReader(string(stdin))
+		}
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("git %v: %v", args, err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git(nil, "init", "-q")
+	bodyHash := git(body, "hash-object", "-w", "--stdin")
+	dictHash := git(dict, "hash-object", "-w", "--stdin")
+	tree := git([]byte("100644 blob "+dictHash+"\tdict.bin\n100644 blob "+bodyHash+"\trekal.body\n"), "mktree")
+	commit := git(nil, "commit-tree", tree, "-m", "rekal: checkpoint")
+	git(nil, "update-ref", ref, commit)
+}
+
+func TestImportBranchToIndex_ToolCallsAndFacets(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := codec.NewEncoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+
+	dict := codec.NewDict()
+	ref := func(ns codec.Namespace, s string) uint64 { return dict.LookupOrAdd(ns, s) }
+	toolCall := func(tool, path string) codec.ToolCallRecord {
+		code, toolRef := dict.EncodeTool(tool)
+		return codec.ToolCallRecord{Tool: code, ToolRef: toolRef, PathFlag: codec.PathDictRef, PathRef: ref(codec.NSPaths, path)}
+	}
+	sf := &codec.SessionFrame{
+		SessionRef: ref(codec.NSSessions, "01SESSION"),
+		CapturedAt: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+		EmailRef:   ref(codec.NSEmails, "alice@example.com"),
+		ActorType:  codec.ActorHuman,
+		Turns:      []codec.TurnRecord{{Role: codec.RoleHuman, Text: "fix the parser"}},
+		ToolCalls: []codec.ToolCallRecord{
+			toolCall("Read", "codec/frame.go"),
+			toolCall("Edit", "codec/frame.go"),
+			toolCall("Edit", "codec/body.go"),
+		},
+	}
+	cf := &codec.CheckpointFrame{
+		CheckpointRef: ref(codec.NSSessions, "01CHECKPOINT"),
+		GitSHA:        strings.Repeat("a", 40),
+		BranchRef:     ref(codec.NSBranches, "feature/parser"),
+		EmailRef:      ref(codec.NSEmails, "alice@example.com"),
+		Timestamp:     time.Date(2026, 3, 1, 9, 5, 0, 0, time.UTC),
+		ActorType:     codec.ActorHuman,
+		SessionRefs:   []uint64{sf.SessionRef},
+		Files: []codec.FileTouchedRecord{
+			{PathRef: ref(codec.NSPaths, "codec/frame.go"), ChangeType: 'M'},
+			{PathRef: ref(codec.NSPaths, "codec/body.go"), ChangeType: 'M'},
+		},
+	}
+	body := codec.AppendFrame(codec.NewBody(), enc.EncodeSessionFrame(sf))
+	body = codec.AppendFrame(body, enc.EncodeCheckpointFrame(cf))
+	writeRemoteBranch(t, dir, "refs/remotes/origin/rekal/alice", body, dict.Encode())
+
+	indexDB, err := db.OpenIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer indexDB.Close()
+	if err := db.InitIndexSchema(indexDB); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := importBranchToIndex(dir, indexDB, "origin/rekal/alice", nil, io.Discard)
+	if err != nil {
+		t.Fatalf("importBranchToIndex: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("imported %d sessions, want 1", n)
+	}
+
+	var tools string
+	if err := indexDB.QueryRow(
+		`SELECT string_agg(tool || ':' || path, ',' ORDER BY call_order) FROM tool_calls_index WHERE session_id = '01SESSION'`,
+	).Scan(&tools); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Read:codec/frame.go,Edit:codec/frame.go,Edit:codec/body.go"; tools != want {
+		t.Errorf("tool_calls_index = %q, want %q", tools, want)
+	}
+
+	var branch, cpID string
+	var toolCount, fileCount int
+	if err := indexDB.QueryRow(
+		`SELECT git_branch, checkpoint_id, tool_call_count, file_count FROM session_facets WHERE session_id = '01SESSION'`,
+	).Scan(&branch, &cpID, &toolCount, &fileCount); err != nil {
+		t.Fatal(err)
+	}
+	if branch != "feature/parser" || cpID != "01CHECKPOINT" || toolCount != 3 || fileCount != 2 {
+		t.Errorf("facets = (%q, %q, %d tool calls, %d files), want (feature/parser, 01CHECKPOINT, 3, 2)",
+			branch, cpID, toolCount, fileCount)
+	}
+
+	var count int
+	if err := indexDB.QueryRow(
+		`SELECT count FROM file_cooccurrence WHERE file_a = 'codec/body.go' AND file_b = 'codec/frame.go'`,
+	).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("file_cooccurrence count = %d, want 2", count)
+	}
+}
+
+func TestImportBranchToIndex_DedupsSessionsByContent(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	body, dict := duplicateSessionsBody(t, "01JNSESS000000000000000001", "01JNSESS000000000000000002")
+	writeRemoteBranch(t, dir, "refs/remotes/origin/rekal/alice", body, dict)
+
+	indexDB, err := db.OpenIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer indexDB.Close()
+	if err := db.InitIndexSchema(indexDB); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := importBranchToIndex(dir, indexDB, "origin/rekal/alice", nil, io.Discard)
+	if err != nil {
+		t.Fatalf("importBranchToIndex: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("imported %d sessions, want 1", n)
+	}
+	var facets, files int
+	if err := indexDB.QueryRow("SELECT count(*) FROM session_facets").Scan(&facets); err != nil || facets != 1 {
+		t.Errorf("session_facets = %d, %v; want 1", facets, err)
+	}
+	if err := indexDB.QueryRow("SELECT count(*) FROM files_index").Scan(&files); err != nil || files != 1 {
+		t.Errorf("files_index = %d, %v; want 1", files, err)
+	}
+}
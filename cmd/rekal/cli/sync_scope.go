@@ -0,0 +1,247 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// teamFile is the committed allowlist of teammates whose sessions team sync
+// imports: one email glob per line, # comments. Without it, everyone's.
+const teamFile = ".rekal-team"
+
+// syncScope limits the remote sessions team sync imports into the index. A
+// nil scope imports everything.
+type syncScope struct {
+	Authors  []string  // email globs from --author; a session matches any
+	Team     []string  // email globs from .rekal-team; nil without the file
+	Branches []string  // code branch globs from --branch
+	Since    time.Time // sessions captured before are skipped; zero for no limit
+
+	since   string       // --since as given, so a relative window is not a new scope every day
+	aliases emailAliases // an alias passes if its canonical email does
+}
+
+// loadSyncScope builds the scope from the sync flags and gitRoot's
+// .rekal-team. Returns nil if nothing limits the sync.
+func loadSyncScope(gitRoot string, authors, branches []string, since string, now time.Time) (*syncScope, error) {
+	team, err := readTeamFile(gitRoot)
+	if err != nil {
+		return nil, err
+	}
+	if len(authors) == 0 && len(branches) == 0 && since == "" && team == nil {
+		return nil, nil
+	}
+	aliases, err := loadEmailAliases(gitRoot)
+	if err != nil {
+		return nil, err
+	}
+	s := &syncScope{Team: team, since: since, aliases: aliases}
+	for _, a := range authors {
+		s.Authors = append(s.Authors, strings.ToLower(a))
+	}
+	s.Branches = append(s.Branches, branches...)
+	for _, g := range append(append([]string{}, s.Authors...), s.Branches...) {
+		if _, err := path.Match(g, ""); err != nil {
+			return nil, fmt.Errorf("bad pattern %q: %w", g, err)
+		}
+	}
+	if since != "" {
+		if s.Since, err = parseSince(since, now); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// readTeamFile returns the email globs in gitRoot's .rekal-team, or nil if
+// there is no such file.
+func readTeamFile(gitRoot string) ([]string, error) {
+	f, err := os.Open(filepath.Join(gitRoot, teamFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	team := []string{}
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.ToLower(strings.TrimSpace(line))
+		if line == "" {
+			continue
+		}
+		if _, err := path.Match(line, ""); err != nil {
+			return nil, fmt.Errorf("%s:%d: bad pattern %q: %w", teamFile, n, line, err)
+		}
+		team = append(team, line)
+	}
+	return team, sc.Err()
+}
+
+// parseSince parses a --since value: a date (2026-01-02), an RFC 3339 time,
+// or a window back from now in hours, days or weeks (36h, 30d, 2w).
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if len(s) > 1 {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		unit := map[byte]time.Duration{'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}[s[len(s)-1]]
+		if err == nil && n >= 0 && unit > 0 {
+			return now.Add(-time.Duration(n) * unit), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("--since %q: want a date (2026-01-02), an RFC 3339 time or a window such as 30d", s)
+}
+
+// matchGlobs reports whether s matches any of globs.
+func matchGlobs(globs []string, s string) bool {
+	for _, g := range globs {
+		if ok, _ := path.Match(g, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAuthor reports whether email, or its canonical email, passes the
+// author filters.
+func (s *syncScope) matchAuthor(email string) bool {
+	if s == nil {
+		return true
+	}
+	emails := []string{strings.ToLower(email), strings.ToLower(s.aliases.resolve(email))}
+	match := func(globs []string) bool {
+		return matchGlobs(globs, emails[0]) || matchGlobs(globs, emails[1])
+	}
+	if s.Team != nil && !match(s.Team) {
+		return false
+	}
+	return len(s.Authors) == 0 || match(s.Authors)
+}
+
+// matchSession reports whether ws is in scope.
+func (s *syncScope) matchSession(ws *wireSession) bool {
+	if s == nil {
+		return true
+	}
+	if !s.matchAuthor(ws.Email) {
+		return false
+	}
+	if len(s.Branches) > 0 && !matchGlobs(s.Branches, ws.Branch) {
+		return false
+	}
+	return s.Since.IsZero() || !ws.CapturedAt.Before(s.Since)
+}
+
+// String describes the scope. Team sync records it with the index and
+// rebuilds when it changes: sessions a narrower scope skipped are behind
+// the watermarks.
+func (s *syncScope) String() string {
+	if s == nil {
+		return ""
+	}
+	var parts []string
+	add := func(name string, globs []string) {
+		if globs != nil {
+			parts = append(parts, name+"="+strings.Join(globs, ","))
+		}
+	}
+	add("team", s.Team)
+	add("author", s.Authors)
+	add("branch", s.Branches)
+	if s.since != "" {
+		parts = append(parts, "since="+s.since)
+	}
+	return strings.Join(parts, " ")
+}
+
+// doSyncList prints the teammate branches on each remote, as the remote
+// lists them, without fetching. Update time and size come from the local
+// copy, so branches never fetched, or moved since, show only that.
+func doSyncList(gitRoot string, w io.Writer, remotes []string, scope *syncScope) error {
+	self := rekalEmail()
+	fmt.Fprintf(w, "%-32s %-10s %-20s %12s  %s\n", "TEAMMATE", "REMOTE", "UPDATED", "SIZE", "IN SCOPE")
+	listed := 0
+	for _, remote := range remotes {
+		if !remoteExists(gitRoot, remote) {
+			fmt.Fprintf(w, "rekal: no remote '%s' configured\n", remote)
+			continue
+		}
+		cmd := exec.Command("git", "-C", gitRoot, "ls-remote", remote, "refs/heads/rekal/*", "refs/rekal/*")
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("ls-remote %s: %s", remote, strings.TrimSpace(stderr.String()))
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			sha, ref, ok := strings.Cut(line, "\t")
+			if !ok {
+				continue
+			}
+			owner := strings.TrimPrefix(strings.TrimPrefix(ref, "refs/heads/rekal/"), "refs/rekal/")
+			if owner == self {
+				continue
+			}
+			updated, size := "not fetched", "-"
+			if ts, n, ok := localCommitInfo(gitRoot, sha); ok {
+				updated, size = ts.Local().Format("2006-01-02 15:04"), strconv.FormatInt(n, 10)
+			}
+			inScope := "yes"
+			if !scope.matchAuthor(owner) {
+				inScope = "no"
+			}
+			fmt.Fprintf(w, "%-32s %-10s %-20s %12s  %s\n", owner, remote, updated, size, inScope)
+			listed++
+		}
+	}
+	if listed == 0 {
+		fmt.Fprintln(w, "rekal: no teammate rekal branches found")
+	}
+	return nil
+}
+
+// localCommitInfo returns the commit time of sha and the size in bytes of
+// the files in its tree, or false if sha is not in the local repository.
+func localCommitInfo(gitRoot, sha string) (time.Time, int64, bool) {
+	out, err := exec.Command("git", "-C", gitRoot, "log", "-1", "--format=%cI", sha, "--").Output()
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	ts, err := time.Parse(time.RFC3339, strings.TrimSpace(string(out)))
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	out, err = exec.Command("git", "-C", gitRoot, "ls-tree", "-r", "-l", sha).Output()
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	var size int64
+	for _, line := range strings.Split(string(out), "\n") {
+		// <mode> blob <hash> <size>\t<path>
+		meta, _, _ := strings.Cut(line, "\t")
+		if f := strings.Fields(meta); len(f) == 4 {
+			n, _ := strconv.ParseInt(f[3], 10, 64)
+			size += n
+		}
+	}
+	return ts, size, true
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	for in, want := range map[string]time.Time{
+		"2026-01-02":           time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+		"2026-01-02T15:04:05Z": time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC),
+		"36h":                  now.Add(-36 * time.Hour),
+		"30d":                  now.AddDate(0, 0, -30),
+		"2w":                   now.AddDate(0, 0, -14),
+	} {
+		got, err := parseSince(in, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("parseSince(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "d", "30", "30m", "-1d", "yesterday"} {
+		if _, err := parseSince(in, now); err == nil {
+			t.Errorf("parseSince(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestReadTeamFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	if team, err := readTeamFile(dir); err != nil || team != nil {
+		t.Fatalf("no file: %v, %v", team, err)
+	}
+
+	content := "# platform team\nAlice@Example.com\n\n  *@infra.example.com  # everyone in infra\n"
+	if err := os.WriteFile(filepath.Join(dir, teamFile), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	team, err := readTeamFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"alice@example.com", "*@infra.example.com"}; !reflect.DeepEqual(team, want) {
+		t.Errorf("team = %q, want %q", team, want)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, teamFile), []byte("[bob\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readTeamFile(dir); err == nil {
+		t.Error("bad pattern accepted")
+	}
+}
+
+func TestSyncScope_MatchSession(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, teamFile), []byte("*@example.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	scope, err := loadSyncScope(dir, []string{"Alice@*", "bob@*"}, []string{"feature/*"}, "7d", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recent := now.Add(-24 * time.Hour)
+	tests := []struct {
+		name string
+		ws   wireSession
+		want bool
+	}{
+		{"in scope", wireSession{Email: "alice@example.com", Branch: "feature/login", CapturedAt: recent}, true},
+		{"author case", wireSession{Email: "BOB@example.com", Branch: "feature/x", CapturedAt: recent}, true},
+		{"not an author", wireSession{Email: "carol@example.com", Branch: "feature/x", CapturedAt: recent}, false},
+		{"not on the team", wireSession{Email: "alice@elsewhere.org", Branch: "feature/x", CapturedAt: recent}, false},
+		{"other branch", wireSession{Email: "alice@example.com", Branch: "main", CapturedAt: recent}, false},
+		{"too old", wireSession{Email: "alice@example.com", Branch: "feature/x", CapturedAt: now.AddDate(0, 0, -8)}, false},
+	}
+	for _, tt := range tests {
+		if got := scope.matchSession(&tt.ws); got != tt.want {
+			t.Errorf("%s: matchSession = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	var none *syncScope
+	if !none.matchSession(&tests[2].ws) || none.String() != "" {
+		t.Error("nil scope should match everything")
+	}
+	if got, want := scope.String(), "team=*@example.com author=alice@*,bob@* branch=feature/* since=7d"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestSyncScope_MatchAuthorAlias(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, aliasFile), []byte("<alice@example.com> <ali@laptop.local>\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	scope, err := loadSyncScope(dir, []string{"*@example.com"}, nil, "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !scope.matchAuthor("ali@laptop.local") {
+		t.Error("an alias of a matching email should match")
+	}
+	if scope.matchAuthor("bob@laptop.local") {
+		t.Error("bob@laptop.local should not match")
+	}
+}
+
+func TestLoadSyncScope_Unscoped(t *testing.T) {
+	t.Parallel()
+	scope, err := loadSyncScope(t.TempDir(), nil, nil, "", time.Now())
+	if err != nil || scope != nil {
+		t.Errorf("loadSyncScope = %v, %v; want nil", scope, err)
+	}
+	if _, err := loadSyncScope(t.TempDir(), []string{"[a"}, nil, "", time.Now()); err == nil {
+		t.Error("bad --author pattern accepted")
+	}
+}
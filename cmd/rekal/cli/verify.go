@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+	"github.com/spf13/cobra"
+)
+
+func newVerifyCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify [branch]",
+		Short: "Check rekal branches for tampering and verify their signatures",
+		Long: `Check rekal branches for tampering and verify their signatures.
+
+Every meta frame in rekal.body seals the frames before it with a SHA-256
+hash chain, and every frame carries a CRC-32C checksum. verify recomputes
+the chain and reports every sealed point that no longer matches — a frame
+was edited, dropped or reordered — and every corrupt byte range skipped
+while reading the body.
+
+Without an argument, every local and remote-tracking rekal/* branch and
+refs/rekal/* ref is checked. Frames written before the hash chain existed are reported as
+unchained; frames after the last meta frame as unsealed. Neither is an
+error.
+
+Branches with signed commits also get a signature summary: how many
+commits carry a good signature from the branch owner, checked against
+.rekal-allowed-signers (or rekal.allowedSigners, or
+gpg.ssh.allowedSignersFile) for SSH keys and the keyring for GPG.
+
+Exits non-zero if any chain is broken, any body is corrupt or any commit
+has a bad signature.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			gitRoot, err := EnsureGitRoot()
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+
+			branches := args
+			if len(branches) == 0 {
+				branches = listAllRekalBranches(gitRoot)
+			}
+			if err := doVerify(gitRoot, cmd.OutOrStdout(), branches); err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			return nil
+		},
+	}
+}
+
+// listAllRekalBranches returns local and fetched rekal data in both
+// layouts: rekal/* branches, remote-tracking rekal branches, refs/rekal/*
+// and refs/rekal-remotes/*.
+func listAllRekalBranches(gitRoot string) []string {
+	return listRekalRefs(gitRoot, branchRefPrefix, "refs/remotes/*/rekal/**", customRefPrefix, customRemoteRefPrefix)
+}
+
+// doVerify checks the hash chain and commit signatures of each branch.
+// Returns an error if any branch has a broken chain or a bad signature.
+func doVerify(gitRoot string, w io.Writer, branches []string) error {
+	if len(branches) == 0 {
+		fmt.Fprintln(w, "rekal: no rekal branches found")
+		return nil
+	}
+
+	broken := 0
+	for _, branch := range branches {
+		body, err := readBranchBody(gitRoot, branch)
+		if err != nil {
+			fmt.Fprintf(w, "%s: unreadable: %v\n", branch, err)
+			broken++
+			continue
+		}
+		if len(body) == 0 {
+			fmt.Fprintf(w, "%s: no rekal.body\n", branch)
+			continue
+		}
+		dec, err := newBranchDecoder(gitRoot, branch)
+		if err != nil {
+			fmt.Fprintf(w, "%s: unreadable: %v\n", branch, err)
+			broken++
+			continue
+		}
+		r, err := dec.VerifyChain(body)
+		dec.Close()
+		if err != nil {
+			fmt.Fprintf(w, "%s: unreadable: %v\n", branch, err)
+			broken++
+			continue
+		}
+
+		switch {
+		case !r.OK():
+			broken++
+			fmt.Fprintf(w, "%s: BROKEN — %d chain break(s), %d corrupt range(s) in %d frames\n", branch, len(r.Breaks), len(r.Skipped), r.Frames)
+			for _, b := range r.Breaks {
+				fmt.Fprintf(w, "  frame %d (offset %d): sealed %s, computed %s\n", b.Frame, b.Offset, b.Want, b.Got)
+			}
+			for _, s := range r.Skipped {
+				fmt.Fprintf(w, "  corrupt bytes %d-%d skipped\n", s.Offset, s.Offset+s.Length-1)
+			}
+		case r.Frames == 0:
+			fmt.Fprintf(w, "%s: empty\n", branch)
+		case r.Sealed == 0:
+			fmt.Fprintf(w, "%s: unchained — %d frames written before the hash chain\n", branch, r.Frames)
+		case r.Unsealed > 0:
+			fmt.Fprintf(w, "%s: ok — %d frames sealed, %d unsealed\n", branch, r.Sealed, r.Unsealed)
+		default:
+			fmt.Fprintf(w, "%s: ok — %d frames sealed\n", branch, r.Sealed)
+		}
+
+		sigs, err := verifyBranchSignatures(gitRoot, branch, branchWatermark{})
+		if err != nil {
+			fmt.Fprintf(w, "rekal: warning: %v\n", err)
+			continue
+		}
+		reportBranchSignatures(w, branch, sigs)
+		if sigs.Bad > 0 {
+			broken++
+		}
+	}
+
+	if broken > 0 {
+		return fmt.Errorf("rekal: verification failed on %d branch(es)", broken)
+	}
+	return nil
+}
+
+// checkImportedHistory compares body against the history recorded for branch
+// at its last import and warns on w if that history was rewritten — frames
+// already imported were changed or removed. Append-only growth is the only
+// expected change. The new head is then recorded. Non-fatal throughout.
+func checkImportedHistory(d *sql.DB, branch string, body []byte, frames []codec.FrameSlice, w io.Writer) {
+	prev, found, err := db.QueryImportedHead(d, branch)
+	if err != nil {
+		fmt.Fprintf(w, "rekal: warning: read import history for %s: %v\n", branch, err)
+		return
+	}
+	if found && historyRewritten(prev, body, frames) {
+		fmt.Fprintf(w, "rekal: warning: %s was rewritten since the last import — %d previously imported frames no longer match (see 'rekal verify %s')\n",
+			branch, prev.NFrames, branch)
+	}
+
+	head := db.ImportedHead{NFrames: len(frames), ChainHead: codec.Chain(body, frames).String()}
+	if err := db.UpsertImportedHead(d, branch, head); err != nil {
+		fmt.Fprintf(w, "rekal: warning: record import history for %s: %v\n", branch, err)
+	}
+}
+
+// extendImportedHistory records frames — the frames of body that follow
+// the prev.NFrames recorded for branch — as appended to its history,
+// continuing prev's chain. It is checkImportedHistory for an import that
+// read only the end of the body, having established the earlier frames are
+// unchanged. Non-fatal throughout.
+func extendImportedHistory(d *sql.DB, branch string, prev db.ImportedHead, body []byte, frames []codec.FrameSlice, w io.Writer) {
+	chain, ok := codec.ParseChainHash(prev.ChainHead)
+	if !ok {
+		fmt.Fprintf(w, "rekal: warning: record import history for %s: malformed chain head %q\n", branch, prev.ChainHead)
+		return
+	}
+	head := db.ImportedHead{NFrames: prev.NFrames + len(frames), ChainHead: codec.ChainFrom(chain, body, frames).String()}
+	if err := db.UpsertImportedHead(d, branch, head); err != nil {
+		fmt.Fprintf(w, "rekal: warning: record import history for %s: %v\n", branch, err)
+	}
+}
+
+// historyRewritten reports whether body no longer starts with the frames
+// recorded in prev.
+func historyRewritten(prev db.ImportedHead, body []byte, frames []codec.FrameSlice) bool {
+	if prev.NFrames > len(frames) {
+		return true
+	}
+	return codec.Chain(body, frames[:prev.NFrames]).String() != prev.ChainHead
+}
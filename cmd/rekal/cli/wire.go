@@ -0,0 +1,799 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+	"github.com/spf13/cobra"
+)
+
+func newWireCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "wire",
+		Short: "Decode and validate the wire format on rekal branches",
+		Long: `Decode and validate the wire format on rekal branches.
+
+Every rekal branch holds two files: rekal.body, an append-only log of
+zstd-compressed frames, and dict.bin, the string dictionary the frames
+refer to. These commands read them straight from git, without touching
+the local databases.
+
+  dump <branch>    one JSON object per line: header, dict entries, frames
+  stats [branch]   frame counts, dictionary sizes and bytes per type/author
+  check [branch]   report frames whose dict references do not resolve
+
+See docs/git-transportation.md for the format itself.`,
+	}
+	cmd.AddCommand(newWireDumpCmd(), newWireStatsCmd(), newWireCheckCmd())
+	return cmd
+}
+
+func newWireDumpCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "dump <branch>",
+		Short: "Decode rekal.body and dict.bin to JSON lines",
+		Long: `Decode rekal.body and dict.bin of a rekal branch to JSON lines.
+
+The first line describes the files. It is followed by one line per dict
+entry and then, in body order, one line per frame with its offset, sizes
+and decoded content, and one line per corrupt byte range skipped. Frames
+refer to strings by dict ref; look them up in the dict lines.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			gitRoot, err := EnsureGitRoot()
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			wd, err := loadWire(gitRoot, args[0])
+			if err == nil {
+				err = doWireDump(cmd.OutOrStdout(), wd)
+			}
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return NewSilentError(err)
+			}
+			return nil
+		},
+	}
+}
+
+func newWireStatsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "stats [branch]",
+		Short: "Report frame counts, dictionary sizes and compression",
+		Long: `Report frame counts, dictionary sizes, and compressed vs uncompressed
+payload bytes per frame type and per author.
+
+Without an argument, every local and remote-tracking rekal/* branch and
+refs/rekal/* ref is reported.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWireBranches(cmd, args, doWireStats)
+		},
+	}
+}
+
+func newWireCheckCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "check [branch]",
+		Short: "Validate dict references in rekal.body",
+		Long: `Validate the references between rekal.body and dict.bin.
+
+Every session, branch, email, path and tool ref in every frame must resolve
+in dict.bin, and every session a checkpoint frame lists must have a session
+frame in the body. Frames that fail to decode and corrupt byte ranges are
+reported too.
+
+Without an argument, every local and remote-tracking rekal/* branch and
+refs/rekal/* ref is checked. Exits non-zero if any problem is found.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWireBranches(cmd, args, doWireCheck)
+		},
+	}
+}
+
+// runWireBranches runs fn for the branch in args, or every rekal branch.
+func runWireBranches(cmd *cobra.Command, args []string, fn func(w io.Writer, wds []*wireData) error) error {
+	cmd.SilenceUsage = true
+
+	gitRoot, err := EnsureGitRoot()
+	if err != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err)
+		return NewSilentError(err)
+	}
+
+	branches := args
+	if len(branches) == 0 {
+		branches = listAllRekalBranches(gitRoot)
+	}
+	if len(branches) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "rekal: no rekal branches found")
+		return nil
+	}
+
+	var wds []*wireData
+	for _, branch := range branches {
+		wd, err := loadWire(gitRoot, branch)
+		if err != nil {
+			fmt.Fprintln(cmd.ErrOrStderr(), err)
+			return NewSilentError(err)
+		}
+		wds = append(wds, wd)
+	}
+	if err := fn(cmd.OutOrStdout(), wds); err != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err)
+		return NewSilentError(err)
+	}
+	return nil
+}
+
+// wireData is the wire format of one branch, scanned but not decoded.
+type wireData struct {
+	Branch    string
+	Body      []byte
+	DictBytes int
+	Dict      *codec.Dict
+	DictErr   error // set if dict.bin is missing or unreadable; Dict is then empty
+	Scan      *codec.ScanResult
+	Segments  int               // segment files; 0 for a legacy single-file rekal.body
+	ZDicts    map[uint32][]byte // trained zstd dictionaries under dicts/
+	Keys      map[uint32][]byte // data keys under keys/ the local identities unwrap
+}
+
+// loadWire reads and scans the wire format of branch.
+func loadWire(gitRoot, branch string) (*wireData, error) {
+	body, err := readBranchBody(gitRoot, branch)
+	if err != nil {
+		return nil, fmt.Errorf("rekal: %w", err)
+	}
+	if len(body) == 0 {
+		return nil, fmt.Errorf("rekal: %s has no rekal.body", branch)
+	}
+	dictData, dictErr := readBranchDictData(gitRoot, branch)
+	wd, err := newWireData(branch, body, dictData)
+	if err != nil {
+		return nil, err
+	}
+	if dictErr != nil {
+		wd.DictBytes, wd.DictErr = len(gitShowFile(gitRoot, branch, "dict.bin")), dictErr
+	}
+	if wd.Keys, err = branchDataKeys(gitRoot, branch); err != nil {
+		return nil, fmt.Errorf("rekal: %w", err)
+	}
+	if m, _ := readBranchManifest(gitRoot, branch); m != nil {
+		wd.Segments = len(m.Segments)
+	}
+	if wd.ZDicts, err = readBranchDicts(gitRoot, branch); err != nil {
+		return nil, fmt.Errorf("rekal: %w", err)
+	}
+	return wd, nil
+}
+
+// newWireData scans body and loads dictData for branch.
+func newWireData(branch string, body, dictData []byte) (*wireData, error) {
+	scan, err := codec.ScanBody(body)
+	if err != nil {
+		return nil, fmt.Errorf("rekal: %s: %w", branch, err)
+	}
+	wd := &wireData{Branch: branch, Body: body, DictBytes: len(dictData), Dict: codec.NewDict(), Scan: scan}
+	if len(dictData) == 0 {
+		wd.DictErr = errors.New("no dict.bin")
+	} else if dict, err := codec.LoadDict(dictData); err != nil {
+		wd.DictErr = err
+	} else {
+		wd.Dict = dict
+	}
+	return wd, nil
+}
+
+// wireNamespaces lists the dict namespaces in dict.bin order.
+var wireNamespaces = []struct {
+	ns   codec.Namespace
+	name string
+}{
+	{codec.NSSessions, "sessions"},
+	{codec.NSBranches, "branches"},
+	{codec.NSEmails, "emails"},
+	{codec.NSPaths, "paths"},
+	{codec.NSTools, "tools"},
+}
+
+// decodeFrame decodes the payload of fs. Returns a *codec.SessionFrame,
+// *codec.CheckpointFrame or *codec.MetaFrame, or nil for tombstones.
+func decodeFrame(dec *codec.Decoder, body []byte, fs codec.FrameSlice) (any, error) {
+	payload := codec.ExtractFramePayload(body, fs)
+	switch fs.Type {
+	case codec.FrameSession:
+		return dec.DecodeSessionFrame(payload)
+	case codec.FrameCheckpoint:
+		return dec.DecodeCheckpointFrame(payload)
+	case codec.FrameMeta:
+		return dec.DecodeMetaFrame(payload)
+	}
+	return nil, nil
+}
+
+// frameTypeName names a frame type for output.
+func frameTypeName(t codec.FrameType) string {
+	switch t {
+	case codec.FrameSession:
+		return "session"
+	case codec.FrameCheckpoint:
+		return "checkpoint"
+	case codec.FrameMeta:
+		return "meta"
+	case codec.FrameTombstone:
+		return "tombstone"
+	}
+	return fmt.Sprintf("0x%02x", byte(t))
+}
+
+func actorName(a byte) string {
+	if a == codec.ActorAgent {
+		return "agent"
+	}
+	return "human"
+}
+
+// --- dump ---
+
+type wireDumpHeader struct {
+	Kind        string `json:"kind"`
+	Branch      string `json:"branch"`
+	BodyVersion int    `json:"body_version"`
+	BodyBytes   int    `json:"body_bytes"`
+	DictBytes   int    `json:"dict_bytes"`
+	DictError   string `json:"dict_error,omitempty"`
+	Frames      int    `json:"frames"`
+}
+
+type wireDumpDictEntry struct {
+	Kind  string `json:"kind"`
+	NS    string `json:"ns"`
+	Ref   int    `json:"ref"`
+	Value string `json:"value"`
+}
+
+type wireDumpFrame struct {
+	Kind            string `json:"kind"`
+	Index           int    `json:"index"`
+	Offset          int    `json:"offset"`
+	Type            string `json:"type"`
+	CompressedLen   int    `json:"compressed_len"`
+	UncompressedLen int    `json:"uncompressed_len"`
+	Error           string `json:"error,omitempty"`
+
+	Session    *wireDumpSession    `json:"session,omitempty"`
+	Checkpoint *wireDumpCheckpoint `json:"checkpoint,omitempty"`
+	Meta       *wireDumpMeta       `json:"meta,omitempty"`
+}
+
+type wireDumpSkipped struct {
+	Kind   string `json:"kind"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+}
+
+type wireDumpSession struct {
+	SessionRef      uint64             `json:"session_ref"`
+	CapturedAt      time.Time          `json:"captured_at"`
+	EmailRef        uint64             `json:"email_ref"`
+	Actor           string             `json:"actor"`
+	AgentIDRef      *uint64            `json:"agent_id_ref,omitempty"`
+	BranchRef       *uint64            `json:"branch_ref,omitempty"`
+	Source          string             `json:"source,omitempty"`
+	ParentSessionID string             `json:"parent_session_id,omitempty"`
+	Turns           []wireDumpTurn     `json:"turns"`
+	ToolCalls       []wireDumpToolCall `json:"tool_calls"`
+}
+
+type wireDumpTurn struct {
+	Role string     `json:"role"`
+	Ts   *time.Time `json:"ts,omitempty"`
+	Text string     `json:"text"`
+}
+
+type wireDumpToolCall struct {
+	Tool       string  `json:"tool,omitempty"` // fixed tool codes only
+	ToolCode   byte    `json:"tool_code"`
+	ToolRef    *uint64 `json:"tool_ref,omitempty"`
+	PathRef    *uint64 `json:"path_ref,omitempty"`
+	PathInline string  `json:"path_inline,omitempty"`
+	CmdPrefix  string  `json:"cmd_prefix,omitempty"`
+}
+
+type wireDumpCheckpoint struct {
+	CheckpointRef uint64         `json:"checkpoint_ref"`
+	GitSHA        string         `json:"git_sha"`
+	BranchRef     uint64         `json:"branch_ref"`
+	EmailRef      uint64         `json:"email_ref"`
+	Timestamp     time.Time      `json:"timestamp"`
+	Actor         string         `json:"actor"`
+	AgentIDRef    *uint64        `json:"agent_id_ref,omitempty"`
+	SessionRefs   []uint64       `json:"session_refs"`
+	Files         []wireDumpFile `json:"files"`
+}
+
+type wireDumpFile struct {
+	PathRef    uint64 `json:"path_ref"`
+	ChangeType string `json:"change_type"`
+}
+
+type wireDumpMeta struct {
+	FormatVersion byte      `json:"format_version"`
+	EmailRef      uint64    `json:"email_ref"`
+	CheckpointSHA string    `json:"checkpoint_sha"`
+	Timestamp     time.Time `json:"timestamp"`
+	NSessions     uint32    `json:"n_sessions"`
+	NCheckpoints  uint32    `json:"n_checkpoints"`
+	NFrames       uint32    `json:"n_frames"`
+	NDictEntries  uint32    `json:"n_dict_entries"`
+	Chain         string    `json:"chain,omitempty"`
+	DictID        string    `json:"dict_id,omitempty"`
+}
+
+func optRef(ref uint64, present bool) *uint64 {
+	if !present {
+		return nil
+	}
+	return &ref
+}
+
+func dumpSession(sf *codec.SessionFrame) *wireDumpSession {
+	s := &wireDumpSession{
+		SessionRef:      sf.SessionRef,
+		CapturedAt:      sf.CapturedAt,
+		EmailRef:        sf.EmailRef,
+		Actor:           actorName(sf.ActorType),
+		AgentIDRef:      optRef(sf.AgentIDRef, sf.HasAgentID),
+		BranchRef:       optRef(sf.BranchRef, sf.HasBranch),
+		Source:          sf.Source,
+		ParentSessionID: sf.ParentSessionID,
+		Turns:           []wireDumpTurn{},
+		ToolCalls:       []wireDumpToolCall{},
+	}
+	for _, t := range sf.Turns {
+		dt := wireDumpTurn{Role: "human", Text: t.Text}
+		if t.Role == codec.RoleAssistant {
+			dt.Role = "assistant"
+		}
+		if !t.Ts.IsZero() {
+			ts := t.Ts
+			dt.Ts = &ts
+		}
+		s.Turns = append(s.Turns, dt)
+	}
+	for _, tc := range sf.ToolCalls {
+		dtc := wireDumpToolCall{
+			ToolCode:   tc.Tool,
+			ToolRef:    optRef(tc.ToolRef, tc.Tool == codec.ToolDictRef),
+			PathRef:    optRef(tc.PathRef, tc.PathFlag == codec.PathDictRef),
+			PathInline: tc.PathInline,
+			CmdPrefix:  tc.CmdPrefix,
+		}
+		if tc.Tool != codec.ToolDictRef {
+			dtc.Tool = codec.ToolName(tc.Tool)
+		}
+		s.ToolCalls = append(s.ToolCalls, dtc)
+	}
+	return s
+}
+
+func dumpCheckpoint(cf *codec.CheckpointFrame) *wireDumpCheckpoint {
+	c := &wireDumpCheckpoint{
+		CheckpointRef: cf.CheckpointRef,
+		GitSHA:        cf.GitSHA,
+		BranchRef:     cf.BranchRef,
+		EmailRef:      cf.EmailRef,
+		Timestamp:     cf.Timestamp,
+		Actor:         actorName(cf.ActorType),
+		AgentIDRef:    optRef(cf.AgentIDRef, cf.ActorType == codec.ActorAgent),
+		SessionRefs:   append([]uint64{}, cf.SessionRefs...),
+		Files:         []wireDumpFile{},
+	}
+	for _, f := range cf.Files {
+		c.Files = append(c.Files, wireDumpFile{PathRef: f.PathRef, ChangeType: string(f.ChangeType)})
+	}
+	return c
+}
+
+func dumpMeta(mf *codec.MetaFrame) *wireDumpMeta {
+	m := &wireDumpMeta{
+		FormatVersion: mf.FormatVersion,
+		EmailRef:      mf.EmailRef,
+		CheckpointSHA: mf.CheckpointSHA,
+		Timestamp:     mf.Timestamp,
+		NSessions:     mf.NSessions,
+		NCheckpoints:  mf.NCheckpoints,
+		NFrames:       mf.NFrames,
+		NDictEntries:  mf.NDictEntries,
+	}
+	if mf.Chained {
+		m.Chain = mf.Chain.String()
+	}
+	if mf.DictID != 0 {
+		m.DictID = fmt.Sprintf("%08x", mf.DictID)
+	}
+	return m
+}
+
+// doWireDump writes wd as JSON lines: a header, the dict entries, then
+// frames and skipped ranges in body order.
+func doWireDump(w io.Writer, wd *wireData) error {
+	dec, err := newDictDecoder(wd.ZDicts)
+	if err != nil {
+		return err
+	}
+	dec.AddKeys(wd.Keys)
+	defer dec.Close()
+
+	enc := json.NewEncoder(w)
+	hdr := wireDumpHeader{
+		Kind:        "header",
+		Branch:      wd.Branch,
+		BodyVersion: int(wd.Scan.Version),
+		BodyBytes:   len(wd.Body),
+		DictBytes:   wd.DictBytes,
+		Frames:      len(wd.Scan.Frames),
+	}
+	if wd.DictErr != nil {
+		hdr.DictError = wd.DictErr.Error()
+	}
+	if err := enc.Encode(hdr); err != nil {
+		return err
+	}
+
+	for _, n := range wireNamespaces {
+		for i := 0; i < wd.Dict.Len(n.ns); i++ {
+			v, _ := wd.Dict.Get(n.ns, uint64(i))
+			if err := enc.Encode(wireDumpDictEntry{Kind: "dict", NS: n.name, Ref: i, Value: v}); err != nil {
+				return err
+			}
+		}
+	}
+
+	skipped := wd.Scan.Skipped
+	for i, fs := range wd.Scan.Frames {
+		for len(skipped) > 0 && skipped[0].Offset < fs.Offset {
+			if err := enc.Encode(wireDumpSkipped{Kind: "skipped", Offset: skipped[0].Offset, Length: skipped[0].Length}); err != nil {
+				return err
+			}
+			skipped = skipped[1:]
+		}
+
+		rec := wireDumpFrame{
+			Kind:            "frame",
+			Index:           i,
+			Offset:          fs.Offset,
+			Type:            frameTypeName(fs.Type),
+			CompressedLen:   fs.CompressedLen,
+			UncompressedLen: fs.UncompressedLen,
+		}
+		f, err := decodeFrame(dec, wd.Body, fs)
+		if err != nil {
+			rec.Error = err.Error()
+		}
+		switch f := f.(type) {
+		case *codec.SessionFrame:
+			rec.Session = dumpSession(f)
+		case *codec.CheckpointFrame:
+			rec.Checkpoint = dumpCheckpoint(f)
+		case *codec.MetaFrame:
+			rec.Meta = dumpMeta(f)
+		}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	for _, s := range skipped {
+		if err := enc.Encode(wireDumpSkipped{Kind: "skipped", Offset: s.Offset, Length: s.Length}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// --- stats ---
+
+// wireSizes accumulates frame counts and payload bytes.
+type wireSizes struct {
+	Frames       int
+	Compressed   int
+	Uncompressed int
+}
+
+func (s *wireSizes) add(fs codec.FrameSlice) {
+	s.Frames++
+	s.Compressed += fs.CompressedLen
+	s.Uncompressed += fs.UncompressedLen
+}
+
+// wireStats summarises the frames of one branch.
+type wireStats struct {
+	ByType   map[codec.FrameType]*wireSizes
+	ByAuthor map[string]*wireSizes
+	Total    wireSizes
+}
+
+// unknownAuthor groups frames whose author cannot be decoded or resolved.
+const unknownAuthor = "(unknown)"
+
+// computeWireStats decodes every frame of wd to attribute it to an author.
+func computeWireStats(dec *codec.Decoder, wd *wireData) *wireStats {
+	st := &wireStats{
+		ByType:   make(map[codec.FrameType]*wireSizes),
+		ByAuthor: make(map[string]*wireSizes),
+	}
+	for _, fs := range wd.Scan.Frames {
+		if st.ByType[fs.Type] == nil {
+			st.ByType[fs.Type] = &wireSizes{}
+		}
+		st.ByType[fs.Type].add(fs)
+		st.Total.add(fs)
+
+		author := unknownAuthor
+		var emailRef uint64
+		ok := false
+		f, err := decodeFrame(dec, wd.Body, fs)
+		if err == nil {
+			switch f := f.(type) {
+			case *codec.SessionFrame:
+				emailRef, ok = f.EmailRef, true
+			case *codec.CheckpointFrame:
+				emailRef, ok = f.EmailRef, true
+			case *codec.MetaFrame:
+				emailRef, ok = f.EmailRef, true
+			}
+		}
+		if ok {
+			if email, err := wd.Dict.Get(codec.NSEmails, emailRef); err == nil && email != "" {
+				author = email
+			}
+		}
+		if st.ByAuthor[author] == nil {
+			st.ByAuthor[author] = &wireSizes{}
+		}
+		st.ByAuthor[author].add(fs)
+	}
+	return st
+}
+
+// ratio formats uncompressed/compressed, or "-" for empty payloads.
+func (s *wireSizes) ratio() string {
+	if s.Compressed == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1fx", float64(s.Uncompressed)/float64(s.Compressed))
+}
+
+func printWireSizes(w io.Writer, label string, s *wireSizes) {
+	fmt.Fprintf(w, "  %-28s %7d %11d %13d %6s\n", label, s.Frames, s.Compressed, s.Uncompressed, s.ratio())
+}
+
+// doWireStats prints frame, dictionary and compression statistics for each
+// branch.
+func doWireStats(w io.Writer, wds []*wireData) error {
+	for i, wd := range wds {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		dec, err := newDictDecoder(wd.ZDicts)
+		if err != nil {
+			return err
+		}
+		dec.AddKeys(wd.Keys)
+		st := computeWireStats(dec, wd)
+		active := activeDictID(dec, wd.Body, wd.Scan.Frames)
+		dec.Close()
+
+		fmt.Fprintf(w, "%s\n", wd.Branch)
+		fmt.Fprintf(w, "  rekal.body  %d bytes, v%d, %d frames", len(wd.Body), wd.Scan.Version, st.Total.Frames)
+		if wd.Segments > 0 {
+			fmt.Fprintf(w, " in %d segment(s)", wd.Segments)
+		}
+		if n := len(wd.Scan.Skipped); n > 0 {
+			fmt.Fprintf(w, ", %d corrupt range(s)", n)
+		}
+		fmt.Fprintln(w)
+		if wd.DictErr != nil {
+			fmt.Fprintf(w, "  dict.bin    %d bytes, unreadable: %v\n", wd.DictBytes, wd.DictErr)
+		} else {
+			fmt.Fprintf(w, "  dict.bin    %d bytes, %d entries —", wd.DictBytes, wd.Dict.TotalEntries())
+			for _, n := range wireNamespaces {
+				fmt.Fprintf(w, " %d %s", wd.Dict.Len(n.ns), n.name)
+			}
+			fmt.Fprintln(w)
+		}
+		if len(wd.ZDicts) > 0 {
+			n := 0
+			for _, d := range wd.ZDicts {
+				n += len(d)
+			}
+			fmt.Fprintf(w, "  dicts/      %d bytes, %d trained dictionary(ies)", n, len(wd.ZDicts))
+			if active != 0 {
+				fmt.Fprintf(w, ", active %08x", active)
+			}
+			fmt.Fprintln(w)
+		}
+
+		fmt.Fprintf(w, "\n  %-28s %7s %11s %13s %6s\n", "TYPE", "FRAMES", "COMPRESSED", "UNCOMPRESSED", "RATIO")
+		for _, t := range []codec.FrameType{codec.FrameSession, codec.FrameCheckpoint, codec.FrameMeta, codec.FrameTombstone} {
+			if s := st.ByType[t]; s != nil {
+				printWireSizes(w, frameTypeName(t), s)
+			}
+		}
+		printWireSizes(w, "total", &st.Total)
+
+		authors := make([]string, 0, len(st.ByAuthor))
+		for a := range st.ByAuthor {
+			authors = append(authors, a)
+		}
+		sort.Slice(authors, func(i, j int) bool {
+			ai, aj := st.ByAuthor[authors[i]], st.ByAuthor[authors[j]]
+			if ai.Compressed != aj.Compressed {
+				return ai.Compressed > aj.Compressed
+			}
+			return authors[i] < authors[j]
+		})
+		fmt.Fprintf(w, "\n  %-28s %7s %11s %13s %6s\n", "AUTHOR", "FRAMES", "COMPRESSED", "UNCOMPRESSED", "RATIO")
+		for _, a := range authors {
+			printWireSizes(w, a, st.ByAuthor[a])
+		}
+	}
+	return nil
+}
+
+// --- check ---
+
+// wireProblem is one validation failure in a branch's wire format. Frame
+// is -1 for problems outside any frame.
+type wireProblem struct {
+	Frame  int
+	Offset int
+	Msg    string
+}
+
+func (p wireProblem) String() string {
+	if p.Frame < 0 {
+		return p.Msg
+	}
+	return fmt.Sprintf("frame %d (offset %d): %s", p.Frame, p.Offset, p.Msg)
+}
+
+// checkWire validates every dict reference in wd, that checkpoint frames
+// only list sessions that have a session frame in the body, and that meta
+// frames only name dictionaries stored on the branch.
+func checkWire(dec *codec.Decoder, wd *wireData) []wireProblem {
+	var problems []wireProblem
+	if wd.DictErr != nil {
+		problems = append(problems, wireProblem{Frame: -1, Msg: fmt.Sprintf("dict.bin: %v", wd.DictErr)})
+	}
+	for _, s := range wd.Scan.Skipped {
+		problems = append(problems, wireProblem{Frame: -1, Msg: fmt.Sprintf("corrupt bytes %d-%d skipped", s.Offset, s.Offset+s.Length-1)})
+	}
+
+	type checkpointRefs struct {
+		frame, offset int
+		refs          []uint64
+	}
+	var checkpoints []checkpointRefs
+	sessions := make(map[uint64]bool)
+	locked := 0
+
+	for i, fs := range wd.Scan.Frames {
+		report := func(format string, args ...any) {
+			problems = append(problems, wireProblem{Frame: i, Offset: fs.Offset, Msg: fmt.Sprintf(format, args...)})
+		}
+		resolve := func(ns codec.Namespace, ref uint64, what string) {
+			if _, err := wd.Dict.Get(ns, ref); err != nil {
+				report("%s ref %d not in dict", what, ref)
+			}
+		}
+
+		f, err := decodeFrame(dec, wd.Body, fs)
+		if errors.Is(err, codec.ErrNoKey) {
+			locked++
+			continue
+		}
+		if err != nil {
+			report("%s frame does not decode: %v", frameTypeName(fs.Type), err)
+			continue
+		}
+		switch f := f.(type) {
+		case *codec.SessionFrame:
+			sessions[f.SessionRef] = true
+			resolve(codec.NSSessions, f.SessionRef, "session")
+			resolve(codec.NSEmails, f.EmailRef, "email")
+			if f.HasAgentID {
+				resolve(codec.NSEmails, f.AgentIDRef, "agent id")
+			}
+			if f.HasBranch {
+				resolve(codec.NSBranches, f.BranchRef, "branch")
+			}
+			for _, tc := range f.ToolCalls {
+				if tc.Tool == codec.ToolDictRef {
+					resolve(codec.NSTools, tc.ToolRef, "tool")
+				}
+				if tc.PathFlag == codec.PathDictRef {
+					resolve(codec.NSPaths, tc.PathRef, "path")
+				}
+			}
+
+		case *codec.CheckpointFrame:
+			resolve(codec.NSSessions, f.CheckpointRef, "checkpoint")
+			resolve(codec.NSBranches, f.BranchRef, "branch")
+			resolve(codec.NSEmails, f.EmailRef, "email")
+			if f.ActorType == codec.ActorAgent {
+				resolve(codec.NSEmails, f.AgentIDRef, "agent id")
+			}
+			for _, file := range f.Files {
+				resolve(codec.NSPaths, file.PathRef, "path")
+			}
+			checkpoints = append(checkpoints, checkpointRefs{frame: i, offset: fs.Offset, refs: f.SessionRefs})
+
+		case *codec.MetaFrame:
+			resolve(codec.NSEmails, f.EmailRef, "email")
+			if _, ok := wd.ZDicts[f.DictID]; f.DictID != 0 && !ok {
+				report("meta names zstd dictionary %08x, not in %s/", f.DictID, dictDir)
+			}
+		}
+	}
+
+	if locked > 0 {
+		problems = append(problems, wireProblem{Frame: -1, Msg: fmt.Sprintf("%d frame(s) encrypted with keys no local identity unwraps — not checked", locked)})
+		return problems
+	}
+
+	// Session frames are written before the checkpoint that lists them, but
+	// only their presence matters to readers.
+	for _, cp := range checkpoints {
+		for _, ref := range cp.refs {
+			if sessions[ref] {
+				continue
+			}
+			msg := fmt.Sprintf("checkpoint lists session ref %d with no session frame", ref)
+			if id, err := wd.Dict.Get(codec.NSSessions, ref); err == nil {
+				msg = fmt.Sprintf("checkpoint lists session %s with no session frame", id)
+			}
+			problems = append(problems, wireProblem{Frame: cp.frame, Offset: cp.offset, Msg: msg})
+		}
+	}
+	return problems
+}
+
+// doWireCheck validates each branch. Returns an error if any has problems.
+func doWireCheck(w io.Writer, wds []*wireData) error {
+	failed := 0
+	for _, wd := range wds {
+		dec, err := newDictDecoder(wd.ZDicts)
+		if err != nil {
+			return err
+		}
+		dec.AddKeys(wd.Keys)
+		problems := checkWire(dec, wd)
+		dec.Close()
+		if len(problems) == 0 {
+			fmt.Fprintf(w, "%s: ok — %d frames, %d dict entries, all references resolve\n",
+				wd.Branch, len(wd.Scan.Frames), wd.Dict.TotalEntries())
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "%s: %d problem(s) in %d frames\n", wd.Branch, len(problems), len(wd.Scan.Frames))
+		for _, p := range problems {
+			fmt.Fprintf(w, "  %s\n", p)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("rekal: wire check failed on %d branch(es)", failed)
+	}
+	return nil
+}
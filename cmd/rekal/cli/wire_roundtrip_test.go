@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/db"
+)
+
+func openRoundtripDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".rekal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d, err := db.OpenData(dir)
+	if err != nil {
+		t.Fatalf("OpenData: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	if err := db.InitDataSchema(d, dataMigrateContext(dir)); err != nil {
+		t.Fatalf("InitDataSchema: %v", err)
+	}
+	return d
+}
+
+// writeRandomSession captures a random session into d the way checkpoint
+// does and returns its ID.
+func writeRandomSession(t *testing.T, d *sql.DB, r *rand.Rand, n int) string {
+	t.Helper()
+	pick := func(vals ...string) string { return vals[r.Intn(len(vals))] }
+	text := func() string {
+		return pick("", "fix the login bug", "日本語のテキスト", "line one\nline two\ttabbed", strings.Repeat("long ", 200))
+	}
+
+	id := fmt.Sprintf("01SE%022d", n)
+	actor := pick("human", "agent")
+	agentID := ""
+	if actor == "agent" || r.Intn(4) == 0 {
+		agentID = pick("", "ci-bot", "reviewer@agents")
+	}
+	parent := ""
+	if r.Intn(3) == 0 {
+		parent = fmt.Sprintf("01PA%022d", r.Intn(100))
+	}
+	capturedAt := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC).Add(time.Duration(r.Intn(86400)) * time.Second)
+
+	batch, err := db.BeginBatch(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer batch.Rollback() //nolint:errcheck
+	if err := db.InsertSession(batch, id, parent, "hash-"+id, actor, agentID,
+		pick("", "dev@example.com"), pick("", "main", "feature/auth"), capturedAt.Format(time.RFC3339),
+		pick("claude", "codex", "gemini", "opencode")); err != nil {
+		t.Fatal(err)
+	}
+
+	nTurns := r.Intn(8)
+	if r.Intn(10) == 0 {
+		nTurns = 256 + r.Intn(50)
+	}
+	ts := capturedAt.Add(-time.Hour)
+	for i := 0; i < nTurns; i++ {
+		var turnTs time.Time
+		if r.Intn(5) > 0 {
+			// Mostly forward, sometimes backwards, microsecond precision.
+			ts = ts.Add(time.Duration(r.Int63n(int64(10*time.Minute))-int64(time.Minute)) / time.Microsecond * time.Microsecond)
+			turnTs = ts
+		}
+		if err := batch.AppendTurn(fmt.Sprintf("%s-t%d", id, i), id, i, pick("human", "assistant"), text(), turnTs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < r.Intn(6); i++ {
+		tool := pick("Read", "Edit", "Bash", "WebFetch", "mcp__github__create_issue", "apply_patch", "shell")
+		if err := batch.AppendToolCall(fmt.Sprintf("%s-c%d", id, i), id, i, tool, pick("", "src/main.go", "ext:/etc/hosts"), pick("", "go test ./...")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// dumpWireRows returns every row of the tables the wire format carries, as
+// strings, excluding generated row IDs and the import markers
+// (session_hash, verified).
+func dumpWireRows(t *testing.T, d *sql.DB) map[string][]string {
+	t.Helper()
+	queries := map[string]string{
+		"sessions": `SELECT concat_ws('|', id, COALESCE(parent_session_id, '<null>'), CAST(captured_at AS VARCHAR),
+			actor_type, COALESCE(agent_id, '<null>'), COALESCE(user_email, '<null>'), COALESCE(branch, '<null>'), source)
+			FROM sessions ORDER BY id`,
+		"turns": `SELECT concat_ws('|', session_id, turn_index, role, content, COALESCE(CAST(ts AS VARCHAR), '<null>'))
+			FROM turns ORDER BY session_id, turn_index`,
+		"tool_calls": `SELECT concat_ws('|', session_id, call_order, tool, COALESCE(path, '<null>'), COALESCE(cmd_prefix, '<null>'))
+			FROM tool_calls ORDER BY session_id, call_order`,
+	}
+	out := make(map[string][]string)
+	for table, q := range queries {
+		rows, err := d.Query(q)
+		if err != nil {
+			t.Fatalf("dump %s: %v", table, err)
+		}
+		for rows.Next() {
+			var s string
+			if err := rows.Scan(&s); err != nil {
+				t.Fatalf("scan %s: %v", table, err)
+			}
+			out[table] = append(out[table], s)
+		}
+		rows.Close()
+	}
+	return out
+}
+
+// TestWireRoundtrip_Property checks that data.db → wire → data.db reproduces
+// every session, turn and tool call row for randomly generated sessions.
+func TestWireRoundtrip_Property(t *testing.T) {
+	t.Parallel()
+	enc, err := codec.NewEncoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+	dec, err := codec.NewDecoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+
+	for seed := int64(1); seed <= 5; seed++ {
+		src, dst := openRoundtripDB(t), openRoundtripDB(t)
+		r := rand.New(rand.NewSource(seed))
+		var ids []string
+		for i := 0; i < 20; i++ {
+			ids = append(ids, writeRandomSession(t, src, r, int(seed)*100+i))
+		}
+
+		// Export every session into one body, then reload dict.bin the way
+		// a teammate's clone would see it.
+		dict := codec.NewDict()
+		body := codec.NewBody()
+		for _, id := range ids {
+			sf, err := sessionFrameFromDB(src, dict, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = codec.AppendFrame(body, enc.EncodeSessionFrame(sf))
+		}
+		dict, err = codec.LoadDict(dict.Encode())
+		if err != nil {
+			t.Fatalf("LoadDict: %v", err)
+		}
+
+		frames, err := codec.ScanFrames(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		newID := func() string { n++; return fmt.Sprintf("row-%d", n) }
+		for _, fs := range frames {
+			sf, err := dec.DecodeSessionFrame(codec.ExtractFramePayload(body, fs))
+			if err != nil {
+				t.Fatalf("seed %d: decode: %v", seed, err)
+			}
+			ws, err := resolveSessionFrame(dict, sf)
+			if err != nil {
+				t.Fatalf("seed %d: resolve: %v", seed, err)
+			}
+			if err := insertWireSession(dst, ws, false, newID); err != nil {
+				t.Fatalf("seed %d: insert: %v", seed, err)
+			}
+		}
+
+		want, got := dumpWireRows(t, src), dumpWireRows(t, dst)
+		for _, table := range []string{"sessions", "turns", "tool_calls"} {
+			if !reflect.DeepEqual(want[table], got[table]) {
+				t.Errorf("seed %d: %s rows differ after round trip", seed, table)
+				for i := range want[table] {
+					if i >= len(got[table]) || want[table][i] != got[table][i] {
+						t.Errorf("  first difference at row %d:\n  want %q\n  got  %q", i, want[table][i], got[table][min(i, len(got[table])-1)])
+						break
+					}
+				}
+			}
+		}
+	}
+}
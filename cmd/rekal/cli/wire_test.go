@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rekal-dev/rekal-cli/cmd/rekal/cli/codec"
+)
+
+// buildTestWire returns a body with one session and one checkpoint frame
+// and its dict. The checkpoint lists the session plus "01JNSESS000000000000000002", which
+// has no session frame.
+func buildTestWire(t *testing.T) ([]byte, *codec.Dict) {
+	t.Helper()
+	enc, err := codec.NewEncoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+
+	dict := codec.NewDict()
+	code, toolRef := dict.EncodeTool("WebFetch")
+	sf := &codec.SessionFrame{
+		SessionRef: dict.LookupOrAdd(codec.NSSessions, "01JNSESS000000000000000001"),
+		CapturedAt: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+		EmailRef:   dict.LookupOrAdd(codec.NSEmails, "alice@example.com"),
+		ActorType:  codec.ActorHuman,
+		Turns:      []codec.TurnRecord{{Role: codec.RoleHuman, Text: "hello"}},
+		ToolCalls:  []codec.ToolCallRecord{{Tool: code, ToolRef: toolRef, PathFlag: codec.PathNull}},
+	}
+	cf := &codec.CheckpointFrame{
+		CheckpointRef: dict.LookupOrAdd(codec.NSSessions, "01JNCKPT000000000000000001"),
+		GitSHA:        strings.Repeat("b", 40),
+		BranchRef:     dict.LookupOrAdd(codec.NSBranches, "main"),
+		EmailRef:      sf.EmailRef,
+		Timestamp:     sf.CapturedAt,
+		ActorType:     codec.ActorHuman,
+		SessionRefs:   []uint64{sf.SessionRef, dict.LookupOrAdd(codec.NSSessions, "01JNSESS000000000000000002")},
+		Files:         []codec.FileTouchedRecord{{PathRef: 7, ChangeType: 'M'}}, // not in dict
+	}
+	body := codec.AppendFrame(codec.NewBody(), enc.EncodeSessionFrame(sf))
+	body = codec.AppendFrame(body, enc.EncodeCheckpointFrame(cf))
+	return body, dict
+}
+
+func TestCheckWire(t *testing.T) {
+	t.Parallel()
+	body, dict := buildTestWire(t)
+	wd, err := newWireData("rekal/alice", body, dict.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := codec.NewDecoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+
+	var got []string
+	for _, p := range checkWire(dec, wd) {
+		got = append(got, p.String())
+	}
+	want := []string{
+		"frame 1 (offset " + strconv.Itoa(wd.Scan.Frames[1].Offset) + "): path ref 7 not in dict",
+		"frame 1 (offset " + strconv.Itoa(wd.Scan.Frames[1].Offset) + "): checkpoint lists session 01JNSESS000000000000000002 with no session frame",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Without dict.bin nothing resolves.
+	wd, err = newWireData("rekal/alice", body, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(checkWire(dec, wd)); n < 5 {
+		t.Errorf("checkWire without dict found %d problems, want every ref unresolved", n)
+	}
+}
+
+func TestWireDump(t *testing.T) {
+	t.Parallel()
+	body, dict := buildTestWire(t)
+	body = append(body, 0xde, 0xad) // torn append
+	wd, err := newWireData("rekal/alice", body, dict.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := doWireDump(&buf, wd); err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := map[string]int{}
+	var session struct {
+		Session struct {
+			SessionRef uint64 `json:"session_ref"`
+			ToolCalls  []struct {
+				ToolRef *uint64 `json:"tool_ref"`
+			} `json:"tool_calls"`
+		} `json:"session"`
+	}
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var rec struct {
+			Kind string `json:"kind"`
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		kinds[rec.Kind]++
+		if rec.Type == "session" {
+			if err := json.Unmarshal(sc.Bytes(), &session); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	want := map[string]int{"header": 1, "dict": dict.TotalEntries(), "frame": 2, "skipped": 1}
+	for k, n := range want {
+		if kinds[k] != n {
+			t.Errorf("%d %q lines, want %d", kinds[k], k, n)
+		}
+	}
+	if len(session.Session.ToolCalls) != 1 || session.Session.ToolCalls[0].ToolRef == nil || *session.Session.ToolCalls[0].ToolRef != 0 {
+		t.Errorf("session tool calls = %+v, want one with tool_ref 0", session.Session.ToolCalls)
+	}
+}
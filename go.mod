@@ -9,6 +9,7 @@ require (
 	github.com/spf13/cobra v1.8.0
 	golang.org/x/mod v0.33.0
 	gonum.org/v1/gonum v0.17.0
+	modernc.org/sqlite v1.46.1
 )
 
 require (
@@ -35,5 +36,4 @@ require (
 	modernc.org/libc v1.67.6 // indirect
 	modernc.org/mathutil v1.7.1 // indirect
 	modernc.org/memory v1.11.0 // indirect
-	modernc.org/sqlite v1.46.1 // indirect
 )